@@ -0,0 +1,157 @@
+// Package changeset records the pull requests klcm opened together for one
+// layout change across several upstream repositories, so they can be
+// followed and merged as a set.
+package changeset
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/history"
+)
+
+// DefaultPath is the change set record of the workspace in the current
+// directory
+const DefaultPath = ".klcm/changesets.json"
+
+// version is the file format version
+const version = 1
+
+// PullRequest is one member of a change set
+type PullRequest struct {
+	Keyboard string `json:"keyboard"`
+	Forge    string `json:"forge,omitempty"`     // "github" when empty
+	ForgeURL string `json:"forge_url,omitempty"` // web root of the forge
+	Repo     string `json:"repo"`                // "owner/name"
+	Number   int    `json:"number"`
+	URL      string `json:"url"`
+	Branch   string `json:"branch"`
+}
+
+// Set is the pull requests opened for one change
+type Set struct {
+	ID           string        `json:"id"`
+	Title        string        `json:"title,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+	PullRequests []PullRequest `json:"pull_requests"`
+	// Failed lists keyboards without a pull request in the set because it
+	// could not be opened; the set is incomplete until a later run adds them
+	Failed []string `json:"failed,omitempty"`
+}
+
+// Add puts pr in the set, replacing an earlier entry for the same pull
+// request, and takes its keyboard off the failed list
+func (s *Set) Add(pr PullRequest) {
+	for i, keyboard := range s.Failed {
+		if keyboard == pr.Keyboard {
+			s.Failed = append(s.Failed[:i:i], s.Failed[i+1:]...)
+			break
+		}
+	}
+	for i, member := range s.PullRequests {
+		if member.Forge == pr.Forge && member.ForgeURL == pr.ForgeURL && member.Repo == pr.Repo && member.Number == pr.Number {
+			s.PullRequests[i] = pr
+			return
+		}
+	}
+	s.PullRequests = append(s.PullRequests, pr)
+}
+
+// Member returns the pull request of keyboard in the set
+func (s *Set) Member(keyboard string) (PullRequest, bool) {
+	for _, member := range s.PullRequests {
+		if member.Keyboard == keyboard {
+			return member, true
+		}
+	}
+	return PullRequest{}, false
+}
+
+// Fail records that the pull request of keyboard could not be opened. A
+// keyboard that already has a pull request in the set is not failed: a
+// later run that cannot update it leaves the set as it was.
+func (s *Set) Fail(keyboard string) {
+	if _, ok := s.Member(keyboard); ok {
+		return
+	}
+	for _, failed := range s.Failed {
+		if failed == keyboard {
+			return
+		}
+	}
+	s.Failed = append(s.Failed, keyboard)
+}
+
+// File is the content of the change set record
+type File struct {
+	Version int    `json:"version"`
+	Sets    []*Set `json:"sets"`
+}
+
+// NewID returns the ID of a change set created at t, e.g. "cs-20261019-150405"
+func NewID(t time.Time) string {
+	return "cs-" + t.Format("20060102-150405")
+}
+
+// Load reads a change set record; a missing file has no sets
+func Load(path string) (*File, error) {
+	file := &File{Version: version}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(content, file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if file.Version > version {
+		return nil, fmt.Errorf("%s has format version %d; this klcm understands up to %d", path, file.Version, version)
+	}
+	return file, nil
+}
+
+// Save writes the record atomically
+func (f *File) Save(path string) error {
+	f.Version = version
+	content, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return history.WriteFileAtomic(path, append(content, '\n'), 0644)
+}
+
+// Find returns the set with an ID, or the only set whose ID starts with it
+func (f *File) Find(id string) (*Set, error) {
+	var matches []*Set
+	for _, set := range f.Sets {
+		if set.ID == id {
+			return set, nil
+		}
+		if strings.HasPrefix(set.ID, id) {
+			matches = append(matches, set)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no change set %q", id)
+	case 1:
+		return matches[0], nil
+	}
+	return nil, fmt.Errorf("change set %q is ambiguous: it matches %d sets", id, len(matches))
+}
+
+// Put adds a set, replacing a recorded set with the same ID
+func (f *File) Put(set *Set) {
+	for i, existing := range f.Sets {
+		if existing.ID == set.ID {
+			f.Sets[i] = set
+			return
+		}
+	}
+	f.Sets = append(f.Sets, set)
+}
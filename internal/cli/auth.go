@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+	"masters3d.com/keyboard_layout_config_mapper/internal/gitea"
+	"masters3d.com/keyboard_layout_config_mapper/internal/github"
+	"masters3d.com/keyboard_layout_config_mapper/internal/gitlab"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+	"masters3d.com/keyboard_layout_config_mapper/internal/remote"
+)
+
+var (
+	authOnce sync.Once
+	auth     *remote.Auth
+)
+
+// githubAuth returns the credentials for GitHub requests, configured by
+//
+//	github:
+//	  token: ghp_...            # GITHUB_TOKEN or GH_TOKEN take precedence
+//	  credential_helper: false  # default true: ask `git credential fill`
+func githubAuth() *remote.Auth {
+	authOnce.Do(func() {
+		viper.SetDefault("github.credential_helper", true)
+		auth = &remote.Auth{
+			ConfigToken:      viper.GetString("github.token"),
+			CredentialHelper: viper.GetBool("github.credential_helper"),
+		}
+		remote.AddSecret(auth.ConfigToken)
+	})
+	return auth
+}
+
+// fetchError describes a failed fetch, naming the keyboard and repository
+// when access was denied
+func fetchError(kb registry.Keyboard, source remote.Source, err error) error {
+	var authErr *remote.AuthError
+	if errors.As(err, &authErr) {
+		return fmt.Errorf("cannot access %s for keyboard %s: %w", accessedRepo(authErr.URL, kb), kb.Name, err)
+	}
+	return fmt.Errorf("failed to fetch remote content for %s from %s: %w", kb.Name, source, err)
+}
+
+// accessedRepo names the repository a URL reads from: owner/repo for
+// raw.githubusercontent.com and github.com URLs, the URL itself otherwise
+func accessedRepo(rawURL string, kb registry.Keyboard) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return kb.RepoURL()
+	}
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	switch {
+	case u.Hostname() == "api.github.com" && len(parts) >= 3 && parts[0] == "repos":
+		return parts[1] + "/" + parts[2]
+	case remote.IsGitHubHost(u.Hostname()) && len(parts) >= 2:
+		return parts[0] + "/" + parts[1]
+	}
+	return rawURL
+}
+
+// forgeNames are the display names of the supported forges
+var forgeNames = map[string]string{
+	registry.ForgeGitHub: "GitHub",
+	registry.ForgeGitLab: "GitLab",
+	registry.ForgeGitea:  "Gitea",
+}
+
+// forgeToken returns the token for a forge and where it came from. GitHub
+// uses githubAuth; GitLab and Gitea tokens are configured by
+//
+//	gitlab:
+//	  token: glpat-...  # GITLAB_TOKEN takes precedence
+//	gitea:
+//	  token: ...        # GITEA_TOKEN takes precedence
+func forgeToken(kind string) (token, origin string) {
+	if kind == registry.ForgeGitHub {
+		return githubAuth().Token()
+	}
+	env := strings.ToUpper(kind) + "_TOKEN"
+	if token := strings.TrimSpace(os.Getenv(env)); token != "" {
+		remote.AddSecret(token)
+		return token, env
+	}
+	if token := strings.TrimSpace(viper.GetString(kind + ".token")); token != "" {
+		remote.AddSecret(token)
+		return token, kind + ".token in the config file"
+	}
+	return "", ""
+}
+
+// newForge returns an API client for the forge of the given kind whose web
+// UI is at webURL, authenticated with its token; pull request automation
+// cannot work anonymously
+func newForge(kind, webURL string) (forge.Forge, error) {
+	if kind == "" {
+		kind = registry.ForgeGitHub
+	}
+	webURL = strings.TrimSuffix(webURL, "/")
+	token, _ := forgeToken(kind)
+	if token == "" && kind == registry.ForgeGitHub {
+		return nil, fmt.Errorf("GitHub authentication required: set GITHUB_TOKEN, github.token in .klcm.yaml, or a git credential helper for github.com")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("%s authentication required: set %s_TOKEN or %s.token in .klcm.yaml", forgeNames[kind], strings.ToUpper(kind), kind)
+	}
+
+	switch kind {
+	case registry.ForgeGitHub:
+		if webURL == "" || webURL == "https://github.com" {
+			return github.NewClient(remote.GitHubAPIURL(), token, remote.DefaultClient), nil
+		}
+		// GitHub Enterprise Server
+		return github.NewClient(webURL+"/api/v3", token, remote.DefaultClient), nil
+	case registry.ForgeGitLab:
+		if webURL == "" {
+			return gitlab.NewClient("", token, remote.DefaultClient), nil
+		}
+		return gitlab.NewClient(webURL+"/api/v4", token, remote.DefaultClient), nil
+	case registry.ForgeGitea:
+		if webURL == "" {
+			return nil, fmt.Errorf("Gitea needs forge_url, the web root of the instance")
+		}
+		return gitea.NewClient(webURL+"/api/v1", token, remote.DefaultClient), nil
+	}
+	return nil, fmt.Errorf("unsupported forge %q", kind)
+}
+
+// forges builds one API client per forge and caches it
+type forges map[string]forge.Forge
+
+// get returns the client for the forge of the given kind at webURL
+func (f forges) get(kind, webURL string) (forge.Forge, error) {
+	key := kind + " " + webURL
+	if client, ok := f[key]; ok {
+		return client, nil
+	}
+	client, err := newForge(kind, webURL)
+	if err != nil {
+		return nil, err
+	}
+	f[key] = client
+	return client, nil
+}
+
+// forRepo returns the client for the forge hosting a keyboard's upstream
+// repository
+func (f forges) forRepo(repo RepoConfig) (forge.Forge, error) {
+	return f.get(repo.Forge, repo.ForgeURL)
+}
+
+// forgeError explains an API error about subject (usually owner/repo),
+// naming where a rejected token came from
+func forgeError(kind, subject string, err error) error {
+	if forge.IsUnauthorized(err) {
+		if kind == "" {
+			kind = registry.ForgeGitHub
+		}
+		_, origin := forgeToken(kind)
+		return fmt.Errorf("%s: the token from %s was rejected or lacks access: %w", subject, origin, err)
+	}
+	return fmt.Errorf("%s: %w", subject, err)
+}
+
+// redactingWriter hides secrets in everything written through it
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, remote.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// redactExitError hides secrets in the error Execute returns to main
+func redactExitError(err error) error {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		exitErr.Err = remote.RedactError(exitErr.Err)
+		return err
+	}
+	return remote.RedactError(err)
+}
@@ -2,14 +2,17 @@ package cli
 
 import (
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+)
+
+var (
+	compareCheck    bool
+	compareSemantic bool
 )
 
 var compareRemoteCmd = &cobra.Command{
@@ -19,48 +22,85 @@ var compareRemoteCmd = &cobra.Command{
 from their respective GitHub repositories. This helps you understand what 
 changes you would lose before downloading/overriding your local files.
 
-Supported keyboards:
-  glove80   - Glove80 ZMK keymap
-  adv360    - Advantage360 ZMK keymap
-  adv_mod   - Kinesis Advantage (Pillz Mod) ZMK keymap
+Keyboards come from the registry; see 'klcm keyboards list'.
 
 Examples:
   klcm compare-remote                    # Compare all configurations
   klcm compare-remote adv360 glove80     # Compare specific keyboards
-  klcm compare-remote --show-unchanged   # Include files with no differences`,
+  klcm compare-remote --show-unchanged   # Include files with no differences
+  klcm compare-remote --diff-format html --diff-output report.html
+  klcm compare-remote --check --semantic # Nightly drift check, exit 2 on drift
+
+--check prints one line per keyboard instead of the diff: the layers, keys
+and behaviors upstream changes would bring in. --semantic compares parsed
+keymaps, so formatting-only differences (whitespace, comments, realigned
+grids, such as the MoErgo editor produces) do not count as drift. Like every
+command, compare-remote exits with status 2 when anything drifted.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		showUnchanged, _ := cmd.Flags().GetBool("show-unchanged")
 		
+		format, err := selectedDiffFormat()
+		if err != nil {
+			return err
+		}
+		if compareCheck {
+			if format != DiffFormatText {
+				return fmt.Errorf("--check prints its own summary and cannot be combined with --diff-format %s", format)
+			}
+			return runDriftCheck(cmd, args)
+		}
+		if format != DiffFormatText {
+			files, err := collectKeyboardDiffs(args)
+			if err != nil {
+				return err
+			}
+			if compareSemantic {
+				files = withoutFormattingOnly(files)
+			}
+			return writeDiffReport(format, files)
+		}
+		
 		if len(args) == 0 {
 			// Compare all if no specific keyboards specified
-			return compareAllRemote(showUnchanged)
+			return compareAllRemote(cmd, showUnchanged)
 		}
 		
 		// Compare specific keyboards
+		prefetchKeyboards(args)
+		errs := make(keyboardErrors)
 		for _, keyboard := range args {
 			_, err := compareKeyboardRemote(keyboard, showUnchanged)
 			if err != nil {
-				return fmt.Errorf("failed to compare %s: %w", keyboard, err)
+				fmt.Printf("  ❌ %v\n", err)
+				errs[keyboard] = err
 			}
 		}
 		
-		return nil
+		return errs.summary(cmd, len(args))
 	},
 }
 
-func compareAllRemote(showUnchanged bool) error {
+func compareAllRemote(cmd *cobra.Command, showUnchanged bool) error {
 	fmt.Println("🔍 Comparing local configurations with remote versions...")
 	
+	names := registry.Current().Names()
+	prefetchKeyboards(names)
 	hasChanges := false
-	for _, kb := range keyboards {
-		changed, err := compareKeyboardRemote(kb.name, showUnchanged)
+	errs := make(keyboardErrors)
+	for _, name := range names {
+		changed, err := compareKeyboardRemote(name, showUnchanged)
 		if err != nil {
-			return err
+			fmt.Printf("  ❌ %v\n", err)
+			errs[name] = err
+			continue
 		}
 		if changed {
 			hasChanges = true
 		}
 	}
+	if len(errs) > 0 {
+		return errs.summary(cmd, len(names))
+	}
 	
 	if !hasChanges {
 		fmt.Println("\n✅ All local files are up to date with remote versions!")
@@ -72,61 +112,41 @@ func compareAllRemote(showUnchanged bool) error {
 }
 
 func compareKeyboardRemote(name string, showUnchanged bool) (bool, error) {
-	var kb *keyboardConfig
-	for _, k := range keyboards {
-		if k.name == name {
-			kb = &k
-			break
-		}
-	}
-	
-	if kb == nil {
-		return false, fmt.Errorf("unknown keyboard: %s", name)
+	kb, err := registry.Current().Lookup(name)
+	if err != nil {
+		return false, err
 	}
 	
 	return compareFileRemote(kb, showUnchanged)
 }
 
-func compareFileRemote(kb *keyboardConfig, showUnchanged bool) (bool, error) {
-	filePath := filepath.Join(kb.dir, kb.filename)
+func compareFileRemote(kb registry.Keyboard, showUnchanged bool) (bool, error) {
+	filePath := filepath.FromSlash(kb.LocalPath)
 	
 	// Check if local file exists
 	localContent, err := os.ReadFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			fmt.Printf("\n📁 %s/%s\n", kb.dir, kb.filename)
+			fmt.Printf("\n📁 %s/%s\n", kb.Dir(), kb.Filename())
 			fmt.Printf("  🆕 Local file does not exist - would be created by download\n")
+			reportDrift(kb.Name, kb.LocalPath, "local file does not exist")
 			return true, nil
 		}
 		return false, fmt.Errorf("failed to read local file %s: %w", filePath, err)
 	}
 	
 	// Fetch remote content
-	fmt.Printf("\n📁 %s/%s\n", kb.dir, kb.filename)
+	fmt.Printf("\n📁 %s/%s\n", kb.Dir(), kb.Filename())
 	fmt.Printf("  📡 Fetching remote version...\n")
 	
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	
-	resp, err := client.Get(kb.url)
+	remoteContent, err := fetchKeyboardRemote(kb.Name)
 	if err != nil {
-		return false, fmt.Errorf("failed to fetch remote content from %s: %w", kb.url, err)
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("failed to fetch remote content: HTTP %d", resp.StatusCode)
-	}
-	
-	remoteContent, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return false, fmt.Errorf("failed to read remote content: %w", err)
+		return false, err
 	}
 	
 	// Compare content
 	localStr := strings.TrimSpace(string(localContent))
-	remoteStr := strings.TrimSpace(string(remoteContent))
+	remoteStr := strings.TrimSpace(remoteContent)
 	
 	if localStr == remoteStr {
 		if showUnchanged {
@@ -134,6 +154,13 @@ func compareFileRemote(kb *keyboardConfig, showUnchanged bool) (bool, error) {
 		}
 		return false, nil
 	}
+	if compareSemantic {
+		fd := newFileDiff(kb.Name, kb.LocalPath, "local", "remote", localStr, remoteStr)
+		if fd.Semantic != nil && fd.Semantic.IsEmpty() {
+			fmt.Printf("  🎨 Only formatting differs (ignored with --semantic)\n")
+			return false, nil
+		}
+	}
 	
 	// Show differences
 	fmt.Printf("  ⚠️  Differences found:\n")
@@ -143,6 +170,7 @@ func compareFileRemote(kb *keyboardConfig, showUnchanged bool) (bool, error) {
 	
 	// Simple diff algorithm - show a few key differences
 	showDiff(localLines, remoteLines)
+	reportDrift(kb.Name, kb.LocalPath, "differs from remote: "+SimpleDiffSummary(localLines, remoteLines))
 	
 	return true, nil
 }
@@ -188,4 +216,7 @@ func truncateLine(line string) string {
 func init() {
 	rootCmd.AddCommand(compareRemoteCmd)
 	compareRemoteCmd.Flags().BoolP("show-unchanged", "u", false, "Show files with no differences")
+	compareRemoteCmd.Flags().BoolVar(&compareCheck, "check", false, "Print a one-line drift summary per keyboard instead of diffs")
+	compareRemoteCmd.Flags().BoolVar(&compareSemantic, "semantic", false, "Ignore formatting-only differences")
+	addDiffFormatFlags(compareRemoteCmd)
 }
\ No newline at end of file
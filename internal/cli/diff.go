@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/models"
+	"masters3d.com/keyboard_layout_config_mapper/internal/parsers"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <a> <b>",
+	Short: "Show semantic differences between two keymaps",
+	Long: `Compare two keymaps after parsing them, instead of comparing their text.
+
+Each side can be:
+  - a keymap file path          (configs/zmk_adv360/adv360.keymap)
+  - a registered keyboard name  (adv360, glove80, ...; see klcm keyboards list)
+  - either of the above at a git revision, written as <name-or-path>@<rev>
+
+The report lists added/removed layers, per-key binding changes by logical key
+and layer name, and behavior, combo and macro changes. Formatting-only changes
+(whitespace, comments, realigned grids) are ignored.`,
+	Example: `  # Compare two keyboards
+  klcm diff adv360 glove80
+
+  # Compare a keyboard with its previous commit
+  klcm diff adv360@HEAD~1 adv360
+
+  # Compare two files
+  klcm diff old.keymap configs/zmk_adv360/adv360.keymap`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDiff,
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	from, err := resolveKeymapSpec(args[0])
+	if err != nil {
+		return err
+	}
+	to, err := resolveKeymapSpec(args[1])
+	if err != nil {
+		return err
+	}
+
+	// A file outside configs/ takes the keyboard type of the other side
+	if from.keyboardType == "" {
+		from.keyboardType = to.keyboardType
+	}
+	if to.keyboardType == "" {
+		to.keyboardType = from.keyboardType
+	}
+
+	fromLayout, err := from.parse()
+	if err != nil {
+		return err
+	}
+	toLayout, err := to.parse()
+	if err != nil {
+		return err
+	}
+
+	diff := parsers.DiffLayouts(fromLayout, toLayout)
+	printLayoutDiff(diff)
+	return nil
+}
+
+// keymapSpec is a keymap named on the command line, resolved to its content
+type keymapSpec struct {
+	label        string
+	keyboardType models.KeyboardType
+	content      []byte
+}
+
+func (s keymapSpec) parse() (*models.KeyboardLayout, error) {
+	return parsers.NewZMKParser(s.keyboardType).ParseContent(s.label, s.content)
+}
+
+// resolveKeymapSpec reads the keymap named by a diff argument (see diffCmd)
+func resolveKeymapSpec(spec string) (keymapSpec, error) {
+	target, rev := spec, ""
+	if idx := strings.LastIndex(spec, "@"); idx > 0 {
+		target, rev = spec[:idx], spec[idx+1:]
+	}
+
+	path := target
+	keyboardType := models.KeyboardType(target)
+	if configPath, err := parsers.GetConfigPath(keyboardType); err == nil {
+		path = configPath
+	} else {
+		keyboardType = inferKeyboardType(target)
+	}
+
+	resolved := keymapSpec{label: path, keyboardType: keyboardType}
+	var err error
+	if rev != "" {
+		resolved.content, err = gitShowFile(rev, path)
+		resolved.label = fmt.Sprintf("%s@%s", path, rev)
+	} else {
+		resolved.content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return keymapSpec{}, fmt.Errorf("failed to read %s: %w", spec, err)
+	}
+
+	return resolved, nil
+}
+
+// inferKeyboardType guesses the keyboard type of a keymap file from its path
+func inferKeyboardType(path string) models.KeyboardType {
+	if kb, ok := registry.Current().ByPath(path); ok {
+		return models.KeyboardType(kb.Name)
+	}
+	return ""
+}
+
+// gitShowFile returns the content of a repository file at a git revision
+func gitShowFile(rev, path string) ([]byte, error) {
+	cmd := exec.Command("git", "show", fmt.Sprintf("%s:%s", rev, filepath.ToSlash(path)))
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git show: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+	return output, nil
+}
+
+func printLayoutDiff(diff *parsers.LayoutDiff) {
+	fmt.Printf("🔍 %s → %s\n", diff.From, diff.To)
+
+	if diff.IsEmpty() {
+		fmt.Println("✅ No semantic differences")
+		return
+	}
+
+	for _, layer := range diff.AddedLayers {
+		fmt.Printf("  🆕 Layer added: %s\n", layer)
+	}
+	for _, layer := range diff.RemovedLayers {
+		fmt.Printf("  🗑️  Layer removed: %s\n", layer)
+	}
+
+	if len(diff.KeyChanges) > 0 {
+		fmt.Println("\n⌨️  Key changes:")
+		currentLayer := ""
+		for _, change := range diff.KeyChanges {
+			if change.Layer != currentLayer {
+				currentLayer = change.Layer
+				fmt.Printf("  📋 %s\n", currentLayer)
+			}
+			fmt.Printf("    %-8s %s → %s\n", change.Key, describeBinding(change.Before), describeBinding(change.After))
+		}
+	}
+
+	printDefinitionChanges("⚙️  Behavior changes:", diff.BehaviorChanges)
+	printDefinitionChanges("🎹 Combo changes:", diff.ComboChanges)
+	printDefinitionChanges("📜 Macro changes:", diff.MacroChanges)
+
+	fmt.Printf("\n📈 Summary: %s\n", diff.Summary())
+}
+
+func printDefinitionChanges(title string, changes []parsers.DefinitionChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%s\n", title)
+	for _, change := range changes {
+		switch change.Kind {
+		case parsers.ChangeAdded:
+			fmt.Printf("  🆕 %s added\n", change.Name)
+		case parsers.ChangeRemoved:
+			fmt.Printf("  🗑️  %s removed\n", change.Name)
+		default:
+			fmt.Printf("  ✏️  %s modified\n", change.Name)
+			for _, prop := range change.Properties {
+				fmt.Printf("      %s: %s → %s\n", prop.Property, describeBinding(prop.Before), describeBinding(prop.After))
+			}
+		}
+	}
+}
+
+func describeBinding(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
@@ -0,0 +1,548 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/models"
+	"masters3d.com/keyboard_layout_config_mapper/internal/parsers"
+)
+
+// DiffFormat selects how preview diffs are rendered
+type DiffFormat string
+
+const (
+	DiffFormatText  DiffFormat = "text"  // colored terminal output (default)
+	DiffFormatPatch DiffFormat = "patch" // unified diff applicable with git apply
+	DiffFormatJSON  DiffFormat = "json"  // hunks and semantic key changes
+	DiffFormatHTML  DiffFormat = "html"  // self-contained side-by-side report
+)
+
+var (
+	diffFormat string
+	diffOutput string
+)
+
+// addDiffFormatFlags registers --diff-format and --diff-output on a command
+func addDiffFormatFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&diffFormat, "diff-format", string(DiffFormatText), "diff output format for previews (text, patch, json, html)")
+	cmd.Flags().StringVar(&diffOutput, "diff-output", "", "write the diff report to a file instead of stdout (with --output json it is part of the result)")
+}
+
+// selectedDiffFormat validates the --diff-format flag
+func selectedDiffFormat() (DiffFormat, error) {
+	switch format := DiffFormat(strings.ToLower(diffFormat)); format {
+	case DiffFormatText, DiffFormatPatch, DiffFormatJSON, DiffFormatHTML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown diff format %q (expected text, patch, json or html)", diffFormat)
+	}
+}
+
+// FileDiff is the change to a single keymap file in a diff report
+type FileDiff struct {
+	Keyboard   string
+	Path       string // repository-relative path of the file being changed
+	OldLabel   string // e.g. "local"
+	NewLabel   string // e.g. "remote"
+	OldContent string
+	NewContent string
+	OldMissing bool // the file does not exist yet on the old side
+	Semantic   *parsers.LayoutDiff
+}
+
+// newFileDiff builds a FileDiff and, when both sides parse, its semantic diff
+func newFileDiff(keyboard, path, oldLabel, newLabel, oldContent, newContent string) FileDiff {
+	fd := FileDiff{
+		Keyboard:   keyboard,
+		Path:       path,
+		OldLabel:   oldLabel,
+		NewLabel:   newLabel,
+		OldContent: oldContent,
+		NewContent: newContent,
+	}
+
+	parser := parsers.NewZMKParser(models.KeyboardType(keyboard))
+	oldLayout, oldErr := parser.ParseContent(oldLabel, []byte(oldContent))
+	newLayout, newErr := parser.ParseContent(newLabel, []byte(newContent))
+	if oldErr == nil && newErr == nil {
+		fd.Semantic = parsers.DiffLayouts(oldLayout, newLayout)
+	}
+
+	return fd
+}
+
+// writeDiffReport renders the report in the selected format to --diff-output
+// or stdout, and records every changed file as drift. With --output json and
+// no --diff-output, the report becomes the data of the JSON result instead.
+func writeDiffReport(format DiffFormat, files []FileDiff) error {
+	for _, fd := range files {
+		if fd.OldContent != fd.NewContent || fd.OldMissing {
+			reportDrift(fd.Keyboard, fd.Path, fmt.Sprintf("%s differs from %s", fd.OldLabel, fd.NewLabel))
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	var report bytes.Buffer
+	switch {
+	case diffOutput != "":
+		file, err := os.Create(diffOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", diffOutput, err)
+		}
+		defer file.Close()
+		w = file
+	case jsonOut != nil:
+		// os.Stdout is stderr while --output json is in effect
+		w = &report
+	}
+
+	var err error
+	switch format {
+	case DiffFormatPatch:
+		_, err = io.WriteString(w, renderPatch(files))
+	case DiffFormatJSON:
+		err = renderJSONReport(w, files)
+	case DiffFormatHTML:
+		_, err = io.WriteString(w, renderHTMLReport(files))
+	default:
+		return fmt.Errorf("diff format %s cannot be written as a report", format)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write diff report: %w", err)
+	}
+
+	switch {
+	case diffOutput != "":
+		fmt.Fprintf(os.Stderr, "📝 Wrote %s diff report to %s\n", format, diffOutput)
+	case jsonOut != nil && format == DiffFormatJSON:
+		reportData(json.RawMessage(report.Bytes()))
+	case jsonOut != nil:
+		reportData(map[string]string{"format": string(format), "report": report.String()})
+	}
+	return nil
+}
+
+// splitFileLines splits content into lines without their terminators and
+// reports whether the last line ended with a newline
+func splitFileLines(content string) ([]string, bool) {
+	if content == "" {
+		return nil, true
+	}
+	hasNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	return lines, hasNewline
+}
+
+// exactDiffChunks computes hunks from a longest-common-subsequence line diff.
+// Unlike generateDiffChunks it never treats whitespace-only changes as equal,
+// so the result can be applied as a patch.
+func exactDiffChunks(oldLines, newLines []string, contextLines int) []DiffChunk {
+	// Trim the common prefix and suffix to keep the LCS table small
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+	a := oldLines[prefix : len(oldLines)-suffix]
+	b := newLines[prefix : len(newLines)-suffix]
+
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Full edit script over both files, with 1-based line numbers
+	var script []DiffLine
+	for i := 0; i < prefix; i++ {
+		script = append(script, DiffLine{Type: LineContext, Content: oldLines[i], LocalNo: i + 1, RemoteNo: i + 1})
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			script = append(script, DiffLine{Type: LineContext, Content: a[i], LocalNo: prefix + i + 1, RemoteNo: prefix + j + 1})
+			i++
+			j++
+		case j >= len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			script = append(script, DiffLine{Type: LineRemoved, Content: a[i], LocalNo: prefix + i + 1})
+			i++
+		default:
+			script = append(script, DiffLine{Type: LineAdded, Content: b[j], RemoteNo: prefix + j + 1})
+			j++
+		}
+	}
+	for k := 0; k < suffix; k++ {
+		oldNo := len(oldLines) - suffix + k
+		newNo := len(newLines) - suffix + k
+		script = append(script, DiffLine{Type: LineContext, Content: oldLines[oldNo], LocalNo: oldNo + 1, RemoteNo: newNo + 1})
+	}
+
+	// Group changes that are within 2*context lines of each other into hunks
+	var chunks []DiffChunk
+	for k := 0; k < len(script); {
+		if script[k].Type == LineContext {
+			k++
+			continue
+		}
+
+		start := max(0, k-contextLines)
+		end := k
+		for end < len(script) {
+			if script[end].Type != LineContext {
+				end++
+				continue
+			}
+			run := end
+			for run < len(script) && script[run].Type == LineContext {
+				run++
+			}
+			if run == len(script) || run-end > 2*contextLines {
+				end = min(len(script), end+contextLines)
+				break
+			}
+			end = run
+		}
+
+		chunks = append(chunks, newExactChunk(script[start:end], script, start))
+		k = end
+	}
+
+	return chunks
+}
+
+func newExactChunk(lines, script []DiffLine, start int) DiffChunk {
+	chunk := DiffChunk{Lines: append([]DiffLine(nil), lines...)}
+	for _, line := range lines {
+		if line.Type != LineAdded {
+			chunk.LocalCount++
+			if chunk.LocalStart == 0 {
+				chunk.LocalStart = line.LocalNo
+			}
+		}
+		if line.Type != LineRemoved {
+			chunk.RemoteCount++
+			if chunk.RemoteStart == 0 {
+				chunk.RemoteStart = line.RemoteNo
+			}
+		}
+	}
+
+	// An empty side starts after the line preceding the hunk (0 at the top of the file)
+	if chunk.LocalCount == 0 || chunk.RemoteCount == 0 {
+		prevLocal, prevRemote := 0, 0
+		for _, line := range script[:start] {
+			if line.LocalNo > 0 {
+				prevLocal = line.LocalNo
+			}
+			if line.RemoteNo > 0 {
+				prevRemote = line.RemoteNo
+			}
+		}
+		if chunk.LocalCount == 0 {
+			chunk.LocalStart = prevLocal
+		}
+		if chunk.RemoteCount == 0 {
+			chunk.RemoteStart = prevRemote
+		}
+	}
+
+	return chunk
+}
+
+// fileChunks returns the exact hunks for a file diff with intra-line annotations
+func (fd FileDiff) fileChunks(contextLines int) ([]DiffChunk, bool, bool) {
+	oldLines, oldNewline := splitFileLines(fd.OldContent)
+	newLines, newNewline := splitFileLines(fd.NewContent)
+	chunks := exactDiffChunks(oldLines, newLines, contextLines)
+	annotateIntraLine(chunks)
+	return chunks, oldNewline, newNewline
+}
+
+// noNewlineMark is appended while diffing to a last line that has no
+// newline, so that a change of only the final newline still shows up as a
+// removed and an added line
+const noNewlineMark = "\x00no newline"
+
+// patchLines splits content for a patch, marking a last line without newline
+func patchLines(content string) []string {
+	lines, hasNewline := splitFileLines(content)
+	if !hasNewline {
+		lines[len(lines)-1] += noNewlineMark
+	}
+	return lines
+}
+
+// renderPatch renders a git-style patch that transforms the old side of each
+// file into the new side, suitable for git apply
+func renderPatch(files []FileDiff) string {
+	var result strings.Builder
+
+	for _, fd := range files {
+		if fd.OldContent == fd.NewContent && !fd.OldMissing {
+			continue
+		}
+
+		chunks := exactDiffChunks(patchLines(fd.OldContent), patchLines(fd.NewContent), 3)
+
+		result.WriteString(fmt.Sprintf("diff --git a/%s b/%s\n", fd.Path, fd.Path))
+		if fd.OldMissing {
+			result.WriteString("new file mode 100644\n")
+			result.WriteString("--- /dev/null\n")
+		} else {
+			result.WriteString(fmt.Sprintf("--- a/%s\n", fd.Path))
+		}
+		result.WriteString(fmt.Sprintf("+++ b/%s\n", fd.Path))
+
+		for _, chunk := range chunks {
+			result.WriteString(fmt.Sprintf("@@ -%s +%s @@\n",
+				hunkRange(chunk.LocalStart, chunk.LocalCount),
+				hunkRange(chunk.RemoteStart, chunk.RemoteCount)))
+
+			for _, line := range chunk.Lines {
+				prefix := " "
+				switch line.Type {
+				case LineRemoved:
+					prefix = "-"
+				case LineAdded:
+					prefix = "+"
+				}
+				// The marker follows the last line of whichever side lacks
+				// a final newline (both, for a shared context line)
+				content, lastLine := strings.CutSuffix(line.Content, noNewlineMark)
+				result.WriteString(prefix + content + "\n")
+				if lastLine {
+					result.WriteString("\\ No newline at end of file\n")
+				}
+			}
+		}
+	}
+
+	return result.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// jsonDiffReport is the document written by --diff-format json
+type jsonDiffReport struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Files       []jsonFileDiff `json:"files"`
+}
+
+type jsonFileDiff struct {
+	Keyboard string              `json:"keyboard"`
+	Path     string              `json:"path"`
+	Old      string              `json:"old"`
+	New      string              `json:"new"`
+	Created  bool                `json:"created,omitempty"`
+	Hunks    []jsonHunk          `json:"hunks"`
+	Semantic *parsers.LayoutDiff `json:"semantic,omitempty"`
+}
+
+type jsonHunk struct {
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []jsonLine `json:"lines"`
+}
+
+type jsonLine struct {
+	Type    string   `json:"type"` // "context", "removed" or "added"
+	Content string   `json:"content"`
+	OldLine int      `json:"old_line,omitempty"`
+	NewLine int      `json:"new_line,omitempty"`
+	Changed []string `json:"changed_bindings,omitempty"`
+}
+
+func renderJSONReport(w io.Writer, files []FileDiff) error {
+	report := jsonDiffReport{GeneratedAt: time.Now().UTC(), Files: []jsonFileDiff{}}
+
+	for _, fd := range files {
+		chunks, _, _ := fd.fileChunks(3)
+		jf := jsonFileDiff{
+			Keyboard: fd.Keyboard,
+			Path:     fd.Path,
+			Old:      fd.OldLabel,
+			New:      fd.NewLabel,
+			Created:  fd.OldMissing,
+			Hunks:    []jsonHunk{},
+			Semantic: fd.Semantic,
+		}
+		for _, chunk := range chunks {
+			hunk := jsonHunk{
+				OldStart: chunk.LocalStart,
+				OldLines: chunk.LocalCount,
+				NewStart: chunk.RemoteStart,
+				NewLines: chunk.RemoteCount,
+			}
+			for _, line := range chunk.Lines {
+				jl := jsonLine{Type: lineTypeName(line.Type), Content: line.Content, OldLine: line.LocalNo, NewLine: line.RemoteNo}
+				for _, segment := range line.Segments {
+					if segment.Changed {
+						jl.Changed = append(jl.Changed, strings.TrimSpace(segment.Text))
+					}
+				}
+				hunk.Lines = append(hunk.Lines, jl)
+			}
+			jf.Hunks = append(jf.Hunks, hunk)
+		}
+		report.Files = append(report.Files, jf)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(report)
+}
+
+func lineTypeName(lineType LineType) string {
+	switch lineType {
+	case LineAdded:
+		return "added"
+	case LineRemoved:
+		return "removed"
+	default:
+		return "context"
+	}
+}
+
+const htmlReportStyle = `body{font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;margin:24px;color:#1f2328}
+h1{font-size:20px}h2{font-size:16px;margin-top:32px}
+.summary{background:#f6f8fa;border:1px solid #d0d7de;border-radius:6px;padding:8px 16px}
+table.diff{border-collapse:collapse;width:100%;table-layout:fixed;font-family:ui-monospace,Menlo,Consolas,monospace;font-size:12px}
+table.diff td{padding:0 6px;vertical-align:top;white-space:pre-wrap;word-break:break-all}
+td.num{width:40px;color:#6e7781;text-align:right;user-select:none}
+td.del{background:#ffebe9}td.add{background:#e6ffec}td.empty{background:#f6f8fa}
+tr.hunk td{background:#ddf4ff;color:#57606a}
+span.del{background:#ff818266}span.add{background:#abf2bc}`
+
+// renderHTMLReport renders a self-contained side-by-side HTML report
+func renderHTMLReport(files []FileDiff) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>KLCM diff report</title>\n")
+	b.WriteString("<style>" + htmlReportStyle + "</style></head><body>\n")
+	b.WriteString(fmt.Sprintf("<h1>KLCM diff report</h1>\n<p>Generated %s</p>\n", html.EscapeString(time.Now().Format("2006-01-02 15:04 MST"))))
+
+	for _, fd := range files {
+		b.WriteString(fmt.Sprintf("<h2>%s <small>(%s → %s)</small></h2>\n",
+			html.EscapeString(fd.Path), html.EscapeString(fd.OldLabel), html.EscapeString(fd.NewLabel)))
+
+		if fd.Semantic != nil {
+			writeHTMLSemanticSummary(&b, fd.Semantic)
+		}
+
+		chunks, _, _ := fd.fileChunks(3)
+		if len(chunks) == 0 {
+			b.WriteString("<p>No differences.</p>\n")
+			continue
+		}
+
+		b.WriteString("<table class=\"diff\">\n")
+		for _, chunk := range chunks {
+			b.WriteString(fmt.Sprintf("<tr class=\"hunk\"><td colspan=\"4\">@@ -%d,%d +%d,%d @@</td></tr>\n",
+				chunk.LocalStart, chunk.LocalCount, chunk.RemoteStart, chunk.RemoteCount))
+			writeHTMLChunkRows(&b, chunk.Lines)
+		}
+		b.WriteString("</table>\n")
+	}
+
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+func writeHTMLSemanticSummary(b *strings.Builder, diff *parsers.LayoutDiff) {
+	b.WriteString("<div class=\"summary\"><p><strong>" + html.EscapeString(diff.Summary()) + "</strong></p>")
+	if len(diff.KeyChanges) > 0 {
+		b.WriteString("<ul>")
+		for _, change := range diff.KeyChanges {
+			b.WriteString(fmt.Sprintf("<li>%s / %s: <code>%s</code> → <code>%s</code></li>",
+				html.EscapeString(change.Layer), html.EscapeString(change.Key),
+				html.EscapeString(describeBinding(change.Before)), html.EscapeString(describeBinding(change.After))))
+		}
+		b.WriteString("</ul>")
+	}
+	b.WriteString("</div>\n")
+}
+
+// writeHTMLChunkRows lays out a hunk side by side, pairing removed lines with
+// the added lines that replace them
+func writeHTMLChunkRows(b *strings.Builder, lines []DiffLine) {
+	for i := 0; i < len(lines); {
+		if lines[i].Type == LineContext {
+			content := html.EscapeString(lines[i].Content)
+			b.WriteString(fmt.Sprintf("<tr><td class=\"num\">%d</td><td>%s</td><td class=\"num\">%d</td><td>%s</td></tr>\n",
+				lines[i].LocalNo, content, lines[i].RemoteNo, content))
+			i++
+			continue
+		}
+
+		var removed, added []DiffLine
+		for i < len(lines) && lines[i].Type == LineRemoved {
+			removed = append(removed, lines[i])
+			i++
+		}
+		for i < len(lines) && lines[i].Type == LineAdded {
+			added = append(added, lines[i])
+			i++
+		}
+
+		for k := 0; k < max(len(removed), len(added)); k++ {
+			b.WriteString("<tr>")
+			if k < len(removed) {
+				b.WriteString(fmt.Sprintf("<td class=\"num\">%d</td><td class=\"del\">%s</td>", removed[k].LocalNo, htmlLineContent(removed[k], "del")))
+			} else {
+				b.WriteString("<td class=\"num\"></td><td class=\"empty\"></td>")
+			}
+			if k < len(added) {
+				b.WriteString(fmt.Sprintf("<td class=\"num\">%d</td><td class=\"add\">%s</td>", added[k].RemoteNo, htmlLineContent(added[k], "add")))
+			} else {
+				b.WriteString("<td class=\"num\"></td><td class=\"empty\"></td>")
+			}
+			b.WriteString("</tr>\n")
+		}
+	}
+}
+
+func htmlLineContent(line DiffLine, class string) string {
+	if len(line.Segments) == 0 {
+		return html.EscapeString(line.Content)
+	}
+
+	var b strings.Builder
+	for _, segment := range line.Segments {
+		if !segment.Changed {
+			b.WriteString(html.EscapeString(segment.Text))
+			continue
+		}
+		trimmed := strings.TrimRight(segment.Text, " \t")
+		b.WriteString(fmt.Sprintf("<span class=\"%s\">%s</span>%s", class,
+			html.EscapeString(trimmed), html.EscapeString(segment.Text[len(trimmed):])))
+	}
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRenderPatchApplies checks that every patch renderPatch writes is
+// accepted by git apply and turns the old side into the new side
+func TestRenderPatchApplies(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tests := []struct {
+		name       string
+		old, new   string
+		oldMissing bool
+	}{
+		{name: "changed line", old: "a\nb\nc\n", new: "a\nB\nc\n"},
+		{name: "newline removed at end", old: "a\nb\n", new: "a\nb"},
+		{name: "newline added at end", old: "a\nb", new: "a\nb\n"},
+		{name: "last line changed without newline", old: "a\nb", new: "a\nc"},
+		{name: "line appended after missing newline", old: "a\nb", new: "a\nb\nc\n"},
+		{name: "change far from unterminated end", old: "1\n2\n3\n4\n5\n6\n7\n8\n9", new: "1\nX\n3\n4\n5\n6\n7\n8\n9"},
+		{name: "new file", new: "a\nb\n", oldMissing: true},
+		{name: "new file without newline", new: "a", oldMissing: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := "config/test.keymap"
+			file := filepath.Join(dir, filepath.FromSlash(path))
+			if !tt.oldMissing {
+				if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(file, []byte(tt.old), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			patch := renderPatch([]FileDiff{{Path: path, OldContent: tt.old, NewContent: tt.new, OldMissing: tt.oldMissing}})
+			if patch == "" {
+				t.Fatal("renderPatch returned an empty patch")
+			}
+			for _, args := range [][]string{{"apply", "--check"}, {"apply"}} {
+				cmd := exec.Command("git", args...)
+				cmd.Dir = dir
+				cmd.Stdin = strings.NewReader(patch)
+				if output, err := cmd.CombinedOutput(); err != nil {
+					t.Fatalf("git %s: %v\n%s\npatch:\n%s", strings.Join(args, " "), err, output, patch)
+				}
+			}
+
+			got, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.new {
+				t.Errorf("applied patch gave %q, want %q\npatch:\n%s", got, tt.new, patch)
+			}
+		})
+	}
+}
+
+func TestRenderPatchSkipsIdenticalFiles(t *testing.T) {
+	if patch := renderPatch([]FileDiff{{Path: "a.keymap", OldContent: "a", NewContent: "a"}}); patch != "" {
+		t.Errorf("renderPatch of identical files = %q, want empty", patch)
+	}
+}
+
+func TestWriteDiffReportReportsDrift(t *testing.T) {
+	buf := captureResult(t)
+	files := []FileDiff{
+		{Keyboard: "glove80", Path: "configs/glove80.keymap", OldLabel: "local", NewLabel: "remote", OldContent: "a\n", NewContent: "b\n"},
+		{Keyboard: "adv360", Path: "configs/adv360.keymap", OldLabel: "local", NewLabel: "remote", OldContent: "a\n", NewContent: "a\n"},
+	}
+
+	for _, format := range []DiffFormat{DiffFormatPatch, DiffFormatJSON, DiffFormatHTML} {
+		t.Run(string(format), func(t *testing.T) {
+			result = newResult()
+			buf.Reset()
+			if err := writeDiffReport(format, files); err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Drift) != 1 || result.Drift[0].Keyboard != "glove80" {
+				t.Errorf("drift = %+v, want glove80 only", result.Drift)
+			}
+			if result.Data == nil {
+				t.Error("the report was not attached to the JSON result")
+			}
+
+			var exitErr *ExitError
+			if err := finishCommand(nil); !errors.As(err, &exitErr) || exitErr.Code != exitDrift {
+				t.Errorf("finishCommand() = %v, want exit %d", err, exitDrift)
+			}
+			var got Result
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("stdout is not a single JSON result: %v\n%s", err, buf)
+			}
+			if got.Status != "drift" {
+				t.Errorf("status = %q, want drift", got.Status)
+			}
+		})
+	}
+}
+
+func TestWriteDiffReportToFile(t *testing.T) {
+	buf := captureResult(t)
+	diffOutput = filepath.Join(t.TempDir(), "report.patch")
+	t.Cleanup(func() { diffOutput = "" })
+
+	files := []FileDiff{{Keyboard: "glove80", Path: "glove80.keymap", OldLabel: "local", NewLabel: "remote", OldContent: "a\n", NewContent: "b\n"}}
+	if err := writeDiffReport(DiffFormatPatch, files); err != nil {
+		t.Fatal(err)
+	}
+	patch, err := os.ReadFile(diffOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(patch), "+b") {
+		t.Errorf("report file = %q, want the patch", patch)
+	}
+	if result.Data != nil || buf.Len() != 0 {
+		t.Errorf("a report written to --diff-output was also attached to the result")
+	}
+	if len(result.Drift) != 1 {
+		t.Errorf("drift = %+v, want glove80", result.Drift)
+	}
+}
@@ -2,14 +2,12 @@ package cli
 
 import (
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
 )
 
 var downloadCmd = &cobra.Command{
@@ -17,20 +15,34 @@ var downloadCmd = &cobra.Command{
 	Short: "Download keyboard configurations from GitHub repositories",
 	Long: `Download the latest keyboard configurations from their respective GitHub repositories.
 
-Supported keyboards:
-  glove80   - Glove80 ZMK keymap
-  adv360    - Advantage360 ZMK keymap
-  adv_mod   - Kinesis Advantage (Pillz Mod) ZMK keymap
+Keyboards come from the registry; see 'klcm keyboards list'.
 
 Examples:
   klcm download                    # Download all configurations
   klcm download adv360 glove80     # Download specific keyboards
   klcm download --force adv_mod    # Force re-download Pillz Mod keymap
-  klcm download --preview          # Preview changes before downloading`,
+  klcm download --preview          # Preview changes before downloading
+  klcm download --preview --diff-format patch > remote.patch`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		recordRevisions = true
 		force, _ := cmd.Flags().GetBool("force")
 		preview, _ := cmd.Flags().GetBool("preview")
 		
+		format, err := selectedDiffFormat()
+		if err != nil {
+			return err
+		}
+		if format != DiffFormatText {
+			if !preview {
+				return fmt.Errorf("--diff-format %s requires --preview", format)
+			}
+			files, err := collectKeyboardDiffs(args)
+			if err != nil {
+				return err
+			}
+			return writeDiffReport(format, files)
+		}
+		
 		// Handle preview mode
 		if preview {
 			fmt.Println("🔍 Previewing changes before download...")
@@ -38,34 +50,37 @@ Examples:
 			keyboardsToPreview := args
 			if len(args) == 0 {
 				// Preview all keyboards
-				keyboardsToPreview = make([]string, 0, len(keyboards))
-				for _, kb := range keyboards {
-					keyboardsToPreview = append(keyboardsToPreview, kb.name)
-				}
+				keyboardsToPreview = registry.Current().Names()
 			}
 			
-			hasChanges := false
+			prefetchKeyboards(keyboardsToPreview)
+			var changedKeyboards []string
+			errs := make(keyboardErrors)
 			for _, keyboard := range keyboardsToPreview {
 				changed, err := previewKeyboardChanges(keyboard)
 				if err != nil {
-					return fmt.Errorf("failed to preview %s: %w", keyboard, err)
+					fmt.Printf("  ❌ %v\n", err)
+					errs[keyboard] = err
 				}
 				if changed {
-					hasChanges = true
+					changedKeyboards = append(changedKeyboards, keyboard)
 				}
 			}
+			if len(errs) > 0 {
+				return errs.summary(cmd, len(keyboardsToPreview))
+			}
 			
-			if !hasChanges {
+			if len(changedKeyboards) == 0 {
 				fmt.Println("\n✅ All local files are up to date. No download needed.")
 				return nil
 			}
 			
-			fmt.Print("\n❓ Proceed with download? (y/N): ")
-			var response string
-			fmt.Scanln(&response)
-			
-			if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
+			if !confirm("\n❓ Proceed with download? (y/N): ", false) {
 				fmt.Println("📦 Download cancelled.")
+				for _, keyboard := range changedKeyboards {
+					kb, _ := registry.Current().Get(keyboard)
+					reportDrift(keyboard, kb.LocalPath, "remote has changes (not downloaded)")
+				}
 				return nil
 			}
 			
@@ -77,80 +92,76 @@ Examples:
 		
 		if len(args) == 0 {
 			// Download all if no specific keyboards specified
-			return downloadAll(force)
+			return downloadAll(cmd, force)
 		}
 		
 		// Download specific keyboards
+		prefetchKeyboards(keyboardsToDownload(args, force))
+		errs := make(keyboardErrors)
 		for _, keyboard := range args {
 			if err := downloadKeyboard(keyboard, force); err != nil {
-				return fmt.Errorf("failed to download %s: %w", keyboard, err)
+				fmt.Printf("  ❌ %v\n", err)
+				errs[keyboard] = err
 			}
 		}
 		
-		return nil
-	},
-}
-
-type keyboardConfig struct {
-	name     string
-	dir      string
-	filename string
-	url      string
-}
-
-var keyboards = []keyboardConfig{
-	{
-		name:     "glove80",
-		dir:      "configs/zmk_glove80",
-		filename: "glove80.keymap",
-		url:      "https://raw.githubusercontent.com/masters3d/keyboard_layout_config_mapper/v7_target/configs/zmk_glove80/glove80.keymap",
-	},
-	{
-		name:     "adv360",
-		dir:      "configs/zmk_adv360",
-		filename: "adv360.keymap",
-		url:      "https://raw.githubusercontent.com/masters3d/keyboard_layout_config_mapper/v7_target/configs/zmk_adv360/adv360.keymap",
-	},
-	{
-		name:     "adv_mod",
-		dir:      "configs/zmk_adv_mod",
-		filename: "pillzmod_pro.keymap",
-		url:      "https://raw.githubusercontent.com/masters3d/zmk-config-pillzmod-nicenano/cheyo/config/pillzmod_pro.keymap",
+		return errs.summary(cmd, len(args))
 	},
 }
 
-func downloadAll(force bool) error {
+func downloadAll(cmd *cobra.Command, force bool) error {
 	fmt.Println("🚀 Starting keyboard configuration download...")
 	
-	for _, kb := range keyboards {
-		if err := downloadKeyboard(kb.name, force); err != nil {
-			return err
+	names := registry.Current().Names()
+	prefetchKeyboards(keyboardsToDownload(names, force))
+	errs := make(keyboardErrors)
+	for _, name := range names {
+		if err := downloadKeyboard(name, force); err != nil {
+			fmt.Printf("  ❌ %v\n", err)
+			errs[name] = err
 		}
 	}
+	if len(errs) > 0 {
+		return errs.summary(cmd, len(names))
+	}
 	
 	fmt.Println("\n🎉 All configurations downloaded successfully!")
 	printSummary()
 	return nil
 }
 
-func downloadKeyboard(name string, force bool) error {
-	var kb *keyboardConfig
-	for _, k := range keyboards {
-		if k.name == name {
-			kb = &k
-			break
+// keyboardsToDownload returns the keyboards download will fetch: all of them
+// with --force, otherwise those without a local keymap yet
+func keyboardsToDownload(names []string, force bool) []string {
+	if force {
+		return names
+	}
+	var missing []string
+	for _, name := range names {
+		kb, ok := registry.Current().Get(name)
+		if !ok {
+			continue
+		}
+		if _, err := os.Stat(filepath.FromSlash(kb.LocalPath)); err != nil {
+			missing = append(missing, name)
 		}
 	}
-	
-	if kb == nil {
-		return fmt.Errorf("unknown keyboard: %s", name)
+	return missing
+}
+
+func downloadKeyboard(name string, force bool) error {
+	kb, err := registry.Current().Lookup(name)
+	if err != nil {
+		return err
 	}
 	
-	fmt.Printf("\n📁 Processing %s...\n", kb.dir)
-	return downloadFile(kb.dir, kb.filename, kb.url, force)
+	fmt.Printf("\n📁 Processing %s...\n", kb.Dir())
+	return downloadFile(kb, force)
 }
 
-func downloadFile(dir, filename, url string, force bool) error {
+func downloadFile(kb registry.Keyboard, force bool) error {
+	dir, filename := kb.Dir(), kb.Filename()
+
 	// Create directory if it doesn't exist
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory %s: %w", dir, err)
@@ -162,77 +173,63 @@ func downloadFile(dir, filename, url string, force bool) error {
 	if !force {
 		if _, err := os.Stat(filePath); err == nil {
 			fmt.Printf("  ⏭️  %s already exists (use --force to re-download)\n", filename)
+			reportSkipped(kb.Name, filepath.ToSlash(filePath), "already exists (use --force to re-download)")
 			return nil
 		}
 	}
 	
 	fmt.Printf("  📥 Downloading %s...\n", filename)
 	
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	
-	// Download file
-	resp, err := client.Get(url)
+	content, err := fetchKeyboardRemote(kb.Name)
 	if err != nil {
-		return fmt.Errorf("failed to download from %s: %w", url, err)
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download %s: HTTP %d", filename, resp.StatusCode)
+		return err
 	}
 	
-	// Create file
-	file, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to create file %s: %w", filePath, err)
+	if existing, err := os.ReadFile(filePath); err == nil && string(existing) == content {
+		fmt.Printf("    ✅ %s is already up to date\n", filename)
+		return lockKeyboard(kb.Name, content)
 	}
-	defer file.Close()
-	
-	// Copy content
-	_, err = io.Copy(file, resp.Body)
-	if err != nil {
+
+	// Replace the file atomically, keeping the old version for undo
+	if err := writeKeymap(filePath, []byte(content)); err != nil {
 		return fmt.Errorf("failed to write file %s: %w", filePath, err)
 	}
+	if err := lockKeyboard(kb.Name, content); err != nil {
+		return err
+	}
 	
 	fmt.Printf("    ✅ Successfully downloaded %s\n", filename)
+	reportChanged(kb.Name, filepath.ToSlash(filePath), "downloaded")
 	return nil
 }
 
 func printSummary() {
 	fmt.Println("\n📋 Summary:")
-	fmt.Println("  - configs/zmk_glove80/: Glove80 ZMK keymap (glove80.keymap)")
-	fmt.Println("  - configs/zmk_adv360/: Advantage360 ZMK keymap (adv360.keymap)")
-	fmt.Println("  - configs/zmk_adv_mod/: Kinesis Advantage (Pillz Mod) ZMK keymap (pillzmod_pro.keymap)")
-	
+	for _, kb := range registry.Current().All() {
+		fmt.Printf("  - %s/: %s ZMK keymap (%s)\n", kb.Dir(), kb.Description, kb.Filename())
+	}
+
 	fmt.Println("\n🔗 Repository mapping:")
-	fmt.Println("  - Glove80: masters3d/glove80-zmk-config/cheyo/config/glove80.keymap")
-	fmt.Println("  - Advantage360: masters3d/Adv360-Pro-ZMK/cheyo/config/adv360.keymap")
-	fmt.Println("  - Advantage Mod: masters3d/zmk-config-pillzmod-nicenano/cheyo/config/pillzmod_pro.keymap")
+	for _, kb := range registry.Current().All() {
+		if upstream := kb.Upstream(); upstream != "" {
+			fmt.Printf("  - %s: %s\n", kb.Description, upstream)
+		}
+	}
 }
 
 func previewKeyboardChanges(name string) (bool, error) {
-	var kb *keyboardConfig
-	for _, k := range keyboards {
-		if k.name == name {
-			kb = &k
-			break
-		}
-	}
-	
-	if kb == nil {
-		return false, fmt.Errorf("unknown keyboard: %s", name)
+	kb, err := registry.Current().Lookup(name)
+	if err != nil {
+		return false, err
 	}
 	
-	filePath := filepath.Join(kb.dir, kb.filename)
+	filePath := filepath.FromSlash(kb.LocalPath)
 	
 	// Check if local file exists
 	localContent, err := os.ReadFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			fmt.Printf("\n📁 %s/%s\n", kb.dir, kb.filename)
+			fmt.Printf("\n📁 %s/%s\n", kb.Dir(), kb.Filename())
 			fmt.Printf("  🆕 Local file does not exist - would be created\n")
 			return true, nil
 		}
@@ -240,31 +237,17 @@ func previewKeyboardChanges(name string) (bool, error) {
 	}
 	
 	// Fetch remote content
-	fmt.Printf("\n📁 %s/%s\n", kb.dir, kb.filename)
+	fmt.Printf("\n📁 %s/%s\n", kb.Dir(), kb.Filename())
 	fmt.Printf("  📡 Checking remote version...\n")
 	
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	
-	resp, err := client.Get(kb.url)
+	remoteContent, err := fetchKeyboardRemote(kb.Name)
 	if err != nil {
-		return false, fmt.Errorf("failed to fetch remote content from %s: %w", kb.url, err)
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("failed to fetch remote content: HTTP %d", resp.StatusCode)
-	}
-	
-	remoteContent, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return false, fmt.Errorf("failed to read remote content: %w", err)
+		return false, err
 	}
 	
 	// Compare content
 	localStr := strings.TrimSpace(string(localContent))
-	remoteStr := strings.TrimSpace(string(remoteContent))
+	remoteStr := strings.TrimSpace(remoteContent)
 	
 	if localStr == remoteStr {
 		fmt.Printf("  ✅ Up to date\n")
@@ -313,8 +296,47 @@ func previewKeyboardChanges(name string) (bool, error) {
 	return true, nil
 }
 
+// collectKeyboardDiffs fetches the remote keymap of each named keyboard (all
+// keyboards if names is empty) and returns the local → remote file diffs
+func collectKeyboardDiffs(names []string) ([]FileDiff, error) {
+	if len(names) == 0 {
+		names = registry.Current().Names()
+	}
+	prefetchKeyboards(names)
+
+	var files []FileDiff
+	for _, name := range names {
+		kb, err := registry.Current().Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+
+		filePath := filepath.FromSlash(kb.LocalPath)
+		localContent, err := os.ReadFile(filePath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read local file %s: %w", filePath, err)
+		}
+		missing := os.IsNotExist(err)
+
+		remoteContent, err := fetchKeyboardRemote(kb.Name)
+		if err != nil {
+			return nil, err
+		}
+		if string(localContent) == remoteContent && !missing {
+			continue
+		}
+
+		fd := newFileDiff(kb.Name, filepath.ToSlash(filePath), "local", "remote", string(localContent), remoteContent)
+		fd.OldMissing = missing
+		files = append(files, fd)
+	}
+
+	return files, nil
+}
+
 func init() {
 	rootCmd.AddCommand(downloadCmd)
 	downloadCmd.Flags().BoolP("force", "f", false, "Force re-download even if files exist")
 	downloadCmd.Flags().BoolP("preview", "p", false, "Preview changes before downloading")
+	addDiffFormatFlags(downloadCmd)
 }
\ No newline at end of file
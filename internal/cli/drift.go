@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/parsers"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+)
+
+// keyboardDrift is the --check outcome of one keyboard, reported as Data
+// with --output json
+type keyboardDrift struct {
+	Keyboard         string              `json:"keyboard"`
+	Path             string              `json:"path"`
+	Status           string              `json:"status"` // "in-sync", "formatting", "drift" or "missing"
+	Summary          string              `json:"summary"`
+	LayersChanged    []string            `json:"layers_changed,omitempty"`
+	KeysChanged      int                 `json:"keys_changed"`
+	BehaviorsChanged int                 `json:"behaviors_changed"`
+	Semantic         *parsers.LayoutDiff `json:"semantic,omitempty"`
+}
+
+// runDriftCheck implements compare-remote --check: one summary line per
+// keyboard, with drift reported so the command exits 2
+func runDriftCheck(cmd *cobra.Command, names []string) error {
+	keyboards, err := registry.Current().Select(names)
+	if err != nil {
+		return err
+	}
+	selected := make([]string, len(keyboards))
+	for i, kb := range keyboards {
+		selected[i] = kb.Name
+	}
+
+	mode := "text"
+	if compareSemantic {
+		mode = "semantic"
+	}
+	fmt.Printf("🔍 Checking %d keyboard(s) for upstream drift (%s comparison)...\n\n", len(keyboards), mode)
+	prefetchKeyboards(selected)
+
+	drifts := []keyboardDrift{}
+	drifted := 0
+	errs := make(keyboardErrors)
+	for _, kb := range keyboards {
+		drift, err := checkKeyboardDrift(kb)
+		if err != nil {
+			fmt.Printf("  ❌ %-10s %v\n", kb.Name, err)
+			errs[kb.Name] = err
+			continue
+		}
+		drifts = append(drifts, drift)
+
+		switch drift.Status {
+		case "in-sync":
+			fmt.Printf("  ✅ %-10s in sync\n", kb.Name)
+		case "formatting":
+			if compareSemantic {
+				fmt.Printf("  ✅ %-10s in sync (formatting only)\n", kb.Name)
+				continue
+			}
+			fallthrough
+		default:
+			drifted++
+			fmt.Printf("  ⚠️  %-10s %s\n", kb.Name, drift.Summary)
+			reportDrift(kb.Name, kb.LocalPath, drift.Summary)
+		}
+	}
+	reportData(drifts)
+
+	if err := errs.summary(cmd, len(keyboards)); err != nil {
+		return err
+	}
+	if drifted == 0 {
+		fmt.Println("\n✅ No drift from upstream")
+		return nil
+	}
+	fmt.Printf("\n⚠️  %d of %d keyboard(s) drifted from upstream; see 'klcm compare-remote <keyboard>' for the diff\n", drifted, len(keyboards))
+	return nil
+}
+
+// checkKeyboardDrift compares a keyboard's local keymap with upstream
+func checkKeyboardDrift(kb registry.Keyboard) (keyboardDrift, error) {
+	drift := keyboardDrift{Keyboard: kb.Name, Path: kb.LocalPath}
+
+	localContent, err := os.ReadFile(filepath.FromSlash(kb.LocalPath))
+	if os.IsNotExist(err) {
+		drift.Status, drift.Summary = "missing", "local file does not exist"
+		return drift, nil
+	}
+	if err != nil {
+		return drift, fmt.Errorf("failed to read local file %s: %w", kb.LocalPath, err)
+	}
+	remoteContent, err := fetchKeyboardRemote(kb.Name)
+	if err != nil {
+		return drift, err
+	}
+
+	localStr := strings.TrimSpace(string(localContent))
+	remoteStr := strings.TrimSpace(remoteContent)
+	if localStr == remoteStr {
+		drift.Status, drift.Summary = "in-sync", "no differences"
+		return drift, nil
+	}
+
+	fd := newFileDiff(kb.Name, kb.LocalPath, "local", "remote", localStr, remoteStr)
+	switch {
+	case fd.Semantic == nil:
+		// Without a parse there is nothing semantic to say about the change
+		drift.Status = "drift"
+		drift.Summary = SimpleDiffSummary(strings.Split(localStr, "\n"), strings.Split(remoteStr, "\n")) + " (could not parse for a semantic summary)"
+	case fd.Semantic.IsEmpty():
+		drift.Status, drift.Summary = "formatting", "formatting only"
+	default:
+		drift.Status = "drift"
+		drift.Summary = driftSummary(fd.Semantic)
+		drift.LayersChanged = fd.Semantic.LayersTouched()
+		drift.KeysChanged = len(fd.Semantic.KeyChanges)
+		drift.BehaviorsChanged = len(fd.Semantic.BehaviorChanges)
+		drift.Semantic = fd.Semantic
+	}
+	return drift, nil
+}
+
+// driftSummary describes a semantic diff in one line, naming the layers
+func driftSummary(diff *parsers.LayoutDiff) string {
+	summary := diff.Summary()
+	if layers := diff.LayersTouched(); len(layers) > 0 {
+		summary += " [" + strings.Join(layers, ", ") + "]"
+	}
+	return summary
+}
+
+// withoutFormattingOnly drops files whose differences are formatting only
+func withoutFormattingOnly(files []FileDiff) []FileDiff {
+	var kept []FileDiff
+	for _, fd := range files {
+		if fd.OldMissing || fd.Semantic == nil || !fd.Semantic.IsEmpty() {
+			kept = append(kept, fd)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+)
+
+// newDriftWorkspace returns a temporary workspace whose keyboards are pulled
+// from a plain upstream directory:
+//
+//	glove80  the same keymap locally and upstream
+//	adv360   the same bindings, formatted differently
+//	corne    a key changed upstream
+//	lily58   no local keymap
+func newDriftWorkspace(t *testing.T) []registry.Keyboard {
+	t.Helper()
+	upstream := t.TempDir()
+	chdirTemp(t)
+	captureResult(t)
+	fetched = make(map[string]fetchResult)
+
+	files := []struct {
+		name, local, remote string
+	}{
+		{name: "glove80", local: testKeymap("&kp A &kp B"), remote: testKeymap("&kp A &kp B")},
+		{name: "adv360", local: testKeymap("&kp A &kp B"), remote: testKeymap("&kp A    &kp B")},
+		{name: "corne", local: testKeymap("&kp A &kp B"), remote: testKeymap("&kp A &kp C")},
+		{name: "lily58", remote: testKeymap("&kp A &kp B")},
+	}
+	var keyboards []registry.Keyboard
+	for _, f := range files {
+		kb := registry.Keyboard{
+			Name:         f.name,
+			Type:         "zmk",
+			LocalPath:    "configs/" + f.name + "/" + f.name + ".keymap",
+			Source:       upstream,
+			UpstreamPath: "config/" + f.name + ".keymap",
+		}
+		keyboards = append(keyboards, kb)
+		writeTestFile(t, filepath.Join(upstream, filepath.FromSlash(kb.UpstreamPath)), f.remote)
+		if f.local != "" {
+			writeTestFile(t, filepath.FromSlash(kb.LocalPath), f.local)
+		}
+	}
+
+	previousRegistry := registry.Current()
+	registry.SetCurrent(registry.New(keyboards...))
+	t.Cleanup(func() {
+		registry.SetCurrent(previousRegistry)
+		compareSemantic = false
+		fetched = make(map[string]fetchResult)
+	})
+	return keyboards
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckKeyboardDrift(t *testing.T) {
+	keyboards := newDriftWorkspace(t)
+	want := map[string]string{"glove80": "in-sync", "adv360": "formatting", "corne": "drift", "lily58": "missing"}
+
+	for _, kb := range keyboards {
+		drift, err := checkKeyboardDrift(kb)
+		if err != nil {
+			t.Fatalf("%s: %v", kb.Name, err)
+		}
+		if drift.Status != want[kb.Name] {
+			t.Errorf("%s: status = %q (%s), want %q", kb.Name, drift.Status, drift.Summary, want[kb.Name])
+		}
+		if kb.Name != "corne" {
+			continue
+		}
+		if drift.KeysChanged != 1 || !reflect.DeepEqual(drift.LayersChanged, []string{"base"}) || drift.Semantic == nil {
+			t.Errorf("corne drift = %+v, want one key changed on base", drift)
+		}
+	}
+}
+
+func TestDriftCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		semantic bool
+		names    []string
+		drifted  []string
+	}{
+		{name: "text", drifted: []string{"adv360", "corne", "lily58"}},
+		{name: "semantic ignores formatting", semantic: true, drifted: []string{"corne", "lily58"}},
+		{name: "in sync", names: []string{"glove80", "adv360"}, semantic: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newDriftWorkspace(t)
+			compareSemantic = tt.semantic
+
+			if err := runDriftCheck(compareRemoteCmd, tt.names); err != nil {
+				t.Fatal(err)
+			}
+			var drifted []string
+			for _, item := range result.Drift {
+				drifted = append(drifted, item.Keyboard)
+			}
+			sort.Strings(drifted)
+			if !reflect.DeepEqual(drifted, tt.drifted) {
+				t.Errorf("drifted = %v, want %v", drifted, tt.drifted)
+			}
+
+			err := finishCommand(nil)
+			var exitErr *ExitError
+			switch {
+			case len(tt.drifted) == 0 && err != nil:
+				t.Errorf("finishCommand() = %v, want exit 0", err)
+			case len(tt.drifted) > 0 && (!errors.As(err, &exitErr) || exitErr.Code != exitDrift):
+				t.Errorf("finishCommand() = %v, want exit %d", err, exitDrift)
+			}
+		})
+	}
+}
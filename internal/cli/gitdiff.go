@@ -12,6 +12,7 @@ type DiffOptions struct {
 	Unified      bool   // Use unified diff format (git-style)
 	ShowHeader   bool   // Show file headers
 	MaxWidth     int    // Maximum line width before truncation
+	IntraLine    bool   // Highlight the changed bindings inside changed lines
 }
 
 // DefaultDiffOptions returns sensible defaults for diff display
@@ -22,6 +23,7 @@ func DefaultDiffOptions() DiffOptions {
 		Unified:      true,
 		ShowHeader:   true,
 		MaxWidth:     120,
+		IntraLine:    true,
 	}
 }
 
@@ -40,6 +42,7 @@ type DiffLine struct {
 	Content string
 	LocalNo int  // Line number in local file (0 if not applicable)
 	RemoteNo int // Line number in remote file (0 if not applicable)
+	Segments []DiffSegment // Binding-level changes when paired with a replacement line
 }
 
 // LineType represents the type of a diff line
@@ -70,6 +73,9 @@ func UnifiedDiff(localPath, remotePath string, localContent, remoteContent strin
 	}
 	
 	chunks := generateDiffChunks(localLines, remoteLines, opts.ContextLines)
+	if opts.IntraLine {
+		annotateIntraLine(chunks)
+	}
 	
 	var result strings.Builder
 	
@@ -104,6 +110,16 @@ func UnifiedDiff(localPath, remotePath string, localContent, remoteContent strin
 		
 		// Chunk lines
 		for _, line := range chunk.Lines {
+			if len(line.Segments) > 0 {
+				prefix, lineColor := "-", colorRed
+				if line.Type == LineAdded {
+					prefix, lineColor = "+", colorGreen
+				}
+				result.WriteString(renderSegments(prefix, line.Segments, lineColor, opts))
+				result.WriteString("\n")
+				continue
+			}
+
 			content := line.Content
 			if len(content) > opts.MaxWidth {
 				content = content[:opts.MaxWidth-3] + "..."
@@ -182,6 +198,7 @@ const (
 	colorCyan   = "\033[36m"
 	colorWhite  = "\033[37m"
 	colorBold   = "\033[1m"
+	colorReverse = "\033[7m"
 )
 
 // Helper functions
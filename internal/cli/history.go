@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/history"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+	"masters3d.com/keyboard_layout_config_mapper/internal/remote"
+)
+
+var undoTo int
+
+// keymapHistory returns the history store of the workspace
+func keymapHistory() *history.Store {
+	return history.Open(history.DefaultDir)
+}
+
+// commandLine returns the klcm invocation being run, for history entries
+func commandLine() string {
+	return remote.Redact(strings.TrimSpace("klcm " + strings.Join(os.Args[1:], " ")))
+}
+
+// writeKeymap atomically replaces a keymap, keeping the previous version in
+// the history store
+func writeKeymap(path string, content []byte) error {
+	keyboard := filepath.ToSlash(path)
+	if kb, ok := registry.Current().ByPath(path); ok {
+		keyboard = kb.Name
+	}
+	entry, err := keymapHistory().Write(keyboard, path, commandLine(), content)
+	if err != nil {
+		return err
+	}
+	if entry != nil && verbose {
+		fmt.Printf("  🗂️  Previous version saved as history #%d (klcm undo %s)\n", entry.ID, keyboard)
+	}
+	return nil
+}
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history <keyboard>",
+	Short: "List earlier versions of a keymap",
+	Long: `List the versions of a keyboard's keymap that klcm has written, newest first,
+with the command that produced each one.
+
+Every pull, download and sync saves the version it replaces in .klcm/history
+before writing, so any listed version can be restored with 'klcm undo'.`,
+	Example: `  # See what changed adv360.keymap
+  klcm history adv360
+
+  # Restore the version produced by entry #3
+  klcm undo adv360 --to 3`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistory,
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	keyboard := args[0]
+	entries, err := keymapHistory().For(keyboard)
+	if err != nil {
+		return err
+	}
+	reportData(entries)
+	if len(entries) == 0 {
+		fmt.Printf("📭 No history for %s yet\n", keyboard)
+		return nil
+	}
+
+	path := entries[len(entries)-1].Path
+	current := ""
+	if content, err := os.ReadFile(filepath.FromSlash(path)); err == nil {
+		current = history.Hash(content)
+	}
+
+	fmt.Printf("📜 History of %s (%s):\n\n", keyboard, path)
+	if current != entries[len(entries)-1].After {
+		fmt.Printf("  📝 %s has been edited since klcm last wrote it\n", path)
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		version := "removed"
+		if entry.After != "" {
+			version = history.Short(entry.After)
+		}
+		marker := ""
+		if i == len(entries)-1 && current == entry.After {
+			marker = "  ← current"
+		}
+		fmt.Printf("  #%-4d %s  %-12s  %s%s\n", entry.ID, entry.Time.Local().Format("2006-01-02 15:04:05"), version, entry.Command, marker)
+	}
+	if first := entries[0]; first.Before != "" {
+		fmt.Printf("  #%-4d %-19s  %-12s  (before klcm history)\n", 0, "", history.Short(first.Before))
+	}
+
+	fmt.Printf("\n💡 Restore a version with: klcm undo %s --to <#>\n", keyboard)
+	return nil
+}
+
+// undoCmd represents the undo command
+var undoCmd = &cobra.Command{
+	Use:   "undo [keyboard]",
+	Short: "Restore the version of a keymap before the last change",
+	Long: `Undo the most recent change klcm made to a keymap, restoring the version
+saved in .klcm/history. Without a keyboard the most recent change to any
+keymap is undone. Running undo again steps further back.
+
+--to restores the version produced by a specific entry of 'klcm history'; 0
+is the version from before klcm first changed the file. Undo is recorded in
+the history too, so it can be reverted the same way.`,
+	Example: `  # Revert the last pull, download or sync
+  klcm undo
+
+  # Revert the last change to the Glove80 keymap
+  klcm undo glove80
+
+  # Go back to a specific version
+  klcm history adv360
+  klcm undo adv360 --to 3`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runUndo,
+}
+
+func runUndo(cmd *cobra.Command, args []string) error {
+	store := keymapHistory()
+	keyboard := ""
+	if len(args) > 0 {
+		keyboard = args[0]
+	}
+
+	if cmd.Flags().Changed("to") {
+		if keyboard == "" {
+			return fmt.Errorf("--to needs a keyboard")
+		}
+		return restoreVersion(store, keyboard, undoTo)
+	}
+
+	entry, err := store.Undoable(keyboard)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		if keyboard == "" {
+			fmt.Println("📭 Nothing to undo")
+		} else {
+			fmt.Printf("📭 Nothing to undo for %s\n", keyboard)
+		}
+		return nil
+	}
+
+	if err := checkUnchangedSince(*entry); err != nil {
+		return err
+	}
+	if _, err := store.Undo(*entry, commandLine()); err != nil {
+		return err
+	}
+	fmt.Printf("↩️  Undid #%d on %s: %s\n", entry.ID, entry.Keyboard, entry.Command)
+	reportChanged(entry.Keyboard, entry.Path, fmt.Sprintf("undid #%d (%s)", entry.ID, entry.Command))
+	if entry.Before == "" {
+		fmt.Printf("   🗑️  Removed %s, which did not exist before\n", entry.Path)
+	} else {
+		fmt.Printf("   📁 Restored %s to version %s\n", entry.Path, history.Short(entry.Before))
+	}
+	return nil
+}
+
+// restoreVersion writes back the version a history entry produced
+func restoreVersion(store *history.Store, keyboard string, id int) error {
+	entries, err := store.For(keyboard)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no history for %s", keyboard)
+	}
+
+	path, hash := entries[0].Path, ""
+	if id == 0 {
+		hash = entries[0].Before
+	} else {
+		found := false
+		for _, entry := range entries {
+			if entry.ID == id {
+				path, hash, found = entry.Path, entry.After, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("#%d is not in the history of %s (see klcm history %s)", id, keyboard, keyboard)
+		}
+	}
+
+	entry, err := store.Restore(keyboard, filepath.FromSlash(path), hash, commandLine(), 0)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		fmt.Printf("✅ %s is already at version #%d\n", path, id)
+		return nil
+	}
+	reportChanged(keyboard, path, fmt.Sprintf("restored version #%d", id))
+	fmt.Printf("↩️  Restored %s to version #%d", path, id)
+	if hash != "" {
+		fmt.Printf(" (%s)", history.Short(hash))
+	}
+	fmt.Println()
+	return nil
+}
+
+// checkUnchangedSince refuses to undo over edits made after klcm wrote the
+// file, which undo would otherwise silently discard
+func checkUnchangedSince(entry history.Entry) error {
+	content, err := os.ReadFile(filepath.FromSlash(entry.Path))
+	current := ""
+	if err == nil {
+		current = history.Hash(content)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", entry.Path, err)
+	}
+	if current != entry.After {
+		return fmt.Errorf("%s has changed since #%d (%s); restore a version explicitly with 'klcm undo %s --to <#>'", entry.Path, entry.ID, entry.Command, entry.Keyboard)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(undoCmd)
+
+	undoCmd.Flags().IntVar(&undoTo, "to", 0, "restore the version produced by this history entry (0 = before klcm changed the file)")
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+)
+
+// newHistoryWorkspace returns a temporary workspace in which klcm has written
+// each of versions to the glove80 keymap in turn
+func newHistoryWorkspace(t *testing.T, versions ...string) {
+	t.Helper()
+	chdirTemp(t)
+	captureResult(t)
+	previousRegistry := registry.Current()
+	registry.SetCurrent(registry.New(registry.Keyboard{Name: "glove80", Type: "zmk", LocalPath: testLocalPath}))
+	t.Cleanup(func() { registry.SetCurrent(previousRegistry) })
+
+	for _, version := range versions {
+		if err := writeKeymap(testLocalPath, []byte(version)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// localKeymap returns the glove80 keymap, or "" if there is none
+func localKeymap(t *testing.T) string {
+	t.Helper()
+	content, err := os.ReadFile(testLocalPath)
+	if os.IsNotExist(err) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestUndo(t *testing.T) {
+	newHistoryWorkspace(t, "first\n", "second\n")
+
+	if err := runUndo(undoCmd, []string{"glove80"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := localKeymap(t); got != "first\n" {
+		t.Errorf("keymap after undo = %q, want %q", got, "first\n")
+	}
+	if len(result.Changed) != 1 || result.Changed[0].Keyboard != "glove80" {
+		t.Errorf("changed = %+v, want glove80", result.Changed)
+	}
+
+	// A second undo skips the first one and reverts the write before it
+	if err := runUndo(undoCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(testLocalPath); !os.IsNotExist(err) {
+		t.Errorf("keymap after the second undo: %v, want it removed as before klcm wrote it", err)
+	}
+
+	if err := runUndo(undoCmd, nil); err != nil {
+		t.Errorf("undo with nothing left: %v", err)
+	}
+	entries, err := keymapHistory().Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("history has %d entries, want 2 writes and 2 undos", len(entries))
+	}
+}
+
+func TestUndoRefusesOverEdits(t *testing.T) {
+	newHistoryWorkspace(t, "first\n", "second\n")
+	if err := os.WriteFile(testLocalPath, []byte("edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runUndo(undoCmd, []string{"glove80"})
+	if err == nil || !strings.Contains(err.Error(), "has changed since #2") {
+		t.Errorf("undo over an edit error = %v, want a refusal", err)
+	}
+	if got := localKeymap(t); got != "edited\n" {
+		t.Errorf("keymap = %q, want the edit kept", got)
+	}
+}
+
+func TestUndoTo(t *testing.T) {
+	newHistoryWorkspace(t, "first\n", "second\n", "third\n")
+	store := keymapHistory()
+
+	if err := restoreVersion(store, "glove80", 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := localKeymap(t); got != "first\n" {
+		t.Errorf("keymap restored to #1 = %q, want %q", got, "first\n")
+	}
+	if err := restoreVersion(store, "glove80", 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := localKeymap(t); got != "second\n" {
+		t.Errorf("keymap restored to #2 = %q, want %q", got, "second\n")
+	}
+	if err := restoreVersion(store, "glove80", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := localKeymap(t); got != "" {
+		t.Errorf("keymap restored to before klcm = %q, want it removed", got)
+	}
+	if err := restoreVersion(store, "glove80", 42); err == nil {
+		t.Error("restoring a version not in the history succeeded")
+	}
+}
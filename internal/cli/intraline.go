@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DiffSegment is a piece of a changed line, split on binding boundaries
+// (e.g. "&kp X", "&mt LSHFT A") or on words for lines without bindings
+type DiffSegment struct {
+	Text    string
+	Changed bool // true if this segment differs from the paired line
+}
+
+// annotateIntraLine pairs removed lines with the added lines that replace them
+// and marks which segments of each actually changed
+func annotateIntraLine(chunks []DiffChunk) {
+	for c := range chunks {
+		lines := chunks[c].Lines
+		for i := 0; i < len(lines); {
+			if lines[i].Type != LineRemoved {
+				i++
+				continue
+			}
+
+			removedStart := i
+			for i < len(lines) && lines[i].Type == LineRemoved {
+				i++
+			}
+			addedStart := i
+			for i < len(lines) && lines[i].Type == LineAdded {
+				i++
+			}
+
+			pairs := min(addedStart-removedStart, i-addedStart)
+			for p := 0; p < pairs; p++ {
+				removed := &lines[removedStart+p]
+				added := &lines[addedStart+p]
+				removed.Segments, added.Segments = diffSegments(removed.Content, added.Content)
+			}
+		}
+	}
+}
+
+// diffSegments splits both lines into segments and marks the segments that
+// are not part of their longest common subsequence
+func diffSegments(oldLine, newLine string) ([]DiffSegment, []DiffSegment) {
+	oldParts := splitDiffSegments(oldLine)
+	newParts := splitDiffSegments(newLine)
+
+	// Longest common subsequence over trimmed segment text
+	lcs := make([][]int, len(oldParts)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newParts)+1)
+	}
+	for i := len(oldParts) - 1; i >= 0; i-- {
+		for j := len(newParts) - 1; j >= 0; j-- {
+			if strings.TrimSpace(oldParts[i]) == strings.TrimSpace(newParts[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	oldSegments := make([]DiffSegment, len(oldParts))
+	newSegments := make([]DiffSegment, len(newParts))
+	for i := range oldParts {
+		oldSegments[i] = DiffSegment{Text: oldParts[i], Changed: true}
+	}
+	for j := range newParts {
+		newSegments[j] = DiffSegment{Text: newParts[j], Changed: true}
+	}
+
+	for i, j := 0, 0; i < len(oldParts) && j < len(newParts); {
+		switch {
+		case strings.TrimSpace(oldParts[i]) == strings.TrimSpace(newParts[j]):
+			oldSegments[i].Changed = false
+			newSegments[j].Changed = false
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	// Whitespace-only segments never count as changes on their own
+	for _, segments := range [][]DiffSegment{oldSegments, newSegments} {
+		for i := range segments {
+			if strings.TrimSpace(segments[i].Text) == "" {
+				segments[i].Changed = false
+			}
+		}
+	}
+
+	return oldSegments, newSegments
+}
+
+// splitDiffSegments splits a line so that concatenating the result gives the
+// line back. Lines containing bindings are split before each "&"; other lines
+// are split into words. Trailing whitespace stays with the preceding segment.
+func splitDiffSegments(line string) []string {
+	startsSegment := func(runes []rune, i int) bool {
+		if i == 0 || !unicode.IsSpace(runes[i-1]) {
+			return false
+		}
+		if strings.Contains(line, "&") {
+			return runes[i] == '&'
+		}
+		return !unicode.IsSpace(runes[i])
+	}
+
+	runes := []rune(line)
+	var segments []string
+	start := 0
+	for i := range runes {
+		if i > start && startsSegment(runes, i) {
+			segments = append(segments, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		segments = append(segments, string(runes[start:]))
+	}
+
+	return segments
+}
+
+// changedRange returns the rune offsets spanning all changed segments
+func changedRange(segments []DiffSegment) (int, int, bool) {
+	first, last := -1, -1
+	offset := 0
+	for _, segment := range segments {
+		length := len([]rune(segment.Text))
+		if segment.Changed {
+			if first < 0 {
+				first = offset
+			}
+			last = offset + len([]rune(strings.TrimRightFunc(segment.Text, unicode.IsSpace)))
+		}
+		offset += length
+	}
+	return first, last, first >= 0
+}
+
+// visibleWindow picks which runes of a line to show within maxWidth, keeping
+// the changed region in view. It returns the window and whether an ellipsis
+// is needed on either side.
+func visibleWindow(segments []DiffSegment, maxWidth int) (start, end int, clipStart, clipEnd bool) {
+	total := 0
+	for _, segment := range segments {
+		total += len([]rune(segment.Text))
+	}
+	if maxWidth <= 0 || total <= maxWidth {
+		return 0, total, false, false
+	}
+
+	const ellipsis = 3
+	first, last, ok := changedRange(segments)
+	if !ok || last <= maxWidth-ellipsis {
+		// The whole change fits without scrolling; keep the line's start
+		return 0, maxWidth - ellipsis, false, true
+	}
+
+	// Start at the segment before the change so it keeps some context
+	offset, previous := 0, 0
+	start = first
+	for _, segment := range segments {
+		if offset >= first {
+			start = previous
+			break
+		}
+		previous = offset
+		offset += len([]rune(segment.Text))
+	}
+	if first-start > maxWidth/3 {
+		start = first
+	}
+
+	end = start + maxWidth - 2*ellipsis
+	if end >= total {
+		start = max(0, total-(maxWidth-ellipsis))
+		return start, total, start > 0, false
+	}
+	return start, end, true, true
+}
+
+// renderSegments renders the visible part of a changed line. Changed segments
+// are highlighted on top of the line color when color output is enabled.
+func renderSegments(prefix string, segments []DiffSegment, baseColor string, opts DiffOptions) string {
+	start, end, clipStart, clipEnd := visibleWindow(segments, opts.MaxWidth)
+
+	var result strings.Builder
+	if opts.Color {
+		result.WriteString(baseColor)
+	}
+	result.WriteString(prefix)
+	if clipStart {
+		result.WriteString("...")
+	}
+
+	offset := 0
+	for _, segment := range segments {
+		runes := []rune(segment.Text)
+		segStart, segEnd := offset, offset+len(runes)
+		offset = segEnd
+
+		from, to := max(segStart, start), min(segEnd, end)
+		if from >= to {
+			continue
+		}
+		text := string(runes[from-segStart : to-segStart])
+
+		if opts.Color && segment.Changed {
+			// Highlight the binding but not the whitespace that pads the grid
+			trimmed := strings.TrimRightFunc(text, unicode.IsSpace)
+			result.WriteString(colorReverse + trimmed + colorReset + baseColor)
+			result.WriteString(text[len(trimmed):])
+		} else {
+			result.WriteString(text)
+		}
+	}
+
+	if clipEnd {
+		result.WriteString("...")
+	}
+	if opts.Color {
+		result.WriteString(colorReset)
+	}
+
+	return result.String()
+}
@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"masters3d.com/keyboard_layout_config_mapper/internal/models"
+	"masters3d.com/keyboard_layout_config_mapper/internal/parsers"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+)
+
+var (
+	keyboardsListJSON bool
+
+	keyboardAddRepo           string
+	keyboardAddBranch         string
+	keyboardAddKeymap         string
+	keyboardAddPhysicalLayout string
+	keyboardAddDescription    string
+	keyboardAddRawURL         string
+	keyboardAddSource         string
+)
+
+// keyboardsCmd represents the keyboards command
+var keyboardsCmd = &cobra.Command{
+	Use:     "keyboards",
+	Aliases: []string{"keyboard"},
+	Short:   "Manage the keyboard registry",
+	Long: `Manage the keyboards klcm knows about.
+
+Every command reads keyboards from the registry: the built-in Advantage360,
+Glove80 and Pillz Mod boards, overridden or extended by the "keyboards"
+section of .klcm.yaml:
+
+  keyboards:
+    glove80:                      # override fields of a built-in keyboard
+      raw_url: https://raw.githubusercontent.com/me/glove80-zmk-config/main/config/glove80.keymap
+    corne:                        # add a new keyboard
+      type: zmk
+      description: Corne
+      local_path: configs/zmk_corne/corne.keymap
+      raw_url: https://raw.githubusercontent.com/me/zmk-config/main/config/corne.keymap
+      owner: me
+      repo: zmk-config
+      base_branch: main           # default: main
+      upstream_path: config/corne.keymap  # default: config/<keymap file name>
+      physical_layout: configs/zmk_corne/physical_layout.yaml  # built-in name or layout file; default: the keyboard name
+    adv360:
+      source: ../Adv360-Pro-ZMK#cheyo  # read upstream from elsewhere instead of raw_url
+
+A source is one of:
+  https://host/{owner}/{repo}/{branch}/{path}  HTTP URL template; {keyboard} is
+                                               also available
+  file:///srv/zmk-config#ref or a path         git repository (work tree or bare),
+                                               read at ref (default base_branch)
+  /srv/keymaps                                 plain directory holding upstream_path
+
+'klcm pr create' pushes its branch to a git source instead of GitHub, and
+'klcm keyboard add' writes new entries for you.`,
+}
+
+// keyboardsListCmd represents the keyboards list command
+var keyboardsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List registered keyboards",
+	Example: `  # Show every keyboard and where its keymap comes from
+  klcm keyboards list
+
+  # Machine-readable registry
+  klcm keyboards list --json`,
+	Args: cobra.NoArgs,
+	RunE: runKeyboardsList,
+}
+
+func runKeyboardsList(cmd *cobra.Command, args []string) error {
+	keyboards := registry.Current().All()
+	reportData(keyboards)
+
+	if keyboardsListJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(keyboards)
+	}
+
+	fmt.Printf("📋 Registered keyboards (%d):\n", len(keyboards))
+	for _, kb := range keyboards {
+		fmt.Printf("\n⌨️  %s - %s (%s)\n", kb.Name, kb.Description, kb.Type)
+		fmt.Printf("   📁 Local:    %s\n", kb.LocalPath)
+		if kb.Source != "" {
+			fmt.Printf("   🌐 Source:   %s\n", kb.Source)
+		} else if kb.RawURL != "" {
+			fmt.Printf("   🌐 Raw URL:  %s\n", kb.RawURL)
+		}
+		if kb.RepoURL() != "" {
+			fmt.Printf("   🔗 Upstream: %s (%s) %s\n", kb.RepoURL(), kb.BaseBranch, kb.UpstreamPath)
+		}
+		layout := kb.PhysicalLayout
+		if _, ok := parsers.GetPhysicalLayout(models.KeyboardType(kb.Name)); !ok {
+			layout += " (unknown, key positions are not checked)"
+		}
+		fmt.Printf("   📐 Layout:   %s\n", layout)
+	}
+	return nil
+}
+
+// keyboardsAddCmd represents the keyboards add command
+var keyboardsAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Register a new ZMK keyboard",
+	Long: `Register a new ZMK keyboard in the workspace config (--config, the config
+file in use, or ./.klcm.yaml) and scaffold its configs/zmk_<name>/ directory.
+
+The keymap is kept at configs/zmk_<name>/<keymap file name> and fetched from
+the --keymap path of --repo on --branch. A physical layout file, if given, is
+copied next to it; see 'klcm keyboards --help' for the registry fields.
+
+Physical layout files are YAML or JSON and list rows in binding order:
+
+  rows:
+    - {row: 1, left: 6, right: 6}
+    - {row: 2, left: 6, right: 6}
+    - {row: 3, left: 6, right: 6}
+    - {row: 5, left_thumbs: [L1, L2, L3], right_thumbs: [R3, R2, R1]}`,
+	Example: `  # Register a Corne and fetch its keymap
+  klcm keyboard add corne --repo me/zmk-config --keymap config/corne.keymap --physical-layout corne.yaml
+  klcm pull corne
+
+  # Then use it like any other keyboard
+  klcm validate --keyboard corne
+  klcm sync adv360 corne --preview`,
+	Args: cobra.ExactArgs(1),
+	RunE: runKeyboardsAdd,
+}
+
+var keyboardNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func runKeyboardsAdd(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if !keyboardNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid keyboard name %q (use lowercase letters, digits, '_' and '-')", name)
+	}
+	if _, ok := registry.Current().Get(name); ok {
+		return fmt.Errorf("keyboard %s is already registered", name)
+	}
+
+	owner, repo, err := parseRepoFlag(keyboardAddRepo)
+	if err != nil {
+		return err
+	}
+	upstreamPath := keyboardAddKeymap
+	if upstreamPath == "" {
+		upstreamPath = "config/" + name + ".keymap"
+	}
+	upstreamPath = strings.TrimPrefix(path.Clean(filepath.ToSlash(upstreamPath)), "/")
+
+	dir := path.Join("configs", "zmk_"+name)
+	kb := registry.Keyboard{
+		Name:         name,
+		Type:         "zmk",
+		Description:  keyboardAddDescription,
+		LocalPath:    path.Join(dir, path.Base(upstreamPath)),
+		RawURL:       keyboardAddRawURL,
+		Source:       keyboardAddSource,
+		Owner:        owner,
+		Repo:         repo,
+		BaseBranch:   keyboardAddBranch,
+		UpstreamPath: upstreamPath,
+	}
+	if kb.Description == "" {
+		kb.Description = name
+	}
+
+	if keyboardAddPhysicalLayout != "" {
+		if _, err := parsers.LoadPhysicalLayoutFile(keyboardAddPhysicalLayout); err != nil {
+			return err
+		}
+		kb.PhysicalLayout = path.Join(dir, "physical_layout"+strings.ToLower(filepath.Ext(keyboardAddPhysicalLayout)))
+	}
+
+	configFile := cfgFile
+	if configFile == "" {
+		configFile = viper.ConfigFileUsed()
+	}
+	if configFile == "" {
+		configFile = ".klcm.yaml"
+	}
+
+	// Scaffold configs/zmk_<name>/
+	if err := os.MkdirAll(filepath.FromSlash(dir), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	fmt.Printf("📁 Created %s/\n", dir)
+	if kb.PhysicalLayout != "" {
+		content, err := os.ReadFile(keyboardAddPhysicalLayout)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", keyboardAddPhysicalLayout, err)
+		}
+		if err := os.WriteFile(filepath.FromSlash(kb.PhysicalLayout), content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", kb.PhysicalLayout, err)
+		}
+		fmt.Printf("📐 Copied physical layout to %s\n", kb.PhysicalLayout)
+	}
+
+	if err := registry.AddToConfigFile(configFile, kb); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Registered %s in %s\n", name, configFile)
+	reportChanged(name, configFile, "registered keyboard")
+
+	fmt.Println("\n💡 Next steps:")
+	fmt.Printf("   klcm pull %s                  # fetch %s from %s/%s (%s)\n", name, upstreamPath, owner, repo, kb.BaseBranch)
+	fmt.Printf("   klcm validate --keyboard %s\n", name)
+	if kb.PhysicalLayout == "" {
+		fmt.Println("   ⚠️  No --physical-layout given: key positions and binding counts are not checked")
+	}
+	return nil
+}
+
+// parseRepoFlag accepts "owner/repo" or a GitHub repository URL
+func parseRepoFlag(value string) (string, string, error) {
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(value, "/"), ".git")
+	for _, prefix := range []string{"https://github.com/", "http://github.com/", "git@github.com:", "github.com/"} {
+		trimmed = strings.TrimPrefix(trimmed, prefix)
+	}
+	parts := strings.Split(trimmed, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid --repo %q (expected owner/repo)", value)
+	}
+	return parts[0], parts[1], nil
+}
+
+func init() {
+	rootCmd.AddCommand(keyboardsCmd)
+	keyboardsCmd.AddCommand(keyboardsListCmd)
+	keyboardsCmd.AddCommand(keyboardsAddCmd)
+
+	keyboardsListCmd.Flags().BoolVar(&keyboardsListJSON, "json", false, "print the registry as JSON")
+
+	keyboardsAddCmd.Flags().StringVar(&keyboardAddRepo, "repo", "", "upstream GitHub repository (owner/repo)")
+	keyboardsAddCmd.Flags().StringVar(&keyboardAddBranch, "branch", "main", "upstream branch to pull from and open PRs against")
+	keyboardsAddCmd.Flags().StringVar(&keyboardAddKeymap, "keymap", "", "keymap path inside the repository (default config/<name>.keymap)")
+	keyboardsAddCmd.Flags().StringVar(&keyboardAddPhysicalLayout, "physical-layout", "", "YAML or JSON physical layout file")
+	keyboardsAddCmd.Flags().StringVar(&keyboardAddDescription, "description", "", "human-readable board name (default the keyboard name)")
+	keyboardsAddCmd.Flags().StringVar(&keyboardAddRawURL, "raw-url", "", "fetch the keymap from this URL instead of raw.githubusercontent.com")
+	keyboardsAddCmd.Flags().StringVar(&keyboardAddSource, "source", "", "fetch the keymap from a URL template, git repository or directory (see klcm keyboards --help)")
+	keyboardsAddCmd.MarkFlagRequired("repo")
+}
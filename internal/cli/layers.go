@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/parsers"
+)
+
+var (
+	layersGraphFormat string
+	layersGraphFile   string
+)
+
+// layersCmd represents the layers command
+var layersCmd = &cobra.Command{
+	Use:   "layers",
+	Short: "Inspect keymap layers",
+	Long:  `Inspect how the layers of a keymap are activated.`,
+}
+
+// layersGraphCmd represents the layers graph command
+var layersGraphCmd = &cobra.Command{
+	Use:   "graph <keyboard>",
+	Short: "Export the layer-activation graph",
+	Long: `Export the layer-activation graph of a keymap as Graphviz DOT or Mermaid.
+
+Edges come from &mo, &lt, &to, &tog and &sl bindings, including those wrapped in
+hold-taps, tap-dances and macros, from combos and from conditional layers.
+Momentary edges are dashed and unreachable layers are greyed out.
+
+The keyboard can be a keyboard name, a keymap path, or either at a git
+revision (<name-or-path>@<rev>), as for 'klcm diff'.`,
+	Example: `  # Print the graph in DOT format
+  klcm layers graph adv360
+
+  # Render with Graphviz
+  klcm layers graph glove80 | dot -Tsvg > glove80-layers.svg
+
+  # Write a Mermaid flowchart
+  klcm layers graph adv_mod --format mermaid --file layers.mmd`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLayersGraph,
+}
+
+func runLayersGraph(cmd *cobra.Command, args []string) error {
+	spec, err := resolveKeymapSpec(args[0])
+	if err != nil {
+		return err
+	}
+	layout, err := spec.parse()
+	if err != nil {
+		return err
+	}
+
+	graph := parsers.BuildLayerGraph(layout)
+
+	var rendered string
+	switch layersGraphFormat {
+	case "dot":
+		rendered = graph.DOT()
+	case "mermaid":
+		rendered = graph.Mermaid()
+	default:
+		return fmt.Errorf("unsupported graph format %q (use dot or mermaid)", layersGraphFormat)
+	}
+
+	if layersGraphFile == "" {
+		fmt.Print(rendered)
+		return nil
+	}
+	if err := os.WriteFile(layersGraphFile, []byte(rendered), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", layersGraphFile, err)
+	}
+	fmt.Fprintf(os.Stderr, "📝 Layer graph written to %s\n", layersGraphFile)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(layersCmd)
+	layersCmd.AddCommand(layersGraphCmd)
+
+	layersGraphCmd.Flags().StringVar(&layersGraphFormat, "format", "dot", "graph format (dot, mermaid)")
+	layersGraphCmd.Flags().StringVarP(&layersGraphFile, "file", "f", "", "write the graph to this file instead of stdout")
+}
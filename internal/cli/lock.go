@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/history"
+	"masters3d.com/keyboard_layout_config_mapper/internal/lock"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+	"masters3d.com/keyboard_layout_config_mapper/internal/remote"
+)
+
+// lockKeyboard records in klcm.lock the upstream revision a keyboard's local
+// keymap now matches
+func lockKeyboard(keyboard, content string) error {
+	memo := fetchedRemote(keyboard)
+	file, err := lock.Load(lock.DefaultPath)
+	if err != nil {
+		return err
+	}
+
+	entry := lock.Entry{
+		Source:   memo.source,
+		Ref:      memo.ref,
+		Commit:   memo.revision,
+		SHA256:   lock.Hash([]byte(content)),
+		PulledAt: time.Now().UTC(),
+	}
+	if old, ok := file.Keyboards[keyboard]; ok && old.Source == entry.Source && old.Commit == entry.Commit && old.SHA256 == entry.SHA256 {
+		return nil
+	}
+	file.Keyboards[keyboard] = entry
+	if err := file.Save(lock.DefaultPath); err != nil {
+		return err
+	}
+
+	if entry.Commit != "" {
+		fmt.Printf("  🔒 Locked %s at %s\n", keyboard, shortCommit(entry.Commit))
+	} else if memo.unpinned != nil {
+		fmt.Printf("  🔒 Locked %s by content hash only: %v\n", keyboard, memo.unpinned)
+	} else if verbose {
+		fmt.Printf("  🔒 Locked %s by content hash (its source has no commits)\n", keyboard)
+	}
+	return nil
+}
+
+// pinToLock makes the following fetches read each keyboard at its locked
+// commit, and returns the lock entries to verify the content against
+func pinToLock(keyboards []string) (map[string]lock.Entry, error) {
+	file, err := lock.Load(lock.DefaultPath)
+	if err != nil {
+		return nil, err
+	}
+	pinnedCommits = make(map[string]string)
+	for _, keyboard := range keyboards {
+		if entry, ok := file.Keyboards[keyboard]; ok {
+			pinnedCommits[keyboard] = entry.Commit
+		}
+	}
+	return file.Keyboards, nil
+}
+
+// verifyLocked checks fetched content against its lock entry
+func verifyLocked(keyboard, content string, entries map[string]lock.Entry) error {
+	entry, ok := entries[keyboard]
+	if !ok {
+		return fmt.Errorf("%s is not in %s; run 'klcm pull %s' without --locked first", keyboard, lock.DefaultPath, keyboard)
+	}
+	if hash := lock.Hash([]byte(content)); hash != entry.SHA256 {
+		if entry.Commit == "" {
+			return fmt.Errorf("%s changed upstream since it was locked, and its source has no commits to go back to (locked %s, got %s)", keyboard, history.Short(entry.SHA256), history.Short(hash))
+		}
+		return fmt.Errorf("%s at %s does not match %s (locked %s, got %s)", keyboard, shortCommit(entry.Commit), lock.DefaultPath, history.Short(entry.SHA256), history.Short(hash))
+	}
+	return nil
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 10 {
+		return commit[:10]
+	}
+	return commit
+}
+
+// outdatedCmd represents the outdated command
+var outdatedCmd = &cobra.Command{
+	Use:   "outdated [keyboard...]",
+	Short: "Show keyboards whose upstream branch moved past klcm.lock",
+	Long: `Compare the commits recorded in klcm.lock with the commits the upstream
+branches point to now. Keyboards whose source has no commits (plain
+directories, non-GitHub URLs) are compared by content instead.
+
+Exits with status 2 if any keyboard is outdated.`,
+	Example: `  # Check every locked keyboard
+  klcm outdated
+
+  # Update the outdated ones
+  klcm pull glove80`,
+	RunE: runOutdated,
+}
+
+func runOutdated(cmd *cobra.Command, args []string) error {
+	file, err := lock.Load(lock.DefaultPath)
+	if err != nil {
+		return err
+	}
+	keyboards, err := registry.Current().Select(args)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🔍 Checking upstream revisions against %s...\n\n", lock.DefaultPath)
+	outdated := 0
+	errs := make(keyboardErrors)
+	for _, kb := range keyboards {
+		entry, ok := file.Keyboards[kb.Name]
+		if !ok {
+			fmt.Printf("  ❔ %-10s not locked (run 'klcm pull %s')\n", kb.Name, kb.Name)
+			reportSkipped(kb.Name, kb.LocalPath, "not in "+lock.DefaultPath)
+			continue
+		}
+
+		latest, moved, err := latestRevision(kb, entry)
+		if err != nil {
+			fmt.Printf("  ❌ %-10s %v\n", kb.Name, err)
+			errs[kb.Name] = err
+			continue
+		}
+		ref := entry.Ref
+		if ref == "" {
+			ref = "content"
+		}
+		if !moved {
+			fmt.Printf("  ✅ %-10s %s is at %s\n", kb.Name, ref, latest)
+			continue
+		}
+		outdated++
+		fmt.Printf("  ⬆️  %-10s %s moved: %s → %s\n", kb.Name, ref, lockedRevision(entry), latest)
+		reportDrift(kb.Name, kb.LocalPath, fmt.Sprintf("%s moved from %s to %s", ref, lockedRevision(entry), latest))
+	}
+
+	if err := errs.summary(cmd, len(keyboards)); err != nil {
+		return err
+	}
+	if outdated == 0 {
+		fmt.Println("\n✅ Every locked keyboard is up to date")
+		return nil
+	}
+	fmt.Printf("\n💡 %d keyboard(s) outdated; run 'klcm pull' to update them and the lock\n", outdated)
+	return nil
+}
+
+// latestRevision returns the current upstream revision of a keyboard, as a
+// short commit or content hash, and whether it differs from the lock
+func latestRevision(kb registry.Keyboard, entry lock.Entry) (string, bool, error) {
+	source, err := remote.ForKeyboard(kb, remoteOptions())
+	if err != nil {
+		return "", false, err
+	}
+	if source.String() != entry.Source {
+		return "", false, fmt.Errorf("source changed from %s to %s since it was locked; run 'klcm pull %s'", entry.Source, source, kb.Name)
+	}
+
+	if revisioned, ok := source.(remote.Revisioned); ok && entry.Commit != "" {
+		commit, err := revisioned.Revision(commandContext())
+		if err != nil {
+			return "", false, err
+		}
+		return shortCommit(commit), commit != entry.Commit, nil
+	}
+
+	content, err := source.Fetch(commandContext())
+	if err != nil {
+		return "", false, err
+	}
+	hash := lock.Hash(content.Data)
+	return history.Short(hash), hash != entry.SHA256, nil
+}
+
+func lockedRevision(entry lock.Entry) string {
+	if entry.Commit != "" {
+		return shortCommit(entry.Commit)
+	}
+	return history.Short(entry.SHA256)
+}
+
+func init() {
+	rootCmd.AddCommand(outdatedCmd)
+}
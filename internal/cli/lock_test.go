@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/lock"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+	"masters3d.com/keyboard_layout_config_mapper/internal/remote"
+)
+
+// lockWorkspace is a temporary klcm workspace whose glove80 keymap is pulled
+// from a local git repository
+type lockWorkspace struct {
+	upstream string
+	kb       registry.Keyboard
+}
+
+func newLockWorkspace(t *testing.T) *lockWorkspace {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	ws := &lockWorkspace{upstream: t.TempDir()}
+	chdirTemp(t)
+	captureResult(t)
+	t.Setenv("KLCM_CACHE_DIR", t.TempDir())
+
+	ws.kb = registry.Keyboard{
+		Name:         "glove80",
+		Type:         "zmk",
+		LocalPath:    testLocalPath,
+		Source:       ws.upstream,
+		BaseBranch:   "main",
+		UpstreamPath: testRemotePath,
+	}
+	previousRegistry := registry.Current()
+	registry.SetCurrent(registry.New(ws.kb))
+	assumeYes = true
+	t.Cleanup(func() {
+		registry.SetCurrent(previousRegistry)
+		assumeYes, pullLocked, pullLockEntries = false, false, nil
+		recordRevisions, pinnedCommits = false, nil
+		fetched = make(map[string]fetchResult)
+	})
+
+	ws.git(t, "init", "-q", "-b", "main")
+	return ws
+}
+
+func (ws *lockWorkspace) git(t *testing.T, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", ws.upstream, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// commit moves upstream main to a new commit of the keymap and returns it
+func (ws *lockWorkspace) commit(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(ws.upstream, filepath.FromSlash(testRemotePath))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ws.git(t, "add", "-A")
+	ws.git(t, "commit", "-q", "-m", "Update keymap")
+	return ws.git(t, "rev-parse", "HEAD")
+}
+
+// pull runs klcm pull glove80 as a new invocation would
+func (ws *lockWorkspace) pull(t *testing.T, locked bool) error {
+	t.Helper()
+	fetched = make(map[string]fetchResult)
+	recordRevisions, pinnedCommits = false, nil
+	pullLocked = locked
+	return runPull(pullCmd, []string{"glove80"})
+}
+
+func (ws *lockWorkspace) lockEntry(t *testing.T) lock.Entry {
+	t.Helper()
+	file, err := lock.Load(lock.DefaultPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := file.Keyboards["glove80"]
+	if !ok {
+		t.Fatalf("glove80 is not in %s", lock.DefaultPath)
+	}
+	return entry
+}
+
+func TestPullRecordsLock(t *testing.T) {
+	ws := newLockWorkspace(t)
+	commit := ws.commit(t, testKeymap("&kp A"))
+
+	if err := ws.pull(t, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := localKeymap(t); got != testKeymap("&kp A") {
+		t.Errorf("keymap = %q, want upstream's", got)
+	}
+	entry := ws.lockEntry(t)
+	source := (&remote.GitSource{Repo: ws.upstream, Ref: "main", Path: testRemotePath}).String()
+	if entry.Source != source || entry.Ref != "main" || entry.Commit != commit || entry.SHA256 != lock.Hash([]byte(testKeymap("&kp A"))) {
+		t.Errorf("lock entry = %+v, want main of %s at %s with the keymap's hash", entry, source, commit)
+	}
+}
+
+func TestPullLockedReadsLockedCommit(t *testing.T) {
+	ws := newLockWorkspace(t)
+	locked := ws.commit(t, testKeymap("&kp A"))
+	if err := ws.pull(t, false); err != nil {
+		t.Fatal(err)
+	}
+	ws.commit(t, testKeymap("&kp B"))
+	if err := os.WriteFile(testLocalPath, []byte(testKeymap("&kp C")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ws.pull(t, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := localKeymap(t); got != testKeymap("&kp A") {
+		t.Errorf("keymap after pull --locked = %q, want the locked commit's, not the branch head's", got)
+	}
+	if entry := ws.lockEntry(t); entry.Commit != locked {
+		t.Errorf("locked commit = %s, want %s kept", entry.Commit, locked)
+	}
+}
+
+func TestPullLockedHashMismatch(t *testing.T) {
+	ws := newLockWorkspace(t)
+	ws.commit(t, testKeymap("&kp A"))
+	if err := ws.pull(t, false); err != nil {
+		t.Fatal(err)
+	}
+	file, err := lock.Load(lock.DefaultPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := file.Keyboards["glove80"]
+	entry.SHA256 = lock.Hash([]byte("something else"))
+	file.Keyboards["glove80"] = entry
+	if err := file.Save(lock.DefaultPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(testLocalPath, []byte(testKeymap("&kp C")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ws.pull(t, true); err == nil {
+		t.Fatal("pull --locked with a mismatched hash succeeded")
+	}
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "does not match "+lock.DefaultPath) {
+		t.Errorf("errors = %+v, want the hash mismatch", result.Errors)
+	}
+	if got := localKeymap(t); got != testKeymap("&kp C") {
+		t.Errorf("keymap = %q, want it untouched", got)
+	}
+}
+
+func TestVerifyLocked(t *testing.T) {
+	hash := lock.Hash([]byte("keymap"))
+	entries := map[string]lock.Entry{
+		"glove80": {Commit: strings.Repeat("a", 40), SHA256: hash},
+		"adv360":  {SHA256: hash},
+	}
+	tests := []struct {
+		keyboard string
+		content  string
+		want     string
+	}{
+		{keyboard: "glove80", content: "keymap"},
+		{keyboard: "glove80", content: "changed", want: "does not match"},
+		{keyboard: "adv360", content: "changed", want: "no commits to go back to"},
+		{keyboard: "corne", content: "keymap", want: "is not in " + lock.DefaultPath},
+	}
+	for _, tt := range tests {
+		err := verifyLocked(tt.keyboard, tt.content, entries)
+		if tt.want == "" && err != nil {
+			t.Errorf("verifyLocked(%s, %q): %v", tt.keyboard, tt.content, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("verifyLocked(%s, %q) error = %v, want %q", tt.keyboard, tt.content, err, tt.want)
+		}
+	}
+}
+
+func TestOutdated(t *testing.T) {
+	ws := newLockWorkspace(t)
+	locked := ws.commit(t, testKeymap("&kp A"))
+	if err := ws.pull(t, false); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, moved, err := latestRevision(ws.kb, ws.lockEntry(t))
+	if err != nil || moved || latest != shortCommit(locked) {
+		t.Errorf("latestRevision before the branch moved = %s, %v, %v; want %s unmoved", latest, moved, err, shortCommit(locked))
+	}
+
+	head := ws.commit(t, testKeymap("&kp B"))
+	if latest, moved, err = latestRevision(ws.kb, ws.lockEntry(t)); err != nil || !moved || latest != shortCommit(head) {
+		t.Errorf("latestRevision after the branch moved = %s, %v, %v; want %s moved", latest, moved, err, shortCommit(head))
+	}
+	if err := runOutdated(outdatedCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Drift) != 1 || result.Drift[0].Keyboard != "glove80" || !strings.Contains(result.Drift[0].Message, shortCommit(head)) {
+		t.Errorf("drift = %+v, want glove80 moved to %s", result.Drift, shortCommit(head))
+	}
+
+	// A keyboard pulled from a different source than it was locked from
+	ws.kb.Source = ws.upstream + "#v2"
+	if _, _, err := latestRevision(ws.kb, ws.lockEntry(t)); err == nil || !strings.Contains(err.Error(), "source changed") {
+		t.Errorf("latestRevision with a new source error = %v, want source changed", err)
+	}
+}
@@ -0,0 +1,215 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"masters3d.com/keyboard_layout_config_mapper/internal/remote"
+)
+
+// Output modes of --output
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// Exit codes shared by every command
+const (
+	exitClean    = 0 // nothing to report
+	exitError    = 1 // something failed
+	exitDrift    = 2 // differences were found and left in place
+	exitWarnings = 3 // validation found warnings but no errors
+)
+
+var (
+	assumeYes  bool
+	noInput    bool
+	outputMode string
+
+	// jsonOut is where the --output json result goes; while it is set,
+	// os.Stdout points at stderr so human-readable output stays out of it
+	jsonOut io.Writer
+
+	stdin = bufio.NewReader(os.Stdin)
+)
+
+// Result is the machine-readable outcome of a command, printed with
+// --output json
+type Result struct {
+	Command  string       `json:"command"`
+	Status   string       `json:"status"` // "clean", "drift", "warnings" or "error"
+	ExitCode int          `json:"exit_code"`
+	Changed  []ResultItem `json:"changed"`
+	Drift    []ResultItem `json:"drift"` // differences found and left in place
+	Skipped  []ResultItem `json:"skipped"`
+	Errors   []ResultItem `json:"errors"`
+	Data     any          `json:"data,omitempty"` // command-specific details
+}
+
+// ResultItem is one file or keyboard in a Result
+type ResultItem struct {
+	Keyboard string `json:"keyboard,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Message  string `json:"message"`
+}
+
+var result = newResult()
+
+func newResult() *Result {
+	return &Result{Changed: []ResultItem{}, Drift: []ResultItem{}, Skipped: []ResultItem{}, Errors: []ResultItem{}}
+}
+
+// reportChanged records a file the command changed
+func reportChanged(keyboard, path, message string) {
+	result.Changed = append(result.Changed, ResultItem{Keyboard: keyboard, Path: path, Message: message})
+}
+
+// reportDrift records differences the command found but did not apply; the
+// command then exits 2 unless something failed
+func reportDrift(keyboard, path, message string) {
+	result.Drift = append(result.Drift, ResultItem{Keyboard: keyboard, Path: path, Message: message})
+}
+
+// reportSkipped records work the command deliberately did not do
+func reportSkipped(keyboard, path, message string) {
+	result.Skipped = append(result.Skipped, ResultItem{Keyboard: keyboard, Path: path, Message: message})
+}
+
+// reportError records a failure for one keyboard
+func reportError(keyboard string, err error) {
+	result.Errors = append(result.Errors, ResultItem{Keyboard: keyboard, Message: remote.Redact(err.Error())})
+}
+
+// reportData attaches command-specific details to the result
+func reportData(data any) {
+	result.Data = data
+}
+
+// initOutput applies --output once flags are parsed. An unknown format is an
+// error rather than a fallback to text, so scripts expecting JSON notice.
+func initOutput(cmd *cobra.Command, args []string) error {
+	switch outputMode {
+	case OutputText:
+	case OutputJSON:
+		jsonOut = os.Stdout
+		os.Stdout = os.Stderr
+	default:
+		return fmt.Errorf("unknown --output %q: use %s or %s", outputMode, OutputText, OutputJSON)
+	}
+	return nil
+}
+
+// finishCommand settles the exit code from the command's error and the drift
+// it reported, and prints the result for --output json
+func finishCommand(err error) error {
+	code := exitClean
+	var exitErr *ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		code = exitErr.Code
+	case err != nil:
+		code = exitError
+	case len(result.Drift) > 0:
+		code = exitDrift
+		err = &ExitError{Code: exitDrift}
+	}
+
+	if jsonOut == nil {
+		return err
+	}
+	if cmd, _, findErr := rootCmd.Find(os.Args[1:]); findErr == nil {
+		result.Command = strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name()+" ")
+	}
+	result.ExitCode = code
+	switch code {
+	case exitClean:
+		result.Status = "clean"
+	case exitDrift:
+		result.Status = "drift"
+	case exitWarnings:
+		result.Status = "warnings"
+	default:
+		result.Status = "error"
+		if len(result.Errors) == 0 && err != nil && (exitErr == nil || exitErr.Err != nil) {
+			reportError("", err)
+		}
+	}
+	encoder := json.NewEncoder(jsonOut)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if encodeErr := encoder.Encode(result); encodeErr != nil && err == nil {
+		return encodeErr
+	}
+	return err
+}
+
+// confirm asks a yes/no question. --yes answers yes and --no-input answers
+// no without reading stdin; otherwise an empty answer takes defaultYes.
+func confirm(prompt string, defaultYes bool) bool {
+	fmt.Print(prompt)
+	switch {
+	case assumeYes:
+		fmt.Println("yes (--yes)")
+		return true
+	case noInput:
+		fmt.Println("no (--no-input)")
+		return false
+	}
+	response, _ := stdin.ReadString('\n')
+	response = strings.ToLower(strings.TrimSpace(response))
+	if response == "" {
+		return defaultYes
+	}
+	return response == "y" || response == "yes"
+}
+
+// waitForEnter pauses until the user presses Enter, unless input is off
+func waitForEnter(prompt string) {
+	fmt.Println(prompt)
+	if assumeYes || noInput {
+		return
+	}
+	stdin.ReadString('\n')
+}
+
+// chooseNumber asks for a number from 1 to max; ok is false without input
+func chooseNumber(prompt string, max int) (int, bool) {
+	fmt.Printf("%s (1-%d): ", prompt, max)
+	if noInput {
+		fmt.Println("(--no-input)")
+		return 0, false
+	}
+	choice, _ := stdin.ReadString('\n')
+	n, err := strconv.Atoi(strings.TrimSpace(choice))
+	if err != nil || n < 1 || n > max {
+		return 0, false
+	}
+	return n, true
+}
+
+// childArgs forwards the global flags given to this klcm to a klcm run as a
+// subprocess. --output is not forwarded: the child's output is for humans.
+func childArgs(args ...string) []string {
+	rootCmd.PersistentFlags().Visit(func(flag *pflag.Flag) {
+		if flag.Name != "output" {
+			args = append(args, "--"+flag.Name+"="+flag.Value.String())
+		}
+	})
+	return args
+}
+
+// klcmExecutable returns the path of the running klcm binary
+func klcmExecutable() string {
+	if path, err := os.Executable(); err == nil {
+		return path
+	}
+	return "./klcm"
+}
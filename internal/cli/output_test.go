@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureResult points the --output json result at a buffer for one test
+func captureResult(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previousOut, previousResult := jsonOut, result
+	jsonOut, result = &buf, newResult()
+	t.Cleanup(func() { jsonOut, result = previousOut, previousResult })
+	return &buf
+}
+
+// chdirTemp runs the rest of a test in a new temporary directory
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	previousDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previousDir) })
+	return dir
+}
+
+func TestFinishCommandStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		drift  bool
+		code   int
+		status string
+	}{
+		{name: "clean", code: exitClean, status: "clean"},
+		{name: "error", err: errors.New("boom"), code: exitError, status: "error"},
+		{name: "drift", drift: true, code: exitDrift, status: "drift"},
+		{name: "validate warnings", err: &ExitError{Code: exitWarnings}, code: exitWarnings, status: "warnings"},
+		{name: "validate errors", err: &ExitError{Code: exitError}, code: exitError, status: "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := captureResult(t)
+			if tt.drift {
+				reportDrift("glove80", "glove80.keymap", "differs")
+			}
+
+			err := finishCommand(tt.err)
+			var exitErr *ExitError
+			switch {
+			case tt.code == exitClean && err != nil:
+				t.Errorf("finishCommand() = %v, want nil", err)
+			case tt.code != exitClean && tt.err == nil && (!errors.As(err, &exitErr) || exitErr.Code != tt.code):
+				t.Errorf("finishCommand() = %v, want exit %d", err, tt.code)
+			}
+
+			var got Result
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("result is not JSON: %v\n%s", err, buf)
+			}
+			if got.ExitCode != tt.code || got.Status != tt.status {
+				t.Errorf("result = %d/%q, want %d/%q", got.ExitCode, got.Status, tt.code, tt.status)
+			}
+		})
+	}
+}
+
+func TestInitOutputRejectsUnknownFormat(t *testing.T) {
+	previousMode, previousOut, previousStdout := outputMode, jsonOut, os.Stdout
+	t.Cleanup(func() { outputMode, jsonOut, os.Stdout = previousMode, previousOut, previousStdout })
+
+	outputMode = "jsn"
+	if err := initOutput(rootCmd, nil); err == nil || !strings.Contains(err.Error(), `unknown --output "jsn"`) {
+		t.Errorf("initOutput(jsn) = %v, want an unknown format error", err)
+	}
+	if jsonOut != previousOut || os.Stdout != previousStdout {
+		t.Error("an unknown format changed where output goes")
+	}
+}
@@ -1,15 +1,23 @@
 package cli
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/changeset"
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+	"masters3d.com/keyboard_layout_config_mapper/internal/remote"
 )
 
 var (
@@ -18,57 +26,78 @@ var (
 	prBranch  string
 	prApply   bool
 	prForce   bool
+	prNew     bool
 )
 
-// GitHub repository configuration
+// errUpstreamMatches is returned by pr create for a keyboard whose upstream
+// keymap is already the local one, so there is nothing to propose
+var errUpstreamMatches = errors.New("upstream already matches the local keymap")
+
+// Upstream repository configuration
 type RepoConfig struct {
-	Name        string
-	Owner       string
-	Repo        string // GitHub repo name (e.g., "Adv360-Pro-ZMK")
-	BaseBranch  string // Target branch for PRs (e.g., "cheyo", "main")
-	LocalPath   string
-	RemoteURL   string
-	DefaultFile string
+	Name         string
+	Forge        string // registry.ForgeGitHub, ...; "" when branches are pushed with git instead
+	ForgeURL     string // web root of the forge (e.g., "https://gitlab.com")
+	Owner        string
+	Repo         string // repo name on the forge (e.g., "Adv360-Pro-ZMK")
+	BaseBranch   string // Target branch for PRs (e.g., "cheyo", "main")
+	LocalPath    string
+	RemoteURL    string
+	DefaultFile  string
+	UpstreamPath string // keymap path inside the repo (e.g., "config/adv360.keymap")
 }
 
-var repoConfigs = []RepoConfig{
-	{
-		Name:        "adv360",
-		Owner:       "masters3d",
-		Repo:        "Adv360-Pro-ZMK",
-		BaseBranch:  "cheyo",
-		LocalPath:   "configs/zmk_adv360",
-		RemoteURL:   "https://github.com/masters3d/Adv360-Pro-ZMK",
-		DefaultFile: "adv360.keymap",
-	},
-	{
-		Name:        "glove80", 
-		Owner:       "masters3d",
-		Repo:        "glove80-zmk-config",
-		BaseBranch:  "cheyo",
-		LocalPath:   "configs/zmk_glove80",
-		RemoteURL:   "https://github.com/masters3d/glove80-zmk-config",
-		DefaultFile: "glove80.keymap",
-	},
-	{
-		Name:        "adv_mod",
-		Owner:       "masters3d",
-		Repo:        "zmk-config-pillzmod-nicenano",
-		BaseBranch:  "cheyo",
-		LocalPath:   "configs/zmk_adv_mod",
-		RemoteURL:   "https://github.com/masters3d/zmk-config-pillzmod-nicenano",
-		DefaultFile: "pillzmod_pro.keymap",
-	},
+// repoConfigs returns the upstream repository of every registered keyboard
+// that has one
+func repoConfigs() []RepoConfig {
+	var repos []RepoConfig
+	for _, kb := range registry.Current().All() {
+		remoteURL, forgeKind := kb.RepoURL(), kb.ForgeKind()
+		// A git source (e.g. a local clone standing in for upstream) receives
+		// the PR branch instead of the forge
+		if kb.Source != "" {
+			if source, err := remote.ForKeyboard(kb, remote.Options{}); err == nil {
+				if repo, ok := source.(remote.Repository); ok {
+					remoteURL, forgeKind = repo.RepositoryURL(), ""
+				}
+			}
+		}
+		if remoteURL == "" {
+			continue
+		}
+		repos = append(repos, RepoConfig{
+			Name:         kb.Name,
+			Forge:        forgeKind,
+			ForgeURL:     kb.ForgeWebURL(),
+			Owner:        kb.Owner,
+			Repo:         kb.Repo,
+			BaseBranch:   kb.BaseBranch,
+			LocalPath:    filepath.ToSlash(kb.Dir()),
+			RemoteURL:    remoteURL,
+			DefaultFile:  kb.Filename(),
+			UpstreamPath: kb.UpstreamPath,
+		})
+	}
+	return repos
+}
+
+// forgeRepo names the repository on its forge
+func (r RepoConfig) forgeRepo() forge.Repo {
+	return forge.Repo{Owner: r.Owner, Name: r.Repo}
 }
 
 // prCmd represents the pr command
 var prCmd = &cobra.Command{
 	Use:   "pr",
-	Short: "Manage GitHub pull requests for keyboard configurations",
-	Long: `Create and manage GitHub pull requests for keyboard configuration changes.
+	Short: "Manage pull requests for keyboard configurations",
+	Long: `Create and manage pull requests for keyboard configuration changes.
 
 Supports creating PRs to upstream repositories when you've made changes
-to keyboard configurations that should be shared back to the community.`,
+to keyboard configurations that should be shared back to the community.
+Repositories are on GitHub unless a keyboard sets forge: gitlab (merge
+requests on gitlab.com or forge_url) or forge: gitea (a self-hosted Gitea or
+Forgejo at forge_url). GitLab and Gitea tokens come from GITLAB_TOKEN or
+gitlab.token, and GITEA_TOKEN or gitea.token, in .klcm.yaml.`,
 }
 
 // prCreateCmd represents the pr create command
@@ -81,7 +110,28 @@ This will:
 1. Detect which configurations have changed
 2. Create appropriate branches 
 3. Commit changes with descriptive messages
-4. Create pull requests to upstream repositories`,
+4. Create pull requests to upstream repositories
+
+Titles and bodies are generated from a semantic diff of the local keymap
+against the base branch: the layers touched, before/after tables per logical
+key, behavior, combo and macro changes, and the 'klcm validate' findings.
+--layer-graph also embeds the layer-activation graph as a Mermaid diagram,
+which GitHub, GitLab and Gitea render as an image.
+
+--change-set "<title>" opens the PRs as one change set: they share an ID
+(cs-<date>-<time>), each body links the others, and the set is recorded in
+.klcm/changesets.json so 'klcm pr status' can report it as a whole. Passing
+the ID of a recorded set instead of a title adds the PRs to that set, e.g.
+after fixing a repository that failed the first time. Until then the set is
+recorded as incomplete: each body and 'klcm pr status' name the keyboards
+whose PR could not be opened. Keyboards already in the set whose upstream
+matches the local keymap, e.g. because their PR was merged, are skipped.
+
+The repositories are worked on concurrently, --jobs at a time, and a summary
+lists the outcome for each. A branch pushed for a pull request that then
+cannot be opened is deleted again. Keyboards whose source is a local git
+repository get a branch pushed there instead, built without a clone or
+checkout.`,
 	Example: `  # Create PRs for all changed configurations
   klcm pr create --all
 
@@ -89,13 +139,16 @@ This will:
   klcm pr create --dry-run
 
   # Create PR with custom branch name
-  klcm pr create --branch feature/my-layout-updates`,
+  klcm pr create --branch feature/my-layout-updates
+
+  # Open linked PRs on every changed keyboard's repository
+  klcm pr create --apply --change-set "Move Escape to R5"`,
 	RunE: runPRCreate,
 }
 
 func runPRCreate(cmd *cobra.Command, args []string) error {
 	if verbose {
-		fmt.Println("🚀 Creating GitHub pull requests...")
+		fmt.Println("🚀 Creating pull requests...")
 	}
 
 	var hasChanges bool
@@ -105,7 +158,7 @@ func runPRCreate(cmd *cobra.Command, args []string) error {
 	// If force flag is set, assume all repos have changes
 	if prForce {
 		fmt.Println("🔧 Force mode: Creating PRs for all configured keyboards...")
-		changedRepos = repoConfigs
+		changedRepos = repoConfigs()
 		hasChanges = true
 	} else {
 		// Check for git changes (uncommitted or recent commits)
@@ -138,7 +191,7 @@ func runPRCreate(cmd *cobra.Command, args []string) error {
 		return simulatePRCreation(changedRepos)
 	}
 
-	return createActualPRs(changedRepos)
+	return createActualPRs(cmd, changedRepos)
 }
 
 func detectChanges() (bool, []RepoConfig, error) {
@@ -221,7 +274,7 @@ func detectChanges() (bool, []RepoConfig, error) {
 	}
 
 	// Match changed paths to repo configs
-	for _, repo := range repoConfigs {
+	for _, repo := range repoConfigs() {
 		for path := range changedPaths {
 			if strings.HasPrefix(path, repo.LocalPath) {
 				changedRepos = append(changedRepos, repo)
@@ -251,15 +304,35 @@ func simulatePRCreation(repos []RepoConfig) error {
 		if err != nil {
 			changeSummary = "Unable to determine changes"
 		}
+		desc := previewPRDescription(repo, branchName)
+		existing := previewExistingPR(repo)
 
 		fmt.Printf("   %d. 📁 %s -> %s/%s\n", i+1, repo.LocalPath, repo.Owner, getRepoName(repo.RemoteURL))
-		fmt.Printf("      🌿 Branch: %s\n", branchName)
-		fmt.Printf("      📝 Title: Update %s keyboard layout configuration\n", repo.Name)
+		if existing != nil {
+			fmt.Printf("      🔁 Updates: #%d %s (branch %s)\n", existing.Number, existing.HTMLURL, existing.Head.Ref)
+		} else {
+			fmt.Printf("      🌿 Branch: %s\n", branchName)
+		}
+		fmt.Printf("      📝 Title: %s\n", desc.Title)
 		fmt.Printf("      📊 Changes: %s\n", changeSummary)
+		if desc.Semantic != nil {
+			fmt.Printf("      ⌨️  Keymap: %s\n", desc.Semantic.Summary())
+		}
+		if verbose {
+			fmt.Println()
+			for _, line := range strings.Split(strings.TrimRight(desc.Body, "\n"), "\n") {
+				fmt.Printf("      │ %s\n", line)
+			}
+		}
 		fmt.Printf("      🔗 Remote: %s\n", repo.RemoteURL)
 		fmt.Println()
 	}
 
+	if prChangeSet != "" {
+		fmt.Printf("🔗 Change set: %s; each PR body will link the others\n", prChangeSet)
+		fmt.Println()
+	}
+
 	if !prApply {
 		fmt.Println("💡 Use --apply to create these PRs")
 		fmt.Println("🔍 Use --dry-run to see this simulation again")
@@ -268,20 +341,75 @@ func simulatePRCreation(repos []RepoConfig) error {
 	return nil
 }
 
-func createActualPRs(repos []RepoConfig) error {
-	fmt.Println("🚀 Creating actual pull requests...")
-	fmt.Println()
+// previewPRDescription describes the PR for a dry run, comparing with the
+// keyboard's remote version instead of reading the base branch through the
+// API, so no token is needed
+func previewPRDescription(repo RepoConfig, branchName string) prDescription {
+	local, err := os.ReadFile(filepath.Join(filepath.FromSlash(repo.LocalPath), repo.DefaultFile))
+	if err != nil {
+		return prDescription{Title: fmt.Sprintf("Update %s keyboard layout configuration", repo.Name)}
+	}
+	base, err := fetchKeyboardRemote(repo.Name)
+	if err != nil {
+		if verbose {
+			fmt.Printf("      ⚠️  Could not fetch the upstream keymap to describe the PR: %v\n", err)
+		}
+		return prDescription{Title: fmt.Sprintf("Update %s keyboard layout configuration", repo.Name)}
+	}
+	return describePR(repo, []byte(base), local, branchName)
+}
 
-	// Check if GitHub CLI is available
-	if !isGitHubCLIAvailable() {
-		return fmt.Errorf("GitHub CLI (gh) is required but not found. Please install it: https://cli.github.com/")
+// previewExistingPR returns the open klcm PR pr create --apply would update,
+// when a token is available to look it up
+func previewExistingPR(repo RepoConfig) *forge.PullRequest {
+	if prNew || repo.Forge == "" {
+		return nil
+	}
+	if token, _ := forgeToken(repo.Forge); token == "" {
+		return nil
+	}
+	client, err := newForge(repo.Forge, repo.ForgeURL)
+	if err != nil {
+		return nil
+	}
+	open, err := openKLCMPullRequests(commandContext(), client, repo)
+	if err != nil || len(open) == 0 {
+		return nil
+	}
+	return &open[0]
+}
+
+func createActualPRs(cmd *cobra.Command, repos []RepoConfig) error {
+	needsForge := false
+	for _, repo := range repos {
+		needsForge = needsForge || repo.Forge != ""
 	}
 
-	// Check if user is authenticated
-	if !isGitHubAuthenticated() {
-		fmt.Println("🔑 GitHub authentication required")
-		fmt.Println("Please run: gh auth login")
-		return fmt.Errorf("not authenticated with GitHub")
+	var changeSets *changeset.File
+	var set *changeset.Set
+	if prChangeSet != "" {
+		var err error
+		if changeSets, set, err = startChangeSet(prChangeSet); err != nil {
+			return err
+		}
+		if !needsForge {
+			return fmt.Errorf("--change-set links pull requests, and none of the selected keyboards has a repository on a forge")
+		}
+	}
+
+	clients := make(forges)
+	tasks := make([]*prTask, len(repos))
+	for i, repo := range repos {
+		tasks[i] = &prTask{repo: repo}
+		if repo.Forge == "" {
+			continue
+		}
+		client, err := clients.forRepo(repo)
+		if err != nil {
+			fmt.Printf("🔑 %s authentication required\n", forgeNames[repo.Forge])
+			return err
+		}
+		tasks[i].client = client
 	}
 
 	timestamp := time.Now().Format("20060102-150405")
@@ -289,159 +417,247 @@ func createActualPRs(repos []RepoConfig) error {
 	if prBranch != "" {
 		branchPrefix = prBranch
 	}
+	branchName := fmt.Sprintf("%s-%s", branchPrefix, timestamp)
 
-	createdPRs := make([]string, 0)
-
-	for i, repo := range repos {
-		fmt.Printf("📁 Processing %s (%d/%d)...\n", repo.Name, i+1, len(repos))
-		
-		branchName := fmt.Sprintf("%s-%s", branchPrefix, timestamp)
-		
-		// Create and push branch
-		prURL, err := createPRForRepo(repo, branchName)
-		if err != nil {
-			fmt.Printf("   ❌ Failed: %v\n", err)
+	fmt.Printf("🚀 Creating pull requests for %d keyboard(s), %d at a time...\n", len(tasks), jobs)
+	fmt.Println()
+	runPRTasks(tasks, branchName)
+
+	// Summary, in keyboard order
+	created, updated, skipped, failed := 0, 0, 0, 0
+	var members []changeset.PullRequest
+	var failedKeyboards []string
+	fmt.Println("📋 Summary:")
+	for _, task := range tasks {
+		repo, outcome := task.repo, task.outcome
+		var member changeset.PullRequest
+		inSet := false
+		if set != nil {
+			member, inSet = set.Member(repo.Name)
+		}
+		switch {
+		case inSet && errors.Is(task.err, errUpstreamMatches):
+			// e.g. its PR in the set was merged before the set was extended
+			skipped++
+			fmt.Printf("   ⏭️  %-10s already in change set %s as %s#%d\n", repo.Name, set.ID, member.Repo, member.Number)
+			reportSkipped(repo.Name, member.URL, fmt.Sprintf("upstream already matches; #%d is in change set %s", member.Number, set.ID))
+			continue
+		case task.err != nil:
+			failed++
+			failedKeyboards = append(failedKeyboards, repo.Name)
+			fmt.Printf("   ❌ %-10s %v\n", repo.Name, task.err)
+			reportError(repo.Name, task.err)
 			continue
+		case outcome.Updated:
+			updated++
+			fmt.Printf("   🔁 %-10s %s (%s)\n", repo.Name, outcome.URL, outcome.Note)
+			reportChanged(repo.Name, outcome.URL, "updated: "+outcome.Note)
+		default:
+			created++
+			fmt.Printf("   ✅ %-10s %s\n", repo.Name, outcome.URL)
+			reportChanged(repo.Name, outcome.URL, "created")
 		}
-
-		createdPRs = append(createdPRs, prURL)
-		fmt.Printf("   ✅ Created: %s\n", prURL)
-		fmt.Println()
+		if outcome.Number > 0 {
+			members = append(members, changeset.PullRequest{
+				Keyboard: repo.Name,
+				Forge:    repo.Forge,
+				ForgeURL: repo.ForgeURL,
+				Repo:     repo.forgeRepo().String(),
+				Number:   outcome.Number,
+				URL:      outcome.URL,
+				Branch:   outcome.Branch,
+			})
+		}
+	}
+	if skipped > 0 {
+		fmt.Printf("🎉 %d created, %d updated, %d already in the change set, %d failed\n", created, updated, skipped, failed)
+	} else {
+		fmt.Printf("🎉 %d created, %d updated, %d failed\n", created, updated, failed)
 	}
+	fmt.Println()
 
-	// Summary
-	fmt.Printf("🎉 Successfully created %d pull request(s):\n", len(createdPRs))
-	for _, url := range createdPRs {
-		fmt.Printf("   🔗 %s\n", url)
+	if set != nil {
+		linkChangeSet(clients, changeSets, set, members, failedKeyboards)
 	}
 
-	if len(createdPRs) > 0 {
-		fmt.Println()
+	if created+updated > 0 {
 		fmt.Println("💡 Next steps:")
 		fmt.Println("   - Review the PRs in your browser")
 		fmt.Println("   - Add descriptions and additional context")
 		fmt.Println("   - Monitor for feedback from maintainers")
 	}
 
+	if failed > 0 {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return &ExitError{Code: 1}
+	}
 	return nil
 }
 
-func createPRForRepo(repo RepoConfig, branchName string) (string, error) {
-	// Get current directory (KLCM root)
-	klcmRoot, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
+// prTask is pr create's work for one repository
+type prTask struct {
+	repo    RepoConfig
+	client  forge.Forge // nil for a git source
+	log     bytes.Buffer
+	outcome prOutcome
+	err     error
+}
 
-	// Create temp directory for cloning
-	tempDir, err := os.MkdirTemp("", "klcm-pr-*")
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp directory: %w", err)
+// runPRTasks creates the pull requests concurrently, at most --jobs at a
+// time. Each task writes its progress to its own log, printed as one block
+// when the task finishes, so the output of different repositories never
+// interleaves.
+func runPRTasks(tasks []*prTask, branchName string) {
+	workers := jobs
+	if workers < 1 {
+		workers = 1
 	}
-	defer os.RemoveAll(tempDir)
-
-	// Clone the remote repo (clone the target base branch)
-	repoDir := filepath.Join(tempDir, repo.Name)
-	cloneCmd := exec.Command("git", "clone", "--depth", "1", "--branch", repo.BaseBranch, repo.RemoteURL, repoDir)
-	if output, err := cloneCmd.CombinedOutput(); err != nil {
-		return "", fmt.Errorf("failed to clone repo: %s", string(output))
+	queue := make(chan *prTask)
+	var mu sync.Mutex
+	done := 0
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(tasks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				task.outcome, task.err = createPRForRepo(task, branchName)
+
+				mu.Lock()
+				done++
+				fmt.Printf("📁 %s (%d/%d)\n", task.repo.Name, done, len(tasks))
+				os.Stdout.Write(task.log.Bytes())
+				switch {
+				case task.err != nil:
+					fmt.Printf("   ❌ Failed: %v\n", task.err)
+				case task.outcome.Updated:
+					fmt.Printf("   🔁 Updated: %s (%s)\n", task.outcome.URL, task.outcome.Note)
+				default:
+					fmt.Printf("   ✅ Created: %s\n", task.outcome.URL)
+				}
+				fmt.Println()
+				mu.Unlock()
+			}
+		}()
 	}
-
-	// Change to cloned repo directory
-	if err := os.Chdir(repoDir); err != nil {
-		return "", fmt.Errorf("failed to change to repo directory: %w", err)
+	for _, task := range tasks {
+		queue <- task
 	}
-	defer os.Chdir(klcmRoot)
+	close(queue)
+	wg.Wait()
+}
 
-	// Create new branch
-	if err := runGitCommand("checkout", "-b", branchName); err != nil {
-		return "", fmt.Errorf("failed to create branch: %w", err)
-	}
+// prOutcome is what pr create did for one repository
+type prOutcome struct {
+	URL     string
+	Branch  string
+	Number  int    // the pull request; 0 for a branch pushed to a local git source
+	Updated bool   // an open klcm PR was updated instead of a new one opened
+	Note    string // what the update did
+}
 
-	// Copy the local keymap file to the cloned repo's config directory
-	localFile := filepath.Join(klcmRoot, repo.LocalPath, repo.DefaultFile)
-	
-	// Determine destination path in cloned repo (usually config/<filename>)
-	destFile := filepath.Join(repoDir, "config", repo.DefaultFile)
-	
-	// Ensure config directory exists
-	if err := os.MkdirAll(filepath.Dir(destFile), 0755); err != nil {
-		return "", fmt.Errorf("failed to create config directory: %w", err)
+func createPRForRepo(task *prTask, branchName string) (prOutcome, error) {
+	if task.client != nil {
+		return createForgePR(task.client, task.repo, branchName, &task.log)
 	}
+	return pushGitBranch(commandContext(), task.repo, branchName, &task.log)
+}
+
+// createForgePR commits the local keymap through the forge's API, without a
+// clone, and opens a pull request from it. If klcm already has a PR open
+// for the keyboard, the commit goes onto that PR's branch and its
+// description is refreshed instead, unless --new is given.
+func createForgePR(client forge.Forge, repo RepoConfig, branchName string, log io.Writer) (prOutcome, error) {
+	ctx := commandContext()
+	upstream := repo.forgeRepo()
 
-	// Read local file
-	content, err := os.ReadFile(localFile)
+	content, err := os.ReadFile(filepath.Join(filepath.FromSlash(repo.LocalPath), repo.DefaultFile))
 	if err != nil {
-		return "", fmt.Errorf("failed to read local config: %w", err)
+		return prOutcome{}, fmt.Errorf("failed to read local config: %w", err)
 	}
 
-	// Write to destination
-	if err := os.WriteFile(destFile, content, 0644); err != nil {
-		return "", fmt.Errorf("failed to write config: %w", err)
+	var existing *forge.PullRequest
+	if !prNew {
+		open, err := openKLCMPullRequests(ctx, client, repo)
+		if err != nil {
+			return prOutcome{}, forgeError(repo.Forge, upstream.String(), err)
+		}
+		if len(open) > 0 {
+			existing = &open[0]
+			branchName = existing.Head.Ref
+			fmt.Fprintf(log, "   🔁 Found open PR #%d on %s; adding to it (use --new for a separate PR)\n", existing.Number, branchName)
+		}
 	}
 
-	// Check if there are changes
-	statusCmd := exec.Command("git", "status", "--porcelain")
-	output, err := statusCmd.Output()
-	if err != nil || strings.TrimSpace(string(output)) == "" {
-		return "", fmt.Errorf("no changes detected after copying file")
+	base, err := client.FileContent(ctx, upstream, repo.UpstreamPath, repo.BaseBranch)
+	if err != nil && !forge.IsNotFound(err) {
+		return prOutcome{}, forgeError(repo.Forge, upstream.String(), fmt.Errorf("failed to read %s on %s: %w", repo.UpstreamPath, repo.BaseBranch, err))
 	}
-
-	// Add and commit
-	if err := runGitCommand("add", "."); err != nil {
-		return "", fmt.Errorf("failed to add changes: %w", err)
+	desc := describePR(repo, base, content, branchName)
+	if existing != nil {
+		desc.Body = keepChangeSetSections(existing.Body, desc.Body)
 	}
 
-	commitMsg := fmt.Sprintf("Update %s keyboard layout configuration\n\nGenerated by KLCM (Keyboard Layout Configuration Mapper)", repo.Name)
-	if err := runGitCommand("commit", "-m", commitMsg); err != nil {
-		return "", fmt.Errorf("failed to commit changes: %w", err)
+	commitMsg := desc.Title + "\n\nGenerated by KLCM (Keyboard Layout Configuration Mapper)"
+	push, err := client.PushFile(ctx, upstream, forge.FileChange{
+		Base:    repo.BaseBranch,
+		Branch:  branchName,
+		Path:    repo.UpstreamPath,
+		Content: content,
+		Message: commitMsg,
+	})
+	switch {
+	case errors.Is(err, forge.ErrNoChanges) && existing == nil:
+		return prOutcome{}, fmt.Errorf("%s %w", repo.UpstreamPath, errUpstreamMatches)
+	case errors.Is(err, forge.ErrNoChanges):
+		push = nil
+	case err != nil:
+		return prOutcome{}, forgeError(repo.Forge, upstream.String(), err)
+	case verbose:
+		fmt.Fprintf(log, "   🌿 Committed %s to %s\n", shortCommit(push.Commit), push.Branch)
 	}
 
-	// Push branch
-	if err := runGitCommand("push", "-u", "origin", branchName); err != nil {
-		return "", fmt.Errorf("failed to push branch: %w", err)
+	if existing != nil {
+		pr, err := client.EditPullRequest(ctx, upstream, existing.Number, forge.PullRequestEdit{Title: desc.Title, Body: desc.Body})
+		if err != nil {
+			return prOutcome{}, forgeError(repo.Forge, upstream.String(), fmt.Errorf("failed to update PR #%d: %w", existing.Number, err))
+		}
+		note := "branch already up to date, refreshed description"
+		if push != nil {
+			note = fmt.Sprintf("pushed %s to %s, refreshed description", shortCommit(push.Commit), branchName)
+		}
+		return prOutcome{URL: pr.HTMLURL, Number: pr.Number, Branch: branchName, Updated: true, Note: note}, nil
 	}
 
-	// Create PR using GitHub CLI
-	prTitle := fmt.Sprintf("Update %s keyboard layout configuration", repo.Name)
-	prBody := fmt.Sprintf(`# %s Layout Update
-
-This PR contains keyboard layout configuration updates generated by KLCM (Keyboard Layout Configuration Mapper).
-
-## Changes
-- Updated keyboard layout configuration
-- Validated configuration syntax
-- Ready for review and testing
-
-## Generated by
-- **Tool**: KLCM v0.1.0
-- **Date**: %s
-- **Branch**: %s
-
-Please review the changes and test the configuration before merging.`, repo.Name, time.Now().Format("2006-01-02"), branchName)
-
-	// Create PR
-	prCmd := exec.Command("gh", "pr", "create",
-		"--repo", fmt.Sprintf("%s/%s", repo.Owner, repo.Repo),
-		"--base", repo.BaseBranch,
-		"--title", prTitle,
-		"--body", prBody,
-		"--head", branchName)
-
-	prOutput, err := prCmd.CombinedOutput()
+	pr, _, err := forge.OpenPullRequest(ctx, client, upstream, forge.NewPullRequest{
+		Title: desc.Title,
+		Body:  desc.Body,
+		Head:  branchName,
+		Base:  repo.BaseBranch,
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to create PR: %s", string(prOutput))
+		err = forgeError(repo.Forge, upstream.String(), fmt.Errorf("failed to create PR: %w", err))
+		return prOutcome{}, rollBackBranch(ctx, client, upstream, push, err)
 	}
-
-	prURL := strings.TrimSpace(string(prOutput))
-	return prURL, nil
+	return prOutcome{URL: pr.HTMLURL, Number: pr.Number, Branch: branchName}, nil
 }
 
-func runGitCommand(args ...string) error {
-	cmd := exec.Command("git", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// rollBackBranch deletes the branch pushed for a pull request that could not
+// be opened, so a failed run leaves nothing behind upstream, and returns
+// cause saying so. A branch that existed before the push is left alone.
+func rollBackBranch(ctx context.Context, client forge.Forge, upstream forge.Repo, push *forge.Push, cause error) error {
+	if push == nil || !push.Created {
+		return cause
+	}
+	// Roll back even if the command was interrupted
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if err := client.DeleteBranch(ctx, upstream, push.Branch); err != nil {
+		return fmt.Errorf("%w; branch %s was left behind: %v", cause, push.Branch, err)
+	}
+	return fmt.Errorf("%w; deleted branch %s again", cause, push.Branch)
 }
 
 func getCurrentBranch() (string, error) {
@@ -453,18 +669,6 @@ func getCurrentBranch() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-func isGitHubCLIAvailable() bool {
-	cmd := exec.Command("gh", "--version")
-	err := cmd.Run()
-	return err == nil
-}
-
-func isGitHubAuthenticated() bool {
-	cmd := exec.Command("gh", "auth", "status")
-	err := cmd.Run()
-	return err == nil
-}
-
 func getChangeSummary(repo RepoConfig) (string, error) {
 	// Get diff stats
 	cmd := exec.Command("git", "diff", "--numstat", "HEAD", "--", repo.LocalPath)
@@ -532,6 +736,9 @@ func init() {
 	prCreateCmd.Flags().BoolVar(&prApply, "apply", false, "actually create the PRs (required for real creation)")
 	prCreateCmd.Flags().StringVar(&prBranch, "branch", "", "custom branch name prefix")
 	prCreateCmd.Flags().BoolVar(&prForce, "force", false, "force creation even if no changes detected")
+	prCreateCmd.Flags().BoolVar(&prNew, "new", false, "open a new PR even if you already have a klcm PR open for the keyboard")
+	prCreateCmd.Flags().BoolVar(&prLayerGraph, "layer-graph", false, "embed the layer-activation graph in the PR body as a Mermaid diagram")
+	prCreateCmd.Flags().StringVar(&prChangeSet, "change-set", "", "open the PRs as one linked change set with this title, or add them to the recorded set with this ID")
 }
 
 // prStatusCmd represents the pr status command
@@ -540,7 +747,13 @@ var prStatusCmd = &cobra.Command{
 	Short: "Check status of existing pull requests",
 	Long: `Check the status of pull requests created by KLCM.
 	
-Shows information about open PRs, their review status, and merge status.`,
+Shows the open PRs you authored on each upstream repository, with their
+reviews, checks and whether they can be merged.
+
+Change sets opened with 'klcm pr create --change-set' are then reported as a
+whole: all merged, partially merged, blocked (a PR was closed without merging
+or has requested changes, failing checks or conflicts), incomplete (a
+keyboard's PR could not be opened), ready to merge together, or still open.`,
 	Example: `  # Check status of all PRs
   klcm pr status
   
@@ -554,72 +767,184 @@ func runPRStatus(cmd *cobra.Command, args []string) error {
 		fmt.Println("📊 Checking PR status...")
 	}
 
-	if !isGitHubCLIAvailable() {
-		return fmt.Errorf("GitHub CLI (gh) is required but not found. Please install it: https://cli.github.com/")
+	// Identify the user on every forge first; PRs are listed by author
+	ctx := commandContext()
+	clients := make(forges)
+	logins := make(map[forge.Forge]string)
+	var users []string
+	errs := make(keyboardErrors)
+	repos := repoConfigs()
+	for _, repo := range repos {
+		if repo.Forge == "" {
+			continue
+		}
+		client, err := clients.forRepo(repo)
+		if err != nil {
+			errs[repo.Name] = err
+			continue
+		}
+		if _, ok := logins[client]; ok {
+			continue
+		}
+		user, err := client.AuthenticatedUser(ctx)
+		if err != nil {
+			errs[repo.Name] = forgeError(repo.Forge, fmt.Sprintf("failed to identify the %s user", client.Name()), err)
+			continue
+		}
+		logins[client] = user.Login
+		users = append(users, fmt.Sprintf("%s on %s", user.Login, client.Name()))
 	}
-
-	if !isGitHubAuthenticated() {
-		fmt.Println("🔑 GitHub authentication required")
-		fmt.Println("Please run: gh auth login")
-		return fmt.Errorf("not authenticated with GitHub")
+	if len(logins) == 0 && len(errs) > 0 {
+		for _, repo := range repos {
+			if err := errs[repo.Name]; err != nil {
+				fmt.Printf("🔑 %s authentication required\n", forgeNames[repo.Forge])
+				return err
+			}
+		}
 	}
 
-	fmt.Println("🔍 Checking pull request status...")
-	fmt.Println()
+	switch len(users) {
+	case 0:
+	case 1:
+		for _, login := range logins {
+			fmt.Printf("🔍 Checking pull requests opened by %s...\n", login)
+		}
+		fmt.Println()
+	default:
+		fmt.Printf("🔍 Checking pull requests opened by %s...\n", strings.Join(users, ", "))
+		fmt.Println()
+	}
 
-	for i, repo := range repoConfigs {
+	statuses := make(map[string][]*forge.PullRequestStatus)
+	for i, repo := range repos {
 		fmt.Printf("%d. 📁 %s (%s)\n", i+1, repo.Name, getRepoName(repo.RemoteURL))
-		
+		if err := errs[repo.Name]; err != nil {
+			fmt.Printf("   ❌ Error checking PRs: %v\n", err)
+			fmt.Println()
+			continue
+		}
+
 		// Check for PRs from this repo
-		err := checkReposPRs(repo)
+		var client forge.Forge
+		if repo.Forge != "" {
+			client, _ = clients.forRepo(repo)
+		}
+		prs, err := checkReposPRs(client, repo, logins[client])
 		if err != nil {
 			fmt.Printf("   ❌ Error checking PRs: %v\n", err)
+			errs[repo.Name] = err
+		}
+		if prs != nil {
+			statuses[repo.Name] = prs
 		}
 		fmt.Println()
 	}
+	sets := printChangeSets(clients, errs)
+	reportData(prStatusReport{PullRequests: statuses, ChangeSets: sets})
 
-	return nil
+	return errs.summary(cmd, len(repos))
 }
 
-func checkReposPRs(repo RepoConfig) error {
-	// Get current directory and change to repo directory
-	currentDir, err := os.Getwd()
-	if err != nil {
-		return err
-	}
+// prStatusReport is the Data of pr status with --output json
+type prStatusReport struct {
+	PullRequests map[string][]*forge.PullRequestStatus `json:"pull_requests"`
+	ChangeSets   []changeSetStatus                     `json:"change_sets"`
+}
 
-	repoPath := filepath.Join(currentDir, repo.LocalPath)
-	if err := os.Chdir(repoPath); err != nil {
-		fmt.Printf("   📭 No local repository found\n")
-		return nil
+// checkReposPRs prints the open pull requests author has on a keyboard's
+// upstream repository, with their reviews, checks and mergeability
+func checkReposPRs(client forge.Forge, repo RepoConfig, author string) ([]*forge.PullRequestStatus, error) {
+	if repo.Forge == "" {
+		fmt.Printf("   📭 Not on a forge; klcm pushes branches there without PRs\n")
+		return nil, nil
 	}
-	defer os.Chdir(currentDir)
 
-	// List PRs using GitHub CLI
-	cmd := exec.Command("gh", "pr", "list", "--author", "@me", "--limit", "10")
-	output, err := cmd.Output()
+	ctx := commandContext()
+	upstream := repo.forgeRepo()
+	open, err := client.PullRequests(ctx, upstream, forge.ListOptions{State: "open", Base: repo.BaseBranch})
 	if err != nil {
-		fmt.Printf("   📭 No PRs found or error accessing repository\n")
-		return nil
+		return nil, forgeError(repo.Forge, upstream.String(), err)
 	}
 
-	prs := strings.TrimSpace(string(output))
-	if prs == "" {
+	var statuses []*forge.PullRequestStatus
+	for _, pr := range open {
+		if pr.User.Login != author {
+			continue
+		}
+		status, err := client.Status(ctx, upstream, pr.Number)
+		if err != nil {
+			return statuses, forgeError(repo.Forge, upstream.String(), err)
+		}
+		statuses = append(statuses, status)
+	}
+
+	if len(statuses) == 0 {
 		fmt.Printf("   📭 No open PRs found\n")
-		return nil
+		return statuses, nil
 	}
 
-	// Parse and display PR information
-	lines := strings.Split(prs, "\n")
-	fmt.Printf("   📋 Found %d open PR(s):\n", len(lines))
-	
-	for _, line := range lines {
-		if strings.TrimSpace(line) != "" {
-			fmt.Printf("      🔗 %s\n", line)
+	fmt.Printf("   📋 Found %d open PR(s):\n", len(statuses))
+	for _, status := range statuses {
+		draft := ""
+		if status.Draft {
+			draft = " [draft]"
 		}
+		fmt.Printf("      🔗 #%d %s%s (%s)\n", status.Number, status.Title, draft, status.Head.Ref)
+		fmt.Printf("         %s\n", status.HTMLURL)
+		fmt.Printf("         %s · %s · %s\n", describeReviews(status), describeChecks(status), describeMergeable(status))
 	}
+	return statuses, nil
+}
 
-	return nil
+func describeReviews(status *forge.PullRequestStatus) string {
+	var reviewers []string
+	for _, review := range status.Reviews {
+		reviewers = append(reviewers, review.User.Login)
+	}
+	by := ""
+	if len(reviewers) > 0 {
+		by = " (" + strings.Join(reviewers, ", ") + ")"
+	}
+	switch status.ReviewDecision {
+	case forge.ReviewApproved:
+		return "👍 approved" + by
+	case forge.ReviewChangesRequested:
+		return "✋ changes requested" + by
+	default:
+		return "👀 review required" + by
+	}
+}
+
+func describeChecks(status *forge.PullRequestStatus) string {
+	passed := 0
+	for _, check := range status.Checks {
+		if check.Status == "completed" && (check.Conclusion == "success" || check.Conclusion == "neutral" || check.Conclusion == "skipped") {
+			passed++
+		}
+	}
+	switch status.ChecksState {
+	case forge.ChecksPassing:
+		return fmt.Sprintf("✅ checks %d/%d passed", passed, len(status.Checks))
+	case forge.ChecksFailing:
+		return fmt.Sprintf("❌ checks failing (%d/%d passed)", passed, len(status.Checks))
+	case forge.ChecksPending:
+		return fmt.Sprintf("⏳ checks running (%d/%d passed)", passed, len(status.Checks))
+	default:
+		return "➖ no checks"
+	}
+}
+
+func describeMergeable(status *forge.PullRequestStatus) string {
+	switch {
+	case status.Mergeable == nil:
+		return "❔ mergeability unknown"
+	case !*status.Mergeable:
+		return "⚔️  merge conflicts"
+	case status.MergeableState == "blocked":
+		return "🚧 blocked by branch protection"
+	default:
+		return "🔀 mergeable"
+	}
 }
 
 // prWorkflowCmd represents the pr workflow command  
@@ -646,20 +971,18 @@ func runPRWorkflow(cmd *cobra.Command, args []string) error {
 	fmt.Println("================================================")
 	fmt.Println()
 
-	reader := bufio.NewReader(os.Stdin)
-
 	// Step 1: Update configurations
 	fmt.Println("📋 Step 1: Update to Latest Configurations")
 	fmt.Println("Before making changes, let's ensure you have the latest configurations.")
 	fmt.Println()
 	
-	if askYesNo(reader, "Would you like to check for and apply remote updates? (Y/n): ") {
+	if confirm("Would you like to check for and apply remote updates? (Y/n): ", true) {
 		fmt.Println("🔍 Checking for remote updates...")
-		if err := runCommandInteractive("./klcm", "pull", "--preview"); err != nil {
+		if err := runCLICommand("pull", "--preview"); err != nil {
 			fmt.Printf("❌ Error checking updates: %v\n", err)
 		} else {
-			if askYesNo(reader, "Apply these updates? (Y/n): ") {
-				if err := runCommandInteractive("./klcm", "pull"); err != nil {
+			if confirm("Apply these updates? (Y/n): ", true) {
+				if err := runCLICommand("pull"); err != nil {
 					fmt.Printf("❌ Error applying updates: %v\n", err)
 				} else {
 					fmt.Println("✅ Updates applied successfully")
@@ -672,11 +995,9 @@ func runPRWorkflow(cmd *cobra.Command, args []string) error {
 	// Step 2: Make changes
 	fmt.Println("📋 Step 2: Make Your Changes")
 	fmt.Println("Now edit your keyboard configuration files:")
-	fmt.Println("  📁 Advantage360: configs/zmk_adv360/adv360.keymap")
-	fmt.Println("  📁 Glove80: configs/zmk_glove80/glove80.keymap")
+	printKeymapPaths()
 	fmt.Println()
-	fmt.Println("Press Enter when you've finished making your changes...")
-	reader.ReadLine()
+	waitForEnter("Press Enter when you've finished making your changes...")
 
 	// Step 3: Validate changes
 	fmt.Println("📋 Step 3: Validate Your Changes")
@@ -684,10 +1005,10 @@ func runPRWorkflow(cmd *cobra.Command, args []string) error {
 	fmt.Println()
 	
 	fmt.Println("🔍 Running validation...")
-	if err := runCommandInteractive("./klcm", "validate"); err != nil {
+	if err := runCLICommand("validate"); err != nil {
 		fmt.Printf("❌ Validation failed: %v\n", err)
 		fmt.Println("Please fix the errors and run 'klcm validate' again before continuing.")
-		return nil
+		return workflowFailed(cmd)
 	}
 	fmt.Println("✅ All configurations are valid!")
 	fmt.Println()
@@ -697,41 +1018,28 @@ func runPRWorkflow(cmd *cobra.Command, args []string) error {
 	fmt.Println("If you want the same changes on both keyboards, we can sync them.")
 	fmt.Println()
 	
-	if askYesNo(reader, "Would you like to sync changes between keyboards? (y/N): ") {
+	if confirm("Would you like to sync changes between keyboards? (y/N): ", false) {
 		// Ask which direction to sync
-		fmt.Println("Which direction would you like to sync?")
-		fmt.Println("  1. Advantage360 → Glove80")
-		fmt.Println("  2. Glove80 → Advantage360")
-		fmt.Print("Enter choice (1/2): ")
-		
-		choice, _ := reader.ReadString('\n')
-		choice = strings.TrimSpace(choice)
-		
-		var source, target string
-		switch choice {
-		case "1":
-			source, target = "adv360", "glove80"
-		case "2":
-			source, target = "glove80", "adv360"
-		default:
+		source, target, ok := chooseSyncDirection()
+		if !ok {
 			fmt.Println("Invalid choice, skipping sync.")
 			goto step5
 		}
 
 		fmt.Printf("🔍 Previewing sync from %s to %s...\n", source, target)
-		if err := runCommandInteractive("./klcm", "sync", source, target, "--preview"); err != nil {
+		if err := runCLICommand("sync", source, target, "--preview"); err != nil {
 			fmt.Printf("❌ Error previewing sync: %v\n", err)
 		} else {
-			if askYesNo(reader, "Apply this sync? (y/N): ") {
-				if err := runCommandInteractive("./klcm", "sync", source, target); err != nil {
+			if confirm("Apply this sync? (y/N): ", false) {
+				if err := runCLICommand("sync", source, target); err != nil {
 					fmt.Printf("❌ Error applying sync: %v\n", err)
 				} else {
 					fmt.Println("✅ Sync applied successfully")
 					// Validate after sync
 					fmt.Println("🔍 Re-validating after sync...")
-					if err := runCommandInteractive("./klcm", "validate"); err != nil {
+					if err := runCLICommand("validate"); err != nil {
 						fmt.Printf("❌ Validation failed after sync: %v\n", err)
-						return nil
+						return workflowFailed(cmd)
 					}
 					fmt.Println("✅ All configurations still valid!")
 				}
@@ -748,14 +1056,14 @@ func runPRWorkflow(cmd *cobra.Command, args []string) error {
 	fmt.Println()
 	
 	fmt.Println("🔍 Analyzing what PRs would be created...")
-	if err := runCommandInteractive("./klcm", "pr", "create", "--dry-run"); err != nil {
+	if err := runCLICommand("pr", "create", "--dry-run"); err != nil {
 		fmt.Printf("❌ Error analyzing PRs: %v\n", err)
-		return nil
+		return workflowFailed(cmd)
 	}
 
-	if askYesNo(reader, "Create these pull requests? (y/N): ") {
+	if confirm("Create these pull requests? (y/N): ", false) {
 		fmt.Println("🚀 Creating pull requests...")
-		if err := runCommandInteractive("./klcm", "pr", "create", "--apply"); err != nil {
+		if err := runCLICommand("pr", "create", "--apply"); err != nil {
 			fmt.Printf("❌ Error creating PRs: %v\n", err)
 		} else {
 			fmt.Println("✅ Pull requests created successfully!")
@@ -768,18 +1076,3 @@ func runPRWorkflow(cmd *cobra.Command, args []string) error {
 	
 	return nil
 }
-
-func askYesNo(reader *bufio.Reader, prompt string) bool {
-	fmt.Print(prompt)
-	response, _ := reader.ReadString('\n')
-	response = strings.ToLower(strings.TrimSpace(response))
-	return response == "y" || response == "yes" || response == ""
-}
-
-func runCommandInteractive(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	return cmd.Run()
-}
\ No newline at end of file
@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/models"
+	"masters3d.com/keyboard_layout_config_mapper/internal/parsers"
+)
+
+// maxKeyRows caps the per-key tables so large rewrites stay readable
+const maxKeyRows = 60
+
+var prLayerGraph bool
+
+// prDescription is the title and body of the pull request for one keyboard
+type prDescription struct {
+	Title    string
+	Body     string
+	Semantic *parsers.LayoutDiff // nil if either side did not parse
+}
+
+// describePR writes the pull request for a keymap from a semantic diff of
+// the upstream base version against the local one. base is nil when the
+// file does not exist upstream yet.
+func describePR(repo RepoConfig, base, local []byte, branchName string) prDescription {
+	parser := parsers.NewZMKParser(models.KeyboardType(repo.Name))
+	localLayout, localErr := parser.ParseContent(repo.LocalPath+"/"+repo.DefaultFile, local)
+
+	desc := prDescription{}
+	var b strings.Builder
+	fmt.Fprintf(&b, "## ⌨️ %s keymap update\n\n", repo.Name)
+
+	switch {
+	case base == nil:
+		desc.Title = fmt.Sprintf("%s: add keymap", repo.Name)
+		fmt.Fprintf(&b, "Adds `%s` to `%s`.\n", repo.UpstreamPath, repo.BaseBranch)
+	case localErr != nil:
+		desc.Title = fmt.Sprintf("Update %s keyboard layout configuration", repo.Name)
+		fmt.Fprintf(&b, "Changes `%s` on `%s`: %s. klcm could not parse the keymap for a semantic summary (%v).\n",
+			repo.UpstreamPath, repo.BaseBranch, SimpleDiffSummary(strings.Split(string(base), "\n"), strings.Split(string(local), "\n")), localErr)
+	default:
+		baseLayout, baseErr := parser.ParseContent(repo.UpstreamPath+"@"+repo.BaseBranch, base)
+		if baseErr != nil {
+			desc.Title = fmt.Sprintf("Update %s keyboard layout configuration", repo.Name)
+			fmt.Fprintf(&b, "Changes `%s` on `%s`. klcm could not parse the upstream version for a semantic summary (%v).\n",
+				repo.UpstreamPath, repo.BaseBranch, baseErr)
+			break
+		}
+		desc.Semantic = parsers.DiffLayouts(baseLayout, localLayout)
+		desc.Title = semanticPRTitle(repo.Name, desc.Semantic)
+		fmt.Fprintf(&b, "Changes `%s` on `%s`: %s.\n", repo.UpstreamPath, repo.BaseBranch, desc.Semantic.Summary())
+		writeSemanticSections(&b, desc.Semantic)
+	}
+
+	writeValidationSection(&b, repo.Name)
+	if prLayerGraph && localErr == nil {
+		fmt.Fprintf(&b, "\n### Layer graph\n\n```mermaid\n%s```\n", parsers.BuildLayerGraph(localLayout).Mermaid())
+	}
+
+	fmt.Fprintf(&b, "\n---\n<sub>Generated by KLCM (Keyboard Layout Configuration Mapper) on %s from branch `%s`.</sub>\n%s\n",
+		time.Now().Format("2006-01-02"), branchName, klcmPRMarker(repo.Name))
+	desc.Body = b.String()
+	return desc
+}
+
+// semanticPRTitle summarizes a diff as "<keyboard>: <what changed>", e.g.
+// "glove80: remap 3 keys on Base, Lower; update behavior hrm"
+func semanticPRTitle(keyboard string, diff *parsers.LayoutDiff) string {
+	var parts []string
+	if len(diff.AddedLayers) > 0 {
+		parts = append(parts, "add "+countedNames(diff.AddedLayers, "layer", "layers"))
+	}
+	if len(diff.RemovedLayers) > 0 {
+		parts = append(parts, "remove "+countedNames(diff.RemovedLayers, "layer", "layers"))
+	}
+	if n := len(diff.KeyChanges); n > 0 {
+		var layers []string
+		seen := make(map[string]bool)
+		for _, change := range diff.KeyChanges {
+			if !seen[change.Layer] {
+				seen[change.Layer] = true
+				layers = append(layers, change.Layer)
+			}
+		}
+		parts = append(parts, fmt.Sprintf("remap %s on %s", plural(n, "key", "keys"), nameList(layers, 2)))
+	}
+	for _, group := range []struct {
+		singular, plural string
+		changes          []parsers.DefinitionChange
+	}{
+		{"behavior", "behaviors", diff.BehaviorChanges},
+		{"combo", "combos", diff.ComboChanges},
+		{"macro", "macros", diff.MacroChanges},
+	} {
+		if len(group.changes) == 0 {
+			continue
+		}
+		names := make([]string, len(group.changes))
+		for i, change := range group.changes {
+			names[i] = change.Name
+		}
+		parts = append(parts, "update "+countedNames(names, group.singular, group.plural))
+	}
+	if len(parts) == 0 {
+		return keyboard + ": reformat keymap"
+	}
+
+	title := keyboard + ": " + parts[0]
+	for i, part := range parts[1:] {
+		if len(title)+len(part) > 70 {
+			return fmt.Sprintf("%s and %d more change(s)", title, len(parts)-1-i)
+		}
+		title += "; " + part
+	}
+	return title
+}
+
+// countedNames names one or two items ("layer Gaming") and counts more
+// ("3 layers")
+func countedNames(names []string, singular, pluralForm string) string {
+	switch len(names) {
+	case 1:
+		return singular + " " + names[0]
+	case 2:
+		return pluralForm + " " + names[0] + " and " + names[1]
+	}
+	return plural(len(names), singular, pluralForm)
+}
+
+// nameList joins up to max names, e.g. "Base, Lower and 2 more"
+func nameList(names []string, max int) string {
+	if len(names) <= max {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:max], ", "), len(names)-max)
+}
+
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", n, pluralForm)
+}
+
+// writeSemanticSections adds the layer, key and definition changes of a diff
+func writeSemanticSections(b *strings.Builder, diff *parsers.LayoutDiff) {
+	if diff.IsEmpty() {
+		b.WriteString("\nOnly formatting changed; the keymap behaves the same.\n")
+		return
+	}
+
+	keysPerLayer := make(map[string]int)
+	for _, change := range diff.KeyChanges {
+		keysPerLayer[change.Layer]++
+	}
+	b.WriteString("\n### Layers touched\n\n| Layer | Change |\n| --- | --- |\n")
+	for _, layer := range diff.AddedLayers {
+		fmt.Fprintf(b, "| `%s` | 🆕 added |\n", layer)
+	}
+	for _, layer := range diff.RemovedLayers {
+		fmt.Fprintf(b, "| `%s` | 🗑️ removed |\n", layer)
+	}
+	for _, layer := range diff.LayersTouched() {
+		if n, ok := keysPerLayer[layer]; ok {
+			fmt.Fprintf(b, "| `%s` | %s changed |\n", layer, plural(n, "key", "keys"))
+		}
+	}
+
+	if len(diff.KeyChanges) > 0 {
+		b.WriteString("\n### Key changes\n")
+		currentLayer := ""
+		for i, change := range diff.KeyChanges {
+			if i == maxKeyRows {
+				fmt.Fprintf(b, "\n_…and %d more key changes; run `klcm diff` for the full list._\n", len(diff.KeyChanges)-maxKeyRows)
+				break
+			}
+			if change.Layer != currentLayer {
+				currentLayer = change.Layer
+				fmt.Fprintf(b, "\n#### `%s`\n\n| Key | Before | After |\n| --- | --- | --- |\n", currentLayer)
+			}
+			fmt.Fprintf(b, "| `%s` | %s | %s |\n", change.Key, markdownBinding(change.Before), markdownBinding(change.After))
+		}
+	}
+
+	writeDefinitionSection(b, "Behavior changes", diff.BehaviorChanges)
+	writeDefinitionSection(b, "Combo changes", diff.ComboChanges)
+	writeDefinitionSection(b, "Macro changes", diff.MacroChanges)
+}
+
+func writeDefinitionSection(b *strings.Builder, title string, changes []parsers.DefinitionChange) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n### %s\n\n", title)
+	for _, change := range changes {
+		switch change.Kind {
+		case parsers.ChangeAdded:
+			fmt.Fprintf(b, "- 🆕 `%s` added\n", change.Name)
+		case parsers.ChangeRemoved:
+			fmt.Fprintf(b, "- 🗑️ `%s` removed\n", change.Name)
+		default:
+			fmt.Fprintf(b, "- ✏️ `%s` modified\n", change.Name)
+			for _, prop := range change.Properties {
+				fmt.Fprintf(b, "  - `%s`: %s → %s\n", prop.Property, markdownBinding(prop.Before), markdownBinding(prop.After))
+			}
+		}
+	}
+}
+
+// markdownBinding formats a binding for a table cell
+func markdownBinding(value string) string {
+	if value == "" {
+		return "_(none)_"
+	}
+	return "`" + strings.ReplaceAll(value, "|", "\\|") + "`"
+}
+
+// writeValidationSection adds the klcm validate findings for the keyboard
+func writeValidationSection(b *strings.Builder, keyboard string) {
+	b.WriteString("\n### Validation\n\n")
+	validator, err := configuredValidator()
+	if err != nil {
+		fmt.Fprintf(b, "⚠️ Not validated: %v\n", err)
+		return
+	}
+	result := validator.ValidateKeyboard(keyboard, false)
+	if len(result.Findings) == 0 {
+		b.WriteString("✅ `klcm validate` found no problems.\n")
+		return
+	}
+
+	fmt.Fprintf(b, "`klcm validate`: %d error(s), %d warning(s), %d info\n\n| Severity | Line | Rule | Message |\n| --- | --- | --- | --- |\n",
+		result.Count(parsers.SeverityError), result.Count(parsers.SeverityWarning), result.Count(parsers.SeverityInfo))
+	for _, finding := range result.Findings {
+		line := ""
+		if finding.Line > 0 {
+			line = fmt.Sprint(finding.Line)
+		}
+		fmt.Fprintf(b, "| %s | %s | `%s` | %s |\n", finding.Severity, line, finding.RuleID, strings.ReplaceAll(finding.Message, "|", "\\|"))
+	}
+}
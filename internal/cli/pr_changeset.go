@@ -0,0 +1,379 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/changeset"
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+)
+
+// prChangeSet is --change-set: the title of a new change set, or the ID of a
+// recorded one to add the PRs to
+var prChangeSet string
+
+// prFooter starts the footer describePR ends every body with
+const prFooter = "\n---\n<sub>Generated by KLCM"
+
+// changeSetEnd closes the section a change set adds to each member's body
+const changeSetEnd = "<!-- /klcm:change-set -->"
+
+// changeSetMarker opens the section a change set adds to each member's body
+func changeSetMarker(id string) string {
+	return fmt.Sprintf("<!-- klcm:change-set=%s -->", id)
+}
+
+// startChangeSet resolves --change-set before any PR is touched, so a typo in
+// an ID fails early. A value naming a recorded set extends it; anything else
+// is the title of a new set.
+func startChangeSet(value string) (*changeset.File, *changeset.Set, error) {
+	file, err := changeset.Load(changeset.DefaultPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if strings.HasPrefix(value, "cs-") {
+		set, err := file.Find(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		return file, set, nil
+	}
+	now := time.Now()
+	return file, &changeset.Set{ID: changeset.NewID(now), Title: value, CreatedAt: now}, nil
+}
+
+// linkChangeSet records the PRs pr create opened or updated as one change set
+// and adds a section to each body that links the others. Keyboards that
+// failed are recorded too, so the set shows as incomplete until they are added.
+func linkChangeSet(clients forges, file *changeset.File, set *changeset.Set, members []changeset.PullRequest, failed []string) {
+	for _, member := range members {
+		set.Add(member)
+	}
+	if len(set.PullRequests) == 0 {
+		fmt.Println("⚠️  No pull requests were opened; the change set was not recorded")
+		return
+	}
+	for _, keyboard := range failed {
+		set.Fail(keyboard)
+	}
+
+	file.Put(set)
+	if err := file.Save(changeset.DefaultPath); err != nil {
+		fmt.Printf("❌ Failed to record change set %s: %v\n", set.ID, err)
+		reportError("change set "+set.ID, err)
+		return
+	}
+
+	fmt.Printf("🔗 Linking change set %s (%d pull request(s))...\n", set.ID, len(set.PullRequests))
+	ctx := commandContext()
+	for _, member := range set.PullRequests {
+		client, upstream, err := changeSetRepo(clients, member)
+		if err == nil {
+			var pr *forge.PullRequest
+			if pr, err = client.GetPullRequest(ctx, upstream, member.Number); err == nil {
+				if body := withChangeSetSection(pr.Body, set, member); body != pr.Body {
+					_, err = client.EditPullRequest(ctx, upstream, member.Number, forge.PullRequestEdit{Body: body})
+				}
+			}
+			if err != nil {
+				err = forgeError(member.Forge, fmt.Sprintf("#%d", member.Number), err)
+			}
+		}
+		if err != nil {
+			fmt.Printf("   ❌ %s: %v\n", member.Repo, err)
+			reportError(member.Keyboard, fmt.Errorf("failed to link change set %s: %w", set.ID, err))
+			continue
+		}
+		fmt.Printf("   🔗 %s #%d\n", member.Repo, member.Number)
+	}
+	fmt.Printf("📒 Recorded in %s; follow it with 'klcm pr status'\n", changeset.DefaultPath)
+	if len(set.Failed) > 0 {
+		fmt.Printf("⚠️  Change set %s is incomplete: no PR for %s. Once fixed, add them with 'klcm pr create --apply --change-set %s'\n",
+			set.ID, strings.Join(set.Failed, ", "), set.ID)
+	}
+	fmt.Println()
+}
+
+// changeSetRepo returns the forge and repository of a change set member. The
+// owner is everything before the last "/", as GitLab namespaces may nest.
+func changeSetRepo(clients forges, member changeset.PullRequest) (forge.Forge, forge.Repo, error) {
+	i := strings.LastIndex(member.Repo, "/")
+	if i <= 0 || i == len(member.Repo)-1 {
+		return nil, forge.Repo{}, fmt.Errorf("invalid repository %q in %s", member.Repo, changeset.DefaultPath)
+	}
+	client, err := clients.get(member.Forge, member.ForgeURL)
+	if err != nil {
+		return nil, forge.Repo{}, err
+	}
+	return client, forge.Repo{Owner: member.Repo[:i], Name: member.Repo[i+1:]}, nil
+}
+
+// withChangeSetSection returns body with the section of set, written from the
+// point of view of self, in place of any earlier version of it
+func withChangeSetSection(body string, set *changeset.Set, self changeset.PullRequest) string {
+	body = withoutChangeSetSection(body, set.ID)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n### 🔗 Change set `%s`\n\n", changeSetMarker(set.ID), set.ID)
+	if set.Title != "" {
+		fmt.Fprintf(&b, "%s\n\n", set.Title)
+	}
+	fmt.Fprintf(&b, "This PR is one of %d that make the same change on different keyboards; they are meant to land together.\n\n", len(set.PullRequests))
+	b.WriteString("| Keyboard | Pull request |\n| --- | --- |\n")
+	for _, member := range set.PullRequests {
+		this := ""
+		if member.Repo == self.Repo && member.Number == self.Number {
+			this = " (this PR)"
+		}
+		fmt.Fprintf(&b, "| %s | [%s#%d](%s)%s |\n", member.Keyboard, member.Repo, member.Number, member.URL, this)
+	}
+	for _, keyboard := range set.Failed {
+		fmt.Fprintf(&b, "| %s | ❌ not opened yet |\n", keyboard)
+	}
+	if len(set.Failed) > 0 {
+		fmt.Fprintf(&b, "\n⚠️ **Incomplete:** no pull request could be opened for %s yet; hold off merging until it is part of the set.\n", strings.Join(set.Failed, ", "))
+	}
+	b.WriteString(changeSetEnd + "\n")
+	return insertBeforeFooter(body, b.String())
+}
+
+// withoutChangeSetSection removes the section of change set id from body
+func withoutChangeSetSection(body, id string) string {
+	start := strings.Index(body, changeSetMarker(id))
+	if start < 0 {
+		return body
+	}
+	end := strings.Index(body[start:], changeSetEnd)
+	if end < 0 {
+		return body
+	}
+	end += start + len(changeSetEnd)
+	if strings.HasPrefix(body[end:], "\n") {
+		end++
+	}
+	if strings.HasSuffix(body[:start], "\n\n") {
+		start--
+	}
+	return body[:start] + body[end:]
+}
+
+// changeSetSections returns the change set sections of body, in order
+func changeSetSections(body string) []string {
+	var sections []string
+	for {
+		start := strings.Index(body, "<!-- klcm:change-set=")
+		if start < 0 {
+			return sections
+		}
+		end := strings.Index(body[start:], changeSetEnd)
+		if end < 0 {
+			return sections
+		}
+		end += start + len(changeSetEnd)
+		sections = append(sections, body[start:end]+"\n")
+		body = body[end:]
+	}
+}
+
+// keepChangeSetSections carries the change set sections of a PR's current
+// body over to its regenerated one, so refreshing a description does not
+// unlink it from its sets
+func keepChangeSetSections(current, regenerated string) string {
+	for _, section := range changeSetSections(current) {
+		regenerated = insertBeforeFooter(regenerated, section)
+	}
+	return regenerated
+}
+
+// insertBeforeFooter adds a section to a PR body above the klcm footer, or at
+// the end of a body without one
+func insertBeforeFooter(body, section string) string {
+	if i := strings.Index(body, prFooter); i >= 0 {
+		return body[:i] + "\n" + section + body[i:]
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + section
+}
+
+// Change set states, from the set as a whole
+const (
+	changeSetMerged          = "merged"           // every PR merged
+	changeSetIncomplete      = "incomplete"       // a keyboard has no PR in the set yet
+	changeSetPartiallyMerged = "partially-merged" // some merged, the rest open and unblocked
+	changeSetBlocked         = "blocked"          // a PR was closed unmerged, or cannot merge as is
+	changeSetReady           = "ready"            // every PR approved, passing and mergeable
+	changeSetOpen            = "open"             // waiting for reviews or checks
+	changeSetUnknown         = "unknown"          // a PR could not be looked up
+)
+
+// changeSetStatus is the state of a recorded change set, reported as Data by
+// pr status with --output json
+type changeSetStatus struct {
+	ID      string                  `json:"id"`
+	Title   string                  `json:"title,omitempty"`
+	State   string                  `json:"state"`
+	Merged  int                     `json:"merged"`
+	Total   int                     `json:"total"`
+	Members []changeSetMemberStatus `json:"pull_requests"`
+	Failed  []string                `json:"failed,omitempty"` // keyboards without a PR
+}
+
+// changeSetMemberStatus is the state of one PR of a change set
+type changeSetMemberStatus struct {
+	changeset.PullRequest
+	State    string   `json:"state"` // "merged", "closed", "open" or "unknown"
+	Ready    bool     `json:"ready"` // open, approved, passing and mergeable
+	Blockers []string `json:"blockers,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// checkChangeSet looks up every PR of a set and settles the state of the set
+func checkChangeSet(clients forges, set *changeset.Set, errs keyboardErrors) changeSetStatus {
+	ctx := commandContext()
+	status := changeSetStatus{ID: set.ID, Title: set.Title, Total: len(set.PullRequests), Failed: set.Failed}
+	blocked, unknown, ready := false, false, 0
+	for _, member := range set.PullRequests {
+		memberStatus := changeSetMemberStatus{PullRequest: member, State: "unknown"}
+		client, upstream, err := changeSetRepo(clients, member)
+		var pr *forge.PullRequestStatus
+		if err == nil {
+			if pr, err = client.Status(ctx, upstream, member.Number); err != nil {
+				err = forgeError(member.Forge, fmt.Sprintf("%s#%d", member.Repo, member.Number), err)
+			}
+		}
+		switch {
+		case err != nil:
+			unknown = true
+			memberStatus.Error = err.Error()
+			errs[member.Keyboard] = fmt.Errorf("change set %s: %w", set.ID, err)
+		case pr.Merged:
+			memberStatus.State = "merged"
+			status.Merged++
+		case pr.State == "closed":
+			memberStatus.State = "closed"
+			memberStatus.Blockers = []string{"closed without merging"}
+		default:
+			memberStatus.State = "open"
+			memberStatus.Blockers = pullRequestBlockers(pr)
+			memberStatus.Ready = len(memberStatus.Blockers) == 0 &&
+				pr.ReviewDecision == forge.ReviewApproved &&
+				(pr.ChecksState == forge.ChecksPassing || pr.ChecksState == forge.ChecksNone) &&
+				pr.Mergeable != nil
+			if memberStatus.Ready {
+				ready++
+			}
+		}
+		blocked = blocked || len(memberStatus.Blockers) > 0
+		status.Members = append(status.Members, memberStatus)
+	}
+
+	switch {
+	case status.Merged == status.Total && len(status.Failed) == 0:
+		status.State = changeSetMerged
+	case blocked:
+		status.State = changeSetBlocked
+	case len(status.Failed) > 0:
+		status.State = changeSetIncomplete
+	case unknown:
+		status.State = changeSetUnknown
+	case status.Merged > 0:
+		status.State = changeSetPartiallyMerged
+	case ready == status.Total:
+		status.State = changeSetReady
+	default:
+		status.State = changeSetOpen
+	}
+	return status
+}
+
+// pullRequestBlockers lists what keeps an open PR from merging as it is
+func pullRequestBlockers(pr *forge.PullRequestStatus) []string {
+	var blockers []string
+	if pr.Draft {
+		blockers = append(blockers, "draft")
+	}
+	if pr.ReviewDecision == forge.ReviewChangesRequested {
+		blockers = append(blockers, "changes requested")
+	}
+	if pr.ChecksState == forge.ChecksFailing {
+		blockers = append(blockers, "checks failing")
+	}
+	if pr.Mergeable != nil && !*pr.Mergeable {
+		blockers = append(blockers, "merge conflicts")
+	}
+	return blockers
+}
+
+// printChangeSets reports every recorded change set as a whole
+func printChangeSets(clients forges, errs keyboardErrors) []changeSetStatus {
+	file, err := changeset.Load(changeset.DefaultPath)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		errs["change sets"] = err
+		return nil
+	}
+	if len(file.Sets) == 0 {
+		return nil
+	}
+
+	fmt.Printf("🔗 Change sets (%d):\n", len(file.Sets))
+	statuses := []changeSetStatus{}
+	for _, set := range file.Sets {
+		status := checkChangeSet(clients, set, errs)
+		statuses = append(statuses, status)
+
+		title := ""
+		if set.Title != "" {
+			title = " " + set.Title
+		}
+		fmt.Printf("   %s%s — %s\n", set.ID, title, describeChangeSet(status))
+		if status.State == changeSetMerged && !verbose {
+			continue
+		}
+		for _, member := range status.Members {
+			icon, state := describeChangeSetMember(member)
+			fmt.Printf("      %s %-10s %s#%d: %s\n", icon, member.Keyboard, member.Repo, member.Number, state)
+		}
+		for _, keyboard := range status.Failed {
+			fmt.Printf("      ❌ %-10s no pull request; add it with 'klcm pr create --apply --change-set %s'\n", keyboard, set.ID)
+		}
+	}
+	fmt.Println()
+	return statuses
+}
+
+func describeChangeSet(status changeSetStatus) string {
+	merged := fmt.Sprintf("%d/%d merged", status.Merged, status.Total)
+	switch status.State {
+	case changeSetMerged:
+		return "✅ all merged"
+	case changeSetBlocked:
+		return "🚧 blocked (" + merged + ")"
+	case changeSetIncomplete:
+		return fmt.Sprintf("🧩 incomplete, no PR for %s (%s)", strings.Join(status.Failed, ", "), merged)
+	case changeSetPartiallyMerged:
+		return "🟡 partially merged (" + merged + ")"
+	case changeSetReady:
+		return "🟢 ready to merge together"
+	case changeSetOpen:
+		return "⏳ open (" + merged + ")"
+	default:
+		return "❔ unknown (" + merged + ")"
+	}
+}
+
+// describeChangeSetMember returns the icon and state of a change set member
+func describeChangeSetMember(member changeSetMemberStatus) (string, string) {
+	switch {
+	case member.Error != "":
+		return "❌", member.Error
+	case member.State == "merged":
+		return "✅", "merged"
+	case len(member.Blockers) > 0:
+		return "🚧", strings.Join(member.Blockers, ", ")
+	case member.Ready:
+		return "🟢", "ready"
+	default:
+		return "⏳", "waiting for reviews or checks"
+	}
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// pushGitBranch commits the local keymap onto the base branch of a git source
+// and pushes the commit as branchName. The commit is built with git plumbing
+// in a scratch bare repository that holds only the fetched base commit: no
+// clone, no work tree and no change of the working directory, so several
+// repositories can be pushed at once.
+func pushGitBranch(ctx context.Context, repo RepoConfig, branchName string, log io.Writer) (prOutcome, error) {
+	content, err := os.ReadFile(filepath.Join(filepath.FromSlash(repo.LocalPath), repo.DefaultFile))
+	if err != nil {
+		return prOutcome{}, fmt.Errorf("failed to read local config: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "klcm-pr-*")
+	if err != nil {
+		return prOutcome{}, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	scratch := scratchRepo{dir: dir}
+
+	if _, err := scratch.git(ctx, nil, "init", "--quiet", "--bare"); err != nil {
+		return prOutcome{}, err
+	}
+	if _, err := scratch.git(ctx, nil, "fetch", "--quiet", "--depth", "1", repo.RemoteURL, "refs/heads/"+repo.BaseBranch); err != nil {
+		return prOutcome{}, fmt.Errorf("failed to fetch %s from %s: %w", repo.BaseBranch, repo.RemoteURL, err)
+	}
+	parent, err := scratch.object(ctx, nil, "rev-parse", "--verify", "FETCH_HEAD^{commit}")
+	if err != nil {
+		return prOutcome{}, err
+	}
+
+	path := filepath.ToSlash(repo.UpstreamPath)
+	var base []byte
+	if previous, err := scratch.git(ctx, nil, "show", parent+":"+path); err == nil {
+		base = previous
+		if bytes.Equal(base, content) {
+			return prOutcome{}, fmt.Errorf("%s %w", repo.UpstreamPath, errUpstreamMatches)
+		}
+	}
+
+	// The base tree with the keymap replaced, in a private index
+	blob, err := scratch.object(ctx, content, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return prOutcome{}, err
+	}
+	if _, err := scratch.git(ctx, nil, "read-tree", parent); err != nil {
+		return prOutcome{}, err
+	}
+	if _, err := scratch.git(ctx, nil, "update-index", "--add", "--cacheinfo", "100644,"+blob+","+path); err != nil {
+		return prOutcome{}, err
+	}
+	tree, err := scratch.object(ctx, nil, "write-tree")
+	if err != nil {
+		return prOutcome{}, err
+	}
+
+	if _, err := scratch.git(ctx, nil, "var", "GIT_COMMITTER_IDENT"); err != nil {
+		return prOutcome{}, fmt.Errorf("failed to commit changes: git has no identity to commit with; set user.name and user.email with git config --global")
+	}
+	commitMsg := describePR(repo, base, content, branchName).Title + "\n\nGenerated by KLCM (Keyboard Layout Configuration Mapper)"
+	commit, err := scratch.object(ctx, []byte(commitMsg), "commit-tree", tree, "-p", parent)
+	if err != nil {
+		return prOutcome{}, fmt.Errorf("failed to commit changes: %w", err)
+	}
+	if verbose {
+		fmt.Fprintf(log, "   🌿 Committed %s to %s\n", shortCommit(commit), branchName)
+	}
+
+	// Pushing a single ref is atomic: the branch either appears with the
+	// commit or not at all, so there is nothing to roll back
+	if _, err := scratch.git(ctx, nil, "push", "--quiet", repo.RemoteURL, commit+":refs/heads/"+branchName); err != nil {
+		return prOutcome{}, fmt.Errorf("failed to push branch: %w", err)
+	}
+
+	// Local git sources have no pull requests; the pushed branch is the result
+	return prOutcome{URL: fmt.Sprintf("%s (branch %s)", repo.RemoteURL, branchName), Branch: branchName}, nil
+}
+
+// scratchRepo is a temporary bare repository with its own index
+type scratchRepo struct {
+	dir string
+}
+
+// git runs git in the repository, feeding it stdin if not nil
+func (r scratchRepo) git(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", r.dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_INDEX_FILE="+filepath.Join(r.dir, "index"))
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+	return output, nil
+}
+
+// object runs git and returns the object name it prints
+func (r scratchRepo) object(ctx context.Context, stdin []byte, args ...string) (string, error) {
+	output, err := r.git(ctx, stdin, args...)
+	return strings.TrimSpace(string(output)), err
+}
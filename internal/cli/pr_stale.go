@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+)
+
+// klcmPRMarker tags the body of the pull requests klcm opens for a keyboard,
+// so later runs can find them again
+func klcmPRMarker(keyboard string) string {
+	return fmt.Sprintf("<!-- klcm:keyboard=%s -->", keyboard)
+}
+
+// isKLCMPullRequest reports whether klcm opened pr for keyboard
+func isKLCMPullRequest(pr forge.PullRequest, keyboard string) bool {
+	return strings.Contains(pr.Body, klcmPRMarker(keyboard))
+}
+
+// openKLCMPullRequests returns the open pull requests klcm opened for a
+// keyboard as the authenticated user, most recently updated first
+func openKLCMPullRequests(ctx context.Context, client forge.Forge, repo RepoConfig) ([]forge.PullRequest, error) {
+	user, err := client.AuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return klcmPullRequests(ctx, client, repo, user.Login, "open")
+}
+
+// klcmPullRequests returns the pull requests klcm opened for a keyboard as
+// login from branches of the upstream repository. Other people's klcm PRs,
+// and PRs from forks, are never pushed to, closed or cleaned up.
+func klcmPullRequests(ctx context.Context, client forge.Forge, repo RepoConfig, login, state string) ([]forge.PullRequest, error) {
+	upstream := repo.forgeRepo()
+	prs, err := client.PullRequests(ctx, upstream, forge.ListOptions{State: state, Base: repo.BaseBranch})
+	if err != nil {
+		return nil, err
+	}
+	var mine []forge.PullRequest
+	for _, pr := range prs {
+		if isKLCMPullRequest(pr, repo.Name) && pr.User.Login == login && pr.FromRepo(upstream) {
+			mine = append(mine, pr)
+		}
+	}
+	return mine, nil
+}
+
+// prCloseStaleCmd represents the pr close-stale command
+var prCloseStaleCmd = &cobra.Command{
+	Use:   "close-stale [keyboard...]",
+	Short: "Close superseded klcm PRs and delete their branches",
+	Long: `Clean up after 'klcm pr create --new' and earlier klcm versions that opened a
+new pull request on every run.
+
+For each keyboard, the most recently updated open klcm PR is kept. Older open
+klcm PRs for the same keyboard are closed with a comment pointing at the one
+kept, and their branches are deleted. Branches left behind by klcm PRs that
+were already closed or merged are deleted too.
+
+Only PRs you opened, from branches of the upstream repository, are touched;
+klcm PRs by other people or from forks are left alone.
+
+Nothing is changed until you confirm (or pass --yes).`,
+	Example: `  # See what would be cleaned up, then confirm
+  klcm pr close-stale
+
+  # Clean up one keyboard without prompting
+  klcm pr close-stale glove80 --yes`,
+	RunE: runPRCloseStale,
+}
+
+// staleCleanup is the work close-stale found for one repository
+type staleCleanup struct {
+	repo     RepoConfig
+	client   forge.Forge
+	upstream forge.Repo
+	keep     *forge.PullRequest
+	close    []forge.PullRequest
+	branches []string // branches of closed klcm PRs
+}
+
+func runPRCloseStale(cmd *cobra.Command, args []string) error {
+	ctx := commandContext()
+	clients := make(forges)
+
+	selected := make(map[string]bool)
+	for _, name := range args {
+		selected[name] = true
+	}
+
+	fmt.Println("🔍 Looking for superseded klcm pull requests...")
+	fmt.Println()
+
+	var cleanups []staleCleanup
+	errs := make(keyboardErrors)
+	checked := 0
+	for _, repo := range repoConfigs() {
+		if (len(selected) > 0 && !selected[repo.Name]) || repo.Forge == "" {
+			continue
+		}
+		checked++
+		client, err := clients.forRepo(repo)
+		if err != nil {
+			fmt.Printf("   🔑 %s: %v\n", repo.Name, err)
+			errs[repo.Name] = err
+			continue
+		}
+		cleanup, err := findStale(ctx, client, repo)
+		if err != nil {
+			fmt.Printf("   ❌ %s: %v\n", repo.Name, err)
+			errs[repo.Name] = err
+			continue
+		}
+		if len(cleanup.close) == 0 && len(cleanup.branches) == 0 {
+			fmt.Printf("   ✅ %s: nothing stale\n", repo.Name)
+			continue
+		}
+		fmt.Printf("   📁 %s (%s)\n", repo.Name, cleanup.upstream)
+		for _, pr := range cleanup.close {
+			fmt.Printf("      🔒 close #%d %s (superseded by #%d) and delete %s\n", pr.Number, pr.Title, cleanup.keep.Number, pr.Head.Ref)
+		}
+		for _, branch := range cleanup.branches {
+			fmt.Printf("      🗑️  delete branch %s (its PR is closed)\n", branch)
+		}
+		cleanups = append(cleanups, cleanup)
+	}
+	if err := errs.summary(cmd, checked); err != nil {
+		return err
+	}
+	if len(cleanups) == 0 {
+		fmt.Println("\n✅ No stale klcm pull requests or branches")
+		return nil
+	}
+
+	fmt.Println()
+	if !confirm("Close these PRs and delete these branches? (y/N): ", false) {
+		for _, cleanup := range cleanups {
+			reportDrift(cleanup.repo.Name, cleanup.upstream.String(), fmt.Sprintf("%d stale PR(s) and %d branch(es) left in place", len(cleanup.close), len(cleanup.branches)+len(cleanup.close)))
+		}
+		fmt.Println("⏭️  Nothing changed")
+		return nil
+	}
+
+	for _, cleanup := range cleanups {
+		if err := applyCleanup(ctx, cleanup); err != nil {
+			fmt.Printf("   ❌ %s: %v\n", cleanup.repo.Name, err)
+			errs[cleanup.repo.Name] = err
+		}
+	}
+	if err := errs.summary(cmd, len(cleanups)); err != nil {
+		return err
+	}
+	fmt.Println("\n🧹 Stale klcm pull requests cleaned up")
+	return nil
+}
+
+// findStale lists the superseded open PRs and leftover branches of a repository
+func findStale(ctx context.Context, client forge.Forge, repo RepoConfig) (staleCleanup, error) {
+	upstream := repo.forgeRepo()
+	cleanup := staleCleanup{repo: repo, client: client, upstream: upstream}
+
+	user, err := client.AuthenticatedUser(ctx)
+	if err != nil {
+		return cleanup, forgeError(repo.Forge, fmt.Sprintf("failed to identify the %s user", client.Name()), err)
+	}
+	open, err := klcmPullRequests(ctx, client, repo, user.Login, "open")
+	if err != nil {
+		return cleanup, forgeError(repo.Forge, upstream.String(), err)
+	}
+	inUse := make(map[string]bool)
+	if len(open) > 0 {
+		cleanup.keep = &open[0]
+		inUse[open[0].Head.Ref] = true
+		cleanup.close = open[1:]
+	}
+
+	closed, err := klcmPullRequests(ctx, client, repo, user.Login, "closed")
+	if err != nil {
+		return cleanup, forgeError(repo.Forge, upstream.String(), err)
+	}
+	for _, pr := range closed {
+		branch := pr.Head.Ref
+		if inUse[branch] {
+			continue
+		}
+		inUse[branch] = true
+		if _, err := client.BranchHead(ctx, upstream, branch); err == nil {
+			cleanup.branches = append(cleanup.branches, branch)
+		} else if !forge.IsNotFound(err) {
+			return cleanup, forgeError(repo.Forge, upstream.String(), err)
+		}
+	}
+	return cleanup, nil
+}
+
+// applyCleanup closes superseded PRs and deletes leftover branches
+func applyCleanup(ctx context.Context, cleanup staleCleanup) error {
+	for _, pr := range cleanup.close {
+		comment := fmt.Sprintf("Superseded by #%d; closed by `klcm pr close-stale`.", cleanup.keep.Number)
+		if err := cleanup.client.Comment(ctx, cleanup.upstream, pr.Number, comment); err != nil {
+			return forgeError(cleanup.repo.Forge, cleanup.upstream.String(), err)
+		}
+		if _, err := cleanup.client.EditPullRequest(ctx, cleanup.upstream, pr.Number, forge.PullRequestEdit{State: "closed"}); err != nil {
+			return forgeError(cleanup.repo.Forge, cleanup.upstream.String(), err)
+		}
+		fmt.Printf("   🔒 %s: closed #%d\n", cleanup.repo.Name, pr.Number)
+		reportChanged(cleanup.repo.Name, pr.HTMLURL, fmt.Sprintf("closed #%d, superseded by #%d", pr.Number, cleanup.keep.Number))
+		if err := deleteBranch(ctx, cleanup, pr.Head.Ref); err != nil {
+			return err
+		}
+	}
+	for _, branch := range cleanup.branches {
+		if err := deleteBranch(ctx, cleanup, branch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteBranch(ctx context.Context, cleanup staleCleanup, branch string) error {
+	if err := cleanup.client.DeleteBranch(ctx, cleanup.upstream, branch); err != nil {
+		return forgeError(cleanup.repo.Forge, cleanup.upstream.String(), err)
+	}
+	fmt.Printf("   🗑️  %s: deleted branch %s\n", cleanup.repo.Name, branch)
+	reportChanged(cleanup.repo.Name, cleanup.upstream.String()+":"+branch, "deleted branch")
+	return nil
+}
+
+func init() {
+	prCmd.AddCommand(prCloseStaleCmd)
+}
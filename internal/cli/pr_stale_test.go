@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+)
+
+// openTestPR opens a klcm PR for glove80 from branch with the given keymap
+func (ws *prWorkspace) openTestPR(t *testing.T, branch, bindings string) int {
+	t.Helper()
+	ws.writeKeymap(t, testKeymap(bindings))
+	outcome, err := createForgePR(ws.github.Client(), ws.repo, branch, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return outcome.Number
+}
+
+func TestPRCloseStale(t *testing.T) {
+	ws := newPRWorkspace(t)
+	prNew, assumeYes = true, true
+	t.Cleanup(func() { prNew, assumeYes = false, false })
+	client := ws.github.Client()
+	upstream := ws.repo.forgeRepo()
+	ctx := context.Background()
+
+	// A closed klcm PR whose branch was left behind
+	closed := ws.openTestPR(t, "klcm-sync-1", "&kp A &kp C")
+	if _, err := client.EditPullRequest(ctx, upstream, closed, forge.PullRequestEdit{State: "closed"}); err != nil {
+		t.Fatal(err)
+	}
+	older := ws.openTestPR(t, "klcm-sync-2", "&kp A &kp D")
+	newest := ws.openTestPR(t, "klcm-sync-3", "&kp A &kp E")
+
+	// Someone else's klcm PR, and one of ours from a fork whose branch name
+	// is also taken upstream
+	ws.github.Commit(testOwner, testRepo, "their-branch", testRemotePath, "theirs\n")
+	ws.github.Commit(testOwner, testRepo, "klcm-sync-fork", testRemotePath, "upstream\n")
+	foreign := ws.github.AddPullRequest(testOwner, testRepo, forge.PullRequest{
+		Title: "Their keymap",
+		Body:  klcmPRMarker("glove80"),
+		User:  forge.User{Login: "someone-else"},
+		Head:  forge.PRBranch{Ref: "their-branch"},
+		Base:  forge.PRBranch{Ref: "main"},
+	})
+	fork := ws.github.AddPullRequest(testOwner, testRepo, forge.PullRequest{
+		Title: "From my fork",
+		Body:  klcmPRMarker("glove80"),
+		User:  forge.User{Login: ws.github.Login},
+		Head:  forge.PRBranch{Ref: "klcm-sync-fork", Repo: &forge.RepoRef{FullName: ws.github.Login + "/" + testRepo}},
+		Base:  forge.PRBranch{Ref: "main"},
+	})
+	if _, err := client.EditPullRequest(ctx, upstream, fork, forge.PullRequestEdit{State: "closed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runPRCloseStale(prCloseStaleCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	states := make(map[int]string)
+	for _, pr := range ws.pullRequests() {
+		states[pr.Number] = pr.State
+	}
+	want := map[int]string{closed: "closed", older: "closed", newest: "open", foreign: "open", fork: "closed"}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("PR states = %v, want %v", states, want)
+	}
+	if comments := ws.github.Comments(testOwner, testRepo, older); len(comments) != 1 || !strings.Contains(comments[0], "Superseded by #") {
+		t.Errorf("comments on #%d = %q, want one pointing at #%d", older, comments, newest)
+	}
+	if comments := ws.github.Comments(testOwner, testRepo, foreign); len(comments) != 0 {
+		t.Errorf("someone else's PR got comments %q", comments)
+	}
+	branches := ws.github.Branches(testOwner, testRepo)
+	if want := []string{"klcm-sync-3", "klcm-sync-fork", "main", "their-branch"}; !reflect.DeepEqual(branches, want) {
+		t.Errorf("branches = %v, want %v", branches, want)
+	}
+}
+
+func TestPRCloseStaleNothingToDo(t *testing.T) {
+	ws := newPRWorkspace(t)
+	ws.openTestPR(t, "klcm-sync-1", "&kp A &kp C")
+
+	if err := runPRCloseStale(prCloseStaleCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Changed) != 0 || len(result.Drift) != 0 {
+		t.Errorf("result = %+v, want nothing changed or left", result)
+	}
+	if got := ws.pullRequests(); len(got) != 1 || got[0].State != "open" {
+		t.Errorf("PRs = %+v, want the only PR left open", got)
+	}
+}
@@ -0,0 +1,335 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/changeset"
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+	"masters3d.com/keyboard_layout_config_mapper/internal/github/githubtest"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+)
+
+const (
+	testOwner      = "moergo-sc"
+	testRepo       = "glove80-zmk-config"
+	testLocalPath  = "configs/zmk_glove80/glove80.keymap"
+	testRemotePath = "config/glove80.keymap"
+)
+
+func testKeymap(bindings string) string {
+	return "/ {\n\tkeymap {\n\t\tcompatible = \"zmk,keymap\";\n\t\tbase {\n\t\t\tbindings = <" + bindings + ">;\n\t\t};\n\t};\n};\n"
+}
+
+// prWorkspace is a temporary klcm workspace whose glove80 keymap has an
+// upstream repository on a fake GitHub
+type prWorkspace struct {
+	github *githubtest.Server
+	repo   RepoConfig
+}
+
+func newPRWorkspace(t *testing.T) *prWorkspace {
+	t.Helper()
+	chdirTemp(t)
+
+	previousRegistry := registry.Current()
+	registry.SetCurrent(registry.New(registry.Keyboard{
+		Name:         "glove80",
+		Type:         "zmk",
+		Owner:        testOwner,
+		Repo:         testRepo,
+		BaseBranch:   "main",
+		LocalPath:    testLocalPath,
+		UpstreamPath: testRemotePath,
+	}))
+	t.Cleanup(func() { registry.SetCurrent(previousRegistry) })
+
+	server := githubtest.NewServer()
+	server.Token = "klcm-test-token"
+	t.Cleanup(server.Close)
+	server.AddRepo(testOwner, testRepo, "main", map[string]string{testRemotePath: testKeymap("&kp A &kp B")})
+	t.Setenv("GITHUB_TOKEN", server.Token)
+	t.Setenv("GITHUB_API_URL", server.URL)
+
+	captureResult(t)
+	ws := &prWorkspace{github: server}
+	ws.writeKeymap(t, testKeymap("&kp A &kp C"))
+	repos := repoConfigs()
+	if len(repos) != 1 {
+		t.Fatalf("repoConfigs() = %+v, want glove80", repos)
+	}
+	ws.repo = repos[0]
+	return ws
+}
+
+func (ws *prWorkspace) writeKeymap(t *testing.T, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(testLocalPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(testLocalPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (ws *prWorkspace) pullRequests() []forge.PullRequest {
+	return ws.github.PullRequests(testOwner, testRepo)
+}
+
+func TestPRCreateOpensThenUpdates(t *testing.T) {
+	ws := newPRWorkspace(t)
+
+	if err := createActualPRs(prCreateCmd, []RepoConfig{ws.repo}); err != nil {
+		t.Fatal(err)
+	}
+	prs := ws.pullRequests()
+	if len(prs) != 1 {
+		t.Fatalf("repository has %d PRs, want 1", len(prs))
+	}
+	pr := prs[0]
+	if !strings.HasPrefix(pr.Head.Ref, "klcm-sync-") || pr.Base.Ref != "main" || !isKLCMPullRequest(pr, "glove80") {
+		t.Errorf("PR = %s → %s, want a marked klcm-sync-* branch into main", pr.Head.Ref, pr.Base.Ref)
+	}
+	if got, _ := ws.github.File(testOwner, testRepo, pr.Head.Ref, testRemotePath); got != testKeymap("&kp A &kp C") {
+		t.Errorf("keymap on %s = %q, want the local keymap", pr.Head.Ref, got)
+	}
+	if len(result.Changed) != 1 || result.Changed[0].Message != "created" {
+		t.Errorf("result = %+v, want glove80 created", result.Changed)
+	}
+
+	// A later run adds to the open PR instead of opening another
+	result = newResult()
+	ws.writeKeymap(t, testKeymap("&kp A &kp D"))
+	if err := createActualPRs(prCreateCmd, []RepoConfig{ws.repo}); err != nil {
+		t.Fatal(err)
+	}
+	prs = ws.pullRequests()
+	if len(prs) != 1 || prs[0].Number != pr.Number {
+		t.Fatalf("PRs after the second run = %+v, want only #%d", prs, pr.Number)
+	}
+	if got, _ := ws.github.File(testOwner, testRepo, pr.Head.Ref, testRemotePath); got != testKeymap("&kp A &kp D") {
+		t.Errorf("keymap on %s = %q, want the updated local keymap", pr.Head.Ref, got)
+	}
+	if len(result.Changed) != 1 || !strings.HasPrefix(result.Changed[0].Message, "updated: pushed") {
+		t.Errorf("result = %+v, want glove80 updated with a push", result.Changed)
+	}
+
+	// Nothing new to push only refreshes the description
+	result = newResult()
+	if err := createActualPRs(prCreateCmd, []RepoConfig{ws.repo}); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Changed) != 1 || !strings.Contains(result.Changed[0].Message, "branch already up to date") {
+		t.Errorf("result = %+v, want the description refreshed", result.Changed)
+	}
+}
+
+func TestPRCreateFailsWhenUpstreamMatches(t *testing.T) {
+	ws := newPRWorkspace(t)
+	ws.writeKeymap(t, testKeymap("&kp A &kp B"))
+
+	err := createActualPRs(prCreateCmd, []RepoConfig{ws.repo})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != exitError {
+		t.Fatalf("createActualPRs() = %v, want exit %d", err, exitError)
+	}
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "already matches") {
+		t.Errorf("errors = %+v, want upstream already matches", result.Errors)
+	}
+	if got := ws.github.Branches(testOwner, testRepo); !reflect.DeepEqual(got, []string{"main"}) {
+		t.Errorf("branches = %v, want main only", got)
+	}
+}
+
+// failingPulls is a forge that cannot open pull requests
+type failingPulls struct {
+	forge.Forge
+}
+
+func (failingPulls) CreatePullRequest(ctx context.Context, repo forge.Repo, pr forge.NewPullRequest) (*forge.PullRequest, error) {
+	return nil, errors.New("pull requests are disabled")
+}
+
+func TestPRCreateRollsBackBranch(t *testing.T) {
+	ws := newPRWorkspace(t)
+
+	var log bytes.Buffer
+	_, err := createForgePR(failingPulls{ws.github.Client()}, ws.repo, "klcm-sync-test", &log)
+	if err == nil || !strings.Contains(err.Error(), "pull requests are disabled") || !strings.Contains(err.Error(), "deleted branch klcm-sync-test again") {
+		t.Fatalf("createForgePR() = %v, want the failure and the rollback", err)
+	}
+	if got := ws.github.Branches(testOwner, testRepo); !reflect.DeepEqual(got, []string{"main"}) {
+		t.Errorf("branches = %v, want the pushed branch deleted", got)
+	}
+}
+
+func TestPRStatus(t *testing.T) {
+	ws := newPRWorkspace(t)
+	if err := createActualPRs(prCreateCmd, []RepoConfig{ws.repo}); err != nil {
+		t.Fatal(err)
+	}
+	number := ws.pullRequests()[0].Number
+	ws.github.AddReview(testOwner, testRepo, number, "reviewer", "APPROVED")
+	ws.github.SetChecks(testOwner, testRepo, number, forge.Check{Name: "build", Status: "completed", Conclusion: "failure"})
+
+	result = newResult()
+	if err := runPRStatus(prStatusCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	report, ok := result.Data.(prStatusReport)
+	if !ok {
+		t.Fatalf("result data = %T, want prStatusReport", result.Data)
+	}
+	statuses := report.PullRequests["glove80"]
+	if len(statuses) != 1 || statuses[0].Number != number {
+		t.Fatalf("statuses = %+v, want PR #%d", statuses, number)
+	}
+	if statuses[0].ReviewDecision != forge.ReviewApproved || statuses[0].ChecksState != forge.ChecksFailing {
+		t.Errorf("status = %s/%s, want %s/%s", statuses[0].ReviewDecision, statuses[0].ChecksState, forge.ReviewApproved, forge.ChecksFailing)
+	}
+}
+
+// addAdv360 registers a second keyboard, adv360, whose repository does not
+// exist on the fake yet, and starts a change set
+func (ws *prWorkspace) addAdv360(t *testing.T) {
+	t.Helper()
+	registry.SetCurrent(registry.New(registry.Current().All()[0], registry.Keyboard{
+		Name:         "adv360",
+		Type:         "zmk",
+		Owner:        testOwner,
+		Repo:         "adv360-config",
+		BaseBranch:   "main",
+		LocalPath:    "configs/zmk_adv360/adv360.keymap",
+		UpstreamPath: "config/adv360.keymap",
+	}))
+	if err := os.MkdirAll("configs/zmk_adv360", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("configs/zmk_adv360/adv360.keymap", []byte(testKeymap("&kp A &kp C")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prChangeSet = "Swap C"
+	t.Cleanup(func() { prChangeSet = "" })
+}
+
+// loadChangeSet returns the only recorded change set
+func loadChangeSet(t *testing.T) *changeset.Set {
+	t.Helper()
+	file, err := changeset.Load(changeset.DefaultPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Sets) != 1 {
+		t.Fatalf("recorded sets = %+v, want one", file.Sets)
+	}
+	return file.Sets[0]
+}
+
+func TestPRCreateChangeSetRecordsFailures(t *testing.T) {
+	ws := newPRWorkspace(t)
+	ws.addAdv360(t)
+
+	// adv360's repository does not exist, so only glove80 gets a PR
+	repos := repoConfigs()
+	if err := createActualPRs(prCreateCmd, repos); err == nil {
+		t.Fatal("createActualPRs() succeeded although adv360 failed")
+	}
+	set := loadChangeSet(t)
+	if len(set.PullRequests) != 1 || set.PullRequests[0].Keyboard != "glove80" || !reflect.DeepEqual(set.Failed, []string{"adv360"}) {
+		t.Fatalf("set = %+v, want glove80's PR with adv360 failed", set)
+	}
+	body := ws.pullRequests()[0].Body
+	if !strings.Contains(body, "| adv360 | ❌ not opened yet |") || !strings.Contains(body, "**Incomplete:**") {
+		t.Errorf("glove80 PR body does not name the missing adv360 PR:\n%s", body)
+	}
+
+	result = newResult()
+	runPRStatus(prStatusCmd, nil)
+	report := result.Data.(prStatusReport)
+	if len(report.ChangeSets) != 1 || report.ChangeSets[0].State != changeSetIncomplete || !reflect.DeepEqual(report.ChangeSets[0].Failed, []string{"adv360"}) {
+		t.Errorf("change sets = %+v, want %s incomplete without adv360", report.ChangeSets, set.ID)
+	}
+
+	// Once the repository exists, adding adv360 completes the set
+	ws.github.AddRepo(testOwner, "adv360-config", "main", map[string]string{"config/adv360.keymap": testKeymap("&kp A &kp B")})
+	prChangeSet = set.ID
+	result = newResult()
+	if err := createActualPRs(prCreateCmd, repos); err != nil {
+		t.Fatal(err)
+	}
+	set = loadChangeSet(t)
+	if len(set.PullRequests) != 2 || len(set.Failed) != 0 {
+		t.Errorf("set = %+v, want both PRs and no failures", set)
+	}
+	if body := ws.pullRequests()[0].Body; strings.Contains(body, "Incomplete") || !strings.Contains(body, "adv360-config#1") {
+		t.Errorf("glove80 PR body still marks the set incomplete:\n%s", body)
+	}
+}
+
+func TestPRCreateChangeSetKeepsMergedMembers(t *testing.T) {
+	ws := newPRWorkspace(t)
+	ws.addAdv360(t)
+	repos := repoConfigs()
+	createActualPRs(prCreateCmd, repos)
+	set := loadChangeSet(t)
+
+	// glove80's PR is merged before adv360 is added, so its upstream now
+	// matches the local keymap
+	ws.github.Merge(testOwner, testRepo, set.PullRequests[0].Number)
+	ws.github.AddRepo(testOwner, "adv360-config", "main", map[string]string{"config/adv360.keymap": testKeymap("&kp A &kp B")})
+	prChangeSet = set.ID
+	result = newResult()
+	if err := createActualPRs(prCreateCmd, repos); err != nil {
+		t.Fatalf("createActualPRs() = %v, want glove80 skipped rather than failed", err)
+	}
+	set = loadChangeSet(t)
+	if len(set.PullRequests) != 2 || len(set.Failed) != 0 {
+		t.Errorf("set = %+v, want both PRs and no failures", set)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Keyboard != "glove80" {
+		t.Errorf("skipped = %+v, want glove80", result.Skipped)
+	}
+
+	result = newResult()
+	runPRStatus(prStatusCmd, nil)
+	report := result.Data.(prStatusReport)
+	if len(report.ChangeSets) != 1 || report.ChangeSets[0].State != changeSetPartiallyMerged {
+		t.Errorf("change sets = %+v, want %s partially merged", report.ChangeSets, set.ID)
+	}
+	if body := ws.pullRequests()[0].Body; strings.Contains(body, "not opened yet") {
+		t.Errorf("glove80 PR body calls its own PR missing:\n%s", body)
+	}
+}
+
+func TestPRCreateLeavesOthersPRsAlone(t *testing.T) {
+	ws := newPRWorkspace(t)
+	ws.github.Commit(testOwner, testRepo, "their-branch", testRemotePath, "theirs\n")
+	theirs := ws.github.AddPullRequest(testOwner, testRepo, forge.PullRequest{
+		Title: "Their keymap",
+		Body:  klcmPRMarker("glove80"),
+		User:  forge.User{Login: "someone-else"},
+		Head:  forge.PRBranch{Ref: "their-branch"},
+		Base:  forge.PRBranch{Ref: "main"},
+	})
+
+	if err := createActualPRs(prCreateCmd, []RepoConfig{ws.repo}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ws.github.File(testOwner, testRepo, "their-branch", testRemotePath); got != "theirs\n" {
+		t.Errorf("their branch holds %q, want it untouched", got)
+	}
+	for _, pr := range ws.pullRequests() {
+		if pr.Number == theirs && pr.Title != "Their keymap" {
+			t.Errorf("their PR was retitled %q", pr.Title)
+		}
+	}
+	if len(result.Changed) != 1 || result.Changed[0].Message != "created" {
+		t.Errorf("result = %+v, want a PR of our own created", result.Changed)
+	}
+}
@@ -2,21 +2,24 @@ package cli
 
 import (
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/lock"
 	"masters3d.com/keyboard_layout_config_mapper/internal/models"
 	"masters3d.com/keyboard_layout_config_mapper/internal/parsers"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
 )
 
 var (
 	pullPreview bool
 	pullAll     bool
+	pullLocked  bool
+
+	// pullLockEntries holds klcm.lock while pulling with --locked
+	pullLockEntries map[string]lock.Entry
 )
 
 // pullCmd represents the pull command
@@ -26,7 +29,11 @@ var pullCmd = &cobra.Command{
 	Long: `Pull command downloads the latest configuration files from the remote repository.
 	
 Similar to 'git pull', this updates your local configurations with the latest remote versions.
-Supports preview mode to see changes before applying them.`,
+Supports preview mode to see changes before applying them.
+
+Each pull records the upstream commit and content hash in klcm.lock. With
+--locked, pull fetches exactly the locked commit instead of the branch head
+and fails if the content does not match the lock.`,
 	Example: `  # Pull updates for all keyboards
   klcm pull
 
@@ -37,7 +44,10 @@ Supports preview mode to see changes before applying them.`,
   klcm pull --preview
 
   # Preview changes for specific keyboards  
-  klcm pull --preview adv360`,
+  klcm pull --preview adv360
+
+  # Reproduce the keymaps recorded in klcm.lock
+  klcm pull --locked`,
 	RunE: runPull,
 }
 
@@ -49,8 +59,28 @@ func runPull(cmd *cobra.Command, args []string) error {
 	// Determine which keyboards to pull
 	keyboards := args
 	if len(keyboards) == 0 || pullAll {
-		// Default to all supported keyboards
-		keyboards = []string{"adv360", "glove80", "adv_mod"}
+		// Default to all registered keyboards
+		keyboards = registry.Current().Names()
+	}
+
+	if pullLocked {
+		var err error
+		if pullLockEntries, err = pinToLock(keyboards); err != nil {
+			return err
+		}
+	} else if !pullPreview {
+		recordRevisions = true
+	}
+
+	format, err := selectedDiffFormat()
+	if err != nil {
+		return err
+	}
+	if format != DiffFormatText {
+		if !pullPreview {
+			return fmt.Errorf("--diff-format %s requires --preview", format)
+		}
+		return writePullDiffReport(keyboards, format)
 	}
 
 	if pullPreview {
@@ -58,6 +88,8 @@ func runPull(cmd *cobra.Command, args []string) error {
 		fmt.Println("📋 Preview mode - no changes will be applied")
 	}
 
+	prefetchKeyboards(keyboards)
+	errs := make(keyboardErrors)
 	for _, keyboard := range keyboards {
 		keyboardType := models.KeyboardType(keyboard)
 		
@@ -65,32 +97,33 @@ func runPull(cmd *cobra.Command, args []string) error {
 		configPath, err := parsers.GetConfigPath(keyboardType)
 		if err != nil {
 			fmt.Printf("❌ Failed to get config path for %s: %v\n", keyboard, err)
+			errs[keyboard] = err
 			continue
 		}
 
 		if err := pullKeyboard(keyboardType, configPath, pullPreview); err != nil {
 			fmt.Printf("❌ Failed to pull %s: %v\n", keyboard, err)
+			errs[keyboard] = err
 			continue
 		}
 	}
 
-	return nil
+	return errs.summary(cmd, len(keyboards))
 }
 
 func pullKeyboard(keyboardType models.KeyboardType, configPath string, preview bool) error {
-	// Get remote URL for this keyboard
-	remoteURL, err := getRemoteURL(keyboardType)
-	if err != nil {
-		return fmt.Errorf("failed to get remote URL: %v", err)
-	}
-
 	fmt.Printf("📁 %s\n", filepath.Base(configPath))
 	fmt.Println("  📡 Fetching remote version...")
 
 	// Fetch remote content
-	remoteContent, err := fetchRemoteContent(remoteURL)
+	remoteContent, err := fetchKeyboardRemote(string(keyboardType))
 	if err != nil {
-		return fmt.Errorf("failed to fetch remote content: %v", err)
+		return err
+	}
+	if pullLocked {
+		if err := verifyLocked(string(keyboardType), remoteContent, pullLockEntries); err != nil {
+			return err
+		}
 	}
 
 	// Read local content if it exists
@@ -108,7 +141,10 @@ func pullKeyboard(keyboardType models.KeyboardType, configPath string, preview b
 	// Compare content
 	if localContent == remoteContent {
 		fmt.Println("  ✅ Already up to date")
-		return nil
+		if preview || pullLocked {
+			return nil
+		}
+		return lockKeyboard(string(keyboardType), remoteContent)
 	}
 
 	// Show differences
@@ -125,17 +161,14 @@ func pullKeyboard(keyboardType models.KeyboardType, configPath string, preview b
 	}
 
 	if preview {
+		reportDrift(string(keyboardType), filepath.ToSlash(configPath), "remote has changes (preview)")
 		return nil // Don't actually apply changes in preview mode
 	}
 
 	// Ask for confirmation
-	fmt.Print("\n❓ Apply changes? (y/N): ")
-	var response string
-	fmt.Scanln(&response)
-	response = strings.ToLower(strings.TrimSpace(response))
-	
-	if response != "y" && response != "yes" {
+	if !confirm("\n❓ Apply changes? (y/N): ", false) {
 		fmt.Println("❌ Pull cancelled")
+		reportDrift(string(keyboardType), filepath.ToSlash(configPath), "remote has changes (not applied)")
 		return nil
 	}
 
@@ -144,50 +177,48 @@ func pullKeyboard(keyboardType models.KeyboardType, configPath string, preview b
 		return fmt.Errorf("failed to create directory: %v", err)
 	}
 
-	if err := os.WriteFile(configPath, []byte(remoteContent), 0644); err != nil {
+	if err := writeKeymap(configPath, []byte(remoteContent)); err != nil {
 		return fmt.Errorf("failed to write file: %v", err)
 	}
 
 	fmt.Println("  ✅ Changes applied successfully")
-	return nil
-}
-
-func getRemoteURL(keyboardType models.KeyboardType) (string, error) {
-	baseURL := "https://raw.githubusercontent.com/masters3d/keyboard_layout_config_mapper/v7_target/configs"
-	
-	switch keyboardType {
-	case models.KeyboardZMKAdv360:
-		return baseURL + "/zmk_adv360/adv360.keymap", nil
-	case models.KeyboardZMKGlove80:
-		return baseURL + "/zmk_glove80/glove80.keymap", nil
-	case models.KeyboardZMKAdvMod:
-		return "https://raw.githubusercontent.com/masters3d/zmk-config-pillzmod-nicenano/cheyo/config/pillzmod_pro.keymap", nil
-	default:
-		return "", fmt.Errorf("unsupported keyboard type: %s", keyboardType)
+	reportChanged(string(keyboardType), filepath.ToSlash(configPath), "pulled remote changes")
+	if pullLocked {
+		return nil
 	}
+	return lockKeyboard(string(keyboardType), remoteContent)
 }
 
-func fetchRemoteContent(url string) (string, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	
-	resp, err := client.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
-	}
-	
-	content, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+// writePullDiffReport fetches each keyboard's remote keymap and writes the
+// local → remote differences as a single report
+func writePullDiffReport(keyboards []string, format DiffFormat) error {
+	prefetchKeyboards(keyboards)
+	var files []FileDiff
+	for _, keyboard := range keyboards {
+		keyboardType := models.KeyboardType(keyboard)
+		configPath, err := parsers.GetConfigPath(keyboardType)
+		if err != nil {
+			return err
+		}
+		remoteContent, err := fetchKeyboardRemote(keyboard)
+		if err != nil {
+			return err
+		}
+
+		localBytes, err := os.ReadFile(configPath)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read local file: %v", err)
+		}
+		if string(localBytes) == remoteContent {
+			continue
+		}
+
+		fd := newFileDiff(keyboard, filepath.ToSlash(configPath), "local", "remote", string(localBytes), remoteContent)
+		fd.OldMissing = os.IsNotExist(err)
+		files = append(files, fd)
 	}
-	
-	return string(content), nil
+
+	return writeDiffReport(format, files)
 }
 
 func init() {
@@ -195,4 +226,6 @@ func init() {
 
 	pullCmd.Flags().BoolVarP(&pullPreview, "preview", "p", false, "preview changes without applying")
 	pullCmd.Flags().BoolVar(&pullAll, "all", false, "pull updates for all keyboards")
+	pullCmd.Flags().BoolVar(&pullLocked, "locked", false, "fetch the revisions recorded in klcm.lock instead of the branch heads")
+	addDiffFormatFlags(pullCmd)
 }
\ No newline at end of file
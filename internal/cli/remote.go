@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+	"masters3d.com/keyboard_layout_config_mapper/internal/remote"
+)
+
+var (
+	offline bool
+	jobs    int
+)
+
+// fetchResult is a remote keymap, or why it could not be fetched
+type fetchResult struct {
+	content  string
+	source   string // where it came from, unpinned
+	ref      string // branch or tag the source follows
+	revision string // commit it was read at, if known
+	unpinned error  // why revision is empty, if resolving it failed
+	err      error
+}
+
+var (
+	// recordRevisions resolves the commit behind each fetch, for klcm.lock
+	recordRevisions bool
+	// pinnedCommits fetches keyboards at the commits in klcm.lock
+	pinnedCommits map[string]string
+)
+
+// fetched memoizes remote content per keyboard for the rest of the run, so a
+// preview followed by apply fetches each keymap once
+var (
+	fetchedMu sync.Mutex
+	fetched   = make(map[string]fetchResult)
+)
+
+// commandContext returns the context of the running command, which is
+// cancelled on interrupt
+func commandContext() context.Context {
+	if ctx := rootCmd.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// remoteOptions returns the source options selected by the global flags
+func remoteOptions() remote.Options {
+	opts := remote.Options{Offline: offline, Auth: githubAuth()}
+	cache, err := remote.DefaultCache()
+	if err != nil {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "⚠️  Remote cache disabled: %v\n", err)
+		}
+		return opts
+	}
+	opts.Cache = cache
+	return opts
+}
+
+// prefetchKeyboards fetches the remote keymaps of several keyboards
+// concurrently, at most --jobs at a time, so the commands that then walk
+// them one by one find them already fetched. Failures are kept per keyboard.
+func prefetchKeyboards(names []string) {
+	var pending []registry.Keyboard
+	var sources []remote.Source
+	fetchedMu.Lock()
+	for _, name := range names {
+		if _, ok := fetched[name]; ok {
+			continue
+		}
+		kb, err := registry.Current().Lookup(name)
+		if err == nil {
+			var source remote.Source
+			if source, err = keyboardSource(kb); err == nil {
+				pending = append(pending, kb)
+				sources = append(sources, source)
+				continue
+			}
+		}
+		fetched[name] = fetchResult{err: err}
+	}
+	fetchedMu.Unlock()
+
+	if len(sources) == 0 {
+		return
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "📡 Fetching %d keymap(s), %d at a time...\n", len(sources), jobs)
+	}
+
+	results := remote.FetchAll(commandContext(), sources, jobs)
+	for i, result := range results {
+		recordFetch(pending[i], sources[i], result.Content, result.Err)
+	}
+}
+
+// fetchKeyboardRemote fetches a keyboard's keymap from its remote source
+func fetchKeyboardRemote(keyboard string) (string, error) {
+	fetchedMu.Lock()
+	memo, ok := fetched[keyboard]
+	fetchedMu.Unlock()
+	if ok {
+		return memo.content, memo.err
+	}
+
+	kb, err := registry.Current().Lookup(keyboard)
+	if err != nil {
+		return "", err
+	}
+	source, err := keyboardSource(kb)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := source.Fetch(commandContext())
+	result := recordFetch(kb, source, content, err)
+	return result.content, result.err
+}
+
+// keyboardSource returns the source of a keyboard, pinned to its locked
+// commit or set to record the commit it reads when klcm.lock needs it
+func keyboardSource(kb registry.Keyboard) (remote.Source, error) {
+	source, err := remote.ForKeyboard(kb, remoteOptions())
+	if err != nil {
+		return nil, err
+	}
+	if commit := pinnedCommits[kb.Name]; commit != "" {
+		return remote.Pin(source, commit), nil
+	}
+	if recordRevisions && !offline {
+		return remote.Pin(source, ""), nil
+	}
+	return source, nil
+}
+
+// fetchedRemote returns the memoized fetch of a keyboard
+func fetchedRemote(keyboard string) fetchResult {
+	fetchedMu.Lock()
+	defer fetchedMu.Unlock()
+	return fetched[keyboard]
+}
+
+// recordFetch reports how a fetch went and memoizes it
+func recordFetch(kb registry.Keyboard, source remote.Source, content *remote.Content, err error) fetchResult {
+	result := fetchResult{source: source.String()}
+	if revisioned, ok := source.(interface{ RefName() string }); ok {
+		result.ref = revisioned.RefName()
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "  🔗 %s: %s\n", kb.Name, source)
+	}
+	switch {
+	case err != nil:
+		result.err = fetchError(kb, source, err)
+	case offline && content.Cached:
+		fmt.Printf("  📴 Offline: using cached %s as of %s\n", kb.Name, content.AsOf.Local().Format("2006-01-02 15:04:05"))
+	case content.Cached && verbose:
+		fmt.Fprintf(os.Stderr, "  ♻️  %s not modified, using cached copy\n", kb.Name)
+	}
+	if content != nil {
+		result.content = string(content.Data)
+		result.revision = content.Revision
+		result.unpinned = content.Unpinned
+	}
+
+	fetchedMu.Lock()
+	fetched[kb.Name] = result
+	fetchedMu.Unlock()
+	return result
+}
+
+// keyboardErrors collects per-keyboard failures so a command can finish the
+// other keyboards and report them all at the end
+type keyboardErrors map[string]error
+
+// summary prints the failures and returns an exit error for the command, or
+// nil if every keyboard succeeded
+func (e keyboardErrors) summary(cmd *cobra.Command, total int) error {
+	if len(e) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("\n💥 %d of %d keyboard(s) failed:\n", len(e), total)
+	for _, name := range names {
+		fmt.Printf("   ❌ %s: %v\n", name, e[name])
+		reportError(name, e[name])
+	}
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	return &ExitError{Code: 1}
+}
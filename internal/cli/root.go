@@ -1,7 +1,14 @@
 package cli
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
 )
 
 var (
@@ -20,7 +27,13 @@ Features:
 - Pull latest configurations from remote repositories
 - Compare local vs remote configurations  
 - GitHub PR automation for upstream changes
-- Configuration validation`,
+- Configuration validation
+
+For scripts and CI, --no-input never waits for input, --yes confirms every
+change, and --output json prints a result listing what changed, what was
+skipped and what failed. Exit codes: 0 clean, 1 error, 2 differences found
+and left in place (compare-remote, outdated, previews, declined changes),
+3 warnings but no errors (validate).`,
 	Example: `  # Pull latest configurations for all keyboards
   klcm pull
 
@@ -37,12 +50,76 @@ Features:
   klcm download adv360`,
 }
 
+// ExitError asks main to exit with a specific code. Err is printed if set;
+// a nil Err exits quietly because the command already reported the problem.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
+// Interrupting klcm cancels the context commands use for remote fetches.
+// Tokens are redacted from errors, including those cobra prints. Commands
+// that leave differences in place exit 2; see finishCommand.
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	rootCmd.SetErr(redactingWriter{os.Stderr})
+	return redactExitError(finishCommand(rootCmd.ExecuteContext(ctx)))
+}
+
+// initConfig reads the config file named by --config, or .klcm.yaml from the
+// current directory or $HOME. A missing default config file is not an error.
+func initConfig() {
+	if cfgFile != "" {
+		viper.SetConfigFile(cfgFile)
+	} else {
+		viper.SetConfigName(".klcm")
+		viper.SetConfigType("yaml")
+		viper.AddConfigPath(".")
+		if home, err := os.UserHomeDir(); err == nil {
+			viper.AddConfigPath(home)
+		}
+	}
+
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok || cfgFile != "" {
+			fmt.Fprintf(os.Stderr, "⚠️  Failed to read config file: %v\n", err)
+		}
+		return
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "📄 Using config file: %s\n", viper.ConfigFileUsed())
+	}
+
+	reg, err := registry.Load(viper.GetViper())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Invalid keyboard registry, using built-in keyboards: %v\n", err)
+		return
+	}
+	registry.SetCurrent(reg)
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.klcm.yaml)")
+	cobra.OnInitialize(initConfig)
+	rootCmd.PersistentPreRunE = initOutput
+
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./.klcm.yaml or $HOME/.klcm.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "serve remote keymaps from the local cache without network access")
+	rootCmd.PersistentFlags().IntVar(&jobs, "jobs", 4, "maximum number of remote keymaps fetched, or pull requests created, at once")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "answer yes to every confirmation")
+	rootCmd.PersistentFlags().BoolVar(&noInput, "no-input", false, "never read stdin; confirmations are answered no unless --yes is given")
+	rootCmd.PersistentFlags().StringVar(&outputMode, "output", OutputText, "result format: text, or json for a machine-readable result on stdout")
 }
\ No newline at end of file
@@ -1,12 +1,13 @@
 package cli
 
 import (
-	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
 )
 
 var syncCmd = &cobra.Command{
@@ -20,6 +21,9 @@ Examples:
   # Compare adv360 and glove80 configurations
   klcm sync adv360 glove80 --preview
 
+  # Export the sync as a patch
+  klcm sync adv360 glove80 --preview --diff-format patch > sync.patch
+
   # Apply changes from adv360 to glove80
   klcm sync adv360 glove80
 
@@ -49,13 +53,14 @@ func init() {
 	rootCmd.AddCommand(syncCmd)
 	syncCmd.Flags().Bool("list", false, "List available keyboards for syncing")
 	syncCmd.Flags().Bool("preview", false, "Preview changes without applying them")
+	addDiffFormatFlags(syncCmd)
 }
 
 func showAvailableKeyboards() error {
 	fmt.Println("📋 Available keyboards for syncing:")
-	fmt.Println("  • adv360    - Kinesis Advantage360 (ZMK)")
-	fmt.Println("  • glove80   - MoErgo Glove80 (ZMK)")
-	fmt.Println("  • adv_mod   - Kinesis Advantage with Pillz Mod (ZMK)")
+	for _, kb := range registry.Current().All() {
+		fmt.Printf("  • %-9s - %s (%s)\n", kb.Name, kb.Description, strings.ToUpper(kb.Type))
+	}
 	fmt.Println()
 	fmt.Println("💡 Example: klcm sync adv360 glove80 --preview")
 	return nil
@@ -96,6 +101,19 @@ func syncKeyboards(source, target string, preview bool) error {
 		return fmt.Errorf("could not find default layer in %s", target)
 	}
 
+	format, err := selectedDiffFormat()
+	if err != nil {
+		return err
+	}
+	if format != DiffFormatText {
+		if !preview {
+			return fmt.Errorf("--diff-format %s requires --preview", format)
+		}
+		updatedContent := applyChangesToTarget(string(targetContent), targetDefault, sourceDefault)
+		fd := newFileDiff(target, filepath.ToSlash(targetPath), target, fmt.Sprintf("%s (synced from %s)", target, source), string(targetContent), updatedContent)
+		return writeDiffReport(format, []FileDiff{fd})
+	}
+
 	// Compare and show differences
 	fmt.Printf("🔄 Comparing %s → %s\n\n", source, target)
 	
@@ -114,44 +132,38 @@ func syncKeyboards(source, target string, preview bool) error {
 		fmt.Println()
 	}
 
+	drift := fmt.Sprintf("%d key(s) differ from %s", len(differences), source)
 	if preview {
 		fmt.Println("👀 Preview mode - no changes applied")
+		reportDrift(target, filepath.ToSlash(targetPath), drift+" (preview)")
 		return nil
 	}
 
 	// Ask for confirmation
-	fmt.Printf("❓ Apply changes from %s to %s? (y/N): ", source, target)
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
-	if err != nil {
-		return fmt.Errorf("failed to read input: %w", err)
-	}
-
-	response = strings.ToLower(strings.TrimSpace(response))
-	if response != "y" && response != "yes" {
+	if !confirm(fmt.Sprintf("❓ Apply changes from %s to %s? (y/N): ", source, target), false) {
 		fmt.Println("❌ Sync cancelled")
+		reportDrift(target, filepath.ToSlash(targetPath), drift+" (not applied)")
 		return nil
 	}
 
 	// Apply changes
 	updatedContent := applyChangesToTarget(string(targetContent), targetDefault, sourceDefault)
 	
-	err = os.WriteFile(targetPath, []byte(updatedContent), 0644)
+	err = writeKeymap(targetPath, []byte(updatedContent))
 	if err != nil {
 		return fmt.Errorf("failed to write updated file: %w", err)
 	}
 
 	fmt.Printf("✅ Successfully synced changes from %s to %s\n", source, target)
+	reportChanged(target, filepath.ToSlash(targetPath), fmt.Sprintf("synced %d key(s) from %s", len(differences), source))
 	return nil
 }
 
 func getKeyboardConfigPath(keyboard string) string {
-	paths := map[string]string{
-		"adv360":   "configs/zmk_adv360/adv360.keymap",
-		"glove80":  "configs/zmk_glove80/glove80.keymap",
-		"adv_mod":  "configs/zmk_adv_mod/pillzmod_pro.keymap",
+	if kb, ok := registry.Current().Get(keyboard); ok {
+		return filepath.FromSlash(kb.LocalPath)
 	}
-	return paths[keyboard]
+	return ""
 }
 
 func findDefaultLayer(content string) string {
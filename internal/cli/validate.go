@@ -1,28 +1,74 @@
 package cli
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"masters3d.com/keyboard_layout_config_mapper/internal/parsers"
 )
 
 var (
-	validateAll     bool
-	validateCompile bool
+	validateAll      bool
+	validateCompile  bool
 	validateKeyboard string
+	validateFormat   string
 )
 
 // validateCmd represents the validate command
 var validateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate keyboard configuration files",
-	Long: `Validate keyboard configuration files for syntax errors.
+	Long: `Validate keyboard configuration files for syntax errors and run semantic
+lint rules over the parsed keymap.
 
-Supports validation for ZMK keymap files (.keymap) for:
-- adv360  - Kinesis Advantage360
-- glove80 - MoErgo Glove80  
-- adv_mod - Kinesis Advantage with Pillz Mod`,
+Supports validation for the ZMK keymap files (.keymap) of every registered
+keyboard; see 'klcm keyboards list'.
+
+Lint rules:
+  undefined-reference  bindings use a behavior or macro that is not defined
+  layer-target         &mo/&lt/&to/&tog/&sl target a layer that does not exist
+  binding-count        a layer's binding count differs from the board's key count
+  duplicate-label      behaviors or macros share a node label or label property
+  unused-definition    a behavior or macro is never used
+  layer-define         a #define layer constant disagrees with the layer order
+  unknown-keycode      a &kp, &mt, &lt, &sk or &kt parameter is not a ZMK keycode
+  unreachable-layer    no key, combo or conditional layer activates the layer
+  one-way-layer        &to switches to a layer with no way back to the default layer
+  self-activation      a momentary layer key activates the layer it is on (use &trans)
+  combo-conflict       combos on overlapping layers use the same key positions
+  combo-timing         a combo key is a hold-tap with a tapping term below the combo timeout
+  combo-position       a combo key position is beyond the board's key count
+
+Rules can be disabled or re-graded globally or per keyboard in .klcm.yaml:
+
+  lint:
+    rules:
+      unused-definition: {severity: info}
+    keyboards:
+      adv360:
+        rules:
+          undefined-reference: {enabled: false}
+
+--compile builds each keymap with the command configured under "compile" in
+.klcm.yaml and maps its diagnostics back to keymap lines. Without a command,
+a built-in devicetree structural check runs instead.
+
+  compile:
+    command: [west, build, -p, -s, zmk/app, -b, glove80_lh, --, "-DZMK_CONFIG={config_dir}"]
+    timeout: 15m
+    keyboards:
+      adv_mod:
+        command: [dtc, -I, dts, -O, dtb, -o, /dev/null, "{keymap}"]
+
+Placeholders: {config_dir} (temporary copy of the keymap directory),
+{keymap} (keymap inside it) and {keyboard}.
+
+Exit codes: 0 when there are no errors or warnings, 1 when any error was
+found, 3 when only warnings were found.`,
 	Example: `  # Validate all keyboards
   klcm validate --all
 
@@ -30,37 +76,84 @@ Supports validation for ZMK keymap files (.keymap) for:
   klcm validate --keyboard adv360
 
   # Validate with compilation check
-  klcm validate --compile`,
+  klcm validate --compile
+
+  # Produce SARIF for code scanning annotations
+  klcm validate --format sarif > klcm.sarif
+
+  # Produce a JUnit report for CI dashboards
+  klcm validate --format junit > klcm-junit.xml`,
 	RunE: runValidate,
 }
 
 func runValidate(cmd *cobra.Command, args []string) error {
+	format, err := selectedValidateFormat()
+	if err != nil {
+		return err
+	}
+
 	if verbose {
-		fmt.Println("✅ Starting keyboard configuration validation...")
+		fmt.Fprintln(os.Stderr, "✅ Starting keyboard configuration validation...")
 	}
 
-	validator := parsers.NewValidator()
+	validator, err := configuredValidator()
+	if err != nil {
+		return err
+	}
 
+	var results []parsers.ValidationResult
 	if validateAll || validateKeyboard == "" {
 		// Validate all keyboards
 		if verbose {
-			fmt.Println("🔍 Validating all keyboard configurations...")
+			fmt.Fprintln(os.Stderr, "🔍 Validating all keyboard configurations...")
 		}
-		return validator.ValidateAll(validateCompile)
+		results = validator.ValidateAll(validateCompile)
+	} else {
+		// Validate specific keyboard
+		if verbose {
+			fmt.Fprintf(os.Stderr, "🎯 Validating %s configuration\n", validateKeyboard)
+		}
+		results = []parsers.ValidationResult{validator.ValidateKeyboard(validateKeyboard, validateCompile)}
 	}
 
-	// Validate specific keyboard
-	if verbose {
-		fmt.Printf("🎯 Validating %s configuration\n", validateKeyboard)
+	if err := writeValidationReport(os.Stdout, format, results); err != nil {
+		return err
+	}
+	if jsonOut != nil {
+		var report bytes.Buffer
+		if err := renderValidationJSON(&report, results); err != nil {
+			return err
+		}
+		reportData(json.RawMessage(report.Bytes()))
+	}
+
+	// Findings were already reported; only the exit code is left to set
+	if code := validateExitCode(results); code != 0 {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return &ExitError{Code: code}
 	}
+	return nil
+}
 
-	return validator.ValidateKeyboard(validateKeyboard, validateCompile)
+// configuredValidator returns a validator with the lint and compile settings
+// of .klcm.yaml
+func configuredValidator() (*parsers.Validator, error) {
+	validator := parsers.NewValidator()
+	if err := viper.UnmarshalKey("lint", &validator.LintConfig); err != nil {
+		return nil, fmt.Errorf("invalid lint configuration: %w", err)
+	}
+	if err := viper.UnmarshalKey("compile", &validator.CompileConfig); err != nil {
+		return nil, fmt.Errorf("invalid compile configuration: %w", err)
+	}
+	return validator, nil
 }
 
 func init() {
 	rootCmd.AddCommand(validateCmd)
 
 	validateCmd.Flags().BoolVar(&validateAll, "all", false, "validate all keyboard configurations")
-	validateCmd.Flags().BoolVar(&validateCompile, "compile", false, "attempt compilation validation")
-	validateCmd.Flags().StringVar(&validateKeyboard, "keyboard", "", "specific keyboard to validate (adv360, glove80, adv_mod)")
-}
\ No newline at end of file
+	validateCmd.Flags().BoolVar(&validateCompile, "compile", false, "compile each keymap with the configured builder (or a structural check)")
+	validateCmd.Flags().StringVar(&validateFormat, "format", string(ValidateFormatText), "report format (text, json, sarif, junit)")
+	validateCmd.Flags().StringVar(&validateKeyboard, "keyboard", "", "specific keyboard to validate (see klcm keyboards list)")
+}
@@ -0,0 +1,357 @@
+package cli
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/parsers"
+)
+
+// ValidateFormat selects how validation results are reported
+type ValidateFormat string
+
+const (
+	ValidateFormatText  ValidateFormat = "text"  // emoji terminal output (default)
+	ValidateFormatJSON  ValidateFormat = "json"  // findings and counts per keyboard
+	ValidateFormatSARIF ValidateFormat = "sarif" // SARIF 2.1.0 for code scanning annotations
+	ValidateFormatJUnit ValidateFormat = "junit" // JUnit XML, one test case per rule
+)
+
+// selectedValidateFormat validates the --format flag of validate
+func selectedValidateFormat() (ValidateFormat, error) {
+	switch format := ValidateFormat(strings.ToLower(validateFormat)); format {
+	case ValidateFormatText, ValidateFormatJSON, ValidateFormatSARIF, ValidateFormatJUnit:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown validate format %q (expected text, json, sarif or junit)", validateFormat)
+	}
+}
+
+// validateExitCode maps results to the exit code of klcm validate
+func validateExitCode(results []parsers.ValidationResult) int {
+	code := exitClean
+	for _, result := range results {
+		if result.Count(parsers.SeverityError) > 0 {
+			return exitError
+		}
+		if result.Count(parsers.SeverityWarning) > 0 {
+			code = exitWarnings
+		}
+	}
+	return code
+}
+
+// writeValidationReport renders validation results in the given format
+func writeValidationReport(w io.Writer, format ValidateFormat, results []parsers.ValidationResult) error {
+	switch format {
+	case ValidateFormatJSON:
+		return renderValidationJSON(w, results)
+	case ValidateFormatSARIF:
+		return renderValidationSARIF(w, results)
+	case ValidateFormatJUnit:
+		return renderValidationJUnit(w, results)
+	default:
+		renderValidationText(w, results)
+		return nil
+	}
+}
+
+func renderValidationText(w io.Writer, results []parsers.ValidationResult) {
+	icons := map[parsers.Severity]string{
+		parsers.SeverityError:   "❌",
+		parsers.SeverityWarning: "⚠️ ",
+		parsers.SeverityInfo:    "ℹ️ ",
+	}
+
+	var failed []string
+	for _, result := range results {
+		for _, finding := range result.Findings {
+			fmt.Fprintf(w, "  %s %s\n", icons[finding.Severity], finding)
+			if finding.Hint != "" {
+				fmt.Fprintf(w, "     💡 %s\n", finding.Hint)
+			}
+		}
+
+		errors, warnings := result.Count(parsers.SeverityError), result.Count(parsers.SeverityWarning)
+		switch {
+		case errors > 0:
+			fmt.Fprintf(w, "❌ %s configuration has %d error(s), %d warning(s)\n", result.Keyboard, errors, warnings)
+			failed = append(failed, result.Keyboard)
+		case warnings > 0:
+			fmt.Fprintf(w, "✅ %s configuration is valid (%d warning(s))\n", result.Keyboard, warnings)
+		default:
+			fmt.Fprintf(w, "✅ %s configuration is valid\n", result.Keyboard)
+		}
+	}
+
+	if len(failed) > 0 {
+		fmt.Fprintf(w, "\n💥 Validation failed for: %s\n", strings.Join(failed, ", "))
+	} else if len(results) > 1 {
+		fmt.Fprintln(w, "🎉 All keyboard configurations are valid!")
+	}
+}
+
+// jsonValidationReport is the JSON report format
+type jsonValidationReport struct {
+	Keyboards []jsonKeyboardResult `json:"keyboards"`
+	Summary   jsonValidationCounts `json:"summary"`
+}
+
+type jsonKeyboardResult struct {
+	parsers.ValidationResult
+	jsonValidationCounts
+}
+
+type jsonValidationCounts struct {
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+	Infos    int `json:"infos"`
+}
+
+func renderValidationJSON(w io.Writer, results []parsers.ValidationResult) error {
+	report := jsonValidationReport{Keyboards: []jsonKeyboardResult{}}
+	for _, result := range results {
+		counts := jsonValidationCounts{
+			Errors:   result.Count(parsers.SeverityError),
+			Warnings: result.Count(parsers.SeverityWarning),
+			Infos:    result.Count(parsers.SeverityInfo),
+		}
+		report.Keyboards = append(report.Keyboards, jsonKeyboardResult{result, counts})
+		report.Summary.Errors += counts.Errors
+		report.Summary.Warnings += counts.Warnings
+		report.Summary.Infos += counts.Infos
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(report)
+}
+
+// ruleDescriptions returns the description of every rule a finding can come from
+func ruleDescriptions() map[string]string {
+	descriptions := map[string]string{
+		"config":  "the keyboard configuration file must exist",
+		"syntax":  "the keymap must be syntactically valid devicetree",
+		"compile": "the keymap must compile",
+	}
+	for _, rule := range parsers.DefaultRules() {
+		descriptions[rule.ID()] = rule.Description()
+	}
+	return descriptions
+}
+
+// SARIF 2.1.0 subset used for code scanning
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string            `json:"ruleId"`
+	Level     string            `json:"level"`
+	Message   sarifMessage      `json:"message"`
+	Locations []sarifLocation   `json:"locations,omitempty"`
+	Props     map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           *sarifRegion  `json:"region,omitempty"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func renderValidationSARIF(w io.Writer, results []parsers.ValidationResult) error {
+	descriptions := ruleDescriptions()
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:  "klcm",
+			Rules: []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	ruleSeen := make(map[string]bool)
+	for _, result := range results {
+		for _, finding := range result.Findings {
+			if !ruleSeen[finding.RuleID] {
+				ruleSeen[finding.RuleID] = true
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+					ID:               finding.RuleID,
+					ShortDescription: sarifMessage{Text: descriptions[finding.RuleID]},
+				})
+			}
+
+			level := string(finding.Severity)
+			if finding.Severity == parsers.SeverityInfo {
+				level = "note"
+			}
+			sarif := sarifResult{
+				RuleID:  finding.RuleID,
+				Level:   level,
+				Message: sarifMessage{Text: finding.Message},
+				Props:   map[string]string{"keyboard": result.Keyboard},
+			}
+			if finding.Hint != "" {
+				sarif.Message.Text += "\nHint: " + finding.Hint
+			}
+			if finding.File != "" {
+				location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifact{URI: filepath.ToSlash(finding.File)},
+				}}
+				if finding.Line > 0 {
+					location.PhysicalLocation.Region = &sarifRegion{StartLine: finding.Line, StartColumn: finding.Column}
+				}
+				sarif.Locations = append(sarif.Locations, location)
+			}
+			run.Results = append(run.Results, sarif)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// JUnit XML report: one test suite per keyboard, one test case per rule.
+// Error findings fail the test case; warnings and infos go to system-out.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func renderValidationJUnit(w io.Writer, results []parsers.ValidationResult) error {
+	ruleIDs := []string{"config", "syntax"}
+	for _, rule := range parsers.DefaultRules() {
+		ruleIDs = append(ruleIDs, rule.ID())
+	}
+
+	report := junitTestSuites{}
+	for _, result := range results {
+		byRule := make(map[string][]parsers.Finding)
+		for _, finding := range result.Findings {
+			if _, ok := byRule[finding.RuleID]; !ok && !containsString(ruleIDs, finding.RuleID) {
+				ruleIDs = append(ruleIDs, finding.RuleID)
+			}
+			byRule[finding.RuleID] = append(byRule[finding.RuleID], finding)
+		}
+
+		suite := junitTestSuite{Name: result.Keyboard}
+		for _, ruleID := range ruleIDs {
+			testCase := junitTestCase{Name: ruleID, ClassName: "klcm.validate." + result.Keyboard, File: result.File}
+
+			var failures, notes []string
+			for _, finding := range byRule[ruleID] {
+				if finding.Severity == parsers.SeverityError {
+					failures = append(failures, finding.String())
+				} else {
+					notes = append(notes, finding.String())
+				}
+			}
+			if len(failures) > 0 {
+				testCase.Failure = &junitFailure{
+					Message: fmt.Sprintf("%d error(s)", len(failures)),
+					Type:    ruleID,
+					Text:    strings.Join(failures, "\n"),
+				}
+				suite.Failures++
+			}
+			testCase.SystemOut = strings.Join(notes, "\n")
+
+			suite.Cases = append(suite.Cases, testCase)
+			suite.Tests++
+		}
+
+		report.Suites = append(report.Suites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,12 @@
 package cli
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"os/exec"
-	"strings"
 
 	"github.com/spf13/cobra"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
 )
 
 // workflowCmd represents the workflow command
@@ -39,22 +38,20 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 	fmt.Println("keyboard configuration changes and contributing them back.")
 	fmt.Println()
 
-	reader := bufio.NewReader(os.Stdin)
-
 	// Step 1: Update configurations
 	fmt.Println("📋 Step 1: Update to Latest Configurations")
 	fmt.Println("-----------------------------------------------")
 	fmt.Println("Before making changes, let's ensure you have the latest configurations.")
 	fmt.Println()
 	
-	if askYesNoWorkflow(reader, "Check for and preview remote updates? (Y/n): ") {
+	if confirm("Check for and preview remote updates? (Y/n): ", true) {
 		fmt.Println()
 		fmt.Println("🔍 Checking for remote updates...")
 		if err := runCLICommand("pull", "--preview"); err != nil {
 			fmt.Printf("❌ Error checking updates: %v\n", err)
 		} else {
 			fmt.Println()
-			if askYesNoWorkflow(reader, "Apply these updates? (Y/n): ") {
+			if confirm("Apply these updates? (Y/n): ", true) {
 				if err := runCLICommand("pull"); err != nil {
 					fmt.Printf("❌ Error applying updates: %v\n", err)
 				} else {
@@ -70,17 +67,14 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 	fmt.Println("----------------------------")
 	fmt.Println("Now edit your keyboard configuration files:")
 	fmt.Println()
-	fmt.Println("  📁 Advantage360: configs/zmk_adv360/adv360.keymap")
-	fmt.Println("  📁 Glove80:      configs/zmk_glove80/glove80.keymap")
-	fmt.Println("  📁 Pillz Mod:    configs/zmk_adv_mod/pillzmod_pro.keymap")
+	printKeymapPaths()
 	fmt.Println()
 	fmt.Println("💡 Tips:")
 	fmt.Println("   - Make small, focused changes")
 	fmt.Println("   - Test one change at a time")
 	fmt.Println("   - Keep notes of what you're changing and why")
 	fmt.Println()
-	fmt.Println("Press Enter when you've finished making your changes...")
-	reader.ReadLine()
+	waitForEnter("Press Enter when you've finished making your changes...")
 
 	// Step 3: Validate changes
 	fmt.Println("📋 Step 3: Validate Your Changes")
@@ -94,7 +88,7 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 		fmt.Println()
 		fmt.Println("Please fix the errors shown above and run 'klcm validate' again.")
 		fmt.Println("Then restart this workflow with 'klcm workflow'")
-		return nil
+		return workflowFailed(cmd)
 	}
 	fmt.Println("✅ All configurations are valid!")
 	fmt.Println()
@@ -105,24 +99,11 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 	fmt.Println("If you want the same changes on both keyboards, we can sync them.")
 	fmt.Println()
 	
-	if askYesNoWorkflow(reader, "Sync changes between keyboards? (y/N): ") {
+	if confirm("Sync changes between keyboards? (y/N): ", false) {
 		// Ask which direction to sync
 		fmt.Println()
-		fmt.Println("Which direction would you like to sync?")
-		fmt.Println("  1. Advantage360 → Glove80")
-		fmt.Println("  2. Glove80 → Advantage360")
-		fmt.Print("Enter choice (1/2): ")
-		
-		choice, _ := reader.ReadString('\n')
-		choice = strings.TrimSpace(choice)
-		
-		var source, target string
-		switch choice {
-		case "1":
-			source, target = "adv360", "glove80"
-		case "2":
-			source, target = "glove80", "adv360"
-		default:
+		source, target, ok := chooseSyncDirection()
+		if !ok {
 			fmt.Println("Invalid choice, skipping sync.")
 			goto step5
 		}
@@ -132,7 +113,7 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 			fmt.Printf("❌ Error previewing sync: %v\n", err)
 		} else {
 			fmt.Println()
-			if askYesNoWorkflow(reader, "Apply this sync? (y/N): ") {
+			if confirm("Apply this sync? (y/N): ", false) {
 				if err := runCLICommand("sync", source, target); err != nil {
 					fmt.Printf("❌ Error applying sync: %v\n", err)
 				} else {
@@ -142,7 +123,7 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 					fmt.Println("🔍 Re-validating after sync...")
 					if err := runCLICommand("validate"); err != nil {
 						fmt.Printf("❌ Validation failed after sync: %v\n", err)
-						return nil
+						return workflowFailed(cmd)
 					}
 					fmt.Println("✅ All configurations still valid!")
 				}
@@ -162,14 +143,14 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 	fmt.Println("🔍 Analyzing what PRs would be created...")
 	if err := runCLICommand("pr", "create", "--dry-run"); err != nil {
 		fmt.Printf("❌ Error analyzing PRs: %v\n", err)
-		return nil
+		return workflowFailed(cmd)
 	}
 
 	fmt.Println()
-	if askYesNoWorkflow(reader, "Create these pull requests? (y/N): ") {
+	if confirm("Create these pull requests? (y/N): ", false) {
 		fmt.Println()
 		fmt.Println("🚀 Creating pull requests...")
-		fmt.Println("⚠️  Note: This requires GitHub CLI (gh) and authentication")
+		fmt.Println("⚠️  Note: This requires a token for each forge (GITHUB_TOKEN, GITLAB_TOKEN or GITEA_TOKEN)")
 		fmt.Println()
 		if err := runCLICommand("pr", "create", "--apply"); err != nil {
 			fmt.Printf("❌ Error creating PRs: %v\n", err)
@@ -177,7 +158,7 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 			fmt.Println("💡 You can also create PRs manually:")
 			fmt.Println("   1. Commit your changes: git add . && git commit -m 'Update keyboard layout'")
 			fmt.Println("   2. Push to a branch: git push origin feature/my-layout")
-			fmt.Println("   3. Create PR on GitHub website")
+			fmt.Println("   3. Create the PR on the forge's website")
 		} else {
 			fmt.Println("✅ Pull requests created successfully!")
 		}
@@ -196,19 +177,55 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func askYesNoWorkflow(reader *bufio.Reader, prompt string) bool {
-	fmt.Print(prompt)
-	response, _ := reader.ReadString('\n')
-	response = strings.ToLower(strings.TrimSpace(response))
-	return response == "y" || response == "yes" || response == ""
+// printKeymapPaths lists the keymap file of every registered keyboard
+func printKeymapPaths() {
+	for _, kb := range registry.Current().All() {
+		fmt.Printf("  📁 %-14s %s\n", kb.Description+":", kb.LocalPath)
+	}
+}
+
+// chooseSyncDirection asks for the source and target keyboards of a sync
+func chooseSyncDirection() (string, string, bool) {
+	names := registry.Current().Names()
+	fmt.Println("Which direction would you like to sync?")
+	for i, kb := range registry.Current().All() {
+		fmt.Printf("  %d. %s\n", i+1, kb.Description)
+	}
+
+	source, ok := chooseNumber("Sync from", len(names))
+	if !ok {
+		return "", "", false
+	}
+	target, ok := chooseNumber("Sync to", len(names))
+	if !ok || target == source {
+		return "", "", false
+	}
+	return names[source-1], names[target-1], true
+}
+
+// workflowFailed ends a workflow whose step failed and already said why
+func workflowFailed(cmd *cobra.Command) error {
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	return &ExitError{Code: exitError}
 }
 
+// runCLICommand runs klcm as a subprocess with the global flags of this run.
+// Exit status 2 means differences were found and 3 that validation only
+// warned, neither of which is a failure here.
 func runCLICommand(args ...string) error {
-	cmd := exec.Command("./klcm", args...)
+	cmd := exec.Command(klcmExecutable(), childArgs(args...)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
-	return cmd.Run()
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		switch exitErr.ExitCode() {
+		case exitDrift, exitWarnings:
+			return nil
+		}
+	}
+	return err
 }
 
 func init() {
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+// childExitEnv makes the test binary, run as a klcm child command by
+// runCLICommand, exit at once with the status it holds
+const childExitEnv = "KLCM_TEST_CHILD_EXIT"
+
+func TestMain(m *testing.M) {
+	if code := os.Getenv(childExitEnv); code != "" {
+		n, _ := strconv.Atoi(code)
+		os.Exit(n)
+	}
+	os.Exit(m.Run())
+}
+
+func TestRunCLICommandExitStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		code int
+		fail bool
+	}{
+		{name: "clean", code: exitClean},
+		{name: "drift", code: exitDrift},
+		{name: "validate warnings", code: exitWarnings},
+		{name: "error", code: exitError, fail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(childExitEnv, strconv.Itoa(tt.code))
+			err := runCLICommand("validate")
+			if tt.fail && err == nil {
+				t.Errorf("child exiting %d succeeded", tt.code)
+			}
+			if !tt.fail && err != nil {
+				t.Errorf("child exiting %d: %v, want success", tt.code, err)
+			}
+		})
+	}
+}
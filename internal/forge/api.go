@@ -0,0 +1,152 @@
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// API sends JSON requests to a forge's REST API
+type API struct {
+	Forge   string      // display name for errors, e.g. "GitHub"
+	BaseURL string      // API root, without a trailing slash
+	Header  http.Header // sent with every request: Accept, authentication
+	HTTP    *http.Client
+}
+
+// APIError is a response a forge answered with an error status
+type APIError struct {
+	Forge      string
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string // the forge's error message, if the body had one
+}
+
+func (e *APIError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("%s API %s %s: %d %s", e.Forge, e.Method, e.Path, e.StatusCode, message)
+}
+
+// IsNotFound reports whether err is a 404 from a forge
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized reports whether a forge rejected the credentials. Forges
+// answer 404 for private repositories the caller cannot see, which this does
+// not cover.
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized, http.StatusForbidden)
+}
+
+func hasStatus(err error, codes ...int) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Do sends a request with in as its JSON body, if non-nil, and decodes a
+// JSON response into out, if non-nil
+func (a API) Do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	for name, values := range a.Header {
+		req.Header[name] = values
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return a.error(method, path, resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s API %s %s: invalid response: %w", a.Forge, method, path, err)
+	}
+	return nil
+}
+
+// error reads the message out of an error response. GitHub and Gitea send
+// {"message": "..."} (GitHub adds "errors"); GitLab sends "message" as a
+// string or an object of field errors, or {"error": "..."}.
+func (a API) error(method, path string, resp *http.Response) error {
+	apiErr := &APIError{Forge: a.Forge, Method: method, Path: strings.SplitN(path, "?", 2)[0], StatusCode: resp.StatusCode}
+	var payload struct {
+		Message json.RawMessage `json:"message"`
+		Error   string          `json:"error"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&payload) != nil {
+		return apiErr
+	}
+
+	var text string
+	if json.Unmarshal(payload.Message, &text) == nil {
+		apiErr.Message = text
+	} else if len(payload.Message) > 0 {
+		apiErr.Message = string(payload.Message)
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = payload.Error
+	}
+	for _, e := range payload.Errors {
+		if e.Message != "" {
+			apiErr.Message += ": " + e.Message
+		}
+	}
+	return apiErr
+}
+
+// ListPages calls list for page 1, 2, ... until a page has fewer than
+// perPage items, and returns the items of every page
+func ListPages[T any](perPage int, list func(page int) ([]T, error)) ([]T, error) {
+	var all []T
+	for page := 1; ; page++ {
+		items, err := list(page)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		if len(items) < perPage {
+			return all, nil
+		}
+	}
+}
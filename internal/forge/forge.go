@@ -0,0 +1,262 @@
+// Package forge describes the code hosts klcm opens pull requests on. GitHub,
+// GitLab and Gitea each implement Forge in their own package; the types here
+// are the common ground, named after GitHub's (a GitLab merge request is a
+// PullRequest, its iid the Number).
+package forge
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Forge is the API of a code host, as far as klcm's pull request automation
+// needs it: pushing a file to a branch, and opening, listing, updating and
+// following pull requests
+type Forge interface {
+	// Name is the display name of the forge, e.g. "GitLab"
+	Name() string
+
+	// AuthenticatedUser returns the account the token belongs to
+	AuthenticatedUser(ctx context.Context) (*User, error)
+	// FileContent returns the content of a file at a branch, tag or commit
+	FileContent(ctx context.Context, repo Repo, path, ref string) ([]byte, error)
+	// BranchHead returns the commit a branch points to
+	BranchHead(ctx context.Context, repo Repo, branch string) (string, error)
+	// PushFile commits one file to change.Branch, creating the branch from
+	// change.Base if needed. It returns ErrNoChanges if the file is already
+	// up to date.
+	PushFile(ctx context.Context, repo Repo, change FileChange) (*Push, error)
+	// DeleteBranch deletes a branch; a branch that is already gone is not
+	// an error
+	DeleteBranch(ctx context.Context, repo Repo, branch string) error
+
+	CreatePullRequest(ctx context.Context, repo Repo, pr NewPullRequest) (*PullRequest, error)
+	// EditPullRequest updates the title, body or state of a pull request
+	EditPullRequest(ctx context.Context, repo Repo, number int, edit PullRequestEdit) (*PullRequest, error)
+	GetPullRequest(ctx context.Context, repo Repo, number int) (*PullRequest, error)
+	// PullRequests lists pull requests, most recently updated first
+	PullRequests(ctx context.Context, repo Repo, opts ListOptions) ([]PullRequest, error)
+	// Status gathers the reviews, checks and mergeability of a pull request
+	Status(ctx context.Context, repo Repo, number int) (*PullRequestStatus, error)
+	// Comment adds a comment to a pull request
+	Comment(ctx context.Context, repo Repo, number int, body string) error
+}
+
+// ErrNoChanges is returned by PushFile when the file already has the content
+// on the branch it would commit to
+var ErrNoChanges = errors.New("no changes to commit")
+
+// Repo names a repository. On GitLab the owner is the namespace, which may
+// include subgroups ("group/subgroup").
+type Repo struct {
+	Owner string
+	Name  string
+}
+
+func (r Repo) String() string {
+	return r.Owner + "/" + r.Name
+}
+
+// User is an account on a forge
+type User struct {
+	Login string `json:"login"`
+}
+
+// FileChange is a single file committed by PushFile
+type FileChange struct {
+	Base    string // branch to start from when Branch does not exist yet
+	Branch  string // branch to commit to, created from Base if needed
+	Path    string // repository path of the file
+	Content []byte
+	Message string // commit message
+}
+
+// Push is the outcome of PushFile
+type Push struct {
+	Branch  string
+	Commit  string // the new commit
+	Parent  string // the commit it was made on
+	Created bool   // the branch did not exist before
+}
+
+// PullRequest is the part of a pull or merge request klcm uses
+type PullRequest struct {
+	Number         int        `json:"number"`
+	Title          string     `json:"title"`
+	Body           string     `json:"body"`
+	State          string     `json:"state"` // "open" or "closed"
+	Draft          bool       `json:"draft"`
+	Merged         bool       `json:"merged"`
+	Mergeable      *bool      `json:"mergeable"` // nil while the forge is still computing it
+	MergeableState string     `json:"mergeable_state"`
+	HTMLURL        string     `json:"html_url"`
+	User           User       `json:"user"`
+	Head           PRBranch   `json:"head"`
+	Base           PRBranch   `json:"base"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	MergedAt       *time.Time `json:"merged_at"`
+}
+
+// FromRepo reports whether the head branch of pr lives in repo rather than
+// in a fork
+func (pr PullRequest) FromRepo(repo Repo) bool {
+	return pr.Head.Repo != nil && strings.EqualFold(pr.Head.Repo.FullName, repo.String())
+}
+
+// PRBranch is the head or base of a pull request
+type PRBranch struct {
+	Ref  string   `json:"ref"`
+	SHA  string   `json:"sha"`
+	Repo *RepoRef `json:"repo"` // nil if the forge does not name it, e.g. a deleted fork
+}
+
+// RepoRef is the repository a pull request branch lives in
+type RepoRef struct {
+	FullName string `json:"full_name"` // owner/name
+}
+
+// NewPullRequest describes a pull request to open
+type NewPullRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Head  string `json:"head"` // branch with the changes
+	Base  string `json:"base"` // branch to merge into
+	Draft bool   `json:"draft,omitempty"`
+}
+
+// PullRequestEdit changes a pull request; empty fields are left as they are
+type PullRequestEdit struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+	State string `json:"state,omitempty"` // "open" or "closed"
+}
+
+// ListOptions filters PullRequests
+type ListOptions struct {
+	State string // "open" (default), "closed" (including merged) or "all"
+	Head  string // head branch in the same repository
+	Base  string
+}
+
+// OpenPullRequest opens a pull request from pr.Head, or updates the title
+// and body of the one already open from it. created reports which happened.
+func OpenPullRequest(ctx context.Context, f Forge, repo Repo, pr NewPullRequest) (result *PullRequest, created bool, err error) {
+	open, err := f.PullRequests(ctx, repo, ListOptions{State: "open", Head: pr.Head, Base: pr.Base})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(open) > 0 {
+		result, err = f.EditPullRequest(ctx, repo, open[0].Number, PullRequestEdit{Title: pr.Title, Body: pr.Body})
+		return result, false, err
+	}
+	result, err = f.CreatePullRequest(ctx, repo, pr)
+	return result, err == nil, err
+}
+
+// Review is a submitted pull request review; forges without reviews report
+// approvals as APPROVED reviews
+type Review struct {
+	User        User      `json:"user"`
+	State       string    `json:"state"` // APPROVED, CHANGES_REQUESTED, COMMENTED, DISMISSED
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+// Check is a CI check, commit status or pipeline on a commit
+type Check struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`     // queued, in_progress or completed
+	Conclusion string `json:"conclusion"` // success, failure, neutral, skipped, cancelled, timed_out, action_required
+	URL        string `json:"html_url"`
+}
+
+// Review decisions of a PullRequestStatus
+const (
+	ReviewApproved         = "approved"
+	ReviewChangesRequested = "changes_requested"
+	ReviewRequired         = "review_required"
+)
+
+// Check states of a PullRequestStatus
+const (
+	ChecksPassing = "passing"
+	ChecksFailing = "failing"
+	ChecksPending = "pending"
+	ChecksNone    = "none"
+)
+
+// PullRequestStatus is the state of a pull request as a reviewer sees it
+type PullRequestStatus struct {
+	PullRequest
+	Reviews        []Review `json:"reviews"` // latest review of each reviewer
+	ReviewDecision string   `json:"review_decision"`
+	Checks         []Check  `json:"checks"`
+	ChecksState    string   `json:"checks_state"`
+}
+
+// NewStatus settles the review decision and check state of a pull request
+func NewStatus(pr PullRequest, reviews []Review, checks []Check) *PullRequestStatus {
+	status := &PullRequestStatus{PullRequest: pr, Checks: checks}
+	status.Reviews = latestReviews(reviews)
+	status.ReviewDecision = reviewDecision(status.Reviews)
+	status.ChecksState = checksState(checks)
+	return status
+}
+
+// latestReviews keeps the last approving or blocking review of each reviewer;
+// comments do not change a reviewer's verdict
+func latestReviews(reviews []Review) []Review {
+	latest := make(map[string]Review)
+	for _, review := range reviews {
+		if review.State == "COMMENTED" || review.State == "PENDING" {
+			if _, ok := latest[review.User.Login]; ok {
+				continue
+			}
+		}
+		latest[review.User.Login] = review
+	}
+	result := make([]Review, 0, len(latest))
+	for _, review := range latest {
+		result = append(result, review)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].User.Login < result[j].User.Login })
+	return result
+}
+
+func reviewDecision(reviews []Review) string {
+	approved := false
+	for _, review := range reviews {
+		switch review.State {
+		case "CHANGES_REQUESTED":
+			return ReviewChangesRequested
+		case "APPROVED":
+			approved = true
+		}
+	}
+	if approved {
+		return ReviewApproved
+	}
+	return ReviewRequired
+}
+
+func checksState(checks []Check) string {
+	if len(checks) == 0 {
+		return ChecksNone
+	}
+	state := ChecksPassing
+	for _, check := range checks {
+		if check.Status != "completed" {
+			state = ChecksPending
+			continue
+		}
+		switch check.Conclusion {
+		case "success", "neutral", "skipped":
+		default:
+			return ChecksFailing
+		}
+	}
+	return state
+}
@@ -0,0 +1,341 @@
+package forgetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+)
+
+// Server is what RunConformance needs of a fake forge to set up repositories
+// and inspect or script them. Store implements it for the GitLab and Gitea
+// fakes; githubtest.Server implements it too.
+type Server interface {
+	AddRepo(owner, name, branch string, files map[string]string)
+	Branches(owner, name string) []string
+	File(owner, name, ref, path string) (string, bool)
+	Comments(owner, name string, number int) []string
+	AddReview(owner, name string, number int, login, state string)
+	SetChecks(owner, name string, number int, checks ...forge.Check)
+	Merge(owner, name string, number int)
+}
+
+// Fake is a running fake forge with clients for it
+type Fake struct {
+	Server Server
+	Client forge.Forge // sends the token the server accepts
+	Reject forge.Forge // sends a token the server rejects
+	Login  string      // the user the accepted token belongs to
+}
+
+// conformance is the repository every conformance test starts from
+type conformance struct {
+	Fake
+	repo forge.Repo
+	ctx  context.Context
+}
+
+const keymapPath = "config/glove80.keymap"
+
+func (c *conformance) change(branch, content string) forge.FileChange {
+	return forge.FileChange{Base: "main", Branch: branch, Path: keymapPath, Content: []byte(content), Message: "Update keymap"}
+}
+
+// push commits the keymap to branch and fails the test on an error
+func (c *conformance) push(t *testing.T, branch, content string) *forge.Push {
+	t.Helper()
+	push, err := c.Client.PushFile(c.ctx, c.repo, c.change(branch, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return push
+}
+
+// open pushes branch and opens a pull request from it into main
+func (c *conformance) open(t *testing.T, branch string) *forge.PullRequest {
+	t.Helper()
+	c.push(t, branch, branch+"\n")
+	pr, err := c.Client.CreatePullRequest(c.ctx, c.repo, forge.NewPullRequest{Title: "Update " + branch, Head: branch, Base: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pr
+}
+
+func (c *conformance) branches() []string {
+	return c.Server.Branches(c.repo.Owner, c.repo.Name)
+}
+
+func (c *conformance) file(branch, path string) string {
+	content, _ := c.Server.File(c.repo.Owner, c.repo.Name, branch, path)
+	return content
+}
+
+// list returns the numbers of the pull requests PullRequests lists, sorted
+func (c *conformance) list(t *testing.T, opts forge.ListOptions) []int {
+	t.Helper()
+	prs, err := c.Client.PullRequests(c.ctx, c.repo, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numbers := []int{}
+	for _, pr := range prs {
+		numbers = append(numbers, pr.Number)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// RunConformance checks that a forge.Forge client behaves as klcm's pull
+// request automation expects, against fakes from newFake. Each test gets a
+// new fake holding repo, whose main branch has a keymap and a README.
+func RunConformance(t *testing.T, repo forge.Repo, newFake func(t *testing.T) Fake) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, c *conformance)
+	}{
+		{name: "read files and branches", run: testReadFiles},
+		{name: "push creates the branch from its base", run: testPushCreatesBranch},
+		{name: "push stacks on an existing branch", run: testPushStacks},
+		{name: "push creates a missing file", run: testPushCreatesFile},
+		{name: "push without changes", run: testPushNoChanges},
+		{name: "open pull request creates then updates", run: testOpenPullRequest},
+		{name: "list pull requests by head and state", run: testListPullRequests},
+		{name: "list pull requests past the first page", run: testListPages},
+		{name: "delete branch after a failed pull request", run: testDeleteBranch},
+		{name: "status", run: testStatus},
+		{name: "comment", run: testComment},
+		{name: "authentication", run: testAuthentication},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFake(t)
+			fake.Server.AddRepo(repo.Owner, repo.Name, "main", map[string]string{keymapPath: "base\n", "README.md": "readme\n"})
+			tt.run(t, &conformance{Fake: fake, repo: repo, ctx: context.Background()})
+		})
+	}
+}
+
+func testReadFiles(t *testing.T, c *conformance) {
+	content, err := c.Client.FileContent(c.ctx, c.repo, keymapPath, "main")
+	if err != nil || string(content) != "base\n" {
+		t.Errorf("FileContent(%s) = %q, %v; want %q", keymapPath, content, err, "base\n")
+	}
+	if _, err := c.Client.FileContent(c.ctx, c.repo, "missing.keymap", "main"); !forge.IsNotFound(err) {
+		t.Errorf("FileContent of a missing file error = %v, want not found", err)
+	}
+	if head, err := c.Client.BranchHead(c.ctx, c.repo, "main"); err != nil || head == "" {
+		t.Errorf("BranchHead(main) = %q, %v; want a commit", head, err)
+	}
+	if _, err := c.Client.BranchHead(c.ctx, c.repo, "missing"); !forge.IsNotFound(err) {
+		t.Errorf("BranchHead of a missing branch error = %v, want not found", err)
+	}
+}
+
+func testPushCreatesBranch(t *testing.T, c *conformance) {
+	base, err := c.Client.BranchHead(c.ctx, c.repo, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	push := c.push(t, "klcm-sync", "first\n")
+	if !push.Created || push.Branch != "klcm-sync" || push.Parent != base || push.Commit == "" {
+		t.Errorf("push = %+v, want klcm-sync created on main's head %s", push, base)
+	}
+	if got := c.branches(); !reflect.DeepEqual(got, []string{"klcm-sync", "main"}) {
+		t.Errorf("branches = %v, want klcm-sync and main", got)
+	}
+	if got := c.file("klcm-sync", keymapPath); got != "first\n" {
+		t.Errorf("keymap on klcm-sync = %q, want %q", got, "first\n")
+	}
+	if got := c.file("klcm-sync", "README.md"); got != "readme\n" {
+		t.Errorf("README.md on klcm-sync = %q, want it carried over from main", got)
+	}
+	if got := c.file("main", keymapPath); got != "base\n" {
+		t.Errorf("keymap on main = %q, want it untouched", got)
+	}
+}
+
+func testPushStacks(t *testing.T, c *conformance) {
+	first := c.push(t, "klcm-sync", "first\n")
+	second := c.push(t, "klcm-sync", "second\n")
+	if second.Created || second.Parent != first.Commit {
+		t.Errorf("second push = %+v, want a commit on top of %s", second, first.Commit)
+	}
+	if head, _ := c.Client.BranchHead(c.ctx, c.repo, "klcm-sync"); head != second.Commit {
+		t.Errorf("klcm-sync is at %s, want %s", head, second.Commit)
+	}
+	if got := c.file("klcm-sync", keymapPath); got != "second\n" {
+		t.Errorf("keymap on klcm-sync = %q, want %q", got, "second\n")
+	}
+}
+
+func testPushCreatesFile(t *testing.T, c *conformance) {
+	change := c.change("klcm-sync", "new\n")
+	change.Path = "config/adv360.keymap"
+	if _, err := c.Client.PushFile(c.ctx, c.repo, change); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.file("klcm-sync", change.Path); got != "new\n" {
+		t.Errorf("%s on klcm-sync = %q, want %q", change.Path, got, "new\n")
+	}
+}
+
+func testPushNoChanges(t *testing.T, c *conformance) {
+	_, err := c.Client.PushFile(c.ctx, c.repo, c.change("klcm-sync", "base\n"))
+	if !errors.Is(err, forge.ErrNoChanges) {
+		t.Fatalf("PushFile of unchanged content error = %v, want ErrNoChanges", err)
+	}
+	if got := c.branches(); !reflect.DeepEqual(got, []string{"main"}) {
+		t.Errorf("branches = %v, want no branch created", got)
+	}
+}
+
+func testOpenPullRequest(t *testing.T, c *conformance) {
+	c.push(t, "klcm-sync", "first\n")
+	pr, created, err := forge.OpenPullRequest(c.ctx, c.Client, c.repo, forge.NewPullRequest{Title: "First", Body: "one", Head: "klcm-sync", Base: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || pr.Number == 0 || pr.State != "open" || pr.User.Login != c.Login {
+		t.Errorf("first OpenPullRequest = %+v (created %v), want a new open PR by %s", pr, created, c.Login)
+	}
+	if pr.Head.Ref != "klcm-sync" || pr.Base.Ref != "main" || !pr.FromRepo(c.repo) {
+		t.Errorf("PR branches = %+v → %+v, want klcm-sync of %s into main", pr.Head, pr.Base, c.repo)
+	}
+
+	updated, created, err := forge.OpenPullRequest(c.ctx, c.Client, c.repo, forge.NewPullRequest{Title: "Second", Body: "two", Head: "klcm-sync", Base: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || updated.Number != pr.Number || updated.Title != "Second" || updated.Body != "two" {
+		t.Errorf("second OpenPullRequest = %+v (created %v), want PR #%d retitled", updated, created, pr.Number)
+	}
+	if got, err := c.Client.GetPullRequest(c.ctx, c.repo, pr.Number); err != nil || got.Title != "Second" {
+		t.Errorf("GetPullRequest = %+v, %v; want the new title", got, err)
+	}
+}
+
+func testListPullRequests(t *testing.T, c *conformance) {
+	opened, merged, closed := c.open(t, "klcm-sync"), c.open(t, "merged"), c.open(t, "closed")
+	c.Server.Merge(c.repo.Owner, c.repo.Name, merged.Number)
+	if _, err := c.Client.EditPullRequest(c.ctx, c.repo, closed.Number, forge.PullRequestEdit{State: "closed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts forge.ListOptions
+		want []int
+	}{
+		{opts: forge.ListOptions{}, want: []int{opened.Number}},
+		{opts: forge.ListOptions{State: "open", Head: "klcm-sync"}, want: []int{opened.Number}},
+		{opts: forge.ListOptions{State: "open", Head: "closed"}, want: []int{}},
+		{opts: forge.ListOptions{State: "open", Base: "other"}, want: []int{}},
+		// Merged pull requests count as closed
+		{opts: forge.ListOptions{State: "closed"}, want: []int{merged.Number, closed.Number}},
+		{opts: forge.ListOptions{State: "all", Base: "main"}, want: []int{opened.Number, merged.Number, closed.Number}},
+	}
+	for _, tt := range tests {
+		if got := c.list(t, tt.opts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PullRequests(%+v) = %v, want %v", tt.opts, got, tt.want)
+		}
+	}
+
+	pr, err := c.Client.GetPullRequest(c.ctx, c.repo, merged.Number)
+	if err != nil || !pr.Merged || pr.State != "closed" {
+		t.Errorf("merged PR = %+v, %v; want closed and merged", pr, err)
+	}
+}
+
+// testListPages opens more pull requests than any forge returns at once
+func testListPages(t *testing.T, c *conformance) {
+	const count = 101
+	var want []int
+	for i := 0; i < count; i++ {
+		want = append(want, c.open(t, fmt.Sprintf("klcm-sync-%03d", i)).Number)
+	}
+	sort.Ints(want)
+
+	if got := c.list(t, forge.ListOptions{State: "open"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("PullRequests listed %d PRs, want all %d", len(got), count)
+	}
+	// The oldest is on the last page
+	if got := c.list(t, forge.ListOptions{State: "open", Head: "klcm-sync-000"}); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("PullRequests(head klcm-sync-000) = %v, want %v", got, want[:1])
+	}
+}
+
+func testDeleteBranch(t *testing.T, c *conformance) {
+	push := c.push(t, "klcm-sync", "first\n")
+	if _, err := c.Client.CreatePullRequest(c.ctx, c.repo, forge.NewPullRequest{Title: "t", Head: push.Branch, Base: "no-such-branch"}); err == nil {
+		t.Fatal("CreatePullRequest against a missing base succeeded")
+	}
+
+	if err := c.Client.DeleteBranch(c.ctx, c.repo, push.Branch); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.branches(); !reflect.DeepEqual(got, []string{"main"}) {
+		t.Errorf("branches after rollback = %v, want main only", got)
+	}
+	if err := c.Client.DeleteBranch(c.ctx, c.repo, push.Branch); err != nil {
+		t.Errorf("deleting a branch that is already gone: %v", err)
+	}
+}
+
+func testStatus(t *testing.T, c *conformance) {
+	pr := c.open(t, "klcm-sync")
+	status, err := c.Client.Status(c.ctx, c.repo, pr.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Number != pr.Number || status.ReviewDecision != forge.ReviewRequired || status.ChecksState != forge.ChecksNone {
+		t.Errorf("new PR status = #%d %s/%s, want #%d %s/%s", status.Number, status.ReviewDecision, status.ChecksState,
+			pr.Number, forge.ReviewRequired, forge.ChecksNone)
+	}
+
+	c.Server.AddReview(c.repo.Owner, c.repo.Name, pr.Number, "reviewer", "APPROVED")
+	tests := []struct {
+		check forge.Check
+		want  string
+	}{
+		{check: forge.Check{Name: "build", Status: "in_progress"}, want: forge.ChecksPending},
+		{check: forge.Check{Name: "build", Status: "completed", Conclusion: "success"}, want: forge.ChecksPassing},
+		{check: forge.Check{Name: "build", Status: "completed", Conclusion: "failure"}, want: forge.ChecksFailing},
+	}
+	for _, tt := range tests {
+		c.Server.SetChecks(c.repo.Owner, c.repo.Name, pr.Number, tt.check)
+		status, err := c.Client.Status(c.ctx, c.repo, pr.Number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.ReviewDecision != forge.ReviewApproved {
+			t.Errorf("review decision = %q, want %q", status.ReviewDecision, forge.ReviewApproved)
+		}
+		if status.ChecksState != tt.want || len(status.Checks) != 1 {
+			t.Errorf("check %s/%s: checks = %q %+v, want one, %s", tt.check.Status, tt.check.Conclusion, status.ChecksState, status.Checks, tt.want)
+		}
+	}
+}
+
+func testComment(t *testing.T, c *conformance) {
+	pr := c.open(t, "klcm-sync")
+	if err := c.Client.Comment(c.ctx, c.repo, pr.Number, "Superseded by #2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Server.Comments(c.repo.Owner, c.repo.Name, pr.Number); !reflect.DeepEqual(got, []string{"Superseded by #2"}) {
+		t.Errorf("comments = %q, want the one posted", got)
+	}
+}
+
+func testAuthentication(t *testing.T, c *conformance) {
+	user, err := c.Client.AuthenticatedUser(c.ctx)
+	if err != nil || user.Login != c.Login {
+		t.Errorf("AuthenticatedUser = %+v, %v; want %s", user, err, c.Login)
+	}
+	if _, err := c.Reject.AuthenticatedUser(c.ctx); !forge.IsUnauthorized(err) {
+		t.Errorf("AuthenticatedUser with a wrong token error = %v, want unauthorized", err)
+	}
+}
@@ -0,0 +1,426 @@
+// Package forgetest is the in-memory repositories and pull requests behind
+// the fake GitLab and Gitea servers (packages gitlabtest and giteatest).
+// Each server translates its forge's REST API onto a Store; tests script
+// reviews, checks and merges through the same Store methods. Page serves
+// list requests a page at a time for all the fake servers, githubtest too.
+package forgetest
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+)
+
+// Errors returned by Store operations, which servers map to status codes
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("conflict")
+)
+
+// Store holds repositories keyed by "owner/name". Every method locks, so a
+// server can call them from concurrent requests.
+type Store struct {
+	// PullURL returns the web URL of a pull request
+	PullURL func(owner, name string, number int) string
+
+	mu    sync.Mutex
+	repos map[string]*repo
+	clock time.Time
+}
+
+type repo struct {
+	branches map[string]string // branch -> commit
+	commits  map[string]commit
+	pulls    []*forge.PullRequest
+	reviews  map[int][]forge.Review
+	comments map[int][]string
+	checks   map[string][]forge.Check // commit -> checks
+}
+
+type commit struct {
+	Parent string
+	Files  map[string]string
+}
+
+// Page returns the page of items a list request asks for with its page query
+// parameter and sizeParam (per_page on GitHub and GitLab, limit on Gitea),
+// with size items per page when the request does not say
+func Page[T any](items []T, query url.Values, sizeParam string, size int) []T {
+	if n, err := strconv.Atoi(query.Get(sizeParam)); err == nil && n > 0 {
+		size = n
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	start := (page - 1) * size
+	if start >= len(items) {
+		return items[:0]
+	}
+	return items[start:min(start+size, len(items))]
+}
+
+// NewStore creates an empty store
+func NewStore() *Store {
+	return &Store{
+		PullURL: func(owner, name string, number int) string {
+			return fmt.Sprintf("/%s/%s/pulls/%d", owner, name, number)
+		},
+		repos: make(map[string]*repo),
+		clock: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// AddRepo creates a repository with one commit on branch holding files
+func (s *Store) AddRepo(owner, name, branch string, files map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &repo{
+		branches: make(map[string]string),
+		commits:  make(map[string]commit),
+		reviews:  make(map[int][]forge.Review),
+		comments: make(map[int][]string),
+		checks:   make(map[string][]forge.Check),
+	}
+	snapshot := make(map[string]string)
+	for path, content := range files {
+		snapshot[path] = content
+	}
+	r.branches[branch] = s.addCommit(r, commit{Files: snapshot})
+	s.repos[owner+"/"+name] = r
+}
+
+// HasRepo reports whether a repository exists
+func (s *Store) HasRepo(owner, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.repos[owner+"/"+name]
+	return ok
+}
+
+// BranchHead returns the commit a branch points to
+func (s *Store) BranchHead(owner, name, branch string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.repos[owner+"/"+name]
+	if !ok {
+		return "", false
+	}
+	head, ok := r.branches[branch]
+	return head, ok
+}
+
+// Branches returns the branches of a repository, sorted
+func (s *Store) Branches(owner, name string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var branches []string
+	if r, ok := s.repos[owner+"/"+name]; ok {
+		for branch := range r.branches {
+			branches = append(branches, branch)
+		}
+	}
+	sort.Strings(branches)
+	return branches
+}
+
+// File returns a file's content at a branch or commit
+func (s *Store) File(owner, name, ref, path string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.repos[owner+"/"+name]
+	if !ok {
+		return "", false
+	}
+	sha, ok := r.branches[ref]
+	if !ok {
+		sha = ref
+	}
+	c, ok := r.commits[sha]
+	if !ok {
+		return "", false
+	}
+	content, ok := c.Files[path]
+	return content, ok
+}
+
+// CommitFile commits one file to branch. With start set, branch must not
+// exist and is created from start; otherwise branch must exist.
+func (s *Store) CommitFile(owner, name, branch, start, path, content string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.repos[owner+"/"+name]
+	if !ok {
+		return "", fmt.Errorf("repository %s/%s: %w", owner, name, ErrNotFound)
+	}
+	parent, exists := r.branches[branch]
+	switch {
+	case start != "" && exists:
+		return "", fmt.Errorf("branch %s already exists: %w", branch, ErrConflict)
+	case start != "":
+		if parent, ok = r.branches[start]; !ok {
+			return "", fmt.Errorf("branch %s: %w", start, ErrNotFound)
+		}
+	case !exists:
+		return "", fmt.Errorf("branch %s: %w", branch, ErrNotFound)
+	}
+
+	files := make(map[string]string)
+	for p, c := range r.commits[parent].Files {
+		files[p] = c
+	}
+	files[path] = content
+	sha := s.addCommit(r, commit{Parent: parent, Files: files})
+	r.branches[branch] = sha
+	return sha, nil
+}
+
+// DeleteBranch deletes a branch and reports whether it existed
+func (s *Store) DeleteBranch(owner, name, branch string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.repos[owner+"/"+name]
+	if !ok {
+		return false
+	}
+	_, ok = r.branches[branch]
+	delete(r.branches, branch)
+	return ok
+}
+
+// CreatePull opens a pull request by login
+func (s *Store) CreatePull(owner, name string, in forge.NewPullRequest, login string) (forge.PullRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.repos[owner+"/"+name]
+	if !ok {
+		return forge.PullRequest{}, fmt.Errorf("repository %s/%s: %w", owner, name, ErrNotFound)
+	}
+	for _, branch := range []string{in.Head, in.Base} {
+		if _, ok := r.branches[branch]; !ok {
+			return forge.PullRequest{}, fmt.Errorf("branch %s: %w", branch, ErrNotFound)
+		}
+	}
+	for _, pr := range r.pulls {
+		if pr.State == "open" && pr.Head.Ref == in.Head && pr.Base.Ref == in.Base {
+			return forge.PullRequest{}, fmt.Errorf("a pull request already exists for %s: %w", in.Head, ErrConflict)
+		}
+	}
+
+	number := len(r.pulls) + 1
+	now := s.tick()
+	pr := &forge.PullRequest{
+		Number:    number,
+		Title:     in.Title,
+		Body:      in.Body,
+		State:     "open",
+		Draft:     in.Draft,
+		HTMLURL:   s.PullURL(owner, name, number),
+		User:      forge.User{Login: login},
+		Head:      forge.PRBranch{Ref: in.Head, Repo: &forge.RepoRef{FullName: owner + "/" + name}},
+		Base:      forge.PRBranch{Ref: in.Base},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.pulls = append(r.pulls, pr)
+	return r.pullCopy(pr), nil
+}
+
+// AddPullRequest adds an open pull request someone else opened, e.g. as
+// another user or from a fork, and returns its number. The number, URL and
+// times are filled in, and the head defaults to a branch of the repository.
+func (s *Store) AddPullRequest(owner, name string, pr forge.PullRequest) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repos[owner+"/"+name]
+	now := s.tick()
+	pr.Number = len(r.pulls) + 1
+	pr.State = "open"
+	pr.HTMLURL = s.PullURL(owner, name, pr.Number)
+	pr.CreatedAt, pr.UpdatedAt = now, now
+	if pr.Head.Repo == nil {
+		pr.Head.Repo = &forge.RepoRef{FullName: owner + "/" + name}
+	}
+	r.pulls = append(r.pulls, &pr)
+	return pr.Number
+}
+
+// Pull returns a pull request with its head and base commits filled in
+func (s *Store) Pull(owner, name string, number int) (forge.PullRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, pr := s.pull(owner, name, number)
+	if pr == nil {
+		return forge.PullRequest{}, false
+	}
+	return r.pullCopy(pr), true
+}
+
+// PullRequests returns every pull request of a repository, newest first
+func (s *Store) PullRequests(owner, name string) []forge.PullRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var prs []forge.PullRequest
+	if r, ok := s.repos[owner+"/"+name]; ok {
+		for i := len(r.pulls) - 1; i >= 0; i-- {
+			prs = append(prs, r.pullCopy(r.pulls[i]))
+		}
+	}
+	return prs
+}
+
+// EditPull changes the title, body or state of a pull request
+func (s *Store) EditPull(owner, name string, number int, edit forge.PullRequestEdit) (forge.PullRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, pr := s.pull(owner, name, number)
+	if pr == nil {
+		return forge.PullRequest{}, false
+	}
+	if edit.Title != "" {
+		pr.Title = edit.Title
+	}
+	if edit.Body != "" {
+		pr.Body = edit.Body
+	}
+	if edit.State != "" && !pr.Merged {
+		pr.State = edit.State
+	}
+	pr.UpdatedAt = s.tick()
+	return r.pullCopy(pr), true
+}
+
+// AddComment comments on a pull request and reports whether it exists
+func (s *Store) AddComment(owner, name string, number int, body string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, pr := s.pull(owner, name, number)
+	if pr == nil {
+		return false
+	}
+	r.comments[number] = append(r.comments[number], body)
+	return true
+}
+
+// Comments returns the comments on a pull request
+func (s *Store) Comments(owner, name string, number int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.repos[owner+"/"+name]; ok {
+		return append([]string(nil), r.comments[number]...)
+	}
+	return nil
+}
+
+// AddReview submits a review of a pull request, with a GitHub review state
+// (APPROVED, CHANGES_REQUESTED, COMMENTED)
+func (s *Store) AddReview(owner, name string, number int, login, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, pr := s.pull(owner, name, number); pr != nil {
+		r.reviews[number] = append(r.reviews[number], forge.Review{User: forge.User{Login: login}, State: state, SubmittedAt: s.tick()})
+	}
+}
+
+// Reviews returns the reviews of a pull request, oldest first
+func (s *Store) Reviews(owner, name string, number int) []forge.Review {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.repos[owner+"/"+name]; ok {
+		return append([]forge.Review{}, r.reviews[number]...)
+	}
+	return []forge.Review{}
+}
+
+// SetChecks sets the checks of the head commit of a pull request
+func (s *Store) SetChecks(owner, name string, number int, checks ...forge.Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, pr := s.pull(owner, name, number); pr != nil {
+		r.checks[r.branches[pr.Head.Ref]] = checks
+	}
+}
+
+// Checks returns the checks of a commit
+func (s *Store) Checks(owner, name, sha string) []forge.Check {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.repos[owner+"/"+name]; ok {
+		return append([]forge.Check{}, r.checks[sha]...)
+	}
+	return []forge.Check{}
+}
+
+// SetMergeable sets whether a pull request can be merged; nil means the
+// forge has not computed it yet
+func (s *Store) SetMergeable(owner, name string, number int, mergeable *bool, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, pr := s.pull(owner, name, number); pr != nil {
+		pr.Mergeable, pr.MergeableState = mergeable, state
+	}
+}
+
+// Merge merges a pull request by moving its base branch to its head
+func (s *Store) Merge(owner, name string, number int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, pr := s.pull(owner, name, number); pr != nil {
+		r.branches[pr.Base.Ref] = r.branches[pr.Head.Ref]
+		now := s.tick()
+		pr.State, pr.Merged, pr.MergedAt = "closed", true, &now
+	}
+}
+
+func (s *Store) pull(owner, name string, number int) (*repo, *forge.PullRequest) {
+	r, ok := s.repos[owner+"/"+name]
+	if !ok {
+		return nil, nil
+	}
+	for _, pr := range r.pulls {
+		if pr.Number == number {
+			return r, pr
+		}
+	}
+	return r, nil
+}
+
+func (s *Store) tick() time.Time {
+	s.clock = s.clock.Add(time.Minute)
+	return s.clock
+}
+
+// addCommit stores a commit; the clock makes commits of equal content distinct
+func (s *Store) addCommit(r *repo, c commit) string {
+	paths := make([]string, 0, len(c.Files))
+	for path := range c.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	h := sha1.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", c.Parent, s.tick())
+	for _, path := range paths {
+		fmt.Fprintf(h, "%s\x00%s\x00", path, c.Files[path])
+	}
+	sha := hex.EncodeToString(h.Sum(nil))
+	r.commits[sha] = c
+	return sha
+}
+
+// pullCopy returns a pull request with its head and base commits filled in
+func (r *repo) pullCopy(pr *forge.PullRequest) forge.PullRequest {
+	result := *pr
+	if sha, ok := r.branches[pr.Head.Ref]; ok {
+		result.Head.SHA = sha
+	}
+	result.Base.SHA = r.branches[pr.Base.Ref]
+	return result
+}
@@ -0,0 +1,292 @@
+// Package gitea is a small client for the Gitea (and Forgejo) REST API calls
+// klcm needs to open pull requests without a local clone. Client implements
+// forge.Forge; Gitea's pull requests already have GitHub's shape, and its
+// commit statuses are the checks.
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+)
+
+// Client calls the Gitea REST API
+type Client struct {
+	BaseURL string // API root, e.g. https://gitea.example.com/api/v1
+	Token   string // access token, sent as "Authorization: token ..."
+	HTTP    *http.Client
+}
+
+var _ forge.Forge = (*Client)(nil)
+
+// NewClient creates a client for the API at baseURL
+func NewClient(baseURL, token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token, HTTP: httpClient}
+}
+
+// Name is the display name of the forge
+func (c *Client) Name() string {
+	return "Gitea"
+}
+
+// repoPath returns the API path of a repository, followed by parts
+func repoPath(repo forge.Repo, parts ...string) string {
+	return "/repos/" + url.PathEscape(repo.Owner) + "/" + url.PathEscape(repo.Name) + strings.Join(parts, "")
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	if c.Token != "" {
+		header.Set("Authorization", "token "+c.Token)
+	}
+	api := forge.API{Forge: c.Name(), BaseURL: c.BaseURL, Header: header, HTTP: c.HTTP}
+	return api.Do(ctx, method, path, in, out)
+}
+
+// AuthenticatedUser returns the account the token belongs to
+func (c *Client) AuthenticatedUser(ctx context.Context) (*forge.User, error) {
+	var user forge.User
+	if err := c.do(ctx, http.MethodGet, "/user", nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// contents is a file as the contents API returns it
+type contents struct {
+	SHA      string `json:"sha"` // blob, needed to update the file
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+}
+
+func (c *Client) contents(ctx context.Context, repo forge.Repo, path, ref string) (*contents, []byte, error) {
+	var file contents
+	if err := c.do(ctx, http.MethodGet, repoPath(repo, "/contents/", path, "?ref=", url.QueryEscape(ref)), nil, &file); err != nil {
+		return nil, nil, err
+	}
+	if file.Encoding != "base64" {
+		return nil, nil, fmt.Errorf("Gitea API: %s at %s has unsupported encoding %q", path, ref, file.Encoding)
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+	return &file, content, err
+}
+
+// FileContent returns the content of a file at a branch, tag or commit
+func (c *Client) FileContent(ctx context.Context, repo forge.Repo, path, ref string) ([]byte, error) {
+	_, content, err := c.contents(ctx, repo, path, ref)
+	return content, err
+}
+
+// BranchHead returns the commit a branch points to
+func (c *Client) BranchHead(ctx context.Context, repo forge.Repo, branch string) (string, error) {
+	var b struct {
+		Commit struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	if err := c.do(ctx, http.MethodGet, repoPath(repo, "/branches/", url.PathEscape(branch)), nil, &b); err != nil {
+		return "", err
+	}
+	return b.Commit.ID, nil
+}
+
+// PushFile commits one file through the contents API, which creates the
+// branch from Base in the same call (new_branch) when it does not exist yet.
+// It returns forge.ErrNoChanges if the file is already up to date.
+func (c *Client) PushFile(ctx context.Context, repo forge.Repo, change forge.FileChange) (*forge.Push, error) {
+	push := &forge.Push{Branch: change.Branch}
+	from := change.Branch
+	parent, err := c.BranchHead(ctx, repo, change.Branch)
+	switch {
+	case forge.IsNotFound(err):
+		push.Created, from = true, change.Base
+		if parent, err = c.BranchHead(ctx, repo, change.Base); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+	push.Parent = parent
+
+	in := map[string]string{
+		"branch":  from,
+		"content": base64.StdEncoding.EncodeToString(change.Content),
+		"message": change.Message,
+	}
+	if push.Created {
+		in["new_branch"] = change.Branch
+	}
+	method := http.MethodPost
+	current, content, err := c.contents(ctx, repo, change.Path, parent)
+	switch {
+	case forge.IsNotFound(err):
+	case err != nil:
+		return nil, err
+	case bytes.Equal(content, change.Content):
+		return nil, forge.ErrNoChanges
+	default:
+		method, in["sha"] = http.MethodPut, current.SHA
+	}
+
+	var out struct {
+		Commit struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	}
+	if err := c.do(ctx, method, repoPath(repo, "/contents/", change.Path), in, &out); err != nil {
+		return nil, err
+	}
+	push.Commit = out.Commit.SHA
+	return push, nil
+}
+
+// DeleteBranch deletes a branch; a branch that is already gone is not an
+// error
+func (c *Client) DeleteBranch(ctx context.Context, repo forge.Repo, branch string) error {
+	err := c.do(ctx, http.MethodDelete, repoPath(repo, "/branches/", url.PathEscape(branch)), nil, nil)
+	if forge.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// CreatePullRequest opens a pull request
+func (c *Client) CreatePullRequest(ctx context.Context, repo forge.Repo, pr forge.NewPullRequest) (*forge.PullRequest, error) {
+	if pr.Draft {
+		pr.Title = "WIP: " + pr.Title
+	}
+	var created forge.PullRequest
+	if err := c.do(ctx, http.MethodPost, repoPath(repo, "/pulls"), pr, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// EditPullRequest updates the title, body or state of a pull request
+func (c *Client) EditPullRequest(ctx context.Context, repo forge.Repo, number int, edit forge.PullRequestEdit) (*forge.PullRequest, error) {
+	var updated forge.PullRequest
+	if err := c.do(ctx, http.MethodPatch, repoPath(repo, fmt.Sprintf("/pulls/%d", number)), edit, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// GetPullRequest returns a pull request, including its mergeability
+func (c *Client) GetPullRequest(ctx context.Context, repo forge.Repo, number int) (*forge.PullRequest, error) {
+	var pr forge.PullRequest
+	if err := c.do(ctx, http.MethodGet, repoPath(repo, fmt.Sprintf("/pulls/%d", number)), nil, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// pullsPerPage is the page size of pull request lists, Gitea's default
+// maximum
+const pullsPerPage = 50
+
+// PullRequests lists pull requests, most recently updated first, reading
+// every page. Gitea cannot filter the list by branch, so head and base are
+// matched here.
+func (c *Client) PullRequests(ctx context.Context, repo forge.Repo, opts forge.ListOptions) ([]forge.PullRequest, error) {
+	query := url.Values{"limit": {strconv.Itoa(pullsPerPage)}, "sort": {"recentupdate"}, "state": {"open"}}
+	if opts.State != "" {
+		query.Set("state", opts.State)
+	}
+	all, err := forge.ListPages(pullsPerPage, func(page int) ([]forge.PullRequest, error) {
+		query.Set("page", strconv.Itoa(page))
+		var prs []forge.PullRequest
+		err := c.do(ctx, http.MethodGet, repoPath(repo, "/pulls?", query.Encode()), nil, &prs)
+		return prs, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var prs []forge.PullRequest
+	for _, pr := range all {
+		if (opts.Head == "" || pr.Head.Ref == opts.Head) && (opts.Base == "" || pr.Base.Ref == opts.Base) {
+			prs = append(prs, pr)
+		}
+	}
+	return prs, nil
+}
+
+// reviews lists the reviews of a pull request with GitHub's review states
+func (c *Client) reviews(ctx context.Context, repo forge.Repo, number int) ([]forge.Review, error) {
+	var reviews []forge.Review
+	if err := c.do(ctx, http.MethodGet, repoPath(repo, fmt.Sprintf("/pulls/%d/reviews", number)), nil, &reviews); err != nil {
+		return nil, err
+	}
+	for i := range reviews {
+		switch reviews[i].State {
+		case "REQUEST_CHANGES":
+			reviews[i].State = "CHANGES_REQUESTED"
+		case "COMMENT":
+			reviews[i].State = "COMMENTED"
+		}
+	}
+	return reviews, nil
+}
+
+// checks lists the commit statuses of a commit as checks
+func (c *Client) checks(ctx context.Context, repo forge.Repo, commit string) ([]forge.Check, error) {
+	var combined struct {
+		Statuses []struct {
+			Context   string `json:"context"`
+			Status    string `json:"status"` // pending, success, error, failure or warning
+			TargetURL string `json:"target_url"`
+		} `json:"statuses"`
+	}
+	if err := c.do(ctx, http.MethodGet, repoPath(repo, "/commits/", commit, "/status"), nil, &combined); err != nil {
+		return nil, err
+	}
+	var checks []forge.Check
+	for _, status := range combined.Statuses {
+		check := forge.Check{Name: status.Context, Status: "completed", Conclusion: status.Status, URL: status.TargetURL}
+		switch status.Status {
+		case "pending":
+			check.Status, check.Conclusion = "in_progress", ""
+		case "error":
+			check.Conclusion = "failure"
+		case "warning":
+			check.Conclusion = "neutral"
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// Status gathers the reviews, commit statuses and mergeability of a pull
+// request
+func (c *Client) Status(ctx context.Context, repo forge.Repo, number int) (*forge.PullRequestStatus, error) {
+	pr, err := c.GetPullRequest(ctx, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	reviews, err := c.reviews(ctx, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := c.checks(ctx, repo, pr.Head.SHA)
+	if err != nil {
+		return nil, err
+	}
+	return forge.NewStatus(*pr, reviews, checks), nil
+}
+
+// Comment adds a comment to a pull request
+func (c *Client) Comment(ctx context.Context, repo forge.Repo, number int, body string) error {
+	in := map[string]string{"body": body}
+	return c.do(ctx, http.MethodPost, repoPath(repo, fmt.Sprintf("/issues/%d/comments", number)), in, nil)
+}
@@ -0,0 +1,130 @@
+package gitea_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge/forgetest"
+	"masters3d.com/keyboard_layout_config_mapper/internal/gitea"
+	"masters3d.com/keyboard_layout_config_mapper/internal/gitea/giteatest"
+)
+
+var upstream = forge.Repo{Owner: "moergo-sc", Name: "glove80-zmk-config"}
+
+func newFake(t *testing.T) forgetest.Fake {
+	server := giteatest.NewServer()
+	server.Token = "test-token"
+	t.Cleanup(server.Close)
+	return forgetest.Fake{
+		Server: server,
+		Client: server.Client(),
+		Reject: gitea.NewClient(server.APIURL(), "wrong-token", server.Server.Client()),
+		Login:  server.Login,
+	}
+}
+
+func TestConformance(t *testing.T) {
+	forgetest.RunConformance(t, upstream, newFake)
+}
+
+// newServer starts a fake holding upstream with a keymap on main
+func newServer(t *testing.T) (*giteatest.Server, *gitea.Client) {
+	t.Helper()
+	server := giteatest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddRepo(upstream.Owner, upstream.Name, "main", map[string]string{"config/glove80.keymap": "base\n"})
+	return server, server.Client()
+}
+
+// open pushes branch from base and opens a pull request from it into base
+func open(t *testing.T, client *gitea.Client, branch, base string, draft bool) *forge.PullRequest {
+	t.Helper()
+	ctx := context.Background()
+	change := forge.FileChange{Base: base, Branch: branch, Path: "config/glove80.keymap", Content: []byte(branch + "\n"), Message: "Update"}
+	if _, err := client.PushFile(ctx, upstream, change); err != nil {
+		t.Fatal(err)
+	}
+	pr, err := client.CreatePullRequest(ctx, upstream, forge.NewPullRequest{Title: "Update " + branch, Head: branch, Base: base, Draft: draft})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pr
+}
+
+func TestCreatePullRequestDraftTitle(t *testing.T) {
+	_, client := newServer(t)
+	if pr := open(t, client, "klcm-sync", "main", true); !strings.HasPrefix(pr.Title, "WIP: ") {
+		t.Errorf("draft title = %q, want the WIP: prefix Gitea uses for drafts", pr.Title)
+	}
+}
+
+// Gitea cannot filter by branch, so the client does
+func TestPullRequestsFiltersBranchesClientSide(t *testing.T) {
+	_, client := newServer(t)
+	ctx := context.Background()
+	sync := open(t, client, "klcm-sync", "main", false)
+	other := open(t, client, "other", "main", false)
+	if _, err := client.PushFile(ctx, upstream, forge.FileChange{Base: "main", Branch: "next", Path: "README.md", Content: []byte("next\n"), Message: "Start next"}); err != nil {
+		t.Fatal(err)
+	}
+	onNext := open(t, client, "on-next", "next", false)
+
+	tests := []struct {
+		opts forge.ListOptions
+		want []int
+	}{
+		{opts: forge.ListOptions{Head: "klcm-sync"}, want: []int{sync.Number}},
+		{opts: forge.ListOptions{Base: "main"}, want: []int{other.Number, sync.Number}},
+		{opts: forge.ListOptions{Base: "next"}, want: []int{onNext.Number}},
+		{opts: forge.ListOptions{Head: "on-next", Base: "main"}, want: nil},
+	}
+	for _, tt := range tests {
+		prs, err := client.PullRequests(ctx, upstream, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, pr := range prs {
+			got = append(got, pr.Number)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PullRequests(%+v) = %v, want %v", tt.opts, got, tt.want)
+		}
+	}
+}
+
+// Gitea's REQUEST_CHANGES comes back as GitHub's CHANGES_REQUESTED, and
+// every commit status counts
+func TestStatusReviewsAndCommitStatuses(t *testing.T) {
+	server, client := newServer(t)
+	ctx := context.Background()
+	pr := open(t, client, "klcm-sync", "main", false)
+
+	server.AddReview(upstream.Owner, upstream.Name, pr.Number, "reviewer", "CHANGES_REQUESTED")
+	server.SetChecks(upstream.Owner, upstream.Name, pr.Number,
+		forge.Check{Name: "build", Status: "completed", Conclusion: "success"},
+		forge.Check{Name: "lint", Status: "in_progress"})
+
+	status, err := client.Status(ctx, upstream, pr.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.ReviewDecision != forge.ReviewChangesRequested {
+		t.Errorf("review decision = %q, want %q", status.ReviewDecision, forge.ReviewChangesRequested)
+	}
+	if status.ChecksState != forge.ChecksPending || len(status.Checks) != 2 {
+		t.Errorf("checks = %q %+v, want two, pending", status.ChecksState, status.Checks)
+	}
+
+	// A later approval by the same reviewer replaces the change request
+	server.AddReview(upstream.Owner, upstream.Name, pr.Number, "reviewer", "APPROVED")
+	if status, err = client.Status(ctx, upstream, pr.Number); err != nil {
+		t.Fatal(err)
+	}
+	if status.ReviewDecision != forge.ReviewApproved {
+		t.Errorf("review decision after approval = %q, want %q", status.ReviewDecision, forge.ReviewApproved)
+	}
+}
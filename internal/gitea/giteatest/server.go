@@ -0,0 +1,323 @@
+// Package giteatest is an in-memory fake of the Gitea REST API calls made by
+// package gitea, served with httptest under /api/v1, for end-to-end tests of
+// klcm's pull request automation without network access.
+package giteatest
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge/forgetest"
+	"masters3d.com/keyboard_layout_config_mapper/internal/gitea"
+)
+
+// Server is a fake Gitea instance. Create repositories with AddRepo, point a
+// gitea.Client at APIURL, then inspect the repositories or script reviews,
+// commit statuses and merges through the embedded Store.
+type Server struct {
+	*httptest.Server
+	*forgetest.Store
+
+	// Token, if set, is the only token accepted; other requests get 401
+	Token string
+	// Login is the user the token belongs to
+	Login string
+}
+
+// NewServer starts a fake Gitea; Close it when done
+func NewServer() *Server {
+	s := &Server{Store: forgetest.NewStore(), Login: "klcm-test"}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	s.Store.PullURL = func(owner, name string, number int) string {
+		return fmt.Sprintf("%s/%s/%s/pulls/%d", s.URL, owner, name, number)
+	}
+	return s
+}
+
+// APIURL is the API root to configure clients with
+func (s *Server) APIURL() string {
+	return s.URL + "/api/v1"
+}
+
+// Client returns a gitea.Client for the fake, sending Token
+func (s *Server) Client() *gitea.Client {
+	return gitea.NewClient(s.APIURL(), s.Token, s.Server.Client())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+// writeStoreError answers with the status matching a Store error
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, forgetest.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, forgetest.ErrConflict):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	}
+}
+
+// blobSHA identifies a file's content, as Gitea's contents API does
+func blobSHA(content string) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("blob %d\x00%s", len(content), content)))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) serve(w http.ResponseWriter, req *http.Request) {
+	if s.Token != "" && req.Header.Get("Authorization") != "token "+s.Token {
+		writeError(w, http.StatusUnauthorized, "token is required")
+		return
+	}
+
+	path, ok := strings.CutPrefix(req.URL.EscapedPath(), "/api/v1/")
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	if path == "user" && req.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, forge.User{Login: s.Login})
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	for i := range parts {
+		unescaped, err := url.PathUnescape(parts[i])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Bad Request")
+			return
+		}
+		parts[i] = unescaped
+	}
+	if len(parts) < 4 || parts[0] != "repos" || !s.HasRepo(parts[1], parts[2]) {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	owner, name := parts[1], parts[2]
+	route := strings.Join(parts[3:], "/")
+
+	switch {
+	case strings.HasPrefix(route, "branches/"):
+		s.serveBranch(w, req, owner, name, strings.TrimPrefix(route, "branches/"))
+	case strings.HasPrefix(route, "contents/"):
+		s.serveContents(w, req, owner, name, strings.TrimPrefix(route, "contents/"))
+	case route == "pulls" && req.Method == http.MethodGet:
+		s.listPulls(w, req, owner, name)
+	case route == "pulls" && req.Method == http.MethodPost:
+		s.createPull(w, req, owner, name)
+	case strings.HasPrefix(route, "pulls/"):
+		s.servePull(w, req, owner, name, strings.TrimPrefix(route, "pulls/"))
+	case strings.HasPrefix(route, "issues/") && strings.HasSuffix(route, "/comments") && req.Method == http.MethodPost:
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(route, "issues/"), "/comments"))
+		var in struct {
+			Body string `json:"body"`
+		}
+		if err != nil || json.NewDecoder(req.Body).Decode(&in) != nil || in.Body == "" {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+			return
+		}
+		if !s.AddComment(owner, name, n, in.Body) {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]any{"body": in.Body})
+	case strings.HasPrefix(route, "commits/") && strings.HasSuffix(route, "/status") && req.Method == http.MethodGet:
+		sha := strings.TrimSuffix(strings.TrimPrefix(route, "commits/"), "/status")
+		statuses := []map[string]string{}
+		for _, check := range s.Checks(owner, name, sha) {
+			statuses = append(statuses, map[string]string{"context": check.Name, "status": commitStatus(check), "target_url": check.URL})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"sha": sha, "statuses": statuses})
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// commitStatus is the Gitea commit status for a check
+func commitStatus(check forge.Check) string {
+	if check.Status != "completed" {
+		return "pending"
+	}
+	switch check.Conclusion {
+	case "success", "skipped":
+		return "success"
+	case "neutral":
+		return "warning"
+	}
+	return "failure"
+}
+
+func (s *Server) serveBranch(w http.ResponseWriter, req *http.Request, owner, name, branch string) {
+	switch req.Method {
+	case http.MethodGet:
+		head, ok := s.BranchHead(owner, name, branch)
+		if !ok {
+			writeError(w, http.StatusNotFound, "Branch not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"name": branch, "commit": map[string]string{"id": head}})
+	case http.MethodDelete:
+		if !s.DeleteBranch(owner, name, branch) {
+			writeError(w, http.StatusNotFound, "Branch not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (s *Server) serveContents(w http.ResponseWriter, req *http.Request, owner, name, path string) {
+	if req.Method == http.MethodGet {
+		ref := req.URL.Query().Get("ref")
+		content, ok := s.File(owner, name, ref, path)
+		if !ok {
+			writeError(w, http.StatusNotFound, "file does not exist")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"type":     "file",
+			"path":     path,
+			"sha":      blobSHA(content),
+			"encoding": "base64",
+			"content":  base64.StdEncoding.EncodeToString([]byte(content)),
+		})
+		return
+	}
+	if req.Method != http.MethodPost && req.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	var in struct {
+		Branch    string `json:"branch"`
+		NewBranch string `json:"new_branch"`
+		Content   string `json:"content"`
+		Message   string `json:"message"`
+		SHA       string `json:"sha"`
+	}
+	if json.NewDecoder(req.Body).Decode(&in) != nil || in.Branch == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+		return
+	}
+	content, err := base64.StdEncoding.DecodeString(in.Content)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "content is not base64")
+		return
+	}
+	current, exists := s.File(owner, name, in.Branch, path)
+	switch {
+	case req.Method == http.MethodPost && exists:
+		writeError(w, http.StatusUnprocessableEntity, "repository file already exists")
+		return
+	case req.Method == http.MethodPut && !exists:
+		writeError(w, http.StatusNotFound, "file does not exist")
+		return
+	case req.Method == http.MethodPut && in.SHA != blobSHA(current):
+		writeError(w, http.StatusUnprocessableEntity, "sha does not match")
+		return
+	}
+
+	branch, start := in.Branch, ""
+	if in.NewBranch != "" {
+		branch, start = in.NewBranch, in.Branch
+	}
+	sha, err := s.CommitFile(owner, name, branch, start, path, string(content))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if req.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, map[string]any{
+		"content": map[string]string{"path": path, "sha": blobSHA(string(content))},
+		"commit":  map[string]string{"sha": sha, "message": in.Message},
+	})
+}
+
+func (s *Server) listPulls(w http.ResponseWriter, req *http.Request, owner, name string) {
+	state := req.URL.Query().Get("state")
+	if state == "" {
+		state = "open"
+	}
+	prs := []forge.PullRequest{}
+	for _, pr := range s.PullRequests(owner, name) {
+		if state == "all" || pr.State == state {
+			prs = append(prs, pr)
+		}
+	}
+	writeJSON(w, http.StatusOK, forgetest.Page(prs, req.URL.Query(), "limit", 30))
+}
+
+func (s *Server) createPull(w http.ResponseWriter, req *http.Request, owner, name string) {
+	var in forge.NewPullRequest
+	if json.NewDecoder(req.Body).Decode(&in) != nil || in.Title == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+		return
+	}
+	in.Draft = strings.HasPrefix(in.Title, "WIP: ")
+	pr, err := s.CreatePull(owner, name, in, s.Login)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, pr)
+}
+
+func (s *Server) servePull(w http.ResponseWriter, req *http.Request, owner, name, route string) {
+	number, rest, _ := strings.Cut(route, "/")
+	n, err := strconv.Atoi(number)
+	pr, ok := s.Pull(owner, name, n)
+	if err != nil || !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	switch {
+	case rest == "" && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, pr)
+	case rest == "" && req.Method == http.MethodPatch:
+		var edit forge.PullRequestEdit
+		if json.NewDecoder(req.Body).Decode(&edit) != nil {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+			return
+		}
+		pr, _ = s.EditPull(owner, name, n, edit)
+		writeJSON(w, http.StatusCreated, pr)
+	case rest == "reviews" && req.Method == http.MethodGet:
+		reviews := []map[string]any{}
+		for _, review := range s.Reviews(owner, name, n) {
+			state := review.State
+			switch state {
+			case "CHANGES_REQUESTED":
+				state = "REQUEST_CHANGES"
+			case "COMMENTED":
+				state = "COMMENT"
+			}
+			reviews = append(reviews, map[string]any{"user": review.User, "state": state, "submitted_at": review.SubmittedAt})
+		}
+		writeJSON(w, http.StatusOK, reviews)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
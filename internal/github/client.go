@@ -0,0 +1,68 @@
+// Package github is a small client for the GitHub REST API calls klcm needs
+// to open pull requests without a local clone: branches, commits through the
+// git data API, pull requests, reviews and checks. Client implements
+// forge.Forge.
+package github
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+)
+
+// DefaultBaseURL is the API root of github.com
+const DefaultBaseURL = "https://api.github.com"
+
+// Client calls the GitHub REST API
+type Client struct {
+	BaseURL string // API root, e.g. DefaultBaseURL or a GitHub Enterprise /api/v3 URL
+	Token   string // sent as "Authorization: token ..."; empty for anonymous calls
+	HTTP    *http.Client
+}
+
+var _ forge.Forge = (*Client)(nil)
+
+// NewClient creates a client for the API at baseURL ("" for github.com)
+func NewClient(baseURL, token string, httpClient *http.Client) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token, HTTP: httpClient}
+}
+
+// Name is the display name of the forge
+func (c *Client) Name() string {
+	return "GitHub"
+}
+
+// repoPath returns the API path of a repository, followed by parts
+func repoPath(repo forge.Repo, parts ...string) string {
+	return "/repos/" + repo.Owner + "/" + repo.Name + strings.Join(parts, "")
+}
+
+// do sends a JSON request and decodes a JSON response into out, if non-nil
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	header := http.Header{}
+	header.Set("Accept", "application/vnd.github+json")
+	header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.Token != "" {
+		header.Set("Authorization", "token "+c.Token)
+	}
+	api := forge.API{Forge: c.Name(), BaseURL: c.BaseURL, Header: header, HTTP: c.HTTP}
+	return api.Do(ctx, method, path, in, out)
+}
+
+// AuthenticatedUser returns the account the token belongs to
+func (c *Client) AuthenticatedUser(ctx context.Context) (*forge.User, error) {
+	var user forge.User
+	if err := c.do(ctx, http.MethodGet, "/user", nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
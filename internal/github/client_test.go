@@ -0,0 +1,90 @@
+package github_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge/forgetest"
+	"masters3d.com/keyboard_layout_config_mapper/internal/github"
+	"masters3d.com/keyboard_layout_config_mapper/internal/github/githubtest"
+)
+
+var upstream = forge.Repo{Owner: "moergo-sc", Name: "glove80-zmk-config"}
+
+func newFake(t *testing.T) forgetest.Fake {
+	server := githubtest.NewServer()
+	server.Token = "test-token"
+	t.Cleanup(server.Close)
+	return forgetest.Fake{
+		Server: server,
+		Client: server.Client(),
+		Reject: github.NewClient(server.URL, "wrong-token", server.Server.Client()),
+		Login:  server.Login,
+	}
+}
+
+func TestConformance(t *testing.T) {
+	forgetest.RunConformance(t, upstream, newFake)
+}
+
+// newServer starts a fake holding upstream with a keymap on main
+func newServer(t *testing.T) (*githubtest.Server, *github.Client) {
+	t.Helper()
+	server := githubtest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddRepo(upstream.Owner, upstream.Name, "main", map[string]string{"config/glove80.keymap": "base\n"})
+	return server, server.Client()
+}
+
+func TestCreatePullRequestValidationFailed(t *testing.T) {
+	_, client := newServer(t)
+
+	_, err := client.CreatePullRequest(context.Background(), upstream, forge.NewPullRequest{Title: "t", Head: "main", Base: "no-such-branch"})
+	var apiErr *forge.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 422 {
+		t.Errorf("CreatePullRequest against a missing base error = %v, want a 422", err)
+	}
+}
+
+// GitHub filters by head as owner:branch, so a fork's branch of the same
+// name is not listed
+func TestPullRequestsHeadExcludesForks(t *testing.T) {
+	server, client := newServer(t)
+	ctx := context.Background()
+	change := forge.FileChange{Base: "main", Branch: "klcm-sync", Path: "config/glove80.keymap", Content: []byte("first\n"), Message: "Update"}
+	if _, err := client.PushFile(ctx, upstream, change); err != nil {
+		t.Fatal(err)
+	}
+	own, err := client.CreatePullRequest(ctx, upstream, forge.NewPullRequest{Title: "Ours", Head: "klcm-sync", Base: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.AddPullRequest(upstream.Owner, upstream.Name, forge.PullRequest{
+		Title: "From a fork",
+		User:  forge.User{Login: "someone-else"},
+		Head:  forge.PRBranch{Ref: "klcm-sync", Repo: &forge.RepoRef{FullName: "someone-else/" + upstream.Name}},
+		Base:  forge.PRBranch{Ref: "main"},
+	})
+
+	prs, err := client.PullRequests(ctx, upstream, forge.ListOptions{Head: "klcm-sync"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prs) != 1 || prs[0].Number != own.Number || !prs[0].FromRepo(upstream) {
+		t.Errorf("PullRequests(head klcm-sync) = %+v, want only #%d from %s", prs, own.Number, upstream)
+	}
+	all, err := client.PullRequests(ctx, upstream, forge.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromUpstream []bool
+	for _, pr := range all {
+		fromUpstream = append(fromUpstream, pr.FromRepo(upstream))
+	}
+	if !reflect.DeepEqual(fromUpstream, []bool{false, true}) {
+		t.Errorf("FromRepo of the fork's and our PR = %v, want false and true", fromUpstream)
+	}
+}
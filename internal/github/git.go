@@ -0,0 +1,139 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+)
+
+type gitObject struct {
+	SHA string `json:"sha"`
+}
+
+type gitRef struct {
+	Ref    string    `json:"ref"`
+	Object gitObject `json:"object"`
+}
+
+type gitCommit struct {
+	SHA     string      `json:"sha"`
+	Message string      `json:"message,omitempty"`
+	Tree    gitObject   `json:"tree"`
+	Parents []gitObject `json:"parents,omitempty"`
+}
+
+// BranchHead returns the commit a branch points to
+func (c *Client) BranchHead(ctx context.Context, repo forge.Repo, branch string) (string, error) {
+	var ref gitRef
+	if err := c.do(ctx, http.MethodGet, repoPath(repo, "/git/ref/heads/", branch), nil, &ref); err != nil {
+		return "", err
+	}
+	return ref.Object.SHA, nil
+}
+
+// CreateBranch creates a branch pointing at commit
+func (c *Client) CreateBranch(ctx context.Context, repo forge.Repo, branch, commit string) error {
+	in := map[string]string{"ref": "refs/heads/" + branch, "sha": commit}
+	return c.do(ctx, http.MethodPost, repoPath(repo, "/git/refs"), in, nil)
+}
+
+// UpdateBranch moves a branch to commit; without force the move must be a
+// fast-forward
+func (c *Client) UpdateBranch(ctx context.Context, repo forge.Repo, branch, commit string, force bool) error {
+	in := map[string]any{"sha": commit, "force": force}
+	return c.do(ctx, http.MethodPatch, repoPath(repo, "/git/refs/heads/", branch), in, nil)
+}
+
+// DeleteBranch deletes a branch; a branch that is already gone is not an
+// error (GitHub answers 422 for it)
+func (c *Client) DeleteBranch(ctx context.Context, repo forge.Repo, branch string) error {
+	err := c.do(ctx, http.MethodDelete, repoPath(repo, "/git/refs/heads/", branch), nil, nil)
+	var apiErr *forge.APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusUnprocessableEntity) {
+		return nil
+	}
+	return err
+}
+
+// PushFile commits one file to a branch through the git data API, without a
+// clone: blob, tree on top of the branch's tree, commit, then the ref. An
+// existing branch gets the commit on top of its head; a new one is created
+// from Base. It returns ErrNoChanges if the file is already up to date.
+func (c *Client) PushFile(ctx context.Context, repo forge.Repo, change forge.FileChange) (*forge.Push, error) {
+	push := &forge.Push{Branch: change.Branch}
+	parent, err := c.BranchHead(ctx, repo, change.Branch)
+	switch {
+	case forge.IsNotFound(err):
+		push.Created = true
+		if parent, err = c.BranchHead(ctx, repo, change.Base); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+	push.Parent = parent
+
+	var parentCommit gitCommit
+	if err := c.do(ctx, http.MethodGet, repoPath(repo, "/git/commits/", parent), nil, &parentCommit); err != nil {
+		return nil, err
+	}
+
+	var blob gitObject
+	blobIn := map[string]string{"content": base64.StdEncoding.EncodeToString(change.Content), "encoding": "base64"}
+	if err := c.do(ctx, http.MethodPost, repoPath(repo, "/git/blobs"), blobIn, &blob); err != nil {
+		return nil, err
+	}
+
+	var tree gitObject
+	treeIn := map[string]any{
+		"base_tree": parentCommit.Tree.SHA,
+		"tree": []map[string]string{
+			{"path": change.Path, "mode": "100644", "type": "blob", "sha": blob.SHA},
+		},
+	}
+	if err := c.do(ctx, http.MethodPost, repoPath(repo, "/git/trees"), treeIn, &tree); err != nil {
+		return nil, err
+	}
+	if tree.SHA == parentCommit.Tree.SHA {
+		return nil, forge.ErrNoChanges
+	}
+
+	var commit gitCommit
+	commitIn := map[string]any{"message": change.Message, "tree": tree.SHA, "parents": []string{parent}}
+	if err := c.do(ctx, http.MethodPost, repoPath(repo, "/git/commits"), commitIn, &commit); err != nil {
+		return nil, err
+	}
+	push.Commit = commit.SHA
+
+	if push.Created {
+		err = c.CreateBranch(ctx, repo, change.Branch, commit.SHA)
+	} else {
+		err = c.UpdateBranch(ctx, repo, change.Branch, commit.SHA, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return push, nil
+}
+
+// FileContent returns the content of a file at a branch, tag or commit
+func (c *Client) FileContent(ctx context.Context, repo forge.Repo, path, ref string) ([]byte, error) {
+	var file struct {
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+	}
+	if err := c.do(ctx, http.MethodGet, repoPath(repo, "/contents/", path, "?ref=", url.QueryEscape(ref)), nil, &file); err != nil {
+		return nil, err
+	}
+	if file.Encoding != "base64" {
+		return nil, fmt.Errorf("GitHub API: %s at %s has unsupported encoding %q", path, ref, file.Encoding)
+	}
+	// GitHub wraps the base64 content at 60 columns
+	return base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+}
@@ -0,0 +1,687 @@
+// Package githubtest is an in-memory fake of the GitHub REST API calls made
+// by package github, served with httptest, for end-to-end tests of klcm's
+// pull request automation without network access.
+package githubtest
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge/forgetest"
+	"masters3d.com/keyboard_layout_config_mapper/internal/github"
+)
+
+// Server is a fake GitHub API. Create repositories with AddRepo, point a
+// github.Client at URL, then inspect the repositories or script reviews and
+// checks.
+type Server struct {
+	*httptest.Server
+
+	// Token, if set, is the only token accepted; other requests get 401
+	Token string
+	// Login is the user the token belongs to
+	Login string
+
+	mu    sync.Mutex
+	repos map[string]*repo
+	clock time.Time
+}
+
+type repo struct {
+	refs     map[string]string // branch -> commit
+	commits  map[string]commit
+	trees    map[string]map[string]string // tree -> path -> blob
+	blobs    map[string][]byte
+	pulls    []*forge.PullRequest
+	reviews  map[int][]forge.Review
+	comments map[int][]string
+	checks   map[string][]forge.Check // commit -> checks
+}
+
+type commit struct {
+	Message string
+	Tree    string
+	Parents []string
+}
+
+// NewServer starts a fake GitHub API; Close it when done
+func NewServer() *Server {
+	s := &Server{
+		Login: "klcm-test",
+		repos: make(map[string]*repo),
+		clock: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Client returns a github.Client for the fake, sending Token
+func (s *Server) Client() *github.Client {
+	return github.NewClient(s.URL, s.Token, s.Server.Client())
+}
+
+// AddRepo creates a repository with one commit on branch holding files
+func (s *Server) AddRepo(owner, name, branch string, files map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &repo{
+		refs:     make(map[string]string),
+		commits:  make(map[string]commit),
+		trees:    make(map[string]map[string]string),
+		blobs:    make(map[string][]byte),
+		reviews:  make(map[int][]forge.Review),
+		comments: make(map[int][]string),
+		checks:   make(map[string][]forge.Check),
+	}
+	entries := make(map[string]string)
+	for path, content := range files {
+		entries[path] = r.addBlob([]byte(content))
+	}
+	r.refs[branch] = r.addCommit(commit{Message: "Initial commit", Tree: r.addTree(entries)})
+	s.repos[owner+"/"+name] = r
+}
+
+// File returns a file's content at the head of a branch
+func (s *Server) File(owner, name, branch, path string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.repos[owner+"/"+name]
+	if !ok {
+		return "", false
+	}
+	head, ok := r.refs[branch]
+	if !ok {
+		return "", false
+	}
+	blob, ok := r.trees[r.commits[head].Tree][path]
+	if !ok {
+		return "", false
+	}
+	return string(r.blobs[blob]), true
+}
+
+// Branches returns the branches of a repository, sorted
+func (s *Server) Branches(owner, name string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var branches []string
+	if r, ok := s.repos[owner+"/"+name]; ok {
+		for branch := range r.refs {
+			branches = append(branches, branch)
+		}
+	}
+	sort.Strings(branches)
+	return branches
+}
+
+// Commit pushes a commit changing one file to a branch, as someone else
+// editing the repository would
+func (s *Server) Commit(owner, name, branch, path, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repos[owner+"/"+name]
+	parent := r.refs[branch]
+	entries := make(map[string]string)
+	for p, blob := range r.trees[r.commits[parent].Tree] {
+		entries[p] = blob
+	}
+	entries[path] = r.addBlob([]byte(content))
+	r.refs[branch] = r.addCommit(commit{Message: "Update " + path, Tree: r.addTree(entries), Parents: []string{parent}})
+}
+
+// PullRequests returns copies of every pull request of a repository
+func (s *Server) PullRequests(owner, name string) []forge.PullRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var prs []forge.PullRequest
+	if r, ok := s.repos[owner+"/"+name]; ok {
+		for _, pr := range r.pulls {
+			prs = append(prs, *pr)
+		}
+	}
+	return prs
+}
+
+// AddPullRequest adds an open pull request someone else opened, e.g. as
+// another user or from a fork, and returns its number. The number, URL and
+// times are filled in, and the head defaults to a branch of the repository.
+func (s *Server) AddPullRequest(owner, name string, pr forge.PullRequest) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repos[owner+"/"+name]
+	now := s.tick()
+	pr.Number = r.nextNumber()
+	pr.State = "open"
+	pr.HTMLURL = fmt.Sprintf("%s/pull/%d", s.URL, pr.Number)
+	pr.CreatedAt, pr.UpdatedAt = now, now
+	if pr.Head.Repo == nil {
+		pr.Head.Repo = &forge.RepoRef{FullName: owner + "/" + name}
+	}
+	r.pulls = append(r.pulls, &pr)
+	return pr.Number
+}
+
+// Comments returns the comments on a pull request
+func (s *Server) Comments(owner, name string, number int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.repos[owner+"/"+name].comments[number]...)
+}
+
+// AddReview submits a review of a pull request
+func (s *Server) AddReview(owner, name string, number int, login, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repos[owner+"/"+name]
+	r.reviews[number] = append(r.reviews[number], forge.Review{User: forge.User{Login: login}, State: state, SubmittedAt: s.tick()})
+}
+
+// SetChecks sets the check runs of the head commit of a pull request
+func (s *Server) SetChecks(owner, name string, number int, checks ...forge.Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repos[owner+"/"+name]
+	if pr := r.pull(number); pr != nil {
+		r.checks[r.refs[pr.Head.Ref]] = checks
+	}
+}
+
+// SetMergeable sets whether a pull request can be merged; nil means GitHub
+// has not computed it yet
+func (s *Server) SetMergeable(owner, name string, number int, mergeable *bool, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pr := s.repos[owner+"/"+name].pull(number); pr != nil {
+		pr.Mergeable, pr.MergeableState = mergeable, state
+	}
+}
+
+// Merge merges a pull request by moving its base branch to its head
+func (s *Server) Merge(owner, name string, number int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repos[owner+"/"+name]
+	if pr := r.pull(number); pr != nil {
+		r.refs[pr.Base.Ref] = r.refs[pr.Head.Ref]
+		now := s.tick()
+		pr.State, pr.Merged, pr.MergedAt = "closed", true, &now
+	}
+}
+
+func (s *Server) tick() time.Time {
+	s.clock = s.clock.Add(time.Minute)
+	return s.clock
+}
+
+func hash(kind string, data []byte) string {
+	sum := sha1.Sum(append([]byte(kind+"\x00"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *repo) addBlob(content []byte) string {
+	sha := hash("blob", content)
+	r.blobs[sha] = content
+	return sha
+}
+
+func (r *repo) addTree(entries map[string]string) string {
+	paths := make([]string, 0, len(entries))
+	for path := range entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var b strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&b, "%s %s\n", entries[path], path)
+	}
+	sha := hash("tree", []byte(b.String()))
+	r.trees[sha] = entries
+	return sha
+}
+
+func (r *repo) addCommit(c commit) string {
+	data, _ := json.Marshal(c)
+	sha := hash("commit", data)
+	r.commits[sha] = c
+	return sha
+}
+
+func (r *repo) nextNumber() int {
+	number := 1
+	for _, pr := range r.pulls {
+		if pr.Number >= number {
+			number = pr.Number + 1
+		}
+	}
+	return number
+}
+
+// headOwner is the owner of the repository a pull request's head branch is in
+func headOwner(pr *forge.PullRequest, owner string) string {
+	if pr.Head.Repo != nil {
+		owner, _, _ = strings.Cut(pr.Head.Repo.FullName, "/")
+	}
+	return owner
+}
+
+func (r *repo) pull(number int) *forge.PullRequest {
+	for _, pr := range r.pulls {
+		if pr.Number == number {
+			return pr
+		}
+	}
+	return nil
+}
+
+// isAncestor reports whether ancestor is reachable from commit
+func (r *repo) isAncestor(ancestor, sha string) bool {
+	if ancestor == sha {
+		return true
+	}
+	for _, parent := range r.commits[sha].Parents {
+		if r.isAncestor(ancestor, parent) {
+			return true
+		}
+	}
+	return false
+}
+
+// pullJSON returns a pull request with its head commit filled in
+func (r *repo) pullJSON(pr *forge.PullRequest) forge.PullRequest {
+	result := *pr
+	if sha, ok := r.refs[pr.Head.Ref]; ok {
+		result.Head.SHA = sha
+	}
+	result.Base.SHA = r.refs[pr.Base.Ref]
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+func (s *Server) serve(w http.ResponseWriter, req *http.Request) {
+	if s.Token != "" && req.Header.Get("Authorization") != "token "+s.Token {
+		writeError(w, http.StatusUnauthorized, "Bad credentials")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.URL.Path == "/user" && req.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, forge.User{Login: s.Login})
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	if len(parts) < 4 || parts[0] != "repos" {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	r, ok := s.repos[parts[1]+"/"+parts[2]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	route := strings.Join(parts[3:], "/")
+
+	switch {
+	case strings.HasPrefix(route, "git/ref/heads/") && req.Method == http.MethodGet:
+		s.getRef(w, r, strings.TrimPrefix(route, "git/ref/heads/"))
+	case route == "git/refs" && req.Method == http.MethodPost:
+		s.createRef(w, req, r)
+	case strings.HasPrefix(route, "git/refs/heads/"):
+		s.updateRef(w, req, r, strings.TrimPrefix(route, "git/refs/heads/"))
+	case strings.HasPrefix(route, "git/commits/") && req.Method == http.MethodGet:
+		s.getCommit(w, r, strings.TrimPrefix(route, "git/commits/"))
+	case route == "git/blobs" && req.Method == http.MethodPost:
+		s.createBlob(w, req, r)
+	case route == "git/trees" && req.Method == http.MethodPost:
+		s.createTree(w, req, r)
+	case route == "git/commits" && req.Method == http.MethodPost:
+		s.createCommit(w, req, r)
+	case strings.HasPrefix(route, "contents/") && req.Method == http.MethodGet:
+		s.getContents(w, req, r, strings.TrimPrefix(route, "contents/"))
+	case route == "pulls" && req.Method == http.MethodGet:
+		s.listPulls(w, req, r, parts[1])
+	case route == "pulls" && req.Method == http.MethodPost:
+		s.createPull(w, req, r, parts[1]+"/"+parts[2])
+	case strings.HasPrefix(route, "issues/") && strings.HasSuffix(route, "/comments") && req.Method == http.MethodPost:
+		s.createComment(w, req, r, strings.TrimSuffix(strings.TrimPrefix(route, "issues/"), "/comments"))
+	case strings.HasPrefix(route, "pulls/"):
+		s.servePull(w, req, r, strings.TrimPrefix(route, "pulls/"))
+	case strings.HasPrefix(route, "commits/") && strings.HasSuffix(route, "/check-runs"):
+		sha := strings.TrimSuffix(strings.TrimPrefix(route, "commits/"), "/check-runs")
+		checks := r.checks[sha]
+		if checks == nil {
+			checks = []forge.Check{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"total_count": len(checks), "check_runs": checks})
+	case strings.HasPrefix(route, "commits/") && strings.HasSuffix(route, "/status"):
+		writeJSON(w, http.StatusOK, map[string]any{"state": "pending", "statuses": []any{}})
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (s *Server) getRef(w http.ResponseWriter, r *repo, branch string) {
+	sha, ok := r.refs[branch]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ref": "refs/heads/" + branch, "object": map[string]string{"sha": sha, "type": "commit"}})
+}
+
+func (s *Server) getContents(w http.ResponseWriter, req *http.Request, r *repo, path string) {
+	ref := req.URL.Query().Get("ref")
+	sha, ok := r.refs[ref]
+	if !ok {
+		if _, isCommit := r.commits[ref]; !isCommit {
+			writeError(w, http.StatusNotFound, "No commit found for the ref "+ref)
+			return
+		}
+		sha = ref
+	}
+	blob, ok := r.trees[r.commits[sha].Tree][path]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"type":     "file",
+		"path":     path,
+		"sha":      blob,
+		"encoding": "base64",
+		"content":  base64.StdEncoding.EncodeToString(r.blobs[blob]),
+	})
+}
+
+func (s *Server) createRef(w http.ResponseWriter, req *http.Request, r *repo) {
+	var in struct{ Ref, SHA string }
+	if json.NewDecoder(req.Body).Decode(&in) != nil || !strings.HasPrefix(in.Ref, "refs/heads/") {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid request")
+		return
+	}
+	branch := strings.TrimPrefix(in.Ref, "refs/heads/")
+	if _, exists := r.refs[branch]; exists {
+		writeError(w, http.StatusUnprocessableEntity, "Reference already exists")
+		return
+	}
+	if _, ok := r.commits[in.SHA]; !ok {
+		writeError(w, http.StatusUnprocessableEntity, "Object does not exist")
+		return
+	}
+	r.refs[branch] = in.SHA
+	writeJSON(w, http.StatusCreated, map[string]any{"ref": in.Ref, "object": map[string]string{"sha": in.SHA}})
+}
+
+func (s *Server) updateRef(w http.ResponseWriter, req *http.Request, r *repo, branch string) {
+	old, ok := r.refs[branch]
+	if !ok {
+		writeError(w, http.StatusUnprocessableEntity, "Reference does not exist")
+		return
+	}
+	switch req.Method {
+	case http.MethodDelete:
+		delete(r.refs, branch)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPatch:
+		var in struct {
+			SHA   string
+			Force bool
+		}
+		if json.NewDecoder(req.Body).Decode(&in) != nil {
+			writeError(w, http.StatusUnprocessableEntity, "Invalid request")
+			return
+		}
+		if _, ok := r.commits[in.SHA]; !ok {
+			writeError(w, http.StatusUnprocessableEntity, "Object does not exist")
+			return
+		}
+		if !in.Force && !r.isAncestor(old, in.SHA) {
+			writeError(w, http.StatusUnprocessableEntity, "Update is not a fast forward")
+			return
+		}
+		r.refs[branch] = in.SHA
+		writeJSON(w, http.StatusOK, map[string]any{"ref": "refs/heads/" + branch, "object": map[string]string{"sha": in.SHA}})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (s *Server) getCommit(w http.ResponseWriter, r *repo, sha string) {
+	c, ok := r.commits[sha]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	parents := []map[string]string{}
+	for _, parent := range c.Parents {
+		parents = append(parents, map[string]string{"sha": parent})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"sha": sha, "message": c.Message, "tree": map[string]string{"sha": c.Tree}, "parents": parents})
+}
+
+func (s *Server) createBlob(w http.ResponseWriter, req *http.Request, r *repo) {
+	var in struct{ Content, Encoding string }
+	if json.NewDecoder(req.Body).Decode(&in) != nil {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid request")
+		return
+	}
+	content := []byte(in.Content)
+	if in.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(in.Content)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "Invalid base64")
+			return
+		}
+		content = decoded
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"sha": r.addBlob(content)})
+}
+
+func (s *Server) createTree(w http.ResponseWriter, req *http.Request, r *repo) {
+	var in struct {
+		BaseTree string `json:"base_tree"`
+		Tree     []struct {
+			Path, Mode, Type string
+			SHA              *string
+			Content          *string
+		}
+	}
+	if json.NewDecoder(req.Body).Decode(&in) != nil {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid request")
+		return
+	}
+	entries := make(map[string]string)
+	if in.BaseTree != "" {
+		base, ok := r.trees[in.BaseTree]
+		if !ok {
+			writeError(w, http.StatusUnprocessableEntity, "Invalid base_tree")
+			return
+		}
+		for path, blob := range base {
+			entries[path] = blob
+		}
+	}
+	for _, entry := range in.Tree {
+		switch {
+		case entry.Content != nil:
+			entries[entry.Path] = r.addBlob([]byte(*entry.Content))
+		case entry.SHA == nil:
+			delete(entries, entry.Path)
+		default:
+			if _, ok := r.blobs[*entry.SHA]; !ok {
+				writeError(w, http.StatusUnprocessableEntity, "Invalid tree entry sha")
+				return
+			}
+			entries[entry.Path] = *entry.SHA
+		}
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"sha": r.addTree(entries)})
+}
+
+func (s *Server) createCommit(w http.ResponseWriter, req *http.Request, r *repo) {
+	var in struct {
+		Message string
+		Tree    string
+		Parents []string
+	}
+	if json.NewDecoder(req.Body).Decode(&in) != nil {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid request")
+		return
+	}
+	if _, ok := r.trees[in.Tree]; !ok {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid tree")
+		return
+	}
+	for _, parent := range in.Parents {
+		if _, ok := r.commits[parent]; !ok {
+			writeError(w, http.StatusUnprocessableEntity, "Invalid parent")
+			return
+		}
+	}
+	// Distinct commits of the same tree need distinct SHAs
+	c := commit{Message: in.Message + "\x00" + s.tick().String(), Tree: in.Tree, Parents: in.Parents}
+	sha := r.addCommit(c)
+	c.Message = in.Message
+	r.commits[sha] = c
+	writeJSON(w, http.StatusCreated, map[string]any{"sha": sha, "tree": map[string]string{"sha": in.Tree}})
+}
+
+func (s *Server) listPulls(w http.ResponseWriter, req *http.Request, r *repo, owner string) {
+	query := req.URL.Query()
+	state := query.Get("state")
+	if state == "" {
+		state = "open"
+	}
+	prs := []forge.PullRequest{}
+	for i := len(r.pulls) - 1; i >= 0; i-- {
+		pr := r.pulls[i]
+		if state != "all" && pr.State != state {
+			continue
+		}
+		if head := query.Get("head"); head != "" && head != headOwner(pr, owner)+":"+pr.Head.Ref {
+			continue
+		}
+		if base := query.Get("base"); base != "" && base != pr.Base.Ref {
+			continue
+		}
+		prs = append(prs, r.pullJSON(pr))
+	}
+	writeJSON(w, http.StatusOK, forgetest.Page(prs, query, "per_page", 30))
+}
+
+func (s *Server) createPull(w http.ResponseWriter, req *http.Request, r *repo, fullName string) {
+	var in forge.NewPullRequest
+	if json.NewDecoder(req.Body).Decode(&in) != nil || in.Title == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+		return
+	}
+	if _, ok := r.refs[in.Head]; !ok {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: head does not exist")
+		return
+	}
+	if _, ok := r.refs[in.Base]; !ok {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: base does not exist")
+		return
+	}
+	for _, pr := range r.pulls {
+		if pr.State == "open" && pr.Head.Ref == in.Head && pr.Base.Ref == in.Base {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Validation Failed: A pull request already exists for %s", in.Head))
+			return
+		}
+	}
+
+	number := r.nextNumber()
+	now := s.tick()
+	pr := &forge.PullRequest{
+		Number:    number,
+		Title:     in.Title,
+		Body:      in.Body,
+		State:     "open",
+		Draft:     in.Draft,
+		HTMLURL:   fmt.Sprintf("%s/pull/%d", s.URL, number),
+		User:      forge.User{Login: s.Login},
+		Head:      forge.PRBranch{Ref: in.Head, Repo: &forge.RepoRef{FullName: fullName}},
+		Base:      forge.PRBranch{Ref: in.Base},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.pulls = append(r.pulls, pr)
+	writeJSON(w, http.StatusCreated, r.pullJSON(pr))
+}
+
+func (s *Server) createComment(w http.ResponseWriter, req *http.Request, r *repo, number string) {
+	n, err := strconv.Atoi(number)
+	if err != nil || r.pull(n) == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	var in struct{ Body string }
+	if json.NewDecoder(req.Body).Decode(&in) != nil || in.Body == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+		return
+	}
+	r.comments[n] = append(r.comments[n], in.Body)
+	writeJSON(w, http.StatusCreated, map[string]any{"body": in.Body})
+}
+
+func (s *Server) servePull(w http.ResponseWriter, req *http.Request, r *repo, route string) {
+	number, rest, _ := strings.Cut(route, "/")
+	n, err := strconv.Atoi(number)
+	pr := r.pull(n)
+	if err != nil || pr == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	switch {
+	case rest == "reviews" && req.Method == http.MethodGet:
+		reviews := r.reviews[n]
+		if reviews == nil {
+			reviews = []forge.Review{}
+		}
+		writeJSON(w, http.StatusOK, reviews)
+	case rest == "" && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, r.pullJSON(pr))
+	case rest == "" && req.Method == http.MethodPatch:
+		var in forge.PullRequestEdit
+		if json.NewDecoder(req.Body).Decode(&in) != nil {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+			return
+		}
+		if in.Title != "" {
+			pr.Title = in.Title
+		}
+		if in.Body != "" {
+			pr.Body = in.Body
+		}
+		if in.State != "" && !pr.Merged {
+			pr.State = in.State
+		}
+		pr.UpdatedAt = s.tick()
+		writeJSON(w, http.StatusOK, r.pullJSON(pr))
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
@@ -0,0 +1,127 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+)
+
+// CreatePullRequest opens a pull request
+func (c *Client) CreatePullRequest(ctx context.Context, repo forge.Repo, pr forge.NewPullRequest) (*forge.PullRequest, error) {
+	var created forge.PullRequest
+	if err := c.do(ctx, http.MethodPost, repoPath(repo, "/pulls"), pr, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// EditPullRequest updates the title, body or state of a pull request
+func (c *Client) EditPullRequest(ctx context.Context, repo forge.Repo, number int, edit forge.PullRequestEdit) (*forge.PullRequest, error) {
+	var updated forge.PullRequest
+	if err := c.do(ctx, http.MethodPatch, repoPath(repo, fmt.Sprintf("/pulls/%d", number)), edit, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// GetPullRequest returns a pull request, including its mergeability
+func (c *Client) GetPullRequest(ctx context.Context, repo forge.Repo, number int) (*forge.PullRequest, error) {
+	var pr forge.PullRequest
+	if err := c.do(ctx, http.MethodGet, repoPath(repo, fmt.Sprintf("/pulls/%d", number)), nil, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// pullsPerPage is the page size of pull request lists, GitHub's maximum
+const pullsPerPage = 100
+
+// PullRequests lists pull requests, most recently updated first, reading
+// every page
+func (c *Client) PullRequests(ctx context.Context, repo forge.Repo, opts forge.ListOptions) ([]forge.PullRequest, error) {
+	query := url.Values{"per_page": {strconv.Itoa(pullsPerPage)}, "sort": {"updated"}, "direction": {"desc"}}
+	if opts.State != "" {
+		query.Set("state", opts.State)
+	}
+	if opts.Head != "" {
+		query.Set("head", repo.Owner+":"+opts.Head)
+	}
+	if opts.Base != "" {
+		query.Set("base", opts.Base)
+	}
+	return forge.ListPages(pullsPerPage, func(page int) ([]forge.PullRequest, error) {
+		query.Set("page", strconv.Itoa(page))
+		var prs []forge.PullRequest
+		err := c.do(ctx, http.MethodGet, repoPath(repo, "/pulls?", query.Encode()), nil, &prs)
+		return prs, err
+	})
+}
+
+// Reviews lists the reviews of a pull request, oldest first
+func (c *Client) Reviews(ctx context.Context, repo forge.Repo, number int) ([]forge.Review, error) {
+	var reviews []forge.Review
+	if err := c.do(ctx, http.MethodGet, repoPath(repo, fmt.Sprintf("/pulls/%d/reviews?per_page=100", number)), nil, &reviews); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// Checks lists the check runs and legacy commit statuses of a commit
+func (c *Client) Checks(ctx context.Context, repo forge.Repo, commit string) ([]forge.Check, error) {
+	var runs struct {
+		CheckRuns []forge.Check `json:"check_runs"`
+	}
+	if err := c.do(ctx, http.MethodGet, repoPath(repo, "/commits/", commit, "/check-runs?per_page=100"), nil, &runs); err != nil {
+		return nil, err
+	}
+	checks := runs.CheckRuns
+
+	var combined struct {
+		Statuses []struct {
+			Context   string `json:"context"`
+			State     string `json:"state"` // error, failure, pending or success
+			TargetURL string `json:"target_url"`
+		} `json:"statuses"`
+	}
+	if err := c.do(ctx, http.MethodGet, repoPath(repo, "/commits/", commit, "/status"), nil, &combined); err != nil {
+		return nil, err
+	}
+	for _, status := range combined.Statuses {
+		check := forge.Check{Name: status.Context, Status: "completed", Conclusion: status.State, URL: status.TargetURL}
+		switch status.State {
+		case "pending":
+			check.Status, check.Conclusion = "in_progress", ""
+		case "error":
+			check.Conclusion = "failure"
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// Status gathers the reviews, checks and mergeability of a pull request
+func (c *Client) Status(ctx context.Context, repo forge.Repo, number int) (*forge.PullRequestStatus, error) {
+	pr, err := c.GetPullRequest(ctx, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	reviews, err := c.Reviews(ctx, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := c.Checks(ctx, repo, pr.Head.SHA)
+	if err != nil {
+		return nil, err
+	}
+	return forge.NewStatus(*pr, reviews, checks), nil
+}
+
+// Comment adds a comment to a pull request
+func (c *Client) Comment(ctx context.Context, repo forge.Repo, number int, body string) error {
+	in := map[string]string{"body": body}
+	return c.do(ctx, http.MethodPost, repoPath(repo, fmt.Sprintf("/issues/%d/comments", number)), in, nil)
+}
@@ -0,0 +1,163 @@
+// Package gitlab is a small client for the GitLab REST API (v4) calls klcm
+// needs to open merge requests without a local clone. Client implements
+// forge.Forge: merge requests are forge.PullRequests numbered by their iid,
+// approvals are APPROVED reviews and the latest pipeline is the one check.
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+)
+
+// DefaultBaseURL is the API root of gitlab.com
+const DefaultBaseURL = "https://gitlab.com/api/v4"
+
+// Client calls the GitLab REST API
+type Client struct {
+	BaseURL string // API root, e.g. DefaultBaseURL or https://gitlab.example.com/api/v4
+	Token   string // personal, project or group access token
+	HTTP    *http.Client
+}
+
+var _ forge.Forge = (*Client)(nil)
+
+// NewClient creates a client for the API at baseURL ("" for gitlab.com)
+func NewClient(baseURL, token string, httpClient *http.Client) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token, HTTP: httpClient}
+}
+
+// Name is the display name of the forge
+func (c *Client) Name() string {
+	return "GitLab"
+}
+
+// projectPath returns the API path of a project, addressed by its URL-encoded
+// full path, followed by parts
+func projectPath(repo forge.Repo, parts ...string) string {
+	return "/projects/" + url.PathEscape(repo.String()) + strings.Join(parts, "")
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	if c.Token != "" {
+		header.Set("PRIVATE-TOKEN", c.Token)
+	}
+	api := forge.API{Forge: c.Name(), BaseURL: c.BaseURL, Header: header, HTTP: c.HTTP}
+	return api.Do(ctx, method, path, in, out)
+}
+
+// AuthenticatedUser returns the account the token belongs to
+func (c *Client) AuthenticatedUser(ctx context.Context) (*forge.User, error) {
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/user", nil, &user); err != nil {
+		return nil, err
+	}
+	return &forge.User{Login: user.Username}, nil
+}
+
+// FileContent returns the content of a file at a branch, tag or commit
+func (c *Client) FileContent(ctx context.Context, repo forge.Repo, path, ref string) ([]byte, error) {
+	var file struct {
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+	}
+	filePath := projectPath(repo, "/repository/files/", url.PathEscape(path), "?ref=", url.QueryEscape(ref))
+	if err := c.do(ctx, http.MethodGet, filePath, nil, &file); err != nil {
+		return nil, err
+	}
+	if file.Encoding != "base64" {
+		return nil, fmt.Errorf("GitLab API: %s at %s has unsupported encoding %q", path, ref, file.Encoding)
+	}
+	return base64.StdEncoding.DecodeString(file.Content)
+}
+
+// BranchHead returns the commit a branch points to
+func (c *Client) BranchHead(ctx context.Context, repo forge.Repo, branch string) (string, error) {
+	var b struct {
+		Commit struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	if err := c.do(ctx, http.MethodGet, projectPath(repo, "/repository/branches/", url.PathEscape(branch)), nil, &b); err != nil {
+		return "", err
+	}
+	return b.Commit.ID, nil
+}
+
+// PushFile commits one file through the commits API, which creates the
+// branch from Base in the same call when it does not exist yet. It returns
+// forge.ErrNoChanges if the file is already up to date.
+func (c *Client) PushFile(ctx context.Context, repo forge.Repo, change forge.FileChange) (*forge.Push, error) {
+	push := &forge.Push{Branch: change.Branch}
+	parent, err := c.BranchHead(ctx, repo, change.Branch)
+	switch {
+	case forge.IsNotFound(err):
+		push.Created = true
+		if parent, err = c.BranchHead(ctx, repo, change.Base); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+	push.Parent = parent
+
+	action := "update"
+	current, err := c.FileContent(ctx, repo, change.Path, parent)
+	switch {
+	case forge.IsNotFound(err):
+		action = "create"
+	case err != nil:
+		return nil, err
+	case bytes.Equal(current, change.Content):
+		return nil, forge.ErrNoChanges
+	}
+
+	in := map[string]any{
+		"branch":         change.Branch,
+		"commit_message": change.Message,
+		"actions": []map[string]string{{
+			"action":    action,
+			"file_path": change.Path,
+			"content":   base64.StdEncoding.EncodeToString(change.Content),
+			"encoding":  "base64",
+		}},
+	}
+	if push.Created {
+		in["start_branch"] = change.Base
+	}
+	var commit struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, projectPath(repo, "/repository/commits"), in, &commit); err != nil {
+		return nil, err
+	}
+	push.Commit = commit.ID
+	return push, nil
+}
+
+// DeleteBranch deletes a branch; a branch that is already gone is not an
+// error
+func (c *Client) DeleteBranch(ctx context.Context, repo forge.Repo, branch string) error {
+	err := c.do(ctx, http.MethodDelete, projectPath(repo, "/repository/branches/", url.PathEscape(branch)), nil, nil)
+	if forge.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
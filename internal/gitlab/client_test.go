@@ -0,0 +1,107 @@
+package gitlab_test
+
+import (
+	"context"
+	"testing"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge/forgetest"
+	"masters3d.com/keyboard_layout_config_mapper/internal/gitlab"
+	"masters3d.com/keyboard_layout_config_mapper/internal/gitlab/gitlabtest"
+)
+
+// upstream sits in a nested namespace, which GitLab allows
+var upstream = forge.Repo{Owner: "moergo-sc/keyboards", Name: "glove80-zmk-config"}
+
+func newFake(t *testing.T) forgetest.Fake {
+	server := gitlabtest.NewServer()
+	server.Token = "test-token"
+	t.Cleanup(server.Close)
+	return forgetest.Fake{
+		Server: server,
+		Client: server.Client(),
+		Reject: gitlab.NewClient(server.APIURL(), "wrong-token", server.Server.Client()),
+		Login:  server.Login,
+	}
+}
+
+func TestConformance(t *testing.T) {
+	forgetest.RunConformance(t, upstream, newFake)
+}
+
+// newServer starts a fake holding upstream with a keymap on main
+func newServer(t *testing.T) (*gitlabtest.Server, *gitlab.Client) {
+	t.Helper()
+	server := gitlabtest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddRepo(upstream.Owner, upstream.Name, "main", map[string]string{"config/glove80.keymap": "base\n"})
+	return server, server.Client()
+}
+
+// openMergeRequest pushes klcm-sync and opens a merge request from it
+func openMergeRequest(t *testing.T, client *gitlab.Client) *forge.PullRequest {
+	t.Helper()
+	ctx := context.Background()
+	change := forge.FileChange{Base: "main", Branch: "klcm-sync", Path: "config/glove80.keymap", Content: []byte("first\n"), Message: "Update"}
+	if _, err := client.PushFile(ctx, upstream, change); err != nil {
+		t.Fatal(err)
+	}
+	pr, err := client.CreatePullRequest(ctx, upstream, forge.NewPullRequest{Title: "t", Head: "klcm-sync", Base: "main", Draft: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pr
+}
+
+func TestCreatePullRequestDraftTitle(t *testing.T) {
+	_, client := newServer(t)
+	if pr := openMergeRequest(t, client); pr.Title != "Draft: t" {
+		t.Errorf("draft title = %q, want the Draft: prefix GitLab uses", pr.Title)
+	}
+}
+
+// Only approvals reach GitLab, and only the latest pipeline counts
+func TestStatusApprovalsAndLatestPipeline(t *testing.T) {
+	server, client := newServer(t)
+	pr := openMergeRequest(t, client)
+
+	server.AddReview(upstream.Owner, upstream.Name, pr.Number, "reviewer", "CHANGES_REQUESTED")
+	server.SetChecks(upstream.Owner, upstream.Name, pr.Number,
+		forge.Check{Name: "build", Status: "completed", Conclusion: "failure"},
+		forge.Check{Name: "build", Status: "completed", Conclusion: "success"})
+
+	status, err := client.Status(context.Background(), upstream, pr.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.ReviewDecision != forge.ReviewRequired {
+		t.Errorf("review decision with a change request = %q, want %q", status.ReviewDecision, forge.ReviewRequired)
+	}
+	if status.ChecksState != forge.ChecksFailing || len(status.Checks) != 1 {
+		t.Errorf("checks = %q %+v, want the failed latest pipeline only", status.ChecksState, status.Checks)
+	}
+}
+
+// A merge request from a fork has a different source project
+func TestPullRequestsFromFork(t *testing.T) {
+	server, client := newServer(t)
+	own := openMergeRequest(t, client)
+	fork := server.AddPullRequest(upstream.Owner, upstream.Name, forge.PullRequest{
+		Title: "From a fork",
+		User:  forge.User{Login: "someone-else"},
+		Head:  forge.PRBranch{Ref: "klcm-sync", Repo: &forge.RepoRef{FullName: "someone-else/" + upstream.Name}},
+		Base:  forge.PRBranch{Ref: "main"},
+	})
+
+	prs, err := client.PullRequests(context.Background(), upstream, forge.ListOptions{Head: "klcm-sync"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromUpstream := map[int]bool{}
+	for _, pr := range prs {
+		fromUpstream[pr.Number] = pr.FromRepo(upstream)
+	}
+	if len(prs) != 2 || !fromUpstream[own.Number] || fromUpstream[fork] {
+		t.Errorf("FromRepo by MR = %v, want !%d from %s and !%d not", fromUpstream, own.Number, upstream, fork)
+	}
+}
@@ -0,0 +1,417 @@
+// Package gitlabtest is an in-memory fake of the GitLab REST API calls made
+// by package gitlab, served with httptest under /api/v4, for end-to-end tests
+// of klcm's merge request automation without network access.
+package gitlabtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge/forgetest"
+	"masters3d.com/keyboard_layout_config_mapper/internal/gitlab"
+)
+
+// Server is a fake GitLab instance. Create projects with AddRepo (the owner
+// may be a nested namespace), point a gitlab.Client at APIURL, then inspect
+// the projects or script approvals, pipelines and merges through the
+// embedded Store. Reviews other than APPROVED are ignored, as GitLab
+// reports only approvals.
+type Server struct {
+	*httptest.Server
+	*forgetest.Store
+
+	// Token, if set, is the only token accepted; other requests get 401
+	Token string
+	// Login is the user the token belongs to
+	Login string
+}
+
+// NewServer starts a fake GitLab; Close it when done
+func NewServer() *Server {
+	s := &Server{Store: forgetest.NewStore(), Login: "klcm-test"}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	s.Store.PullURL = func(owner, name string, number int) string {
+		return fmt.Sprintf("%s/%s/%s/-/merge_requests/%d", s.URL, owner, name, number)
+	}
+	return s
+}
+
+// APIURL is the API root to configure clients with
+func (s *Server) APIURL() string {
+	return s.URL + "/api/v4"
+}
+
+// Client returns a gitlab.Client for the fake, sending Token
+func (s *Server) Client() *gitlab.Client {
+	return gitlab.NewClient(s.APIURL(), s.Token, s.Server.Client())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+// writeStoreError answers with the status matching a Store error
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, forgetest.ErrNotFound):
+		writeError(w, http.StatusNotFound, "404 "+err.Error())
+	case errors.Is(err, forgetest.ErrConflict):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, req *http.Request) {
+	token := req.Header.Get("PRIVATE-TOKEN")
+	if bearer, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	if s.Token != "" && token != s.Token {
+		writeError(w, http.StatusUnauthorized, "401 Unauthorized")
+		return
+	}
+
+	path, ok := strings.CutPrefix(req.URL.EscapedPath(), "/api/v4/")
+	if !ok {
+		writeError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+	if path == "user" && req.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, map[string]any{"id": 1, "username": s.Login})
+		return
+	}
+
+	// projects/:id/... with the project's full path URL-encoded as its id
+	parts := strings.Split(path, "/")
+	if len(parts) < 3 || parts[0] != "projects" {
+		writeError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+	project, err := url.PathUnescape(parts[1])
+	slash := strings.LastIndex(project, "/")
+	if err != nil || slash < 0 || !s.HasRepo(project[:slash], project[slash+1:]) {
+		writeError(w, http.StatusNotFound, "404 Project Not Found")
+		return
+	}
+	p := projectRef{owner: project[:slash], name: project[slash+1:]}
+	rest := parts[2:]
+	for i := range rest {
+		if rest[i], err = url.PathUnescape(rest[i]); err != nil {
+			writeError(w, http.StatusBadRequest, "400 Bad Request")
+			return
+		}
+	}
+	route := strings.Join(rest, "/")
+
+	switch {
+	case strings.HasPrefix(route, "repository/files/") && req.Method == http.MethodGet:
+		s.getFile(w, req, p, strings.TrimPrefix(route, "repository/files/"))
+	case strings.HasPrefix(route, "repository/branches/"):
+		s.serveBranch(w, req, p, strings.TrimPrefix(route, "repository/branches/"))
+	case route == "repository/commits" && req.Method == http.MethodPost:
+		s.createCommit(w, req, p)
+	case route == "merge_requests" && req.Method == http.MethodGet:
+		s.listMergeRequests(w, req, p)
+	case route == "merge_requests" && req.Method == http.MethodPost:
+		s.createMergeRequest(w, req, p)
+	case strings.HasPrefix(route, "merge_requests/"):
+		s.serveMergeRequest(w, req, p, strings.TrimPrefix(route, "merge_requests/"))
+	default:
+		writeError(w, http.StatusNotFound, "404 Not Found")
+	}
+}
+
+type projectRef struct {
+	owner, name string
+}
+
+// projectID is the numeric id GitLab would give the project at a full path
+func projectID(path string) int {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	return int(h.Sum32() >> 1)
+}
+
+func (s *Server) getFile(w http.ResponseWriter, req *http.Request, p projectRef, path string) {
+	ref := req.URL.Query().Get("ref")
+	content, ok := s.File(p.owner, p.name, ref, path)
+	if !ok {
+		writeError(w, http.StatusNotFound, "404 File Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"file_path": path,
+		"ref":       ref,
+		"encoding":  "base64",
+		"content":   base64.StdEncoding.EncodeToString([]byte(content)),
+	})
+}
+
+func (s *Server) serveBranch(w http.ResponseWriter, req *http.Request, p projectRef, branch string) {
+	switch req.Method {
+	case http.MethodGet:
+		head, ok := s.BranchHead(p.owner, p.name, branch)
+		if !ok {
+			writeError(w, http.StatusNotFound, "404 Branch Not Found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"name": branch, "commit": map[string]string{"id": head}})
+	case http.MethodDelete:
+		if !s.DeleteBranch(p.owner, p.name, branch) {
+			writeError(w, http.StatusNotFound, "404 Branch Not Found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "405 Method Not Allowed")
+	}
+}
+
+func (s *Server) createCommit(w http.ResponseWriter, req *http.Request, p projectRef) {
+	var in struct {
+		Branch        string `json:"branch"`
+		StartBranch   string `json:"start_branch"`
+		CommitMessage string `json:"commit_message"`
+		Actions       []struct {
+			Action   string `json:"action"`
+			FilePath string `json:"file_path"`
+			Content  string `json:"content"`
+			Encoding string `json:"encoding"`
+		} `json:"actions"`
+	}
+	if json.NewDecoder(req.Body).Decode(&in) != nil || in.Branch == "" || in.CommitMessage == "" || len(in.Actions) != 1 {
+		writeError(w, http.StatusBadRequest, "400 Bad Request")
+		return
+	}
+	action := in.Actions[0]
+	content := []byte(action.Content)
+	if action.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(action.Content)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "400 Invalid base64")
+			return
+		}
+		content = decoded
+	}
+
+	ref := in.Branch
+	if in.StartBranch != "" {
+		ref = in.StartBranch
+	}
+	_, exists := s.File(p.owner, p.name, ref, action.FilePath)
+	switch {
+	case action.Action == "create" && exists:
+		writeError(w, http.StatusBadRequest, "A file with this name already exists")
+		return
+	case action.Action == "update" && !exists:
+		writeError(w, http.StatusBadRequest, "A file with this name doesn't exist")
+		return
+	case action.Action != "create" && action.Action != "update":
+		writeError(w, http.StatusBadRequest, "400 Unsupported action "+action.Action)
+		return
+	}
+
+	sha, err := s.CommitFile(p.owner, p.name, in.Branch, in.StartBranch, action.FilePath, string(content))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"id": sha, "message": in.CommitMessage})
+}
+
+// mergeRequestJSON renders a pull request of the store as a merge request of
+// project p
+func mergeRequestJSON(p projectRef, pr forge.PullRequest) map[string]any {
+	state := "opened"
+	switch {
+	case pr.Merged:
+		state = "merged"
+	case pr.State == "closed":
+		state = "closed"
+	}
+	source := 0 // a deleted fork
+	if pr.Head.Repo != nil {
+		source = projectID(pr.Head.Repo.FullName)
+	}
+	status := "checking"
+	switch {
+	case pr.Mergeable == nil:
+	case !*pr.Mergeable:
+		status = "conflict"
+	case pr.MergeableState == "blocked":
+		status = "not_approved"
+	default:
+		status = "mergeable"
+	}
+	return map[string]any{
+		"iid":                   pr.Number,
+		"title":                 pr.Title,
+		"description":           pr.Body,
+		"state":                 state,
+		"draft":                 strings.HasPrefix(pr.Title, "Draft: "),
+		"web_url":               pr.HTMLURL,
+		"source_branch":         pr.Head.Ref,
+		"target_branch":         pr.Base.Ref,
+		"sha":                   pr.Head.SHA,
+		"source_project_id":     source,
+		"target_project_id":     projectID(p.owner + "/" + p.name),
+		"author":                map[string]string{"username": pr.User.Login},
+		"has_conflicts":         pr.Mergeable != nil && !*pr.Mergeable,
+		"detailed_merge_status": status,
+		"created_at":            pr.CreatedAt,
+		"updated_at":            pr.UpdatedAt,
+		"merged_at":             pr.MergedAt,
+	}
+}
+
+func (s *Server) listMergeRequests(w http.ResponseWriter, req *http.Request, p projectRef) {
+	query := req.URL.Query()
+	state := query.Get("state")
+	if state == "" {
+		state = "all"
+	}
+	mrs := []map[string]any{}
+	for _, pr := range s.PullRequests(p.owner, p.name) {
+		mr := mergeRequestJSON(p, pr)
+		if state != "all" && mr["state"] != state {
+			continue
+		}
+		if source := query.Get("source_branch"); source != "" && source != pr.Head.Ref {
+			continue
+		}
+		if target := query.Get("target_branch"); target != "" && target != pr.Base.Ref {
+			continue
+		}
+		mrs = append(mrs, mr)
+	}
+	writeJSON(w, http.StatusOK, forgetest.Page(mrs, query, "per_page", 20))
+}
+
+func (s *Server) createMergeRequest(w http.ResponseWriter, req *http.Request, p projectRef) {
+	var in struct {
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+		Title        string `json:"title"`
+		Description  string `json:"description"`
+	}
+	if json.NewDecoder(req.Body).Decode(&in) != nil || in.Title == "" {
+		writeError(w, http.StatusBadRequest, "400 Bad Request")
+		return
+	}
+	pr, err := s.CreatePull(p.owner, p.name, forge.NewPullRequest{
+		Title: in.Title,
+		Body:  in.Description,
+		Head:  in.SourceBranch,
+		Base:  in.TargetBranch,
+		Draft: strings.HasPrefix(in.Title, "Draft: "),
+	}, s.Login)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, mergeRequestJSON(p, pr))
+}
+
+func (s *Server) serveMergeRequest(w http.ResponseWriter, req *http.Request, p projectRef, route string) {
+	iid, rest, _ := strings.Cut(route, "/")
+	n, err := strconv.Atoi(iid)
+	pr, ok := s.Pull(p.owner, p.name, n)
+	if err != nil || !ok {
+		writeError(w, http.StatusNotFound, "404 Not found")
+		return
+	}
+
+	switch {
+	case rest == "" && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, mergeRequestJSON(p, pr))
+	case rest == "" && req.Method == http.MethodPut:
+		var in struct {
+			Title       string `json:"title"`
+			Description string `json:"description"`
+			StateEvent  string `json:"state_event"`
+		}
+		if json.NewDecoder(req.Body).Decode(&in) != nil {
+			writeError(w, http.StatusBadRequest, "400 Bad Request")
+			return
+		}
+		edit := forge.PullRequestEdit{Title: in.Title, Body: in.Description}
+		switch in.StateEvent {
+		case "close":
+			edit.State = "closed"
+		case "reopen":
+			edit.State = "open"
+		}
+		pr, _ = s.EditPull(p.owner, p.name, n, edit)
+		writeJSON(w, http.StatusOK, mergeRequestJSON(p, pr))
+	case rest == "approvals" && req.Method == http.MethodGet:
+		approvedBy := []map[string]any{}
+		for _, review := range forge.NewStatus(pr, s.Reviews(p.owner, p.name, n), nil).Reviews {
+			if review.State == "APPROVED" {
+				approvedBy = append(approvedBy, map[string]any{"user": map[string]string{"username": review.User.Login}})
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"approved": len(approvedBy) > 0, "approved_by": approvedBy})
+	case rest == "pipelines" && req.Method == http.MethodGet:
+		// Each check of the head commit is a pipeline, the first the latest
+		checks := s.Checks(p.owner, p.name, pr.Head.SHA)
+		pipelines := []map[string]any{}
+		for i, check := range checks {
+			pipelines = append(pipelines, map[string]any{
+				"id":      len(checks) - i,
+				"sha":     pr.Head.SHA,
+				"status":  pipelineStatus(check),
+				"web_url": check.URL,
+			})
+		}
+		writeJSON(w, http.StatusOK, pipelines)
+	case rest == "notes" && req.Method == http.MethodPost:
+		var in struct {
+			Body string `json:"body"`
+		}
+		if json.NewDecoder(req.Body).Decode(&in) != nil || in.Body == "" {
+			writeError(w, http.StatusBadRequest, "400 Bad Request")
+			return
+		}
+		s.AddComment(p.owner, p.name, n, in.Body)
+		writeJSON(w, http.StatusCreated, map[string]any{"body": in.Body})
+	default:
+		writeError(w, http.StatusNotFound, "404 Not Found")
+	}
+}
+
+// pipelineStatus is the GitLab pipeline status for a check
+func pipelineStatus(check forge.Check) string {
+	switch check.Status {
+	case "queued":
+		return "pending"
+	case "in_progress":
+		return "running"
+	}
+	switch check.Conclusion {
+	case "success", "neutral":
+		return "success"
+	case "cancelled":
+		return "canceled"
+	case "skipped":
+		return "skipped"
+	case "action_required":
+		return "manual"
+	}
+	return "failed"
+}
@@ -0,0 +1,254 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/forge"
+)
+
+// mergeRequest is a GitLab merge request as the API returns it
+type mergeRequest struct {
+	IID             int    `json:"iid"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	State           string `json:"state"` // opened, closed, locked or merged
+	Draft           bool   `json:"draft"`
+	WebURL          string `json:"web_url"`
+	SourceBranch    string `json:"source_branch"`
+	TargetBranch    string `json:"target_branch"`
+	SHA             string `json:"sha"` // head of the source branch
+	SourceProjectID int    `json:"source_project_id"`
+	TargetProjectID int    `json:"target_project_id"`
+	Author          struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	HasConflicts        bool       `json:"has_conflicts"`
+	DetailedMergeStatus string     `json:"detailed_merge_status"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	MergedAt            *time.Time `json:"merged_at"`
+}
+
+// pullRequest converts a merge request of repo to the forge's pull request.
+// The source branch is only named as in repo when it is not in a fork, as
+// merge requests identify the source project by id alone.
+func (mr mergeRequest) pullRequest(repo forge.Repo) forge.PullRequest {
+	pr := forge.PullRequest{
+		Number:    mr.IID,
+		Title:     mr.Title,
+		Body:      mr.Description,
+		State:     "open",
+		Draft:     mr.Draft,
+		HTMLURL:   mr.WebURL,
+		User:      forge.User{Login: mr.Author.Username},
+		Head:      forge.PRBranch{Ref: mr.SourceBranch, SHA: mr.SHA},
+		Base:      forge.PRBranch{Ref: mr.TargetBranch},
+		CreatedAt: mr.CreatedAt,
+		UpdatedAt: mr.UpdatedAt,
+		MergedAt:  mr.MergedAt,
+	}
+	if mr.SourceProjectID == mr.TargetProjectID {
+		pr.Head.Repo = &forge.RepoRef{FullName: repo.String()}
+	}
+	switch mr.State {
+	case "merged":
+		pr.State, pr.Merged = "closed", true
+	case "closed", "locked":
+		pr.State = "closed"
+	}
+
+	mergeable := true
+	switch {
+	case mr.HasConflicts:
+		mergeable = false
+		pr.Mergeable, pr.MergeableState = &mergeable, "dirty"
+	case mr.DetailedMergeStatus == "mergeable":
+		pr.Mergeable, pr.MergeableState = &mergeable, "clean"
+	case mr.DetailedMergeStatus == "", mr.DetailedMergeStatus == "checking",
+		mr.DetailedMergeStatus == "unchecked", mr.DetailedMergeStatus == "preparing":
+		// not computed yet
+	default:
+		// not_approved, ci_must_pass, discussions_not_resolved, ...
+		pr.Mergeable, pr.MergeableState = &mergeable, "blocked"
+	}
+	return pr
+}
+
+func mergeRequestPath(repo forge.Repo, number int, parts ...string) string {
+	return projectPath(repo, append([]string{fmt.Sprintf("/merge_requests/%d", number)}, parts...)...)
+}
+
+// CreatePullRequest opens a merge request
+func (c *Client) CreatePullRequest(ctx context.Context, repo forge.Repo, pr forge.NewPullRequest) (*forge.PullRequest, error) {
+	title := pr.Title
+	if pr.Draft {
+		title = "Draft: " + title
+	}
+	in := map[string]string{
+		"source_branch": pr.Head,
+		"target_branch": pr.Base,
+		"title":         title,
+		"description":   pr.Body,
+	}
+	var created mergeRequest
+	if err := c.do(ctx, http.MethodPost, projectPath(repo, "/merge_requests"), in, &created); err != nil {
+		return nil, err
+	}
+	result := created.pullRequest(repo)
+	return &result, nil
+}
+
+// EditPullRequest updates the title, description or state of a merge request
+func (c *Client) EditPullRequest(ctx context.Context, repo forge.Repo, number int, edit forge.PullRequestEdit) (*forge.PullRequest, error) {
+	in := make(map[string]string)
+	if edit.Title != "" {
+		in["title"] = edit.Title
+	}
+	if edit.Body != "" {
+		in["description"] = edit.Body
+	}
+	switch edit.State {
+	case "closed":
+		in["state_event"] = "close"
+	case "open":
+		in["state_event"] = "reopen"
+	}
+	var updated mergeRequest
+	if err := c.do(ctx, http.MethodPut, mergeRequestPath(repo, number), in, &updated); err != nil {
+		return nil, err
+	}
+	result := updated.pullRequest(repo)
+	return &result, nil
+}
+
+// GetPullRequest returns a merge request, including its mergeability
+func (c *Client) GetPullRequest(ctx context.Context, repo forge.Repo, number int) (*forge.PullRequest, error) {
+	var mr mergeRequest
+	if err := c.do(ctx, http.MethodGet, mergeRequestPath(repo, number), nil, &mr); err != nil {
+		return nil, err
+	}
+	result := mr.pullRequest(repo)
+	return &result, nil
+}
+
+// mergeRequestsPerPage is the page size of merge request lists, GitLab's
+// maximum
+const mergeRequestsPerPage = 100
+
+// PullRequests lists merge requests, most recently updated first, reading
+// every page. GitLab's "closed" excludes merged requests, so closed ones are
+// filtered from all of them.
+func (c *Client) PullRequests(ctx context.Context, repo forge.Repo, opts forge.ListOptions) ([]forge.PullRequest, error) {
+	query := url.Values{"per_page": {strconv.Itoa(mergeRequestsPerPage)}, "order_by": {"updated_at"}, "sort": {"desc"}, "state": {"opened"}}
+	if opts.State == "closed" || opts.State == "all" {
+		query.Set("state", "all")
+	}
+	if opts.Head != "" {
+		query.Set("source_branch", opts.Head)
+	}
+	if opts.Base != "" {
+		query.Set("target_branch", opts.Base)
+	}
+	mrs, err := forge.ListPages(mergeRequestsPerPage, func(page int) ([]mergeRequest, error) {
+		query.Set("page", strconv.Itoa(page))
+		var mrs []mergeRequest
+		err := c.do(ctx, http.MethodGet, projectPath(repo, "/merge_requests?", query.Encode()), nil, &mrs)
+		return mrs, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var prs []forge.PullRequest
+	for _, mr := range mrs {
+		pr := mr.pullRequest(repo)
+		if opts.State == "closed" && pr.State != "closed" {
+			continue
+		}
+		prs = append(prs, pr)
+	}
+	return prs, nil
+}
+
+// approvals returns an APPROVED review for each user who approved a merge
+// request
+func (c *Client) approvals(ctx context.Context, repo forge.Repo, number int) ([]forge.Review, error) {
+	var approvals struct {
+		ApprovedBy []struct {
+			User struct {
+				Username string `json:"username"`
+			} `json:"user"`
+		} `json:"approved_by"`
+	}
+	if err := c.do(ctx, http.MethodGet, mergeRequestPath(repo, number, "/approvals"), nil, &approvals); err != nil {
+		return nil, err
+	}
+	var reviews []forge.Review
+	for _, approval := range approvals.ApprovedBy {
+		reviews = append(reviews, forge.Review{User: forge.User{Login: approval.User.Username}, State: "APPROVED"})
+	}
+	return reviews, nil
+}
+
+// pipeline returns the latest pipeline of a merge request as a check, or
+// nil if it has none
+func (c *Client) pipeline(ctx context.Context, repo forge.Repo, number int) ([]forge.Check, error) {
+	var pipelines []struct {
+		ID     int    `json:"id"`
+		Status string `json:"status"`
+		WebURL string `json:"web_url"`
+	}
+	if err := c.do(ctx, http.MethodGet, mergeRequestPath(repo, number, "/pipelines"), nil, &pipelines); err != nil {
+		return nil, err
+	}
+	if len(pipelines) == 0 {
+		return nil, nil
+	}
+	// GitLab lists the latest pipeline first
+	latest := pipelines[0]
+	check := forge.Check{Name: fmt.Sprintf("pipeline #%d", latest.ID), Status: "completed", URL: latest.WebURL}
+	switch latest.Status {
+	case "success":
+		check.Conclusion = "success"
+	case "failed":
+		check.Conclusion = "failure"
+	case "canceled":
+		check.Conclusion = "cancelled"
+	case "skipped":
+		check.Conclusion = "skipped"
+	case "manual":
+		check.Conclusion = "action_required"
+	case "created", "waiting_for_resource", "preparing", "pending", "scheduled":
+		check.Status = "queued"
+	default:
+		check.Status = "in_progress"
+	}
+	return []forge.Check{check}, nil
+}
+
+// Status gathers the approvals, pipeline and mergeability of a merge request
+func (c *Client) Status(ctx context.Context, repo forge.Repo, number int) (*forge.PullRequestStatus, error) {
+	pr, err := c.GetPullRequest(ctx, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	reviews, err := c.approvals(ctx, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := c.pipeline(ctx, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	return forge.NewStatus(*pr, reviews, checks), nil
+}
+
+// Comment adds a note to a merge request
+func (c *Client) Comment(ctx context.Context, repo forge.Repo, number int, body string) error {
+	in := map[string]string{"body": body}
+	return c.do(ctx, http.MethodPost, mergeRequestPath(repo, number, "/notes"), in, nil)
+}
@@ -0,0 +1,68 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// leftovers returns the temporary files WriteFileAtomic left in dir
+func leftovers(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config", "glove80.keymap")
+
+	if err := WriteFileAtomic(path, []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "first\n" {
+		t.Errorf("content = %q, want %q", got, "first\n")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("mode of a new file = %v, want 0600", info.Mode().Perm())
+	}
+
+	// An existing file keeps its permissions
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(path, []byte("second\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "second\n" {
+		t.Errorf("content = %q, want %q", got, "second\n")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Errorf("mode of a replaced file = %v, want 0640 kept", info.Mode().Perm())
+	}
+	if files := leftovers(t, filepath.Dir(path)); len(files) != 0 {
+		t.Errorf("temporary files left behind: %v", files)
+	}
+}
+
+func TestWriteFileAtomicCleansUpOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	// A non-empty directory cannot be renamed over
+	path := filepath.Join(dir, "glove80.keymap")
+	if err := os.MkdirAll(filepath.Join(path, "inside"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFileAtomic(path, []byte("first\n"), 0644); err == nil {
+		t.Fatal("WriteFileAtomic over a directory succeeded")
+	}
+	if files := leftovers(t, dir); len(files) != 0 {
+		t.Errorf("temporary files left behind: %v", files)
+	}
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		t.Errorf("%s was disturbed: %v", path, err)
+	}
+}
@@ -0,0 +1,274 @@
+// Package history keeps every version of the keymaps klcm writes, so a bad
+// pull, download or sync can be undone.
+//
+// The store lives in .klcm/history: a log.jsonl with one entry per write and
+// an objects/ directory holding each version once, named by its SHA-256.
+package history
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDir is the history store of the workspace in the current directory
+const DefaultDir = ".klcm/history"
+
+// Entry records one write of a keymap
+type Entry struct {
+	ID       int       `json:"id"`
+	Time     time.Time `json:"time"`
+	Keyboard string    `json:"keyboard"`
+	Path     string    `json:"path"`
+	Command  string    `json:"command"`          // the klcm command line that wrote the file
+	Before   string    `json:"before,omitempty"` // hash of the previous version; "" if the file did not exist
+	After    string    `json:"after,omitempty"`  // hash of the new version; "" if the file was removed
+	Undoes   int       `json:"undoes,omitempty"` // the entry an undo reverted
+}
+
+// Store is a history directory
+type Store struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+// Open returns the store in dir, which is created on the first write
+func Open(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+func (s *Store) logPath() string {
+	return filepath.Join(s.Dir, "log.jsonl")
+}
+
+func (s *Store) objectPath(hash string) string {
+	return filepath.Join(s.Dir, "objects", hash)
+}
+
+// Hash returns the name a version is stored under
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Write snapshots the current content of path and atomically replaces it with
+// data, recording which command did it. Writing identical content records
+// nothing.
+func (s *Store) Write(keyboard, path, command string, data []byte) (*Entry, error) {
+	return s.replace(keyboard, path, command, data, true, 0)
+}
+
+// Entries returns every entry, oldest first
+func (s *Store) Entries() ([]Entry, error) {
+	file, err := os.Open(s.logPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.logPath(), line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return entries, nil
+}
+
+// For returns the entries of one keyboard, oldest first
+func (s *Store) For(keyboard string) ([]Entry, error) {
+	entries, err := s.Entries()
+	if err != nil {
+		return nil, err
+	}
+	var matching []Entry
+	for _, entry := range entries {
+		if entry.Keyboard == keyboard {
+			matching = append(matching, entry)
+		}
+	}
+	return matching, nil
+}
+
+// Get returns an entry by ID
+func (s *Store) Get(id int) (Entry, error) {
+	entries, err := s.Entries()
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return Entry{}, fmt.Errorf("no history entry #%d", id)
+}
+
+// Load returns a stored version
+func (s *Store) Load(hash string) ([]byte, error) {
+	data, err := os.ReadFile(s.objectPath(hash))
+	if err != nil {
+		return nil, fmt.Errorf("version %s is missing from history: %w", Short(hash), err)
+	}
+	return data, nil
+}
+
+// Undoable returns the latest change that has not been undone, for one
+// keyboard or for any if keyboard is "". Undo entries themselves are skipped,
+// so repeated undos walk further back.
+func (s *Store) Undoable(keyboard string) (*Entry, error) {
+	entries, err := s.Entries()
+	if err != nil {
+		return nil, err
+	}
+	undone := make(map[int]bool)
+	for _, entry := range entries {
+		if entry.Undoes != 0 {
+			undone[entry.Undoes] = true
+		}
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Undoes != 0 || undone[entry.ID] {
+			continue
+		}
+		if keyboard == "" || entry.Keyboard == keyboard {
+			return &entry, nil
+		}
+	}
+	return nil, nil
+}
+
+// Undo restores the version an entry replaced
+func (s *Store) Undo(entry Entry, command string) (*Entry, error) {
+	return s.Restore(entry.Keyboard, entry.Path, entry.Before, command, entry.ID)
+}
+
+// Restore writes a stored version back to path, or removes the file if hash
+// is "". The restore is itself recorded, so it can be undone too.
+func (s *Store) Restore(keyboard, path, hash, command string, undoes int) (*Entry, error) {
+	if hash == "" {
+		return s.replace(keyboard, path, command, nil, false, undoes)
+	}
+	data, err := s.Load(hash)
+	if err != nil {
+		return nil, err
+	}
+	return s.replace(keyboard, path, command, data, true, undoes)
+}
+
+func (s *Store) replace(keyboard, path, command string, data []byte, exists bool, undoes int) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := Entry{
+		Time:     time.Now().UTC(),
+		Keyboard: keyboard,
+		Path:     filepath.ToSlash(path),
+		Command:  command,
+		Undoes:   undoes,
+	}
+
+	previous, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		entry.Before, err = s.store(previous)
+		if err != nil {
+			return nil, err
+		}
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if exists {
+		if entry.After, err = s.store(data); err != nil {
+			return nil, err
+		}
+	}
+	if entry.Before == entry.After && undoes == 0 {
+		return nil, nil
+	}
+
+	entries, err := s.Entries()
+	if err != nil {
+		return nil, err
+	}
+	entry.ID = 1
+	if len(entries) > 0 {
+		entry.ID = entries[len(entries)-1].ID + 1
+	}
+
+	// Both versions are already stored; log the change only once the file
+	// really is in the new state, so the log never claims a write that failed
+	if exists {
+		err = WriteFileAtomic(path, data, 0644)
+	} else if entry.Before != "" {
+		err = os.Remove(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.append(entry); err != nil {
+		return nil, fmt.Errorf("%s was written but not recorded: %w", path, err)
+	}
+	return &entry, nil
+}
+
+// store saves a version under its hash unless it is already there
+func (s *Store) store(data []byte) (string, error) {
+	hash := Hash(data)
+	path := s.objectPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := WriteFileAtomic(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save history: %w", err)
+	}
+	return hash, nil
+}
+
+func (s *Store) append(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", s.Dir, err)
+	}
+	file, err := os.OpenFile(s.logPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	return file.Close()
+}
+
+// Short abbreviates a version hash for display
+func Short(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
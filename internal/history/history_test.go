@@ -0,0 +1,121 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newStore returns a store and a keymap path in a temporary directory
+func newStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	return Open(filepath.Join(dir, DefaultDir)), filepath.Join(dir, "config", "glove80.keymap")
+}
+
+func write(t *testing.T, store *Store, path, content string) *Entry {
+	t.Helper()
+	entry, err := store.Write("glove80", path, "klcm pull glove80", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestWriteSnapshotsAndLogs(t *testing.T) {
+	store, path := newStore(t)
+
+	first := write(t, store, path, "first\n")
+	if first.ID != 1 || first.Before != "" || first.After != Hash([]byte("first\n")) {
+		t.Errorf("first entry = %+v, want #1 creating the file", first)
+	}
+	second := write(t, store, path, "second\n")
+	if second.ID != 2 || second.Before != first.After || second.After != Hash([]byte("second\n")) {
+		t.Errorf("second entry = %+v, want #2 replacing #1's version", second)
+	}
+	if got := readFile(t, path); got != "second\n" {
+		t.Errorf("keymap = %q, want %q", got, "second\n")
+	}
+	if old, err := store.Load(second.Before); err != nil || string(old) != "first\n" {
+		t.Errorf("Load(previous version) = %q, %v; want %q", old, err, "first\n")
+	}
+
+	// Writing the same content again records nothing
+	if entry := write(t, store, path, "second\n"); entry != nil {
+		t.Errorf("unchanged write recorded %+v", entry)
+	}
+	if _, err := store.Write("adv360", filepath.Join(filepath.Dir(path), "adv360.keymap"), "klcm pull adv360", []byte("adv\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := store.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	if !reflect.DeepEqual(ids, []int{1, 2, 3}) {
+		t.Errorf("entry IDs = %v, want 1, 2, 3 oldest first", ids)
+	}
+	glove80, err := store.For("glove80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(glove80) != 2 || glove80[0].ID != 1 || glove80[1].ID != 2 || glove80[1].Command != "klcm pull glove80" {
+		t.Errorf("For(glove80) = %+v, want #1 and #2", glove80)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	store, path := newStore(t)
+	first := write(t, store, path, "first\n")
+	second := write(t, store, path, "second\n")
+
+	entry, err := store.Undoable("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil || entry.ID != second.ID {
+		t.Fatalf("Undoable = %+v, want #%d", entry, second.ID)
+	}
+	undo, err := store.Undo(*entry, "klcm undo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undo.Undoes != second.ID || undo.After != first.After {
+		t.Errorf("undo entry = %+v, want it to undo #%d back to #%d's version", undo, second.ID, first.ID)
+	}
+	if got := readFile(t, path); got != "first\n" {
+		t.Errorf("keymap after undo = %q, want %q", got, "first\n")
+	}
+
+	// The undo entry and the undone write are skipped, so undo steps back
+	if entry, err = store.Undoable("glove80"); err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil || entry.ID != first.ID {
+		t.Fatalf("second Undoable = %+v, want #%d", entry, first.ID)
+	}
+	if _, err := store.Undo(*entry, "klcm undo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("keymap after undoing its creation: %v, want it removed", err)
+	}
+
+	if entry, err = store.Undoable(""); err != nil || entry != nil {
+		t.Errorf("Undoable with everything undone = %+v, %v; want nothing", entry, err)
+	}
+}
@@ -0,0 +1,70 @@
+// Package lock reads and writes klcm.lock, which pins each keyboard's keymap
+// to the upstream revision it was pulled from.
+package lock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/history"
+)
+
+// DefaultPath is the lockfile of the workspace in the current directory
+const DefaultPath = "klcm.lock"
+
+// version is the lockfile format version
+const version = 1
+
+// Entry is the upstream revision of one keyboard's keymap
+type Entry struct {
+	Source   string    `json:"source"`           // where the keymap was fetched from
+	Ref      string    `json:"ref,omitempty"`    // branch or tag the commit was resolved from
+	Commit   string    `json:"commit,omitempty"` // upstream commit; empty for sources without revisions
+	SHA256   string    `json:"sha256"`           // hash of the keymap content
+	PulledAt time.Time `json:"pulled_at"`
+}
+
+// File is the content of klcm.lock
+type File struct {
+	Version   int              `json:"version"`
+	Keyboards map[string]Entry `json:"keyboards"`
+}
+
+// Load reads a lockfile; a missing file is an empty lock
+func Load(path string) (*File, error) {
+	file := &File{Version: version, Keyboards: make(map[string]Entry)}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(content, file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if file.Version > version {
+		return nil, fmt.Errorf("%s has format version %d; this klcm understands up to %d", path, file.Version, version)
+	}
+	if file.Keyboards == nil {
+		file.Keyboards = make(map[string]Entry)
+	}
+	return file, nil
+}
+
+// Save writes the lockfile atomically, with keyboards in name order
+func (f *File) Save(path string) error {
+	f.Version = version
+	content, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return history.WriteFileAtomic(path, append(content, '\n'), 0644)
+}
+
+// Hash returns the content hash recorded for a keymap
+func Hash(content []byte) string {
+	return history.Hash(content)
+}
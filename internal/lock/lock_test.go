@@ -0,0 +1,63 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	file, err := Load(filepath.Join(t.TempDir(), DefaultPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Version != version || len(file.Keyboards) != 0 || file.Keyboards == nil {
+		t.Errorf("Load(missing) = %+v, want an empty lock", file)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultPath)
+	entry := Entry{
+		Source:   "/srv/zmk-config@main:config/glove80.keymap",
+		Ref:      "main",
+		Commit:   strings.Repeat("a", 40),
+		SHA256:   Hash([]byte("keymap\n")),
+		PulledAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+	}
+	if err := (&File{Keyboards: map[string]Entry{"glove80": entry}}).Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Version != version || file.Keyboards["glove80"] != entry {
+		t.Errorf("Load = %+v, want version %d with %+v", file, version, entry)
+	}
+}
+
+func TestLoadRejectsFutureVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultPath)
+	if err := os.WriteFile(path, []byte(`{"version": 2, "keyboards": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "format version 2") {
+		t.Errorf("Load of a version 2 lock error = %v, want it rejected", err)
+	}
+}
+
+func TestLoadRejectsInvalidJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultPath)
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load of invalid JSON succeeded")
+	}
+}
@@ -26,6 +26,9 @@ type KeyBinding struct {
 	Value    string      `json:"value"`
 	Layer    int         `json:"layer"`
 	Type     BindingType `json:"type"`
+	Index    int         `json:"index"`            // position index within the layer's bindings
+	Line     int         `json:"line,omitempty"`   // source line of the binding
+	Column   int         `json:"column,omitempty"` // source column of the binding
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
@@ -45,6 +48,8 @@ const (
 type Layer struct {
 	Index    int          `json:"index"`
 	Name     string       `json:"name"`
+	NodeName string       `json:"node_name,omitempty"` // devicetree node name, e.g. "layer0_default"
+	Line     int          `json:"line,omitempty"`
 	Bindings []KeyBinding `json:"bindings"`
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
@@ -57,6 +62,11 @@ type KeyboardLayout struct {
 	Layers       []Layer      `json:"layers"`
 	Behaviors    []Behavior   `json:"behaviors"`
 	Combos       []Combo      `json:"combos"`
+	Macros       []Macro      `json:"macros"`
+	ConditionalLayers []ConditionalLayer `json:"conditional_layers,omitempty"`
+	Defines      map[string]string `json:"defines,omitempty"` // #define constants, e.g. LAYER_KEYPAD -> 6
+	DefineLines  map[string]int    `json:"define_lines,omitempty"` // source line of each #define
+	Includes     []string          `json:"includes,omitempty"`     // #include targets as written, e.g. "<behaviors.dtsi>"
 	LastModified time.Time    `json:"last_modified"`
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
 }
@@ -65,14 +75,36 @@ type KeyboardLayout struct {
 type Behavior struct {
 	Name       string                 `json:"name"`
 	Type       string                 `json:"type"`
+	NodeName   string                 `json:"node_name,omitempty"`
+	Line       int                    `json:"line,omitempty"`
 	Properties map[string]interface{} `json:"properties"`
 }
 
+// Macro represents a ZMK macro behavior
+type Macro struct {
+	Name       string                 `json:"name"`
+	NodeName   string                 `json:"node_name,omitempty"`
+	Line       int                    `json:"line,omitempty"`
+	Bindings   []string               `json:"bindings"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// ConditionalLayer activates ThenLayer while all of IfLayers are active
+type ConditionalLayer struct {
+	Name      string `json:"name"`
+	IfLayers  []int  `json:"if_layers"`
+	ThenLayer int    `json:"then_layer"`
+	Line      int    `json:"line,omitempty"`
+}
+
 // Combo represents key combinations
 type Combo struct {
 	Name    string     `json:"name"`
 	Keys    []Position `json:"keys"`
+	KeyPositions []int `json:"key_positions"`
 	Binding string     `json:"binding"`
 	Layers  []int      `json:"layers,omitempty"`
+	UnresolvedLayers []string `json:"unresolved_layers,omitempty"`
 	Timeout int        `json:"timeout,omitempty"`
+	Line    int        `json:"line,omitempty"`
 }
\ No newline at end of file
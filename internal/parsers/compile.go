@@ -0,0 +1,283 @@
+package parsers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Compiler checks that a keymap would build
+type Compiler interface {
+	// Name identifies the compiler in output, e.g. "west" or "structural"
+	Name() string
+	// Compile checks a keymap and returns its diagnostics as findings
+	Compile(keyboard, keymapPath string) ([]Finding, error)
+}
+
+// CompileConfig configures the compile check, globally and per keyboard.
+// Command arguments may use these placeholders:
+//
+//	{config_dir}  temporary ZMK config directory holding the keymap
+//	{keymap}      path of the keymap inside {config_dir}
+//	{keyboard}    keyboard name, e.g. glove80
+type CompileConfig struct {
+	Command   []string                 `mapstructure:"command"`
+	Timeout   time.Duration            `mapstructure:"timeout"`
+	Keyboards map[string]CompileConfig `mapstructure:"keyboards"`
+}
+
+// defaultCompileTimeout bounds a builder run when no timeout is configured
+const defaultCompileTimeout = 10 * time.Minute
+
+// CompilerFor returns the compiler configured for a keyboard: the external
+// builder if a command is set, otherwise the built-in structural check
+func (c CompileConfig) CompilerFor(keyboard string) Compiler {
+	command, timeout := c.Command, c.Timeout
+	if override, ok := c.Keyboards[keyboard]; ok {
+		if len(override.Command) > 0 {
+			command = override.Command
+		}
+		if override.Timeout > 0 {
+			timeout = override.Timeout
+		}
+	}
+
+	if len(command) == 0 {
+		return StructuralCompiler{}
+	}
+	if timeout <= 0 {
+		timeout = defaultCompileTimeout
+	}
+	return &ExternalCompiler{Command: command, Timeout: timeout}
+}
+
+// ExternalCompiler runs a builder such as west or dtc against a temporary
+// copy of the keymap's config directory
+type ExternalCompiler struct {
+	Command []string
+	Timeout time.Duration
+}
+
+// Name returns the builder executable name
+func (c *ExternalCompiler) Name() string {
+	return filepath.Base(c.Command[0])
+}
+
+// Compile copies the keymap's directory into a temporary config tree, runs
+// the builder there and maps its diagnostics back to keymap lines
+func (c *ExternalCompiler) Compile(keyboard, keymapPath string) ([]Finding, error) {
+	configDir, err := os.MkdirTemp("", "klcm-compile-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary config: %v", err)
+	}
+	defer os.RemoveAll(configDir)
+
+	if err := copyConfigDir(filepath.Dir(keymapPath), configDir); err != nil {
+		return nil, fmt.Errorf("failed to create temporary config: %v", err)
+	}
+	tempKeymap := filepath.Join(configDir, filepath.Base(keymapPath))
+
+	replacer := strings.NewReplacer(
+		"{config_dir}", configDir,
+		"{keymap}", tempKeymap,
+		"{keyboard}", keyboard,
+	)
+	args := make([]string, len(c.Command))
+	for i, arg := range c.Command {
+		args[i] = replacer.Replace(arg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = configDir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	runErr := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%s timed out after %s", c.Name(), c.Timeout)
+	}
+	if runErr != nil {
+		if _, ok := runErr.(*exec.ExitError); !ok {
+			return nil, fmt.Errorf("failed to run %s: %v", c.Name(), runErr)
+		}
+	}
+
+	findings := ParseBuilderDiagnostics(output.String(), keymapPath, configDir)
+	if runErr != nil && !HasErrors(findings) {
+		// The build failed without a diagnostic we understand
+		findings = append(findings, Finding{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("%s failed: %s", c.Name(), lastLines(output.String(), 5)),
+			File:     keymapPath,
+		})
+	}
+	return findings, nil
+}
+
+// copyConfigDir copies the regular files of a config directory, which holds
+// the keymap and the .dtsi/.conf/.json files it may include
+func copyConfigDir(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dst, entry.Name()), content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Builder diagnostic formats:
+//
+//	gcc/cpp:  glove80.keymap:12:5: error: unknown token
+//	dtc:      Error: glove80.keymap:12.5-10 syntax error
+//	          Warning (reg_format): glove80.keymap:30.3-40: ...
+var (
+	gccDiagnostic = regexp.MustCompile(`^(.+?):(\d+):(?:(\d+):)?\s*(fatal error|error|warning|note):\s*(.*)$`)
+	dtcDiagnostic = regexp.MustCompile(`^(Error|Warning|FATAL ERROR)(?: \([^)]*\))?:\s*(.+?):(\d+)(?:\.(\d+))?(?:-[\d.]+)?:?\s*(.*)$`)
+)
+
+// ParseBuilderDiagnostics extracts findings from builder output. Paths inside
+// the temporary config directory are mapped back to the original keymap
+// directory; the keymap is copied verbatim, so line numbers carry over.
+func ParseBuilderDiagnostics(output, keymapPath, configDir string) []Finding {
+	var findings []Finding
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+
+		var file, level, message string
+		var lineNum, column int
+		if match := gccDiagnostic.FindStringSubmatch(line); match != nil {
+			file, level, message = match[1], match[4], match[5]
+			lineNum, _ = strconv.Atoi(match[2])
+			column, _ = strconv.Atoi(match[3])
+		} else if match := dtcDiagnostic.FindStringSubmatch(line); match != nil {
+			file, level, message = match[2], match[1], match[5]
+			lineNum, _ = strconv.Atoi(match[3])
+			column, _ = strconv.Atoi(match[4])
+		} else {
+			continue
+		}
+
+		finding := Finding{
+			Severity: SeverityError,
+			Message:  message,
+			File:     mapBuilderPath(file, keymapPath, configDir),
+			Line:     lineNum,
+			Column:   column,
+		}
+		switch strings.ToLower(level) {
+		case "warning":
+			finding.Severity = SeverityWarning
+		case "note":
+			finding.Severity = SeverityInfo
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// mapBuilderPath maps a path from builder output to the repository file it came from
+func mapBuilderPath(file, keymapPath, configDir string) string {
+	if filepath.Base(file) == filepath.Base(keymapPath) {
+		return keymapPath
+	}
+	if rel, err := filepath.Rel(configDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.Join(filepath.Dir(keymapPath), rel)
+	}
+	return file
+}
+
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " | ")
+}
+
+// StructuralCompiler is the built-in fallback: it checks the devicetree
+// structure ZMK needs without running a toolchain
+type StructuralCompiler struct{}
+
+// Name returns "structural"
+func (StructuralCompiler) Name() string {
+	return "structural"
+}
+
+// Compile checks that the keymap parses as devicetree, has a root node with
+// a zmk,keymap node whose layers all have bindings, and that behaviors and
+// macros declare compatible and #binding-cells
+func (StructuralCompiler) Compile(keyboard, keymapPath string) ([]Finding, error) {
+	content, err := os.ReadFile(keymapPath)
+	if err != nil {
+		return nil, err
+	}
+
+	finding := func(line int, format string, args ...interface{}) Finding {
+		return Finding{Severity: SeverityError, Message: fmt.Sprintf(format, args...), File: keymapPath, Line: line}
+	}
+
+	doc, err := parseDeviceTree(string(content))
+	if err != nil {
+		f := finding(0, "%v", err)
+		if match := syntaxErrorLine.FindStringSubmatch(err.Error()); match != nil {
+			f.Line, _ = strconv.Atoi(match[1])
+			f.Message = match[2]
+		}
+		return []Finding{f}, nil
+	}
+
+	var findings []Finding
+	keymaps := 0
+	doc.Root.Walk(func(node *dtNode) {
+		compatible := strings.Trim(node.PropertyValue("compatible"), `"`)
+		switch {
+		case compatible == "zmk,keymap":
+			keymaps++
+			if len(node.Children) == 0 {
+				findings = append(findings, finding(node.Line, "keymap node %s has no layers", node.Name))
+			}
+			for _, layer := range node.Children {
+				if _, ok := layer.Property("bindings"); !ok {
+					findings = append(findings, finding(layer.Line, "layer %s has no bindings property", layer.Name))
+				}
+			}
+		case strings.HasPrefix(compatible, "zmk,behavior-"):
+			if _, ok := node.Property("#binding-cells"); !ok {
+				findings = append(findings, finding(node.Line, "%s %s is missing #binding-cells", compatible, nodeReference(node)))
+			}
+		case compatible == "" && (node.Name == "behaviors" || node.Name == "macros"):
+			for _, child := range node.Children {
+				if _, ok := child.Property("compatible"); !ok {
+					findings = append(findings, finding(child.Line, "%s node %s is missing a compatible property", node.Name, nodeReference(child)))
+				}
+			}
+		}
+	})
+	if keymaps == 0 {
+		findings = append(findings, finding(0, `no node with compatible = "zmk,keymap"`))
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,138 @@
+package parsers
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeKeymap writes a keymap into its own config directory
+func writeKeymap(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "glove80.keymap")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExternalCompilerParsesDiagnostics(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	keymap := writeKeymap(t, "/ {\n};\n")
+
+	compiler := CompileConfig{Command: []string{"sh", "-c",
+		`echo "{keymap}:12:5: error: unknown token"; echo "{keymap}:3:1: warning: unused define"; exit 1`}}.CompilerFor("glove80")
+	if compiler.Name() != "sh" {
+		t.Errorf("Name() = %q, want sh", compiler.Name())
+	}
+
+	findings, err := compiler.Compile("glove80", keymap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("findings = %+v, want two", findings)
+	}
+
+	want := []Finding{
+		{Severity: SeverityError, Message: "unknown token", File: keymap, Line: 12, Column: 5},
+		{Severity: SeverityWarning, Message: "unused define", File: keymap, Line: 3, Column: 1},
+	}
+	for i, finding := range findings {
+		if finding != want[i] {
+			t.Errorf("finding %d = %+v, want %+v", i, finding, want[i])
+		}
+	}
+}
+
+func TestExternalCompilerFailureWithoutDiagnostics(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	keymap := writeKeymap(t, "/ {\n};\n")
+
+	compiler := &ExternalCompiler{Command: []string{"sh", "-c", "echo build exploded; exit 2"}, Timeout: time.Minute}
+	findings, err := compiler.Compile("glove80", keymap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].Severity != SeverityError || !strings.Contains(findings[0].Message, "build exploded") {
+		t.Errorf("findings = %+v, want one error quoting the build output", findings)
+	}
+}
+
+func TestExternalCompilerTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	keymap := writeKeymap(t, "/ {\n};\n")
+
+	compiler := &ExternalCompiler{Command: []string{"sh", "-c", "exec sleep 5"}, Timeout: 50 * time.Millisecond}
+	if _, err := compiler.Compile("glove80", keymap); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Compile() error = %v, want a timeout", err)
+	}
+}
+
+func TestCompilerForFallsBackToStructural(t *testing.T) {
+	config := CompileConfig{Keyboards: map[string]CompileConfig{
+		"adv360": {Command: []string{"west", "build"}},
+	}}
+	if _, ok := config.CompilerFor("glove80").(StructuralCompiler); !ok {
+		t.Errorf("CompilerFor(glove80) = %T, want StructuralCompiler", config.CompilerFor("glove80"))
+	}
+	if compiler, ok := config.CompilerFor("adv360").(*ExternalCompiler); !ok || compiler.Timeout != defaultCompileTimeout {
+		t.Errorf("CompilerFor(adv360) = %#v, want an ExternalCompiler with the default timeout", config.CompilerFor("adv360"))
+	}
+}
+
+func TestStructuralCompiler(t *testing.T) {
+	tests := []struct {
+		name   string
+		keymap string
+		want   []Finding
+	}{
+		{
+			name:   "valid keymap",
+			keymap: "/ {\n\tkeymap {\n\t\tcompatible = \"zmk,keymap\";\n\t\tbase {\n\t\t\tbindings = <&kp A>;\n\t\t};\n\t};\n};\n",
+		},
+		{
+			name:   "layer without bindings",
+			keymap: "/ {\n\tkeymap {\n\t\tcompatible = \"zmk,keymap\";\n\t\tbase {\n\t\t};\n\t};\n};\n",
+			want:   []Finding{{Severity: SeverityError, Message: "layer base has no bindings property", Line: 4}},
+		},
+		{
+			name:   "no keymap node",
+			keymap: "/ {\n};\n",
+			want:   []Finding{{Severity: SeverityError, Message: `no node with compatible = "zmk,keymap"`}},
+		},
+		{
+			name:   "syntax error",
+			keymap: "/ {\n\tkeymap {\n",
+			want:   []Finding{{Severity: SeverityError, Message: `node "keymap" is never closed`, Line: 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keymap := writeKeymap(t, tt.keymap)
+			findings, err := StructuralCompiler{}.Compile("glove80", keymap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(findings) != len(tt.want) {
+				t.Fatalf("findings = %+v, want %+v", findings, tt.want)
+			}
+			for i := range findings {
+				tt.want[i].File = keymap
+				if findings[i] != tt.want[i] {
+					t.Errorf("finding %d = %+v, want %+v", i, findings[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,383 @@
+package parsers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dtNode represents a node in a devicetree source file
+type dtNode struct {
+	Name       string // node name, e.g. "behavior_mo_key"
+	Label      string // node label, e.g. "mo_key" (empty if none)
+	Line       int    // line where the node starts
+	Properties []dtProperty
+	Children   []*dtNode
+}
+
+// dtProperty represents a property assignment inside a devicetree node
+type dtProperty struct {
+	Name   string
+	Value  string // raw value with comments stripped, e.g. "<&kp A &kp B>"
+	Line   int
+	Column int
+	// Tokens holds the whitespace-separated words of the value with their positions
+	Tokens []dtToken
+}
+
+// dtToken is a single word of a property value and where it appears
+type dtToken struct {
+	Text   string
+	Line   int
+	Column int
+}
+
+// dtDocument is the result of parsing a devicetree source file
+type dtDocument struct {
+	Root     *dtNode
+	Defines  map[string]string
+	Includes []string
+	// DefineLines records the line of each #define
+	DefineLines map[string]int
+}
+
+// Property returns the property with the given name, if present
+func (n *dtNode) Property(name string) (dtProperty, bool) {
+	for _, prop := range n.Properties {
+		if prop.Name == name {
+			return prop, true
+		}
+	}
+	return dtProperty{}, false
+}
+
+// PropertyValue returns the raw value of a property or "" if not present
+func (n *dtNode) PropertyValue(name string) string {
+	prop, _ := n.Property(name)
+	return prop.Value
+}
+
+// Child returns the first direct child with the given node name
+func (n *dtNode) Child(name string) *dtNode {
+	for _, child := range n.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// Walk visits the node and all of its descendants depth-first
+func (n *dtNode) Walk(visit func(node *dtNode)) {
+	visit(n)
+	for _, child := range n.Children {
+		child.Walk(visit)
+	}
+}
+
+// dtSource is a comment-free view of a source file that keeps positions
+type dtSource struct {
+	text  []rune
+	lines []int // line number for each rune
+	cols  []int // column number for each rune
+}
+
+// statement returns the trimmed text between two offsets and the position
+// of its first non-space character
+func (s dtSource) statement(start, end int) (string, int, int) {
+	for start < end && isSpace(s.text[start]) {
+		start++
+	}
+	if start >= end {
+		return "", 0, 0
+	}
+	return strings.TrimSpace(string(s.text[start:end])), s.lines[start], s.cols[start]
+}
+
+// valueTokens returns the words after the '=' of a property statement
+func (s dtSource) valueTokens(start, end int) []dtToken {
+	for start < end && s.text[start] != '=' {
+		start++
+	}
+	if start >= end {
+		return nil
+	}
+
+	var tokens []dtToken
+	var word []rune
+	wordStart := 0
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, dtToken{Text: string(word), Line: s.lines[wordStart], Column: s.cols[wordStart]})
+			word = nil
+		}
+	}
+
+	for i := start + 1; i < end; i++ {
+		r := s.text[i]
+		if isSpace(r) || r == '<' || r == '>' || r == ',' {
+			flush()
+			continue
+		}
+		if len(word) == 0 {
+			wordStart = i
+		}
+		word = append(word, r)
+	}
+	flush()
+
+	return tokens
+}
+
+// parseDeviceTree parses the subset of devicetree syntax used by ZMK keymaps.
+// Preprocessor directives are recorded but not expanded.
+func parseDeviceTree(content string) (*dtDocument, error) {
+	doc := &dtDocument{
+		Root:        &dtNode{Name: "/"},
+		Defines:     make(map[string]string),
+		DefineLines: make(map[string]int),
+	}
+
+	src := stripComments(content, doc)
+
+	stack := []*dtNode{doc.Root}
+	start := 0
+	angleDepth := 0
+	inString := false
+
+	for i, r := range src.text {
+		if inString {
+			if r == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch r {
+		case '"':
+			inString = true
+		case '<':
+			angleDepth++
+		case '>':
+			if angleDepth > 0 {
+				angleDepth--
+			}
+		case '{':
+			if angleDepth > 0 {
+				continue
+			}
+			text, line, _ := src.statement(start, i)
+			node := newDTNode(text, line)
+			parent := stack[len(stack)-1]
+			if existing := mergeTarget(parent, node); existing != nil {
+				node = existing
+			} else {
+				parent.Children = append(parent.Children, node)
+			}
+			stack = append(stack, node)
+			start = i + 1
+		case '}':
+			if angleDepth > 0 {
+				continue
+			}
+			if text, line, _ := src.statement(start, i); text != "" {
+				return nil, fmt.Errorf("line %d: missing ';' after %q", line, text)
+			}
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("line %d: unexpected '}'", src.lines[i])
+			}
+			stack = stack[:len(stack)-1]
+			start = i + 1
+		case ';':
+			if angleDepth > 0 {
+				continue
+			}
+			if text, line, col := src.statement(start, i); text != "" {
+				prop := newDTProperty(text, line, col)
+				prop.Tokens = src.valueTokens(start, i)
+				node := stack[len(stack)-1]
+				node.Properties = append(node.Properties, prop)
+			}
+			start = i + 1
+		}
+	}
+
+	if len(stack) > 1 {
+		open := stack[len(stack)-1]
+		return nil, fmt.Errorf("line %d: node %q is never closed", open.Line, open.Name)
+	}
+	if text, line, _ := src.statement(start, len(src.text)); text != "" {
+		return nil, fmt.Errorf("line %d: unterminated statement %q", line, text)
+	}
+
+	return doc, nil
+}
+
+// mergeTarget returns the node to reuse when a root node ("/ {") is reopened
+func mergeTarget(parent, node *dtNode) *dtNode {
+	if node.Name != "/" {
+		return nil
+	}
+	if parent.Name == "/" && parent.Label == "" {
+		return parent
+	}
+	return nil
+}
+
+func newDTNode(header string, line int) *dtNode {
+	node := &dtNode{Line: line}
+	if idx := strings.Index(header, ":"); idx >= 0 {
+		node.Label = strings.TrimSpace(header[:idx])
+		header = header[idx+1:]
+	}
+	node.Name = strings.TrimSpace(header)
+	return node
+}
+
+func newDTProperty(text string, line, col int) dtProperty {
+	name, value, found := strings.Cut(text, "=")
+	if !found {
+		return dtProperty{Name: strings.TrimSpace(text), Line: line, Column: col}
+	}
+	return dtProperty{
+		Name:   strings.TrimSpace(name),
+		Value:  strings.TrimSpace(value),
+		Line:   line,
+		Column: col,
+	}
+}
+
+// stripComments removes comments and preprocessor lines while recording
+// #define and #include directives in doc
+func stripComments(content string, doc *dtDocument) dtSource {
+	var src dtSource
+	runes := []rune(content)
+	line, col := 1, 1
+	atLineStart := true
+
+	emit := func(r rune) {
+		src.text = append(src.text, r)
+		src.lines = append(src.lines, line)
+		src.cols = append(src.cols, col)
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			i--
+			continue
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			col += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				if runes[i] == '\n' {
+					line++
+					col = 1
+					// keep the newline so statements stay separated
+					emit(' ')
+				} else {
+					col++
+				}
+				i++
+			}
+			i++
+			col += 2
+			continue
+		case r == '#' && atLineStart && isPreprocessorDirective(runes[i:]):
+			start := i
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			recordDirective(strings.TrimSpace(string(runes[start:i])), line, doc)
+			i--
+			continue
+		}
+
+		if r == '\n' {
+			emit(' ')
+			line++
+			col = 1
+			atLineStart = true
+			continue
+		}
+
+		if !isSpace(r) {
+			atLineStart = false
+		}
+		emit(r)
+		col++
+	}
+
+	return src
+}
+
+var preprocessorDirectives = []string{
+	"#include", "#define", "#undef", "#if", "#ifdef", "#ifndef", "#elif", "#else", "#endif", "#pragma",
+}
+
+func isPreprocessorDirective(rest []rune) bool {
+	text := string(rest[:min(len(rest), 16)])
+	for _, directive := range preprocessorDirectives {
+		if strings.HasPrefix(text, directive) {
+			after := strings.TrimPrefix(text, directive)
+			if after == "" || after[0] == ' ' || after[0] == '\t' || after[0] == '<' || after[0] == '"' || after[0] == '\n' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func recordDirective(directive string, line int, doc *dtDocument) {
+	fields := strings.Fields(directive)
+	if len(fields) == 0 {
+		return
+	}
+
+	switch fields[0] {
+	case "#define":
+		if len(fields) >= 2 {
+			doc.Defines[fields[1]] = strings.Join(fields[2:], " ")
+			doc.DefineLines[fields[1]] = line
+		}
+	case "#include":
+		if len(fields) >= 2 {
+			doc.Includes = append(doc.Includes, fields[1])
+		}
+	}
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r' || r == '\n'
+}
+
+// splitBindings splits a bindings property value into individual bindings,
+// e.g. "<&kp A &mt LSHFT B>, <&none>" -> ["&kp A", "&mt LSHFT B", "&none"]
+func splitBindings(value string) []string {
+	value = strings.NewReplacer("<", " ", ">", " ", ",", " ").Replace(value)
+
+	var bindings []string
+	var current []string
+	for _, field := range strings.Fields(value) {
+		if strings.HasPrefix(field, "&") && len(current) > 0 {
+			bindings = append(bindings, strings.Join(current, " "))
+			current = nil
+		}
+		current = append(current, field)
+	}
+	if len(current) > 0 {
+		bindings = append(bindings, strings.Join(current, " "))
+	}
+
+	return bindings
+}
+
+// splitCells splits a cell array value such as "<52 57>" into its cells
+func splitCells(value string) []string {
+	value = strings.NewReplacer("<", " ", ">", " ", ",", " ").Replace(value)
+	return strings.Fields(value)
+}
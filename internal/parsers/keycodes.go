@@ -0,0 +1,162 @@
+package parsers
+
+import (
+	_ "embed"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed zmk_keycodes.txt
+var zmkKeycodeTable string
+
+// zmkKeycodes maps every keycode name and alias to its canonical name
+var zmkKeycodes = loadKeycodes(zmkKeycodeTable)
+
+// modifierFunctions wrap a keycode to send it with a modifier held, e.g. LS(A)
+var modifierFunctions = map[string]bool{
+	"LS": true, "LC": true, "LA": true, "LG": true,
+	"RS": true, "RC": true, "RA": true, "RG": true,
+}
+
+// keycodeBehaviors are behaviors whose parameters are keycodes. The value is
+// the index of the first keycode parameter (&lt takes a layer first).
+var keycodeBehaviors = map[string]int{"kp": 0, "mt": 0, "lt": 1, "sk": 0, "kt": 0}
+
+// loadKeycodes parses the keycode table (see zmk_keycodes.txt)
+func loadKeycodes(table string) map[string]string {
+	codes := make(map[string]string)
+	for _, line := range strings.Split(table, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, name := range fields {
+			codes[name] = fields[0]
+		}
+	}
+	return codes
+}
+
+// splitModifierFunction splits "LS(LG(S))" into "LS" and "LG(S)"
+func splitModifierFunction(code string) (string, string, bool) {
+	open := strings.Index(code, "(")
+	if open <= 0 || !strings.HasSuffix(code, ")") {
+		return "", "", false
+	}
+	return code[:open], code[open+1 : len(code)-1], true
+}
+
+// CanonicalKeycode returns the canonical name of a keycode, resolving aliases
+// inside modifier functions, e.g. "LS(LSHFT)" -> "LS(LEFT_SHIFT)". The second
+// result is false if the keycode is not in the ZMK table.
+func CanonicalKeycode(code string) (string, bool) {
+	if fn, inner, ok := splitModifierFunction(code); ok {
+		if !modifierFunctions[fn] {
+			return code, false
+		}
+		canonical, ok := CanonicalKeycode(inner)
+		return fn + "(" + canonical + ")", ok
+	}
+	if canonical, ok := zmkKeycodes[code]; ok {
+		return canonical, true
+	}
+	return code, false
+}
+
+// unknownKeycode returns the part of a keycode expression that is not a
+// known keycode or modifier function, or "" if the whole expression is valid.
+// Numeric literals and names #defined in the keymap are accepted.
+func unknownKeycode(code string, defines map[string]string) string {
+	if fn, inner, ok := splitModifierFunction(code); ok {
+		if !modifierFunctions[fn] {
+			return fn
+		}
+		return unknownKeycode(inner, defines)
+	}
+	if _, ok := zmkKeycodes[code]; ok {
+		return ""
+	}
+	if _, ok := defines[code]; ok {
+		return ""
+	}
+	if _, err := strconv.ParseUint(code, 0, 32); err == nil {
+		return ""
+	}
+	return code
+}
+
+// SuggestKeycodes returns up to three known keycodes that look like an
+// unknown one, closest first
+func SuggestKeycodes(code string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	upper := strings.ToUpper(code)
+	limit := max(2, len(upper)/3)
+	var candidates []candidate
+	for name := range zmkKeycodes {
+		distance := editDistance(upper, name)
+		if distance <= limit {
+			candidates = append(candidates, candidate{name, distance})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	var suggestions []string
+	for _, c := range candidates {
+		if len(suggestions) == 3 {
+			break
+		}
+		suggestions = append(suggestions, c.name)
+	}
+	return suggestions
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// normalizeKeycodes rewrites the keycode parameters of a binding to their
+// canonical names, e.g. "&kp LSHFT" -> "&kp LEFT_SHIFT"
+func normalizeKeycodes(fields []string) []string {
+	if len(fields) == 0 {
+		return fields
+	}
+	first, ok := keycodeBehaviors[strings.TrimPrefix(fields[0], "&")]
+	if !ok {
+		return fields
+	}
+	normalized := append([]string(nil), fields...)
+	for i := 1 + first; i < len(normalized); i++ {
+		if canonical, ok := CanonicalKeycode(normalized[i]); ok {
+			normalized[i] = canonical
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,378 @@
+package parsers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/models"
+)
+
+// LayerEdge is a way to activate one layer from another
+type LayerEdge struct {
+	From    int    `json:"from"`
+	To      int    `json:"to"`
+	Kind    string `json:"kind"`          // mo, lt, to, tog, sl or conditional
+	Via     string `json:"via,omitempty"` // custom behavior or macro wrapping the layer behavior
+	Key     string `json:"key"`           // logical key, "combo <name>" or the conditional layer name
+	Index   int    `json:"index"`         // binding index on the From layer, -1 for combos and conditional layers
+	Binding string `json:"binding"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// IsMomentary reports whether the edge only activates its layer while held
+func (e LayerEdge) IsMomentary() bool {
+	return e.Kind == "mo" || e.Kind == "lt" || e.Kind == "sl"
+}
+
+// LayerGraph is the layer-activation graph of a keymap
+type LayerGraph struct {
+	Layout *models.KeyboardLayout `json:"-"`
+	Layers []string               `json:"layers"`
+	Edges  []LayerEdge            `json:"edges"`
+}
+
+// BuildLayerGraph collects every layer activation in a keymap, looking through
+// hold-taps, tap-dances, mod-morphs and macros as well as combos and
+// conditional layers
+func BuildLayerGraph(layout *models.KeyboardLayout) *LayerGraph {
+	graph := &LayerGraph{Layout: layout}
+	for _, layer := range layout.Layers {
+		graph.Layers = append(graph.Layers, layer.Name)
+	}
+
+	behaviors := make(map[string]models.Behavior)
+	for _, behavior := range layout.Behaviors {
+		behaviors[behavior.Name] = behavior
+	}
+	macros := make(map[string]models.Macro)
+	for _, macro := range layout.Macros {
+		macros[macro.Name] = macro
+	}
+
+	// activations expands a binding into the layer behaviors it ends up invoking
+	var activations func(binding, via string, depth int) []LayerEdge
+	activations = func(binding, via string, depth int) []LayerEdge {
+		name, params := bindingName(binding), bindingParams(binding)
+		if depth > 8 {
+			return nil
+		}
+
+		if layerBehaviors[name] && len(params) > 0 {
+			target, ok := resolveLayer(layout.Defines, params[0])
+			if !ok {
+				return nil
+			}
+			return []LayerEdge{{To: target, Kind: name, Via: via}}
+		}
+
+		if via == "" {
+			via = name
+		}
+		var edges []LayerEdge
+		if behavior, ok := behaviors[name]; ok {
+			value, _ := behavior.Properties["bindings"].(string)
+			for i, inner := range splitBindings(value) {
+				// Hold-tap bindings take their parameters from the key, e.g.
+				// &mo_key 1 SPACE with bindings = <&mo>, <&kp>
+				if strings.HasSuffix(behavior.Type, "hold-tap") && len(bindingParams(inner)) == 0 && i < len(params) {
+					inner += " " + params[i]
+				}
+				edges = append(edges, activations(inner, via, depth+1)...)
+			}
+		}
+		if macro, ok := macros[name]; ok {
+			for _, inner := range macro.Bindings {
+				edges = append(edges, activations(inner, via, depth+1)...)
+			}
+		}
+		return edges
+	}
+
+	for _, layer := range layout.Layers {
+		for _, binding := range layer.Bindings {
+			for _, edge := range activations(binding.Value, "", 0) {
+				edge.From = layer.Index
+				edge.Key = binding.Position.KeyID
+				edge.Index = binding.Index
+				edge.Binding = binding.Value
+				edge.Line = binding.Line
+				edge.Column = binding.Column
+				graph.Edges = append(graph.Edges, edge)
+			}
+		}
+	}
+
+	for _, combo := range layout.Combos {
+		layers := combo.Layers
+		if len(layers) == 0 && len(combo.UnresolvedLayers) == 0 {
+			for _, layer := range layout.Layers {
+				layers = append(layers, layer.Index)
+			}
+		}
+		for _, edge := range activations(combo.Binding, "", 0) {
+			for _, from := range layers {
+				edge.From = from
+				edge.Key = "combo " + combo.Name
+				edge.Index = -1
+				edge.Binding = combo.Binding
+				edge.Line = combo.Line
+				graph.Edges = append(graph.Edges, edge)
+			}
+		}
+	}
+
+	for _, conditional := range layout.ConditionalLayers {
+		for _, from := range conditional.IfLayers {
+			graph.Edges = append(graph.Edges, LayerEdge{
+				From:    from,
+				To:      conditional.ThenLayer,
+				Kind:    "conditional",
+				Key:     conditional.Name,
+				Index:   -1,
+				Binding: fmt.Sprintf("if-layers %v", conditional.IfLayers),
+				Line:    conditional.Line,
+			})
+		}
+	}
+
+	return graph
+}
+
+// LayerName returns the name of a layer index, or "#n" if it does not exist
+func (g *LayerGraph) LayerName(index int) string {
+	if index >= 0 && index < len(g.Layers) {
+		return g.Layers[index]
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// edgesFrom returns the activations usable while a layer is active on top of
+// the default layer: the layer's own edges plus default-layer edges on keys
+// the layer leaves &trans
+func (g *LayerGraph) edgesFrom(layer int) []LayerEdge {
+	var edges []LayerEdge
+	for _, edge := range g.Edges {
+		switch {
+		case edge.Kind == "conditional":
+		case edge.From == layer:
+			edges = append(edges, edge)
+		case edge.From == 0 && layer != 0 && edge.Index >= 0 && g.isTransparent(layer, edge.Index):
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+func (g *LayerGraph) isTransparent(layer, index int) bool {
+	if layer < 0 || layer >= len(g.Layout.Layers) {
+		return false
+	}
+	bindings := g.Layout.Layers[layer].Bindings
+	return index < len(bindings) && bindingName(bindings[index].Value) == "trans"
+}
+
+// Reachable returns the layers that can be activated starting from layer 0
+func (g *LayerGraph) Reachable() map[int]bool {
+	reachable := map[int]bool{0: true}
+	queue := []int{0}
+	for {
+		for len(queue) > 0 {
+			layer := queue[0]
+			queue = queue[1:]
+			for _, edge := range g.edgesFrom(layer) {
+				if !reachable[edge.To] {
+					reachable[edge.To] = true
+					queue = append(queue, edge.To)
+				}
+			}
+		}
+
+		// A conditional layer is reachable once all of its if-layers are
+		for _, conditional := range g.Layout.ConditionalLayers {
+			if reachable[conditional.ThenLayer] {
+				continue
+			}
+			all := true
+			for _, layer := range conditional.IfLayers {
+				all = all && reachable[layer]
+			}
+			if all {
+				reachable[conditional.ThenLayer] = true
+				queue = append(queue, conditional.ThenLayer)
+			}
+		}
+		if len(queue) == 0 {
+			return reachable
+		}
+	}
+}
+
+// UnreachableLayers returns the layers no sequence of key presses can activate
+func (g *LayerGraph) UnreachableLayers() []int {
+	reachable := g.Reachable()
+	var unreachable []int
+	for i := range g.Layers {
+		if !reachable[i] {
+			unreachable = append(unreachable, i)
+		}
+	}
+	return unreachable
+}
+
+// OneWayEdges returns &to transitions into a layer that offers no way back to
+// the default layer, either directly (&to 0, &tog of the layer) or through
+// layers it can activate
+func (g *LayerGraph) OneWayEdges() []LayerEdge {
+	var oneWay []LayerEdge
+	checked := make(map[int]bool)
+	trapped := make(map[int]bool)
+
+	for _, edge := range g.Edges {
+		if edge.Kind != "to" || edge.To == 0 || edge.To >= len(g.Layers) {
+			continue
+		}
+		if !checked[edge.To] {
+			checked[edge.To] = true
+			trapped[edge.To] = !g.canReturn(edge.To)
+		}
+		if trapped[edge.To] {
+			oneWay = append(oneWay, edge)
+		}
+	}
+	return oneWay
+}
+
+// canReturn reports whether the default layer can be restored after &to layer,
+// directly or through the layers it can activate
+func (g *LayerGraph) canReturn(layer int) bool {
+	seen := map[int]bool{layer: true}
+	queue := []int{layer}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, edge := range g.edgesFrom(current) {
+			if (edge.Kind == "to" && edge.To == 0) || (edge.Kind == "tog" && edge.To == layer) {
+				return true
+			}
+			if !seen[edge.To] {
+				seen[edge.To] = true
+				queue = append(queue, edge.To)
+			}
+		}
+	}
+	return false
+}
+
+// SelfActivations returns momentary bindings that activate the layer they sit
+// on, which do nothing once that layer is active
+func (g *LayerGraph) SelfActivations() []LayerEdge {
+	var self []LayerEdge
+	for _, edge := range g.Edges {
+		if edge.From == edge.To && edge.Index >= 0 && edge.IsMomentary() {
+			self = append(self, edge)
+		}
+	}
+	return self
+}
+
+// graphEdge is a deduplicated edge for display
+type graphEdge struct {
+	from, to int
+	kind     string
+	keys     []string
+}
+
+// displayEdges merges edges with the same endpoints and kind
+func (g *LayerGraph) displayEdges() []graphEdge {
+	merged := make(map[string]*graphEdge)
+	var order []string
+	for _, edge := range g.Edges {
+		id := fmt.Sprintf("%d-%d-%s", edge.From, edge.To, edge.Kind)
+		if _, ok := merged[id]; !ok {
+			merged[id] = &graphEdge{from: edge.From, to: edge.To, kind: edge.Kind}
+			order = append(order, id)
+		}
+		merged[id].keys = append(merged[id].keys, edge.Key)
+	}
+
+	edges := make([]graphEdge, 0, len(order))
+	for _, id := range order {
+		edges = append(edges, *merged[id])
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].from != edges[j].from {
+			return edges[i].from < edges[j].from
+		}
+		return edges[i].to < edges[j].to
+	})
+	return edges
+}
+
+func (e graphEdge) label() string {
+	if len(e.keys) == 1 {
+		return fmt.Sprintf("%s (%s)", e.kind, e.keys[0])
+	}
+	return fmt.Sprintf("%s (%d keys)", e.kind, len(e.keys))
+}
+
+// DOT renders the graph in Graphviz format. Momentary edges are dashed,
+// unreachable layers are grey.
+func (g *LayerGraph) DOT() string {
+	reachable := g.Reachable()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", g.Layout.Name+" layers")
+	b.WriteString("  rankdir=LR;\n  node [shape=box, style=rounded];\n")
+	for i, name := range g.Layers {
+		attrs := fmt.Sprintf("label=%q", fmt.Sprintf("%d: %s", i, name))
+		if !reachable[i] {
+			attrs += ", color=grey, fontcolor=grey"
+		}
+		fmt.Fprintf(&b, "  L%d [%s];\n", i, attrs)
+	}
+	for _, edge := range g.displayEdges() {
+		style := "solid"
+		switch edge.kind {
+		case "mo", "lt", "sl":
+			style = "dashed"
+		case "conditional":
+			style = "dotted"
+		}
+		fmt.Fprintf(&b, "  L%d -> L%d [label=%q, style=%s];\n", edge.from, edge.to, edge.label(), style)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart. Momentary edges are
+// dotted, unreachable layers get the "unreachable" class.
+func (g *LayerGraph) Mermaid() string {
+	reachable := g.Reachable()
+
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, name := range g.Layers {
+		fmt.Fprintf(&b, "  L%d[\"%d: %s\"]\n", i, i, name)
+	}
+	for _, edge := range g.displayEdges() {
+		arrow := "-->"
+		switch edge.kind {
+		case "mo", "lt", "sl", "conditional":
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  L%d %s|\"%s\"| L%d\n", edge.from, arrow, edge.label(), edge.to)
+	}
+	var unreachable []string
+	for i := range g.Layers {
+		if !reachable[i] {
+			unreachable = append(unreachable, fmt.Sprintf("L%d", i))
+		}
+	}
+	if len(unreachable) > 0 {
+		b.WriteString("  classDef unreachable stroke-dasharray: 5 5,color:#999\n")
+		fmt.Fprintf(&b, "  class %s unreachable\n", strings.Join(unreachable, ","))
+	}
+	return b.String()
+}
@@ -0,0 +1,162 @@
+package parsers
+
+import (
+	"fmt"
+	"sort"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/models"
+)
+
+// Severity is how serious a lint finding is
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Finding is a single problem reported by a lint rule
+type Finding struct {
+	RuleID   string   `json:"rule_id"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Hint     string   `json:"hint,omitempty"`
+	Keyboard string   `json:"keyboard,omitempty"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+}
+
+// String formats the finding as "file:line:col: severity [rule] message"
+func (f Finding) String() string {
+	location := f.File
+	if f.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, f.Line)
+		if f.Column > 0 {
+			location = fmt.Sprintf("%s:%d", location, f.Column)
+		}
+	}
+	if location == "" {
+		return fmt.Sprintf("%s [%s] %s", f.Severity, f.RuleID, f.Message)
+	}
+	return fmt.Sprintf("%s: %s [%s] %s", location, f.Severity, f.RuleID, f.Message)
+}
+
+// LintContext is what a rule gets to inspect
+type LintContext struct {
+	Layout   *models.KeyboardLayout
+	Physical *PhysicalLayout // nil when the board's physical layout is unknown
+}
+
+// Rule is a single semantic check over a parsed keymap
+type Rule interface {
+	ID() string
+	Description() string
+	DefaultSeverity() Severity
+	Check(ctx *LintContext) []Finding
+}
+
+// RuleSetting overrides a rule's defaults
+type RuleSetting struct {
+	Enabled  *bool    `mapstructure:"enabled"`
+	Severity Severity `mapstructure:"severity"`
+}
+
+// LintConfig configures rules globally and per keyboard
+type LintConfig struct {
+	Rules     map[string]RuleSetting          `mapstructure:"rules"`
+	Keyboards map[string]KeyboardLintSettings `mapstructure:"keyboards"`
+}
+
+// KeyboardLintSettings are rule overrides that apply to one keyboard only
+type KeyboardLintSettings struct {
+	Rules map[string]RuleSetting `mapstructure:"rules"`
+}
+
+// setting returns the effective setting of a rule for a keyboard
+func (c LintConfig) setting(keyboard, ruleID string) RuleSetting {
+	setting := c.Rules[ruleID]
+	if kb, ok := c.Keyboards[keyboard]; ok {
+		if override, ok := kb.Rules[ruleID]; ok {
+			if override.Enabled != nil {
+				setting.Enabled = override.Enabled
+			}
+			if override.Severity != "" {
+				setting.Severity = override.Severity
+			}
+		}
+	}
+	return setting
+}
+
+// Linter runs a set of rules against parsed layouts
+type Linter struct {
+	rules  []Rule
+	config LintConfig
+}
+
+// NewLinter creates a linter with the given rules and configuration
+func NewLinter(config LintConfig, rules ...Rule) *Linter {
+	return &Linter{rules: rules, config: config}
+}
+
+// NewDefaultLinter creates a linter with all built-in rules
+func NewDefaultLinter(config LintConfig) *Linter {
+	return NewLinter(config, DefaultRules()...)
+}
+
+// Rules returns the rules this linter runs
+func (l *Linter) Rules() []Rule {
+	return l.rules
+}
+
+// Lint runs every enabled rule and returns the findings sorted by location
+func (l *Linter) Lint(layout *models.KeyboardLayout) []Finding {
+	ctx := &LintContext{Layout: layout}
+	if physical, ok := GetPhysicalLayout(layout.Type); ok {
+		ctx.Physical = &physical
+	}
+
+	var findings []Finding
+	for _, rule := range l.rules {
+		setting := l.config.setting(string(layout.Type), rule.ID())
+		if setting.Enabled != nil && !*setting.Enabled {
+			continue
+		}
+		severity := rule.DefaultSeverity()
+		if setting.Severity != "" {
+			severity = setting.Severity
+		}
+
+		for _, finding := range rule.Check(ctx) {
+			finding.RuleID = rule.ID()
+			// A rule may grade an individual finding; configuration still wins
+			if finding.Severity == "" || setting.Severity != "" {
+				finding.Severity = severity
+			}
+			finding.Keyboard = string(layout.Type)
+			finding.File = layout.FilePath
+			findings = append(findings, finding)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Column < findings[j].Column
+	})
+
+	return findings
+}
+
+// HasErrors reports whether any finding has error severity
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
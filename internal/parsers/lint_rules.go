@@ -0,0 +1,721 @@
+package parsers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/models"
+)
+
+// DefaultRules returns all built-in lint rules
+func DefaultRules() []Rule {
+	return []Rule{
+		undefinedReferenceRule{},
+		layerTargetRule{},
+		bindingCountRule{},
+		duplicateLabelRule{},
+		unusedDefinitionRule{},
+		layerDefineRule{},
+		unknownKeycodeRule{},
+		unreachableLayerRule{},
+		oneWayLayerRule{},
+		selfActivationRule{},
+		comboConflictRule{},
+		comboTimingRule{},
+		comboPositionRule{},
+	}
+}
+
+// zmkBuiltinBehaviors are behaviors provided by ZMK itself (behaviors.dtsi)
+var zmkBuiltinBehaviors = map[string]bool{
+	"kp": true, "mt": true, "lt": true, "mo": true, "to": true, "tog": true, "sl": true, "sk": true,
+	"trans": true, "none": true, "bootloader": true, "sys_reset": true, "reset": true, "soft_off": true,
+	"bt": true, "out": true, "rgb_ug": true, "ext_power": true, "bl": true, "caps_word": true,
+	"key_repeat": true, "gresc": true, "kt": true, "mkp": true, "mmv": true, "msc": true,
+	"studio_unlock": true, "inc_dec_kp": true,
+	"macro_tap": true, "macro_press": true, "macro_release": true, "macro_pause_for_release": true,
+	"macro_tap_time": true, "macro_wait_time": true, "macro_param_1to1": true, "macro_param_1to2": true,
+	"macro_param_2to1": true, "macro_param_2to2": true,
+}
+
+// layerBehaviors are behaviors whose first parameter is a layer
+var layerBehaviors = map[string]bool{"mo": true, "lt": true, "to": true, "tog": true, "sl": true}
+
+// reference is a use of a behavior or macro somewhere in the keymap
+type reference struct {
+	Name    string // behavior name without "&"
+	Binding string
+	Where   string // human-readable location, e.g. "layer default, key L1"
+	Line    int
+	Column  int
+}
+
+// bindingName returns the behavior a binding refers to, e.g. "&mt LSHFT A" -> "mt"
+func bindingName(binding string) string {
+	fields := strings.Fields(binding)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(fields[0], "&")
+}
+
+// bindingParams returns the parameters of a binding, e.g. "&mt LSHFT A" -> ["LSHFT", "A"]
+func bindingParams(binding string) []string {
+	fields := strings.Fields(binding)
+	if len(fields) <= 1 {
+		return nil
+	}
+	return fields[1:]
+}
+
+// collectReferences returns every behavior reference in layers, combos,
+// behaviors and macros
+func collectReferences(layout *models.KeyboardLayout) []reference {
+	var refs []reference
+	for _, layer := range layout.Layers {
+		for _, binding := range layer.Bindings {
+			refs = append(refs, reference{
+				Name:    bindingName(binding.Value),
+				Binding: binding.Value,
+				Where:   fmt.Sprintf("layer %s, key %s", layer.Name, binding.Position.KeyID),
+				Line:    binding.Line,
+				Column:  binding.Column,
+			})
+		}
+	}
+	for _, combo := range layout.Combos {
+		for _, binding := range splitBindings(combo.Binding) {
+			refs = append(refs, reference{Name: bindingName(binding), Binding: binding, Where: "combo " + combo.Name, Line: combo.Line})
+		}
+	}
+	for _, behavior := range layout.Behaviors {
+		value, _ := behavior.Properties["bindings"].(string)
+		for _, binding := range splitBindings(value) {
+			refs = append(refs, reference{Name: bindingName(binding), Binding: binding, Where: "behavior " + behavior.Name, Line: behavior.Line})
+		}
+	}
+	for _, macro := range layout.Macros {
+		for _, binding := range macro.Bindings {
+			refs = append(refs, reference{Name: bindingName(binding), Binding: binding, Where: "macro " + macro.Name, Line: macro.Line})
+		}
+	}
+	return refs
+}
+
+// definedBehaviors returns the names bindings can use for local behaviors and macros
+func definedBehaviors(layout *models.KeyboardLayout) map[string]bool {
+	defined := make(map[string]bool)
+	for _, behavior := range layout.Behaviors {
+		defined[behavior.Name] = true
+	}
+	for _, macro := range layout.Macros {
+		defined[macro.Name] = true
+	}
+	return defined
+}
+
+// localIncludes returns quoted #include files, which may define behaviors
+// that are not visible to the parser
+func localIncludes(layout *models.KeyboardLayout) []string {
+	var includes []string
+	for _, include := range layout.Includes {
+		if strings.HasPrefix(include, `"`) {
+			includes = append(includes, strings.Trim(include, `"`))
+		}
+	}
+	return includes
+}
+
+// undefinedReferenceRule flags bindings that use behaviors or macros that are
+// neither built into ZMK nor defined in the keymap
+type undefinedReferenceRule struct{}
+
+func (undefinedReferenceRule) ID() string { return "undefined-reference" }
+func (undefinedReferenceRule) Description() string {
+	return "bindings must reference built-in or locally defined behaviors and macros"
+}
+func (undefinedReferenceRule) DefaultSeverity() Severity { return SeverityError }
+
+func (undefinedReferenceRule) Check(ctx *LintContext) []Finding {
+	defined := definedBehaviors(ctx.Layout)
+	includes := localIncludes(ctx.Layout)
+
+	var findings []Finding
+	for _, ref := range collectReferences(ctx.Layout) {
+		if ref.Name == "" || zmkBuiltinBehaviors[ref.Name] || defined[ref.Name] {
+			continue
+		}
+		finding := Finding{
+			Message: fmt.Sprintf("%s uses undefined behavior &%s", ref.Where, ref.Name),
+			Hint:    fmt.Sprintf("define a behavior or macro labelled %q, or fix the spelling", ref.Name),
+			Line:    ref.Line,
+			Column:  ref.Column,
+		}
+		// Included files are not parsed, so the definition may live there
+		if len(includes) > 0 {
+			finding.Severity = SeverityWarning
+			finding.Hint = fmt.Sprintf("check that %s defines %q, or define it in this keymap", strings.Join(includes, ", "), ref.Name)
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// layerTargetRule flags layer behaviors whose target layer does not exist
+type layerTargetRule struct{}
+
+func (layerTargetRule) ID() string { return "layer-target" }
+func (layerTargetRule) Description() string {
+	return "&mo, &lt, &to, &tog and &sl must target a defined layer"
+}
+func (layerTargetRule) DefaultSeverity() Severity { return SeverityError }
+
+func (layerTargetRule) Check(ctx *LintContext) []Finding {
+	var findings []Finding
+	layerCount := len(ctx.Layout.Layers)
+
+	for _, ref := range collectReferences(ctx.Layout) {
+		params := bindingParams(ref.Binding)
+		if !layerBehaviors[ref.Name] || len(params) == 0 {
+			continue
+		}
+
+		index, ok := resolveLayer(ctx.Layout.Defines, params[0])
+		switch {
+		case !ok:
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("%s: %s targets unknown layer %q", ref.Where, ref.Binding, params[0]),
+				Hint:    fmt.Sprintf("#define %s to a layer index or use a number between 0 and %d", params[0], layerCount-1),
+				Line:    ref.Line,
+				Column:  ref.Column,
+			})
+		case index < 0 || index >= layerCount:
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("%s: %s targets layer %d but only %d layers are defined", ref.Where, ref.Binding, index, layerCount),
+				Hint:    fmt.Sprintf("use a layer between 0 and %d, or add the missing layer", layerCount-1),
+				Line:    ref.Line,
+				Column:  ref.Column,
+			})
+		}
+	}
+	return findings
+}
+
+// bindingCountRule flags layers whose binding count differs from the board's key count
+type bindingCountRule struct{}
+
+func (bindingCountRule) ID() string { return "binding-count" }
+func (bindingCountRule) Description() string {
+	return "every layer must have one binding per physical key"
+}
+func (bindingCountRule) DefaultSeverity() Severity { return SeverityError }
+
+func (bindingCountRule) Check(ctx *LintContext) []Finding {
+	if len(ctx.Layout.Layers) == 0 {
+		return nil
+	}
+
+	expected := len(ctx.Layout.Layers[0].Bindings)
+	source := fmt.Sprintf("layer %s", ctx.Layout.Layers[0].Name)
+	if ctx.Physical != nil {
+		expected = ctx.Physical.KeyCount()
+		source = fmt.Sprintf("%s keys", ctx.Layout.Type)
+	}
+
+	var findings []Finding
+	for _, layer := range ctx.Layout.Layers {
+		if len(layer.Bindings) == expected {
+			continue
+		}
+		findings = append(findings, Finding{
+			Message: fmt.Sprintf("layer %s has %d bindings, expected %d (%s)", layer.Name, len(layer.Bindings), expected, source),
+			Hint:    "add &trans/&none for missing keys or remove extra bindings",
+			Line:    layer.Line,
+		})
+	}
+	return findings
+}
+
+// duplicateLabelRule flags behaviors and macros that share a label
+type duplicateLabelRule struct{}
+
+func (duplicateLabelRule) ID() string { return "duplicate-label" }
+func (duplicateLabelRule) Description() string {
+	return "behavior and macro labels must be unique"
+}
+func (duplicateLabelRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (duplicateLabelRule) Check(ctx *LintContext) []Finding {
+	type labelled struct {
+		kind, name string
+		line       int
+	}
+	var items []labelled
+	for _, behavior := range ctx.Layout.Behaviors {
+		items = append(items, labelled{"behavior", behavior.Name, behavior.Line})
+	}
+	for _, macro := range ctx.Layout.Macros {
+		items = append(items, labelled{"macro", macro.Name, macro.Line})
+	}
+
+	var findings []Finding
+	seenNames := make(map[string]labelled)
+	for _, item := range items {
+		if first, ok := seenNames[item.name]; ok {
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("%s %s is already defined as a %s on line %d", item.kind, item.name, first.kind, first.line),
+				Hint:    "rename one of the definitions",
+				Line:    item.line,
+			})
+			continue
+		}
+		seenNames[item.name] = item
+	}
+
+	// The "label" property must also be unique or ZMK cannot tell the behaviors apart
+	seenLabels := make(map[string]labelled)
+	check := func(kind, name string, line int, properties map[string]interface{}) {
+		value, _ := properties["label"].(string)
+		label := strings.Trim(value, `"`)
+		if label == "" {
+			return
+		}
+		if first, ok := seenLabels[label]; ok {
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("%s %s reuses label %q from %s %s (line %d)", kind, name, label, first.kind, first.name, first.line),
+				Hint:    fmt.Sprintf("give %s a unique label", name),
+				Line:    line,
+			})
+			return
+		}
+		seenLabels[label] = labelled{kind, name, line}
+	}
+	for _, behavior := range ctx.Layout.Behaviors {
+		check("behavior", behavior.Name, behavior.Line, behavior.Properties)
+	}
+	for _, macro := range ctx.Layout.Macros {
+		check("macro", macro.Name, macro.Line, macro.Properties)
+	}
+
+	return findings
+}
+
+// unusedDefinitionRule flags behaviors and macros that nothing references
+type unusedDefinitionRule struct{}
+
+func (unusedDefinitionRule) ID() string { return "unused-definition" }
+func (unusedDefinitionRule) Description() string {
+	return "behaviors and macros should be used by a layer, combo, behavior or macro"
+}
+func (unusedDefinitionRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (unusedDefinitionRule) Check(ctx *LintContext) []Finding {
+	used := make(map[string]bool)
+	for _, ref := range collectReferences(ctx.Layout) {
+		used[ref.Name] = true
+	}
+
+	var findings []Finding
+	for _, behavior := range ctx.Layout.Behaviors {
+		if !used[behavior.Name] {
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("behavior %s is never used", behavior.Name),
+				Hint:    fmt.Sprintf("remove %s or bind it with &%s", behavior.Name, behavior.Name),
+				Line:    behavior.Line,
+			})
+		}
+	}
+	for _, macro := range ctx.Layout.Macros {
+		if !used[macro.Name] {
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("macro %s is never used", macro.Name),
+				Hint:    fmt.Sprintf("remove %s or bind it with &%s", macro.Name, macro.Name),
+				Line:    macro.Line,
+			})
+		}
+	}
+	return findings
+}
+
+// layerDefineRule flags #define layer constants that disagree with the layer order
+type layerDefineRule struct{}
+
+func (layerDefineRule) ID() string { return "layer-define" }
+func (layerDefineRule) Description() string {
+	return "#define layer constants must match the index of the layer they name"
+}
+func (layerDefineRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (layerDefineRule) Check(ctx *LintContext) []Finding {
+	layout := ctx.Layout
+
+	// Constants used as layer parameters, plus anything named LAYER_*
+	layerConstants := make(map[string]bool)
+	for name := range layout.Defines {
+		if strings.HasPrefix(name, "LAYER_") {
+			layerConstants[name] = true
+		}
+	}
+	for _, ref := range collectReferences(layout) {
+		if params := bindingParams(ref.Binding); layerBehaviors[ref.Name] && len(params) > 0 {
+			if _, ok := layout.Defines[params[0]]; ok {
+				layerConstants[params[0]] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(layerConstants))
+	for name := range layerConstants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var findings []Finding
+	for _, name := range names {
+		index, ok := resolveLayer(layout.Defines, name)
+		if !ok {
+			continue
+		}
+		line := layout.DefineLines[name]
+		if index < 0 || index >= len(layout.Layers) {
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("#define %s %d is outside the %d defined layers", name, index, len(layout.Layers)),
+				Hint:    "update the constant to the layer's current index",
+				Line:    line,
+			})
+			continue
+		}
+
+		want := strings.ToLower(strings.TrimPrefix(name, "LAYER_"))
+		layerName := strings.ToLower(layout.Layers[index].Name)
+		if strings.Contains(layerName, want) || strings.Contains(want, layerName) {
+			continue
+		}
+
+		hint := "rename the constant or reorder the layers"
+		for _, layer := range layout.Layers {
+			if strings.Contains(strings.ToLower(layer.Name), want) {
+				hint = fmt.Sprintf("layer %s is at index %d; use #define %s %d", layer.Name, layer.Index, name, layer.Index)
+				break
+			}
+		}
+		findings = append(findings, Finding{
+			Message: fmt.Sprintf("#define %s %d points at layer %s", name, index, layout.Layers[index].Name),
+			Hint:    hint,
+			Line:    line,
+		})
+	}
+	return findings
+}
+
+// unknownKeycodeRule flags keycode parameters of &kp, &mt, &lt, &sk and &kt that are not ZMK keycodes
+type unknownKeycodeRule struct{}
+
+func (unknownKeycodeRule) ID() string { return "unknown-keycode" }
+func (unknownKeycodeRule) Description() string {
+	return "&kp, &mt, &lt, &sk and &kt parameters must be ZMK keycodes or aliases"
+}
+func (unknownKeycodeRule) DefaultSeverity() Severity { return SeverityError }
+
+func (unknownKeycodeRule) Check(ctx *LintContext) []Finding {
+	var findings []Finding
+	for _, ref := range collectReferences(ctx.Layout) {
+		first, ok := keycodeBehaviors[ref.Name]
+		if !ok {
+			continue
+		}
+		params := bindingParams(ref.Binding)
+		for i := first; i < len(params); i++ {
+			unknown := unknownKeycode(params[i], ctx.Layout.Defines)
+			if unknown == "" {
+				continue
+			}
+
+			hint := "see https://zmk.dev/docs/codes for the list of keycodes"
+			if suggestions := SuggestKeycodes(unknown); len(suggestions) > 0 {
+				hint = "did you mean " + strings.Join(suggestions, ", ") + "?"
+			}
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("%s: %s uses unknown keycode %s", ref.Where, ref.Binding, unknown),
+				Hint:    hint,
+				Line:    ref.Line,
+				Column:  ref.Column,
+			})
+		}
+	}
+	return findings
+}
+
+// unreachableLayerRule flags layers that nothing activates
+type unreachableLayerRule struct{}
+
+func (unreachableLayerRule) ID() string { return "unreachable-layer" }
+func (unreachableLayerRule) Description() string {
+	return "every layer must be reachable from the default layer"
+}
+func (unreachableLayerRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (unreachableLayerRule) Check(ctx *LintContext) []Finding {
+	var findings []Finding
+	for _, index := range BuildLayerGraph(ctx.Layout).UnreachableLayers() {
+		layer := ctx.Layout.Layers[index]
+		findings = append(findings, Finding{
+			Message: fmt.Sprintf("layer %s (%d) cannot be reached from the default layer", layer.Name, index),
+			Hint:    fmt.Sprintf("bind &mo %d, &lt %d or &to %d on a reachable layer, or remove the layer", index, index, index),
+			Line:    layer.Line,
+		})
+	}
+	return findings
+}
+
+// oneWayLayerRule flags &to transitions into layers with no way back
+type oneWayLayerRule struct{}
+
+func (oneWayLayerRule) ID() string { return "one-way-layer" }
+func (oneWayLayerRule) Description() string {
+	return "a layer entered with &to must offer a way back to the default layer"
+}
+func (oneWayLayerRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (oneWayLayerRule) Check(ctx *LintContext) []Finding {
+	graph := BuildLayerGraph(ctx.Layout)
+	var findings []Finding
+	for _, edge := range graph.OneWayEdges() {
+		findings = append(findings, Finding{
+			Message: fmt.Sprintf("%s on layer %s (key %s) switches to layer %s, which has no way back",
+				edge.Binding, graph.LayerName(edge.From), edge.Key, graph.LayerName(edge.To)),
+			Hint:   fmt.Sprintf("add &to 0 to layer %s, or leave the key under it &trans", graph.LayerName(edge.To)),
+			Line:   edge.Line,
+			Column: edge.Column,
+		})
+	}
+	return findings
+}
+
+// selfActivationRule flags bindings that activate the layer they are on
+type selfActivationRule struct{}
+
+func (selfActivationRule) ID() string { return "self-activation" }
+func (selfActivationRule) Description() string {
+	return "a layer's activating key should be &trans on the layer itself"
+}
+func (selfActivationRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (selfActivationRule) Check(ctx *LintContext) []Finding {
+	graph := BuildLayerGraph(ctx.Layout)
+	var findings []Finding
+	for _, edge := range graph.SelfActivations() {
+		findings = append(findings, Finding{
+			Message: fmt.Sprintf("layer %s activates itself with %s at key %s", graph.LayerName(edge.From), edge.Binding, edge.Key),
+			Hint:    "use &trans so the key falls through to the binding that activated the layer",
+			Line:    edge.Line,
+			Column:  edge.Column,
+		})
+	}
+	return findings
+}
+
+// zmkDefaultComboTimeout is ZMK's combo timeout-ms when a combo does not set one
+const zmkDefaultComboTimeout = 50
+
+// keyName returns the logical name of a key position, e.g. 52 -> "L6"
+func keyName(ctx *LintContext, index int) string {
+	if ctx.Physical != nil {
+		if positions := ctx.Physical.Positions(); index >= 0 && index < len(positions) {
+			return positions[index].KeyID
+		}
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// keyNames returns the logical names of key positions, e.g. "L6+R6"
+func keyNames(ctx *LintContext, indices []int) string {
+	names := make([]string, len(indices))
+	for i, index := range indices {
+		names[i] = keyName(ctx, index)
+	}
+	return strings.Join(names, "+")
+}
+
+// comboLayers returns the layers a combo is active on; no layers means all
+func comboLayers(layout *models.KeyboardLayout, combo models.Combo) []int {
+	if len(combo.Layers) > 0 || len(combo.UnresolvedLayers) > 0 {
+		return combo.Layers
+	}
+	layers := make([]int, len(layout.Layers))
+	for i := range layout.Layers {
+		layers[i] = i
+	}
+	return layers
+}
+
+// propertyInt reads a single-cell integer property such as "<200>"
+func propertyInt(properties map[string]interface{}, name string) (int, bool) {
+	value, _ := properties[name].(string)
+	cells := splitCells(value)
+	if len(cells) != 1 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(cells[0])
+	return n, err == nil
+}
+
+// comboConflictRule flags combos with the same keys on overlapping layers
+type comboConflictRule struct{}
+
+func (comboConflictRule) ID() string { return "combo-conflict" }
+func (comboConflictRule) Description() string {
+	return "combos on overlapping layers must not use the same key positions"
+}
+func (comboConflictRule) DefaultSeverity() Severity { return SeverityError }
+
+func (comboConflictRule) Check(ctx *LintContext) []Finding {
+	combos := ctx.Layout.Combos
+	positionSet := func(combo models.Combo) string {
+		positions := append([]int(nil), combo.KeyPositions...)
+		sort.Ints(positions)
+		return fmt.Sprint(positions)
+	}
+
+	var findings []Finding
+	for i := range combos {
+		for j := 0; j < i; j++ {
+			// Without every layer cell we can't tell whether the combos overlap
+			if len(combos[i].UnresolvedLayers) > 0 || len(combos[j].UnresolvedLayers) > 0 {
+				continue
+			}
+			if positionSet(combos[i]) != positionSet(combos[j]) {
+				continue
+			}
+
+			active := make(map[int]bool)
+			for _, layer := range comboLayers(ctx.Layout, combos[j]) {
+				active[layer] = true
+			}
+			var shared []string
+			for _, layer := range comboLayers(ctx.Layout, combos[i]) {
+				if active[layer] && layer >= 0 && layer < len(ctx.Layout.Layers) {
+					shared = append(shared, ctx.Layout.Layers[layer].Name)
+				}
+			}
+			if len(shared) == 0 {
+				continue
+			}
+
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("combo %s uses the same keys (%s) as combo %s on layers %s",
+					combos[i].Name, keyNames(ctx, combos[i].KeyPositions), combos[j].Name, strings.Join(shared, ", ")),
+				Hint: "restrict one of the combos with layers = <...> or change its key-positions",
+				Line: combos[i].Line,
+			})
+		}
+	}
+	return findings
+}
+
+// comboTimingRule flags combos on hold-tap keys whose tapping term is shorter
+// than the combo timeout, so the hold fires before the combo can complete
+type comboTimingRule struct{}
+
+func (comboTimingRule) ID() string { return "combo-timing" }
+func (comboTimingRule) Description() string {
+	return "hold-taps under a combo need a tapping term longer than the combo timeout"
+}
+func (comboTimingRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (comboTimingRule) Check(ctx *LintContext) []Finding {
+	tappingTerms := make(map[string]int)
+	for _, behavior := range ctx.Layout.Behaviors {
+		if !strings.HasSuffix(behavior.Type, "hold-tap") {
+			continue
+		}
+		if term, ok := propertyInt(behavior.Properties, "tapping-term-ms"); ok {
+			tappingTerms[behavior.Name] = term
+		}
+	}
+
+	var findings []Finding
+	for _, combo := range ctx.Layout.Combos {
+		timeout := combo.Timeout
+		if timeout == 0 {
+			timeout = zmkDefaultComboTimeout
+		}
+
+		reported := make(map[int]bool)
+		for _, layerIndex := range comboLayers(ctx.Layout, combo) {
+			if layerIndex < 0 || layerIndex >= len(ctx.Layout.Layers) {
+				continue
+			}
+			layer := ctx.Layout.Layers[layerIndex]
+			for _, position := range combo.KeyPositions {
+				if reported[position] || position < 0 || position >= len(layer.Bindings) {
+					continue
+				}
+				binding := layer.Bindings[position].Value
+				term, ok := tappingTerms[bindingName(binding)]
+				if !ok || term >= timeout {
+					continue
+				}
+				reported[position] = true
+				findings = append(findings, Finding{
+					Message: fmt.Sprintf("combo %s (timeout %dms) includes key %s, bound to %s on layer %s with a %dms tapping term",
+						combo.Name, timeout, keyName(ctx, position), binding, layer.Name, term),
+					Hint: fmt.Sprintf("lower the combo's timeout-ms below %d or raise tapping-term-ms of %s", term, bindingName(binding)),
+					Line: combo.Line,
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// comboPositionRule flags combo key positions that do not exist on the board
+type comboPositionRule struct{}
+
+func (comboPositionRule) ID() string { return "combo-position" }
+func (comboPositionRule) Description() string {
+	return "combo key-positions must be within the board's key count"
+}
+func (comboPositionRule) DefaultSeverity() Severity { return SeverityError }
+
+func (comboPositionRule) Check(ctx *LintContext) []Finding {
+	keyCount := 0
+	if ctx.Physical != nil {
+		keyCount = ctx.Physical.KeyCount()
+	} else if len(ctx.Layout.Layers) > 0 {
+		keyCount = len(ctx.Layout.Layers[0].Bindings)
+	}
+	if keyCount == 0 {
+		return nil
+	}
+
+	var findings []Finding
+	for _, combo := range ctx.Layout.Combos {
+		var valid []int
+		var invalid []string
+		for _, position := range combo.KeyPositions {
+			if position < 0 || position >= keyCount {
+				invalid = append(invalid, strconv.Itoa(position))
+			} else {
+				valid = append(valid, position)
+			}
+		}
+		if len(invalid) == 0 {
+			continue
+		}
+
+		message := fmt.Sprintf("combo %s uses key position %s but %s has %d keys (0-%d)",
+			combo.Name, strings.Join(invalid, ", "), ctx.Layout.Name, keyCount, keyCount-1)
+		if len(valid) > 0 {
+			message += fmt.Sprintf("; other keys: %s", keyNames(ctx, valid))
+		}
+		findings = append(findings, Finding{
+			Message: message,
+			Hint:    "key-positions are zero-based indices into the layer bindings",
+			Line:    combo.Line,
+		})
+	}
+	return findings
+}
@@ -4,118 +4,176 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 
 	"masters3d.com/keyboard_layout_config_mapper/internal/models"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
 )
 
 // Parser interface defines methods that all keyboard parsers must implement
 type Parser interface {
 	Parse(filePath string) (*models.KeyboardLayout, error)
+	ParseContent(filePath string, content []byte) (*models.KeyboardLayout, error)
 	Validate(filePath string) error
 	GetKeyboardType() models.KeyboardType
 }
 
-// NewParser creates a parser based on the keyboard type
+// NewParser creates a parser for a registered keyboard
 func NewParser(keyboardType models.KeyboardType) (Parser, error) {
-	switch keyboardType {
-	case models.KeyboardZMKAdv360, models.KeyboardZMKGlove80, models.KeyboardZMKAdvMod:
+	kb, err := registry.Current().Lookup(string(keyboardType))
+	if err != nil {
+		return nil, err
+	}
+	switch kb.Type {
+	case "zmk":
 		return NewZMKParser(keyboardType), nil
 	default:
-		return nil, fmt.Errorf("unsupported keyboard type: %s", keyboardType)
+		return nil, fmt.Errorf("unsupported keyboard type: %s", kb.Type)
 	}
 }
 
 // GetConfigPath returns the configuration file path for a keyboard type
 func GetConfigPath(keyboardType models.KeyboardType) (string, error) {
-	configsDir := "configs"
-	
-	switch keyboardType {
-	case models.KeyboardZMKAdv360:
-		return filepath.Join(configsDir, "zmk_adv360", "adv360.keymap"), nil
-	case models.KeyboardZMKGlove80:
-		return filepath.Join(configsDir, "zmk_glove80", "glove80.keymap"), nil
-	case models.KeyboardZMKAdvMod:
-		return filepath.Join(configsDir, "zmk_adv_mod", "pillzmod_pro.keymap"), nil
-	default:
-		return "", fmt.Errorf("unsupported keyboard type: %s", keyboardType)
+	kb, err := registry.Current().Lookup(string(keyboardType))
+	if err != nil {
+		return "", err
 	}
+	return filepath.FromSlash(kb.LocalPath), nil
 }
 
 // Validator provides validation functionality for keyboard configurations
-type Validator struct{}
+type Validator struct {
+	// LintConfig enables, disables or re-grades lint rules per keyboard
+	LintConfig LintConfig
+	// CompileConfig selects the builder used by compile checks
+	CompileConfig CompileConfig
+}
 
 // NewValidator creates a new validator instance
 func NewValidator() *Validator {
 	return &Validator{}
 }
 
-// ValidateAll validates all keyboard configurations
-func (v *Validator) ValidateAll(compileCheck bool) error {
-	keyboards := []models.KeyboardType{
-		models.KeyboardZMKAdv360,
-		models.KeyboardZMKGlove80,
-		models.KeyboardZMKAdvMod,
-	}
+// ValidationResult is the outcome of validating one keyboard configuration
+type ValidationResult struct {
+	Keyboard string    `json:"keyboard"`
+	File     string    `json:"file"`
+	Findings []Finding `json:"findings"`
+}
 
-	var errors []string
-	
-	for _, keyboard := range keyboards {
-		err := v.ValidateKeyboard(string(keyboard), compileCheck)
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("%s: %v", keyboard, err))
-		} else {
-			fmt.Printf("✅ %s configuration is valid\n", keyboard)
+// Count returns the number of findings with the given severity
+func (r ValidationResult) Count(severity Severity) int {
+	count := 0
+	for _, finding := range r.Findings {
+		if finding.Severity == severity {
+			count++
 		}
 	}
+	return count
+}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("validation errors:\n%v", errors)
+// Keyboards returns the keyboards ValidateAll checks
+func Keyboards() []models.KeyboardType {
+	var keyboards []models.KeyboardType
+	for _, name := range registry.Current().Names() {
+		keyboards = append(keyboards, models.KeyboardType(name))
 	}
+	return keyboards
+}
 
-	fmt.Println("🎉 All keyboard configurations are valid!")
-	return nil
+// ValidateAll validates all keyboard configurations
+func (v *Validator) ValidateAll(compileCheck bool) []ValidationResult {
+	var results []ValidationResult
+	for _, keyboard := range Keyboards() {
+		results = append(results, v.ValidateKeyboard(string(keyboard), compileCheck))
+	}
+	return results
 }
 
-// ValidateKeyboard validates a specific keyboard configuration
-func (v *Validator) ValidateKeyboard(keyboard string, compileCheck bool) error {
+// ValidateKeyboard validates a specific keyboard configuration. Problems that
+// stop the keymap from being checked at all are reported as findings too,
+// under the "config", "syntax" and "compile" rule IDs.
+func (v *Validator) ValidateKeyboard(keyboard string, compileCheck bool) ValidationResult {
 	keyboardType := models.KeyboardType(keyboard)
-	
+	result := ValidationResult{Keyboard: keyboard, Findings: []Finding{}}
+
+	fail := func(ruleID string, err error) ValidationResult {
+		finding := Finding{
+			RuleID:   ruleID,
+			Severity: SeverityError,
+			Message:  err.Error(),
+			Keyboard: keyboard,
+			File:     result.File,
+		}
+		// Syntax errors look like "failed to parse <file>: line 12: ..."
+		if match := syntaxErrorLine.FindStringSubmatch(finding.Message); match != nil {
+			finding.Line, _ = strconv.Atoi(match[1])
+			finding.Message = match[2]
+		}
+		result.Findings = append(result.Findings, finding)
+		return result
+	}
+
 	configPath, err := GetConfigPath(keyboardType)
 	if err != nil {
-		return err
+		return fail("config", err)
 	}
+	result.File = configPath
 
 	// Check if file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return fmt.Errorf("configuration file not found: %s", configPath)
+		result = fail("config", fmt.Errorf("configuration file not found: %s", configPath))
+		result.Findings[len(result.Findings)-1].Hint = fmt.Sprintf("run 'klcm pull %s' to fetch it", keyboard)
+		return result
 	}
 
 	// Create parser and validate
 	parser, err := NewParser(keyboardType)
 	if err != nil {
-		return err
+		return fail("config", err)
 	}
 
-	err = parser.Validate(configPath)
+	// Parse first: its errors carry the line of the problem, while the
+	// legacy checks in Validate only know that braces don't balance
+	layout, err := parser.Parse(configPath)
 	if err != nil {
-		return fmt.Errorf("validation failed: %v", err)
+		return fail("syntax", err)
 	}
 
+	if err := parser.Validate(configPath); err != nil {
+		return fail("syntax", err)
+	}
+
+	result.Findings = append(result.Findings, NewDefaultLinter(v.LintConfig).Lint(layout)...)
+
 	if compileCheck {
-		return v.validateCompilation(keyboardType, configPath)
+		findings, err := v.validateCompilation(keyboard, configPath)
+		if err != nil {
+			return fail("compile", err)
+		}
+		result.Findings = append(result.Findings, findings...)
 	}
 
-	return nil
+	return result
 }
 
-// validateCompilation attempts to validate that the configuration can be compiled
-func (v *Validator) validateCompilation(keyboardType models.KeyboardType, configPath string) error {
-	switch keyboardType {
-	case models.KeyboardZMKAdv360, models.KeyboardZMKGlove80, models.KeyboardZMKAdvMod:
-		// For ZMK, compilation happens via GitHub Actions on the firmware repos
-		fmt.Printf("⚠️  Local compilation check not implemented - use GitHub Actions\n")
-		return nil
-	default:
-		return fmt.Errorf("compilation check not supported for %s", keyboardType)
+var syntaxErrorLine = regexp.MustCompile(`(?:^|: )line (\d+): (.*)$`)
+
+// validateCompilation checks that the configuration can be compiled, using
+// the configured builder or the built-in structural check
+func (v *Validator) validateCompilation(keyboard, configPath string) ([]Finding, error) {
+	compiler := v.CompileConfig.CompilerFor(keyboard)
+	findings, err := compiler.Compile(keyboard, configPath)
+	if err != nil {
+		return nil, err
 	}
-}
\ No newline at end of file
+	for i := range findings {
+		findings[i].RuleID = "compile"
+		findings[i].Keyboard = keyboard
+		if findings[i].File == "" {
+			findings[i].File = configPath
+		}
+	}
+	return findings, nil
+}
@@ -0,0 +1,75 @@
+package parsers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+)
+
+// useKeymap points the glove80 keyboard at a temporary keymap for one test
+func useKeymap(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "glove80.keymap")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := registry.Current()
+	registry.SetCurrent(registry.New(registry.Keyboard{Name: "glove80", Type: "zmk", LocalPath: path}))
+	t.Cleanup(func() { registry.SetCurrent(previous) })
+	return path
+}
+
+func TestValidateKeyboardSyntaxErrorLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		keymap  string
+		line    int
+		message string
+	}{
+		{
+			name:    "node never closed",
+			keymap:  "/ {\n\tkeymap {\n\t\tcompatible = \"zmk,keymap\";\n",
+			line:    2,
+			message: `node "keymap" is never closed`,
+		},
+		{
+			name:    "unexpected closing brace",
+			keymap:  "/ {\n};\n};\n",
+			line:    3,
+			message: "unexpected '}'",
+		},
+		{
+			name:    "missing semicolon",
+			keymap:  "/ {\n\tkeymap {\n\t\tcompatible = \"zmk,keymap\"\n\t};\n};\n",
+			line:    3,
+			message: `missing ';' after "compatible = \"zmk,keymap\""`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useKeymap(t, tt.keymap)
+
+			result := NewValidator().ValidateKeyboard("glove80", false)
+			if len(result.Findings) != 1 {
+				t.Fatalf("findings = %+v, want one", result.Findings)
+			}
+			finding := result.Findings[0]
+			if finding.RuleID != "syntax" || finding.Severity != SeverityError {
+				t.Errorf("finding = %s/%s, want syntax/%s", finding.RuleID, finding.Severity, SeverityError)
+			}
+			if finding.File != path {
+				t.Errorf("file = %q, want %q", finding.File, path)
+			}
+			if finding.Line != tt.line {
+				t.Errorf("line = %d, want %d (message %q)", finding.Line, tt.line, finding.Message)
+			}
+			if finding.Message != tt.message {
+				t.Errorf("message = %q, want %q", finding.Message, tt.message)
+			}
+		})
+	}
+}
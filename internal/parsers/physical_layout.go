@@ -0,0 +1,174 @@
+package parsers
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/spf13/viper"
+	"masters3d.com/keyboard_layout_config_mapper/internal/models"
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+)
+
+// PhysicalLayout describes how binding indices map onto physical keys.
+// Rows are listed in binding order; each row lists its left-hand keys
+// followed by its right-hand keys.
+type PhysicalLayout struct {
+	Rows []PhysicalRow `mapstructure:"rows"`
+}
+
+// PhysicalRow is one row of bindings in a keymap
+type PhysicalRow struct {
+	Row         int      `mapstructure:"row"`          // logical row number; the home row is row 3 on every board
+	Left        int      `mapstructure:"left"`         // number of main keys on the left hand
+	LeftThumbs  []string `mapstructure:"left_thumbs"`  // thumb keys between the left main keys and the right hand
+	RightThumbs []string `mapstructure:"right_thumbs"` // thumb keys before the right main keys
+	Right       int      `mapstructure:"right"`        // number of main keys on the right hand
+}
+
+// KeyCount returns the number of bindings a layer is expected to have
+func (l PhysicalLayout) KeyCount() int {
+	count := 0
+	for _, row := range l.Rows {
+		count += row.Left + len(row.LeftThumbs) + len(row.RightThumbs) + row.Right
+	}
+	return count
+}
+
+// Positions expands the layout into one position per binding index
+func (l PhysicalLayout) Positions() []models.Position {
+	var positions []models.Position
+	for _, row := range l.Rows {
+		for col := 0; col < row.Left; col++ {
+			positions = append(positions, mainPosition(row.Row, col, "left"))
+		}
+		for _, name := range row.LeftThumbs {
+			positions = append(positions, thumbPosition(row.Row, name, "left"))
+		}
+		for _, name := range row.RightThumbs {
+			positions = append(positions, thumbPosition(row.Row, name, "right"))
+		}
+		for i := 0; i < row.Right; i++ {
+			// Right-hand columns are counted from the outer edge so they mirror the left hand
+			positions = append(positions, mainPosition(row.Row, row.Right-1-i, "right"))
+		}
+	}
+	return positions
+}
+
+func mainPosition(row, col int, side string) models.Position {
+	prefix := "L"
+	if side == "right" {
+		prefix = "R"
+	}
+	return models.Position{
+		Row:   row,
+		Col:   col,
+		Side:  side,
+		Zone:  "main",
+		KeyID: fmt.Sprintf("%s-r%dc%d", prefix, row, col),
+	}
+}
+
+func thumbPosition(row int, name, side string) models.Position {
+	return models.Position{
+		Row:   row,
+		Col:   -1,
+		Side:  side,
+		Zone:  "thumb",
+		KeyID: name,
+	}
+}
+
+// Thumb cluster numbering follows configs/THUMB_CLUSTER_MAPPING.md:
+// 1-3 on the bottom row from the thumb outwards, 4-6 above 3, 2 and 1.
+var physicalLayouts = map[string]PhysicalLayout{
+	"adv360": {Rows: []PhysicalRow{
+		{Row: 1, Left: 7, Right: 7},
+		{Row: 2, Left: 7, Right: 7},
+		{Row: 3, Left: 7, LeftThumbs: []string{"L6", "L5"}, RightThumbs: []string{"R5", "R6"}, Right: 7},
+		{Row: 4, Left: 6, LeftThumbs: []string{"L4"}, RightThumbs: []string{"R4"}, Right: 6},
+		{Row: 5, Left: 5, LeftThumbs: []string{"L1", "L2", "L3"}, RightThumbs: []string{"R3", "R2", "R1"}, Right: 5},
+	}},
+	"glove80": {Rows: []PhysicalRow{
+		{Row: 0, Left: 5, Right: 5},
+		{Row: 1, Left: 6, Right: 6},
+		{Row: 2, Left: 6, Right: 6},
+		{Row: 3, Left: 6, Right: 6},
+		{Row: 4, Left: 6, LeftThumbs: []string{"L6", "L5", "L4"}, RightThumbs: []string{"R4", "R5", "R6"}, Right: 6},
+		{Row: 5, Left: 5, LeftThumbs: []string{"L1", "L2", "L3"}, RightThumbs: []string{"R3", "R2", "R1"}, Right: 5},
+	}},
+	"adv_mod": {Rows: []PhysicalRow{
+		{Row: 0, Left: 9, Right: 9},
+		{Row: 1, Left: 6, Right: 6},
+		{Row: 2, Left: 6, Right: 6},
+		{Row: 3, Left: 6, Right: 6},
+		{Row: 4, Left: 6, Right: 6},
+		{Row: 5, Left: 4, Right: 4},
+		{Row: 6, LeftThumbs: []string{"L6", "L5"}, RightThumbs: []string{"R5", "R6"}},
+		{Row: 7, LeftThumbs: []string{"L4"}, RightThumbs: []string{"R4"}},
+		{Row: 8, LeftThumbs: []string{"L1", "L2", "L3"}, RightThumbs: []string{"R3", "R2", "R1"}},
+		{Row: 9, Left: 3},
+	}},
+}
+
+// GetPhysicalLayout returns the physical layout a keyboard's registry entry
+// names: a layout file, or a built-in layout, falling back to the built-in
+// layout named after the keyboard
+func GetPhysicalLayout(keyboardType models.KeyboardType) (PhysicalLayout, bool) {
+	name := string(keyboardType)
+	if kb, ok := registry.Current().Get(name); ok && kb.PhysicalLayout != "" {
+		if file := kb.PhysicalLayoutFile(); file != "" {
+			layout, err := loadPhysicalLayoutFile(file)
+			return layout, err == nil
+		}
+		name = kb.PhysicalLayout
+	}
+	layout, ok := physicalLayouts[name]
+	return layout, ok
+}
+
+var (
+	layoutFilesMu sync.Mutex
+	layoutFiles   = make(map[string]PhysicalLayout)
+)
+
+// loadPhysicalLayoutFile is LoadPhysicalLayoutFile cached per path, since the
+// parser looks the layout up for every binding
+func loadPhysicalLayoutFile(path string) (PhysicalLayout, error) {
+	layoutFilesMu.Lock()
+	defer layoutFilesMu.Unlock()
+	if layout, ok := layoutFiles[path]; ok {
+		return layout, nil
+	}
+	layout, err := LoadPhysicalLayoutFile(path)
+	if err != nil {
+		return PhysicalLayout{}, err
+	}
+	layoutFiles[path] = layout
+	return layout, nil
+}
+
+// LoadPhysicalLayoutFile reads a physical layout from a YAML or JSON file
+// listing rows in binding order, e.g. for a 42-key Corne:
+//
+//	rows:
+//	  - {row: 1, left: 6, right: 6}
+//	  - {row: 2, left: 6, right: 6}
+//	  - {row: 3, left: 6, right: 6}
+//	  - {row: 5, left_thumbs: [L1, L2, L3], right_thumbs: [R3, R2, R1]}
+func LoadPhysicalLayoutFile(path string) (PhysicalLayout, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return PhysicalLayout{}, fmt.Errorf("failed to read physical layout %s: %w", path, err)
+	}
+
+	var layout PhysicalLayout
+	if err := v.Unmarshal(&layout); err != nil {
+		return PhysicalLayout{}, fmt.Errorf("invalid physical layout %s: %w", path, err)
+	}
+	if layout.KeyCount() == 0 {
+		return PhysicalLayout{}, fmt.Errorf("physical layout %s has no keys", path)
+	}
+	return layout, nil
+}
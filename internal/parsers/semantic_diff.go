@@ -0,0 +1,333 @@
+package parsers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/models"
+)
+
+// ChangeKind describes how a definition changed between two layouts
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// LayoutDiff is a semantic comparison of two parsed keyboard layouts.
+// Formatting-only differences (whitespace, comments, grid alignment) never
+// show up here because both sides are compared after parsing.
+type LayoutDiff struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	AddedLayers     []string           `json:"added_layers,omitempty"`
+	RemovedLayers   []string           `json:"removed_layers,omitempty"`
+	KeyChanges      []KeyChange        `json:"key_changes,omitempty"`
+	BehaviorChanges []DefinitionChange `json:"behavior_changes,omitempty"`
+	ComboChanges    []DefinitionChange `json:"combo_changes,omitempty"`
+	MacroChanges    []DefinitionChange `json:"macro_changes,omitempty"`
+}
+
+// KeyChange is a binding that differs on one logical key of one layer
+type KeyChange struct {
+	Layer  string `json:"layer"`
+	Key    string `json:"key"`   // logical key, e.g. "L1" or "L-r3c1"
+	Index  int    `json:"index"` // binding index in the "to" layout (or "from" if removed)
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// DefinitionChange describes an added, removed or modified behavior, combo or macro
+type DefinitionChange struct {
+	Kind       ChangeKind       `json:"kind"`
+	Name       string           `json:"name"`
+	Properties []PropertyChange `json:"properties,omitempty"`
+}
+
+// PropertyChange is a single property that differs between two definitions
+type PropertyChange struct {
+	Property string `json:"property"`
+	Before   string `json:"before,omitempty"`
+	After    string `json:"after,omitempty"`
+}
+
+// IsEmpty reports whether the two layouts are semantically identical
+func (d *LayoutDiff) IsEmpty() bool {
+	return len(d.AddedLayers) == 0 && len(d.RemovedLayers) == 0 && len(d.KeyChanges) == 0 &&
+		len(d.BehaviorChanges) == 0 && len(d.ComboChanges) == 0 && len(d.MacroChanges) == 0
+}
+
+// LayersTouched returns the names of layers that were added, removed or had key changes
+func (d *LayoutDiff) LayersTouched() []string {
+	seen := make(map[string]bool)
+	var layers []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			layers = append(layers, name)
+		}
+	}
+	for _, name := range d.AddedLayers {
+		add(name)
+	}
+	for _, name := range d.RemovedLayers {
+		add(name)
+	}
+	for _, change := range d.KeyChanges {
+		add(change.Layer)
+	}
+	return layers
+}
+
+// Summary returns a one-line description of the diff, e.g. "2 layers changed, 5 keys changed"
+func (d *LayoutDiff) Summary() string {
+	if d.IsEmpty() {
+		return "no semantic changes"
+	}
+
+	var parts []string
+	count := func(n int, singular, plural string) {
+		if n == 1 {
+			parts = append(parts, fmt.Sprintf("1 %s", singular))
+		} else if n > 1 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, plural))
+		}
+	}
+	count(len(d.LayersTouched()), "layer changed", "layers changed")
+	count(len(d.KeyChanges), "key changed", "keys changed")
+	count(len(d.BehaviorChanges), "behavior changed", "behaviors changed")
+	count(len(d.ComboChanges), "combo changed", "combos changed")
+	count(len(d.MacroChanges), "macro changed", "macros changed")
+
+	return strings.Join(parts, ", ")
+}
+
+// DiffLayouts compares two parsed layouts. Layers are matched by name and
+// keys by their logical position, so the two layouts may come from different
+// keyboards.
+func DiffLayouts(from, to *models.KeyboardLayout) *LayoutDiff {
+	diff := &LayoutDiff{
+		From: from.FilePath,
+		To:   to.FilePath,
+	}
+
+	fromLayers := layersByKey(from.Layers)
+	toLayers := layersByKey(to.Layers)
+
+	for _, key := range layerKeys(from.Layers) {
+		if _, ok := toLayers[key]; !ok {
+			diff.RemovedLayers = append(diff.RemovedLayers, key)
+		}
+	}
+	for _, key := range layerKeys(to.Layers) {
+		toLayer := toLayers[key]
+		fromLayer, ok := fromLayers[key]
+		if !ok {
+			diff.AddedLayers = append(diff.AddedLayers, key)
+			continue
+		}
+		diff.KeyChanges = append(diff.KeyChanges, diffLayerBindings(key, fromLayer, toLayer)...)
+	}
+
+	diff.BehaviorChanges = diffDefinitions(behaviorDefinitions(from), behaviorDefinitions(to))
+	diff.ComboChanges = diffDefinitions(comboDefinitions(from), comboDefinitions(to))
+	diff.MacroChanges = diffDefinitions(macroDefinitions(from), macroDefinitions(to))
+
+	return diff
+}
+
+// layerKeys returns a unique key for each layer: its name, with an occurrence
+// suffix when several layers share a name (e.g. "padding", "padding#2")
+func layerKeys(layers []models.Layer) []string {
+	counts := make(map[string]int)
+	keys := make([]string, 0, len(layers))
+	for _, layer := range layers {
+		counts[layer.Name]++
+		key := layer.Name
+		if counts[layer.Name] > 1 {
+			key = fmt.Sprintf("%s#%d", layer.Name, counts[layer.Name])
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func layersByKey(layers []models.Layer) map[string]models.Layer {
+	byKey := make(map[string]models.Layer)
+	for i, key := range layerKeys(layers) {
+		byKey[key] = layers[i]
+	}
+	return byKey
+}
+
+func diffLayerBindings(layerKey string, from, to models.Layer) []KeyChange {
+	fromKeys := make(map[string]models.KeyBinding)
+	for _, binding := range from.Bindings {
+		fromKeys[binding.Position.KeyID] = binding
+	}
+
+	var changes []KeyChange
+	seen := make(map[string]bool)
+	for _, binding := range to.Bindings {
+		keyID := binding.Position.KeyID
+		seen[keyID] = true
+		before, ok := fromKeys[keyID]
+		if ok && NormalizeBinding(before.Value) == NormalizeBinding(binding.Value) {
+			continue
+		}
+		change := KeyChange{Layer: layerKey, Key: keyID, Index: binding.Index, After: binding.Value}
+		if ok {
+			change.Before = before.Value
+		}
+		changes = append(changes, change)
+	}
+	for _, binding := range from.Bindings {
+		if !seen[binding.Position.KeyID] {
+			changes = append(changes, KeyChange{
+				Layer:  layerKey,
+				Key:    binding.Position.KeyID,
+				Index:  binding.Index,
+				Before: binding.Value,
+			})
+		}
+	}
+
+	return changes
+}
+
+// NormalizeBinding returns the canonical form of a binding used for comparisons.
+// Whitespace is collapsed and keycode aliases are resolved, so "&kp LSHFT" and
+// "&kp LEFT_SHIFT" normalize to the same string.
+func NormalizeBinding(binding string) string {
+	return strings.Join(normalizeKeycodes(strings.Fields(binding)), " ")
+}
+
+// definition is a named set of properties compared generically
+type definition struct {
+	name       string
+	properties map[string]string
+}
+
+func behaviorDefinitions(layout *models.KeyboardLayout) []definition {
+	var defs []definition
+	for _, behavior := range layout.Behaviors {
+		props := stringProperties(behavior.Properties)
+		props["compatible"] = behavior.Type
+		defs = append(defs, definition{name: behavior.Name, properties: props})
+	}
+	return defs
+}
+
+func comboDefinitions(layout *models.KeyboardLayout) []definition {
+	var defs []definition
+	for _, combo := range layout.Combos {
+		keys := make([]string, 0, len(combo.Keys))
+		for _, key := range combo.Keys {
+			keys = append(keys, key.KeyID)
+		}
+		var layers []string
+		for _, index := range combo.Layers {
+			layers = append(layers, layerNameForIndex(layout, index))
+		}
+		layers = append(layers, combo.UnresolvedLayers...)
+		props := map[string]string{
+			"bindings":      NormalizeBinding(combo.Binding),
+			"key-positions": strings.Join(keys, " "),
+		}
+		if len(layers) > 0 {
+			props["layers"] = strings.Join(layers, " ")
+		}
+		if combo.Timeout != 0 {
+			props["timeout-ms"] = fmt.Sprintf("%d", combo.Timeout)
+		}
+		defs = append(defs, definition{name: combo.Name, properties: props})
+	}
+	return defs
+}
+
+func macroDefinitions(layout *models.KeyboardLayout) []definition {
+	var defs []definition
+	for _, macro := range layout.Macros {
+		props := stringProperties(macro.Properties)
+		normalized := make([]string, 0, len(macro.Bindings))
+		for _, binding := range macro.Bindings {
+			normalized = append(normalized, NormalizeBinding(binding))
+		}
+		props["bindings"] = strings.Join(normalized, ", ")
+		defs = append(defs, definition{name: macro.Name, properties: props})
+	}
+	return defs
+}
+
+func layerNameForIndex(layout *models.KeyboardLayout, index int) string {
+	if index >= 0 && index < len(layout.Layers) {
+		return layout.Layers[index].Name
+	}
+	return fmt.Sprintf("%d", index)
+}
+
+func stringProperties(properties map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(properties))
+	for name, value := range properties {
+		result[name] = strings.Join(strings.Fields(fmt.Sprint(value)), " ")
+	}
+	return result
+}
+
+func diffDefinitions(from, to []definition) []DefinitionChange {
+	fromByName := make(map[string]definition)
+	for _, def := range from {
+		fromByName[def.name] = def
+	}
+	toByName := make(map[string]definition)
+	for _, def := range to {
+		toByName[def.name] = def
+	}
+
+	var changes []DefinitionChange
+	for _, def := range from {
+		if _, ok := toByName[def.name]; !ok {
+			changes = append(changes, DefinitionChange{Kind: ChangeRemoved, Name: def.name})
+		}
+	}
+	for _, def := range to {
+		before, ok := fromByName[def.name]
+		if !ok {
+			changes = append(changes, DefinitionChange{Kind: ChangeAdded, Name: def.name})
+			continue
+		}
+		if props := diffProperties(before.properties, def.properties); len(props) > 0 {
+			changes = append(changes, DefinitionChange{Kind: ChangeModified, Name: def.name, Properties: props})
+		}
+	}
+
+	return changes
+}
+
+func diffProperties(from, to map[string]string) []PropertyChange {
+	names := make(map[string]bool)
+	for name := range from {
+		names[name] = true
+	}
+	for name := range to {
+		names[name] = true
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var changes []PropertyChange
+	for _, name := range sorted {
+		if from[name] != to[name] {
+			changes = append(changes, PropertyChange{Property: name, Before: from[name], After: to[name]})
+		}
+	}
+	return changes
+}
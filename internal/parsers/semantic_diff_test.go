@@ -0,0 +1,205 @@
+package parsers
+
+import (
+	"reflect"
+	"testing"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/models"
+)
+
+// layer returns a layer whose bindings alternate key IDs and values, e.g.
+// layer("base", "L1", "&kp A", "R1", "&kp B")
+func layer(name string, keysAndValues ...string) models.Layer {
+	l := models.Layer{Name: name}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		l.Bindings = append(l.Bindings, models.KeyBinding{
+			Position: models.Position{KeyID: keysAndValues[i]},
+			Value:    keysAndValues[i+1],
+			Index:    i / 2,
+		})
+	}
+	return l
+}
+
+func layout(layers ...models.Layer) *models.KeyboardLayout {
+	return &models.KeyboardLayout{Layers: layers}
+}
+
+func withBehaviors(l *models.KeyboardLayout, behaviors ...models.Behavior) *models.KeyboardLayout {
+	l.Behaviors = behaviors
+	return l
+}
+
+func withCombos(l *models.KeyboardLayout, combos ...models.Combo) *models.KeyboardLayout {
+	l.Combos = combos
+	return l
+}
+
+func withMacros(l *models.KeyboardLayout, macros ...models.Macro) *models.KeyboardLayout {
+	l.Macros = macros
+	return l
+}
+
+func homeRowMods(tappingTerm int) models.Behavior {
+	return models.Behavior{
+		Name:       "hm",
+		Type:       "zmk,behavior-hold-tap",
+		Properties: map[string]interface{}{"tapping-term-ms": tappingTerm, "flavor": "balanced"},
+	}
+}
+
+func escCombo(binding string, keys ...string) models.Combo {
+	combo := models.Combo{Name: "combo_esc", Binding: binding, Layers: []int{0}}
+	for _, key := range keys {
+		combo.Keys = append(combo.Keys, models.Position{KeyID: key})
+	}
+	return combo
+}
+
+func emailMacro(bindings ...string) models.Macro {
+	return models.Macro{Name: "email", Bindings: bindings, Properties: map[string]interface{}{"wait-ms": 0}}
+}
+
+func TestDiffLayouts(t *testing.T) {
+	base := layer("base", "L1", "&kp A", "L2", "&kp B", "R1", "&kp C")
+
+	tests := []struct {
+		name string
+		from *models.KeyboardLayout
+		to   *models.KeyboardLayout
+		want LayoutDiff
+	}{
+		{
+			name: "identical",
+			from: layout(base, layer("nav", "L1", "&trans")),
+			to:   layout(base, layer("nav", "L1", "&trans")),
+		},
+		{
+			name: "key changed",
+			from: layout(base),
+			to:   layout(layer("base", "L1", "&kp A", "L2", "&kp X", "R1", "&kp C")),
+			want: LayoutDiff{KeyChanges: []KeyChange{{Layer: "base", Key: "L2", Index: 1, Before: "&kp B", After: "&kp X"}}},
+		},
+		{
+			name: "keys matched by key ID, not index",
+			from: layout(base),
+			to:   layout(layer("base", "R1", "&kp C", "L1", "&kp A", "L2", "&kp B")),
+		},
+		{
+			name: "keys added and removed",
+			from: layout(base),
+			to:   layout(layer("base", "L1", "&kp A", "L2", "&kp B", "R2", "&kp D")),
+			want: LayoutDiff{KeyChanges: []KeyChange{
+				{Layer: "base", Key: "R2", Index: 2, After: "&kp D"},
+				{Layer: "base", Key: "R1", Index: 2, Before: "&kp C"},
+			}},
+		},
+		{
+			name: "keycode aliases and spacing",
+			from: layout(layer("base", "L1", "&kp LSHFT", "L2", "&mt LCTRL A")),
+			to:   layout(layer("base", "L1", "&kp  LEFT_SHIFT", "L2", "&mt LEFT_CONTROL A")),
+		},
+		{
+			name: "layers matched by name, not order",
+			from: layout(base, layer("nav", "L1", "&trans")),
+			to:   layout(layer("nav", "L1", "&trans"), base),
+		},
+		{
+			name: "layers added and removed",
+			from: layout(base, layer("nav", "L1", "&trans")),
+			to:   layout(base, layer("sym", "L1", "&kp EXCL")),
+			want: LayoutDiff{AddedLayers: []string{"sym"}, RemovedLayers: []string{"nav"}},
+		},
+		{
+			name: "duplicate layer names",
+			from: layout(base, layer("padding", "L1", "&none"), layer("padding", "L1", "&none")),
+			to:   layout(base, layer("padding", "L1", "&none"), layer("padding", "L1", "&trans"), layer("padding", "L1", "&none")),
+			want: LayoutDiff{
+				AddedLayers: []string{"padding#3"},
+				KeyChanges:  []KeyChange{{Layer: "padding#2", Key: "L1", Before: "&none", After: "&trans"}},
+			},
+		},
+		{
+			name: "behavior property changed",
+			from: withBehaviors(layout(base), homeRowMods(200)),
+			to:   withBehaviors(layout(base), homeRowMods(280)),
+			want: LayoutDiff{BehaviorChanges: []DefinitionChange{{
+				Kind: ChangeModified, Name: "hm",
+				Properties: []PropertyChange{{Property: "tapping-term-ms", Before: "200", After: "280"}},
+			}}},
+		},
+		{
+			name: "behavior type changed",
+			from: withBehaviors(layout(base), homeRowMods(200)),
+			to: withBehaviors(layout(base), models.Behavior{
+				Name: "hm", Type: "zmk,behavior-tap-dance",
+				Properties: map[string]interface{}{"tapping-term-ms": 200, "flavor": "balanced"},
+			}),
+			want: LayoutDiff{BehaviorChanges: []DefinitionChange{{
+				Kind: ChangeModified, Name: "hm",
+				Properties: []PropertyChange{{Property: "compatible", Before: "zmk,behavior-hold-tap", After: "zmk,behavior-tap-dance"}},
+			}}},
+		},
+		{
+			name: "behaviors added and removed",
+			from: withBehaviors(layout(base), homeRowMods(200)),
+			to:   withBehaviors(layout(base), models.Behavior{Name: "td", Type: "zmk,behavior-tap-dance"}),
+			want: LayoutDiff{BehaviorChanges: []DefinitionChange{
+				{Kind: ChangeRemoved, Name: "hm"},
+				{Kind: ChangeAdded, Name: "td"},
+			}},
+		},
+		{
+			name: "combo binding alias",
+			from: withCombos(layout(base), escCombo("&kp ESC", "L1", "L2")),
+			to:   withCombos(layout(base), escCombo("&kp ESCAPE", "L1", "L2")),
+		},
+		{
+			name: "combo keys changed",
+			from: withCombos(layout(base), escCombo("&kp ESC", "L1", "L2")),
+			to:   withCombos(layout(base), escCombo("&kp ESC", "L1", "R1")),
+			want: LayoutDiff{ComboChanges: []DefinitionChange{{
+				Kind: ChangeModified, Name: "combo_esc",
+				Properties: []PropertyChange{{Property: "key-positions", Before: "L1 L2", After: "L1 R1"}},
+			}}},
+		},
+		{
+			name: "macro bindings changed",
+			from: withMacros(layout(base), emailMacro("&kp A", "&kp B")),
+			to:   withMacros(layout(base), emailMacro("&kp A", "&kp C")),
+			want: LayoutDiff{MacroChanges: []DefinitionChange{{
+				Kind: ChangeModified, Name: "email",
+				Properties: []PropertyChange{{Property: "bindings", Before: "&kp A, &kp B", After: "&kp A, &kp C"}},
+			}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffLayouts(tt.from, tt.to)
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("DiffLayouts() = %+v\nwant %+v", *got, tt.want)
+			}
+			if got.IsEmpty() != reflect.DeepEqual(tt.want, LayoutDiff{}) {
+				t.Errorf("IsEmpty() = %v for %+v", got.IsEmpty(), *got)
+			}
+		})
+	}
+}
+
+func TestLayoutDiffSummary(t *testing.T) {
+	diff := DiffLayouts(
+		withBehaviors(layout(layer("base", "L1", "&kp A", "L2", "&kp B"), layer("nav", "L1", "&trans")), homeRowMods(200)),
+		withBehaviors(layout(layer("base", "L1", "&kp X", "L2", "&kp Y"), layer("sym", "L1", "&trans")), homeRowMods(280)),
+	)
+
+	if got, want := diff.LayersTouched(), []string{"sym", "nav", "base"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LayersTouched() = %v, want %v", got, want)
+	}
+	if got, want := diff.Summary(), "3 layers changed, 2 keys changed, 1 behavior changed"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+	if got := (&LayoutDiff{}).Summary(); got != "no semantic changes" {
+		t.Errorf("empty Summary() = %q", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"masters3d.com/keyboard_layout_config_mapper/internal/models"
@@ -29,132 +30,77 @@ func (p *ZMKParser) GetKeyboardType() models.KeyboardType {
 
 // Parse parses a ZMK keymap file and returns a structured representation
 func (p *ZMKParser) Parse(filePath string) (*models.KeyboardLayout, error) {
-	file, err := os.Open(filePath)
+	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %v", err)
 	}
-	defer file.Close()
 
-	layout := &models.KeyboardLayout{
-		Type:     p.keyboardType,
-		FilePath: filePath,
-		Layers:   []models.Layer{},
-		Behaviors: []models.Behavior{},
-		Combos:   []models.Combo{},
-		Metadata: make(map[string]interface{}),
+	layout, err := p.ParseContent(filePath, content)
+	if err != nil {
+		return nil, err
 	}
 
-	scanner := bufio.NewScanner(file)
-	var currentSection string
-	var layerIndex int = -1
-	var inBindings bool
-	var bindingsBuffer []string
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	if info, err := os.Stat(filePath); err == nil {
+		layout.LastModified = info.ModTime()
+	}
 
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "/*") {
-			continue
-		}
+	return layout, nil
+}
 
-		// Detect sections
-		if strings.Contains(line, "keymap {") {
-			currentSection = "keymap"
-			continue
-		}
+// ParseContent parses keymap content that did not necessarily come from disk,
+// such as a file at a git revision or a remote download. filePath is only
+// recorded on the returned layout.
+func (p *ZMKParser) ParseContent(filePath string, content []byte) (*models.KeyboardLayout, error) {
+	doc, err := parseDeviceTree(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", filePath, err)
+	}
 
-		if strings.Contains(line, "behaviors {") {
-			currentSection = "behaviors"
-			continue
-		}
+	layout := &models.KeyboardLayout{
+		Type:        p.keyboardType,
+		Name:        string(p.keyboardType),
+		FilePath:    filePath,
+		Layers:      []models.Layer{},
+		Behaviors:   []models.Behavior{},
+		Combos:      []models.Combo{},
+		Macros:      []models.Macro{},
+		Defines:     doc.Defines,
+		DefineLines: doc.DefineLines,
+		Includes:    doc.Includes,
+		Metadata:    make(map[string]interface{}),
+	}
 
-		if strings.Contains(line, "combos {") {
-			currentSection = "combos"
-			continue
-		}
+	doc.Root.Walk(func(node *dtNode) {
+		compatible := strings.Trim(node.PropertyValue("compatible"), `"`)
 
-		// Parse based on current section
-		switch currentSection {
-		case "keymap":
-			if strings.Contains(line, "default_layer") || strings.Contains(line, "_layer") {
-				// Starting a new layer
-				if inBindings && len(bindingsBuffer) > 0 {
-					// Process previous layer's bindings
-					err := p.processLayerBindings(layout, layerIndex, bindingsBuffer)
-					if err != nil {
-						return nil, fmt.Errorf("error processing layer %d: %v", layerIndex, err)
-					}
-					bindingsBuffer = []string{}
-				}
-				
-				layerIndex++
-				inBindings = false
-				
-				// Extract layer name
-				layerName := p.extractLayerName(line)
-				layer := models.Layer{
-					Index:    layerIndex,
-					Name:     layerName,
-					Bindings: []models.KeyBinding{},
-					Metadata: make(map[string]interface{}),
-				}
-				layout.Layers = append(layout.Layers, layer)
+		switch {
+		case compatible == "zmk,keymap":
+			for _, child := range node.Children {
+				p.addLayer(layout, child)
 			}
-
-			if strings.Contains(line, "bindings") && strings.Contains(line, "<") {
-				inBindings = true
-				// Extract bindings from this line if they exist
-				bindingsLine := p.extractBindingsFromLine(line)
-				if bindingsLine != "" {
-					bindingsBuffer = append(bindingsBuffer, bindingsLine)
-				}
-			} else if inBindings {
-				// Collect bindings lines
-				if strings.Contains(line, ">;") {
-					// End of bindings
-					bindingsLine := p.extractBindingsFromLine(line)
-					if bindingsLine != "" {
-						bindingsBuffer = append(bindingsBuffer, bindingsLine)
-					}
-					// Process the collected bindings
-					err := p.processLayerBindings(layout, layerIndex, bindingsBuffer)
-					if err != nil {
-						return nil, fmt.Errorf("error processing layer %d: %v", layerIndex, err)
-					}
-					bindingsBuffer = []string{}
-					inBindings = false
-				} else {
-					bindingsLine := p.extractBindingsFromLine(line)
-					if bindingsLine != "" {
-						bindingsBuffer = append(bindingsBuffer, bindingsLine)
-					}
+		case compatible == "zmk,combos" || (node.Name == "combos" && compatible == ""):
+			for _, child := range node.Children {
+				layout.Combos = append(layout.Combos, p.parseCombo(child, layout.Defines))
+			}
+		case compatible == "zmk,conditional-layers":
+			for _, child := range node.Children {
+				if conditional, ok := parseConditionalLayer(child, layout.Defines); ok {
+					layout.ConditionalLayers = append(layout.ConditionalLayers, conditional)
 				}
 			}
-
-		case "behaviors":
-			// Parse custom behaviors
-			behavior := p.parseBehavior(line)
-			if behavior != nil {
-				layout.Behaviors = append(layout.Behaviors, *behavior)
+		case node.Name == "macros" && compatible == "":
+			for _, child := range node.Children {
+				layout.Macros = append(layout.Macros, parseMacro(child))
 			}
-
-		case "combos":
-			// Parse combos
-			combo := p.parseCombo(line)
-			if combo != nil {
-				layout.Combos = append(layout.Combos, *combo)
+		case strings.HasPrefix(compatible, "zmk,behavior-macro"):
+			// Macros declared outside a macros node
+			if !hasMacro(layout, node) {
+				layout.Macros = append(layout.Macros, parseMacro(node))
 			}
+		case strings.HasPrefix(compatible, "zmk,behavior-"):
+			layout.Behaviors = append(layout.Behaviors, parseBehavior(node))
 		}
-	}
-
-	// Process any remaining bindings
-	if inBindings && len(bindingsBuffer) > 0 {
-		err := p.processLayerBindings(layout, layerIndex, bindingsBuffer)
-		if err != nil {
-			return nil, fmt.Errorf("error processing final layer %d: %v", layerIndex, err)
-		}
-	}
+	})
 
 	return layout, nil
 }
@@ -196,61 +142,78 @@ func (p *ZMKParser) Validate(filePath string) error {
 
 // Helper methods for parsing
 
-func (p *ZMKParser) extractLayerName(line string) string {
-	// Extract layer name from lines like "default_layer {" or "lower_layer {"
-	re := regexp.MustCompile(`(\w+)_layer\s*{`)
-	matches := re.FindStringSubmatch(line)
-	if len(matches) > 1 {
-		return matches[1]
+// layerDisplayName derives a layer name from its node, preferring an explicit
+// display-name or label, e.g. "layer0_default" -> "default", "keypad_layer" -> "keypad"
+func layerDisplayName(node *dtNode) string {
+	for _, prop := range []string{"display-name", "label"} {
+		if value := strings.Trim(node.PropertyValue(prop), `"`); value != "" {
+			return value
+		}
 	}
-	return "unnamed"
-}
 
-func (p *ZMKParser) extractBindingsFromLine(line string) string {
-	// Extract the part between < and > or just collect the line content
-	start := strings.Index(line, "<")
-	end := strings.LastIndex(line, ">")
-	
-	if start >= 0 && end > start {
-		return strings.TrimSpace(line[start+1 : end])
-	}
-	
-	// If no brackets, return the whole line (might be a continuation)
-	trimmed := strings.TrimSpace(line)
-	if trimmed != "bindings" && trimmed != "=" && trimmed != ";" && trimmed != ">;" {
-		return trimmed
-	}
-	
-	return ""
+	name := layerNodePrefix.ReplaceAllString(node.Name, "")
+	name = strings.TrimSuffix(name, "_layer")
+	if name == "" {
+		return node.Name
+	}
+	return name
 }
 
-func (p *ZMKParser) processLayerBindings(layout *models.KeyboardLayout, layerIndex int, bindingsLines []string) error {
-	if layerIndex < 0 || layerIndex >= len(layout.Layers) {
-		return fmt.Errorf("invalid layer index: %d", layerIndex)
-	}
+var layerNodePrefix = regexp.MustCompile(`^layer\d*_`)
 
-	// Join all bindings lines and split by whitespace and commas
-	allBindings := strings.Join(bindingsLines, " ")
-	allBindings = strings.ReplaceAll(allBindings, ",", " ")
-	bindings := strings.Fields(allBindings)
+func (p *ZMKParser) addLayer(layout *models.KeyboardLayout, node *dtNode) {
+	layerIndex := len(layout.Layers)
+	layer := models.Layer{
+		Index:    layerIndex,
+		Name:     layerDisplayName(node),
+		NodeName: node.Name,
+		Line:     node.Line,
+		Bindings: []models.KeyBinding{},
+		Metadata: make(map[string]interface{}),
+	}
 
-	for i, binding := range bindings {
-		keyBinding := models.KeyBinding{
-			Position: p.getPositionForIndex(i),
-			Value:    strings.TrimSpace(binding),
-			Layer:    layerIndex,
-			Type:     p.determineBindingType(binding),
-			Metadata: make(map[string]interface{}),
+	prop, ok := node.Property("bindings")
+	if ok {
+		for i, binding := range groupBindingTokens(prop.Tokens) {
+			layer.Bindings = append(layer.Bindings, models.KeyBinding{
+				Position: p.getPositionForIndex(i),
+				Value:    binding.Text,
+				Layer:    layerIndex,
+				Type:     p.determineBindingType(binding.Text),
+				Index:    i,
+				Line:     binding.Line,
+				Column:   binding.Column,
+				Metadata: make(map[string]interface{}),
+			})
 		}
-		
-		layout.Layers[layerIndex].Bindings = append(layout.Layers[layerIndex].Bindings, keyBinding)
 	}
 
-	return nil
+	layout.Layers = append(layout.Layers, layer)
+}
+
+// groupBindingTokens joins value tokens into bindings, each starting at an "&" token
+func groupBindingTokens(tokens []dtToken) []dtToken {
+	var bindings []dtToken
+	for _, token := range tokens {
+		if strings.HasPrefix(token.Text, "&") || len(bindings) == 0 {
+			bindings = append(bindings, token)
+			continue
+		}
+		last := &bindings[len(bindings)-1]
+		last.Text += " " + token.Text
+	}
+	return bindings
 }
 
 func (p *ZMKParser) getPositionForIndex(index int) models.Position {
-	// This is a simplified position mapping - should be enhanced with actual keyboard layout
+	if physical, ok := GetPhysicalLayout(p.keyboardType); ok {
+		positions := physical.Positions()
+		if index < len(positions) {
+			return positions[index]
+		}
+	}
+
+	// This is a simplified position mapping for keys outside a known physical layout
 	row := index / 12 // Assuming roughly 12 keys per row
 	col := index % 12
 	side := "left"
@@ -286,36 +249,136 @@ func (p *ZMKParser) determineBindingType(binding string) models.BindingType {
 	if strings.Contains(binding, "&kp") {
 		return models.BindingBasic
 	}
+	if strings.HasPrefix(binding, "&macro") {
+		return models.BindingMacro
+	}
 	if strings.HasPrefix(binding, "&") {
 		return models.BindingBehavior
 	}
-	
+
 	return models.BindingBasic
 }
 
-func (p *ZMKParser) parseBehavior(line string) *models.Behavior {
-	// Basic behavior parsing - can be enhanced
-	if strings.Contains(line, ":") {
-		parts := strings.Split(line, ":")
-		if len(parts) >= 2 {
-			return &models.Behavior{
-				Name:       strings.TrimSpace(parts[0]),
-				Type:       "custom",
-				Properties: make(map[string]interface{}),
+// nodeReference returns the name bindings use to refer to a node (its label if it has one)
+func nodeReference(node *dtNode) string {
+	if node.Label != "" {
+		return node.Label
+	}
+	return node.Name
+}
+
+// nodeProperties converts node properties into a map of normalized raw values
+func nodeProperties(node *dtNode, skip ...string) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for _, prop := range node.Properties {
+		skipped := false
+		for _, name := range skip {
+			if prop.Name == name {
+				skipped = true
 			}
 		}
+		if !skipped {
+			properties[prop.Name] = strings.Join(strings.Fields(prop.Value), " ")
+		}
+	}
+	return properties
+}
+
+func parseBehavior(node *dtNode) models.Behavior {
+	return models.Behavior{
+		Name:       nodeReference(node),
+		Type:       strings.Trim(node.PropertyValue("compatible"), `"`),
+		NodeName:   node.Name,
+		Line:       node.Line,
+		Properties: nodeProperties(node, "compatible"),
 	}
-	return nil
 }
 
-func (p *ZMKParser) parseCombo(line string) *models.Combo {
-	// Basic combo parsing - can be enhanced  
-	if strings.Contains(line, "combo") {
-		return &models.Combo{
-			Name:    "parsed_combo",
-			Keys:    []models.Position{},
-			Binding: line,
+func parseMacro(node *dtNode) models.Macro {
+	var bindings []string
+	if prop, ok := node.Property("bindings"); ok {
+		for _, binding := range groupBindingTokens(prop.Tokens) {
+			bindings = append(bindings, binding.Text)
 		}
 	}
-	return nil
-}
\ No newline at end of file
+	return models.Macro{
+		Name:       nodeReference(node),
+		NodeName:   node.Name,
+		Line:       node.Line,
+		Bindings:   bindings,
+		Properties: nodeProperties(node, "bindings"),
+	}
+}
+
+func hasMacro(layout *models.KeyboardLayout, node *dtNode) bool {
+	for _, macro := range layout.Macros {
+		if macro.NodeName == node.Name && macro.Line == node.Line {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCombo reads a combo node. Layer cells may use #define constants; cells
+// that don't resolve are kept in UnresolvedLayers.
+func (p *ZMKParser) parseCombo(node *dtNode, defines map[string]string) models.Combo {
+	combo := models.Combo{
+		Name:         node.Name,
+		Keys:         []models.Position{},
+		KeyPositions: []int{},
+		Binding:      strings.Join(strings.Fields(strings.Trim(node.PropertyValue("bindings"), "<>")), " "),
+		Line:         node.Line,
+	}
+
+	for _, cell := range splitCells(node.PropertyValue("key-positions")) {
+		if index, err := strconv.Atoi(cell); err == nil {
+			combo.KeyPositions = append(combo.KeyPositions, index)
+			combo.Keys = append(combo.Keys, p.getPositionForIndex(index))
+		}
+	}
+	for _, cell := range splitCells(node.PropertyValue("layers")) {
+		if layer, ok := resolveLayer(defines, cell); ok {
+			combo.Layers = append(combo.Layers, layer)
+		} else {
+			combo.UnresolvedLayers = append(combo.UnresolvedLayers, cell)
+		}
+	}
+	if cells := splitCells(node.PropertyValue("timeout-ms")); len(cells) == 1 {
+		combo.Timeout, _ = strconv.Atoi(cells[0])
+	}
+
+	return combo
+}
+
+// parseConditionalLayer reads an if-layers/then-layer rule. Layer cells may
+// use #define constants.
+func parseConditionalLayer(node *dtNode, defines map[string]string) (models.ConditionalLayer, bool) {
+	conditional := models.ConditionalLayer{Name: node.Name, Line: node.Line}
+	for _, cell := range splitCells(node.PropertyValue("if-layers")) {
+		if index, ok := resolveLayer(defines, cell); ok {
+			conditional.IfLayers = append(conditional.IfLayers, index)
+		}
+	}
+	then := splitCells(node.PropertyValue("then-layer"))
+	if len(then) != 1 || len(conditional.IfLayers) == 0 {
+		return conditional, false
+	}
+	index, ok := resolveLayer(defines, then[0])
+	conditional.ThenLayer = index
+	return conditional, ok
+}
+
+// resolveLayer resolves a layer parameter through #define constants
+func resolveLayer(defines map[string]string, param string) (int, bool) {
+	for depth := 0; depth < 8; depth++ {
+		if index, err := strconv.Atoi(param); err == nil {
+			return index, true
+		}
+		value, ok := defines[param]
+		if !ok {
+			return 0, false
+		}
+		param = strings.Trim(strings.TrimSpace(value), "()")
+	}
+	return 0, false
+}
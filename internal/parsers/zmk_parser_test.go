@@ -0,0 +1,108 @@
+package parsers
+
+import (
+	"reflect"
+	"testing"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/models"
+)
+
+const comboKeymap = `
+#define BASE 0
+#define L_NUM 1
+#define L_ALIAS L_NUM
+
+/ {
+	combos {
+		compatible = "zmk,combos";
+		combo_num {
+			key-positions = <1 2>;
+			bindings = <&kp ESC>;
+			layers = <L_NUM>;
+		};
+		combo_base {
+			key-positions = <2 1>;
+			bindings = <&kp TAB>;
+			layers = <BASE>;
+		};
+		combo_alias {
+			key-positions = <3 4>;
+			bindings = <&kp ENTER>;
+			layers = <L_ALIAS>;
+		};
+		combo_unknown {
+			key-positions = <3 4>;
+			bindings = <&kp SPACE>;
+			layers = <L_MISSING>;
+		};
+	};
+
+	keymap {
+		compatible = "zmk,keymap";
+		base {
+			bindings = <&kp A &kp B &kp C &kp D &kp E>;
+		};
+		num {
+			bindings = <&kp N1 &kp N2 &kp N3 &kp N4 &kp N5>;
+		};
+	};
+};
+`
+
+func TestParseComboResolvesDefinedLayers(t *testing.T) {
+	layout, err := NewZMKParser(models.KeyboardZMKGlove80).ParseContent("test.keymap", []byte(comboKeymap))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	combos := make(map[string]models.Combo)
+	for _, combo := range layout.Combos {
+		combos[combo.Name] = combo
+	}
+	tests := []struct {
+		name       string
+		layers     []int
+		unresolved []string
+	}{
+		{name: "combo_num", layers: []int{1}},
+		{name: "combo_base", layers: []int{0}},
+		{name: "combo_alias", layers: []int{1}},
+		{name: "combo_unknown", unresolved: []string{"L_MISSING"}},
+	}
+	for _, tt := range tests {
+		combo, ok := combos[tt.name]
+		if !ok {
+			t.Errorf("combo %s was not parsed", tt.name)
+			continue
+		}
+		if !reflect.DeepEqual(combo.Layers, tt.layers) {
+			t.Errorf("%s layers = %v, want %v", tt.name, combo.Layers, tt.layers)
+		}
+		if !reflect.DeepEqual(combo.UnresolvedLayers, tt.unresolved) {
+			t.Errorf("%s unresolved layers = %v, want %v", tt.name, combo.UnresolvedLayers, tt.unresolved)
+		}
+	}
+}
+
+func TestComboConflictUsesDefinedLayers(t *testing.T) {
+	layout, err := NewZMKParser(models.KeyboardZMKGlove80).ParseContent("test.keymap", []byte(comboKeymap))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// combo_num and combo_base share keys on different layers, and
+	// combo_unknown can't be placed on a layer, so nothing conflicts
+	findings := NewLinter(LintConfig{}, comboConflictRule{}).Lint(layout)
+	if len(findings) != 0 {
+		t.Errorf("combo-conflict findings = %+v, want none", findings)
+	}
+
+	layout.Combos[1].Layers = []int{1}
+	findings = NewLinter(LintConfig{}, comboConflictRule{}).Lint(layout)
+	if len(findings) != 1 {
+		t.Fatalf("combo-conflict findings = %+v, want one", findings)
+	}
+	if findings[0].Severity != SeverityError {
+		t.Errorf("severity = %s, want %s", findings[0].Severity, SeverityError)
+	}
+}
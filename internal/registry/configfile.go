@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AddToConfigFile appends a keyboard to the "keyboards" section of a YAML
+// config file, creating the file if needed. The rest of the file, including
+// comments, is kept as it is.
+func AddToConfigFile(path string, kb Keyboard) error {
+	var doc yaml.Node
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(bytes.TrimSpace(content)) > 0 {
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: top level is not a mapping", path)
+	}
+
+	section := mappingValue(root, "keyboards")
+	if section == nil {
+		section = &yaml.Node{Kind: yaml.MappingNode}
+		root.Content = append(root.Content, scalarNode("keyboards"), section)
+	}
+	if section.Kind != yaml.MappingNode {
+		// e.g. "keyboards:" with no entries yet
+		*section = yaml.Node{Kind: yaml.MappingNode}
+	}
+	if mappingValue(section, kb.Name) != nil {
+		return fmt.Errorf("keyboard %s is already defined in %s", kb.Name, path)
+	}
+
+	entry := &yaml.Node{Kind: yaml.MappingNode}
+	for _, field := range [][2]string{
+		{"type", kb.Type},
+		{"description", kb.Description},
+		{"local_path", kb.LocalPath},
+		{"raw_url", kb.RawURL},
+		{"source", kb.Source},
+		{"forge", kb.Forge},
+		{"forge_url", kb.ForgeURL},
+		{"owner", kb.Owner},
+		{"repo", kb.Repo},
+		{"base_branch", kb.BaseBranch},
+		{"upstream_path", kb.UpstreamPath},
+		{"physical_layout", kb.PhysicalLayout},
+	} {
+		if field[1] != "" {
+			entry.Content = append(entry.Content, scalarNode(field[0]), scalarNode(field[1]))
+		}
+	}
+	section.Content = append(section.Content, scalarNode(kb.Name), entry)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
+
+// mappingValue returns the value of key in a YAML mapping node
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
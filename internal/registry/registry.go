@@ -0,0 +1,332 @@
+// Package registry defines the keyboards klcm manages: where each keymap
+// lives locally, where it comes from upstream and how it is laid out.
+package registry
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Keyboard is everything klcm knows about one keyboard
+type Keyboard struct {
+	Name           string `mapstructure:"-" json:"name"`
+	Type           string `mapstructure:"type" json:"type"`                       // firmware, currently always "zmk"
+	Description    string `mapstructure:"description" json:"description"`         // human-readable board name
+	LocalPath      string `mapstructure:"local_path" json:"local_path"`           // keymap path in this repository
+	RawURL         string `mapstructure:"raw_url" json:"raw_url"`                 // where pull and download fetch the keymap from
+	Source         string `mapstructure:"source" json:"source,omitempty"`         // URL template, git repository or directory overriding raw_url
+	Forge          string `mapstructure:"forge" json:"forge,omitempty"`           // "github" (default), "gitlab" or "gitea"
+	ForgeURL       string `mapstructure:"forge_url" json:"forge_url,omitempty"`   // web root of a self-hosted forge
+	Owner          string `mapstructure:"owner" json:"owner"`                     // upstream owner; a GitLab namespace may contain "/"
+	Repo           string `mapstructure:"repo" json:"repo"`                       // upstream repository
+	BaseBranch     string `mapstructure:"base_branch" json:"base_branch"`         // upstream branch PRs target
+	UpstreamPath   string `mapstructure:"upstream_path" json:"upstream_path"`     // keymap path inside the upstream repository
+	PhysicalLayout string `mapstructure:"physical_layout" json:"physical_layout"` // built-in physical layout name or layout file
+}
+
+// Dir returns the local directory holding the keymap
+func (k Keyboard) Dir() string {
+	return filepath.Dir(k.LocalPath)
+}
+
+// Filename returns the keymap file name
+func (k Keyboard) Filename() string {
+	return filepath.Base(k.LocalPath)
+}
+
+// PhysicalLayoutFile returns the physical layout file, or "" if the keyboard
+// uses a built-in layout
+func (k Keyboard) PhysicalLayoutFile() string {
+	switch strings.ToLower(filepath.Ext(k.PhysicalLayout)) {
+	case ".yaml", ".yml", ".json":
+		return filepath.FromSlash(k.PhysicalLayout)
+	}
+	return ""
+}
+
+// Forges klcm can open pull requests on
+const (
+	ForgeGitHub = "github"
+	ForgeGitLab = "gitlab"
+	ForgeGitea  = "gitea"
+)
+
+// ForgeKind returns the forge hosting the upstream repository
+func (k Keyboard) ForgeKind() string {
+	if k.Forge == "" {
+		return ForgeGitHub
+	}
+	return k.Forge
+}
+
+// ForgeWebURL returns the web root of the forge, e.g. https://gitlab.com
+func (k Keyboard) ForgeWebURL() string {
+	if k.ForgeURL != "" {
+		return strings.TrimSuffix(k.ForgeURL, "/")
+	}
+	switch k.ForgeKind() {
+	case ForgeGitHub:
+		return "https://github.com"
+	case ForgeGitLab:
+		return "https://gitlab.com"
+	}
+	return ""
+}
+
+// RepoURL returns the upstream repository URL, or "" if no repository is set
+func (k Keyboard) RepoURL() string {
+	if k.Owner == "" || k.Repo == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/%s", k.ForgeWebURL(), k.Owner, k.Repo)
+}
+
+// rawFileURL returns where the forge serves the upstream keymap at the base
+// branch
+func (k Keyboard) rawFileURL() string {
+	switch k.ForgeKind() {
+	case ForgeGitLab:
+		return fmt.Sprintf("%s/-/raw/%s/%s", k.RepoURL(), k.BaseBranch, k.UpstreamPath)
+	case ForgeGitea:
+		return fmt.Sprintf("%s/raw/branch/%s/%s", k.RepoURL(), k.BaseBranch, k.UpstreamPath)
+	}
+	if k.ForgeURL != "" {
+		// GitHub Enterprise
+		return fmt.Sprintf("%s/raw/%s/%s", k.RepoURL(), k.BaseBranch, k.UpstreamPath)
+	}
+	return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", k.Owner, k.Repo, k.BaseBranch, k.UpstreamPath)
+}
+
+// Upstream returns "owner/repo/branch/path" for display
+func (k Keyboard) Upstream() string {
+	if k.Owner == "" || k.Repo == "" {
+		return ""
+	}
+	return strings.Join([]string{k.Owner, k.Repo, k.BaseBranch, k.UpstreamPath}, "/")
+}
+
+// Validate checks that a keyboard has the fields every command relies on
+func (k Keyboard) Validate() error {
+	if k.Name == "" {
+		return fmt.Errorf("keyboard has no name")
+	}
+	if k.Type != "zmk" {
+		return fmt.Errorf("keyboard %s: unsupported type %q (only zmk is supported)", k.Name, k.Type)
+	}
+	if k.LocalPath == "" {
+		return fmt.Errorf("keyboard %s: local_path is required", k.Name)
+	}
+	switch k.ForgeKind() {
+	case ForgeGitHub, ForgeGitLab:
+	case ForgeGitea:
+		if k.ForgeURL == "" {
+			return fmt.Errorf("keyboard %s: forge_url is required for gitea", k.Name)
+		}
+	default:
+		return fmt.Errorf("keyboard %s: unsupported forge %q (use github, gitlab or gitea)", k.Name, k.Forge)
+	}
+	return nil
+}
+
+// Registry is an ordered set of keyboards
+type Registry struct {
+	keyboards []Keyboard
+}
+
+// New creates a registry from a list of keyboards
+func New(keyboards ...Keyboard) *Registry {
+	return &Registry{keyboards: keyboards}
+}
+
+// Defaults returns the keyboards klcm ships with
+func Defaults() []Keyboard {
+	return []Keyboard{
+		{
+			Name:           "adv360",
+			Type:           "zmk",
+			Description:    "Kinesis Advantage360",
+			LocalPath:      "configs/zmk_adv360/adv360.keymap",
+			RawURL:         "https://raw.githubusercontent.com/masters3d/keyboard_layout_config_mapper/v7_target/configs/zmk_adv360/adv360.keymap",
+			Owner:          "masters3d",
+			Repo:           "Adv360-Pro-ZMK",
+			BaseBranch:     "cheyo",
+			UpstreamPath:   "config/adv360.keymap",
+			PhysicalLayout: "adv360",
+		},
+		{
+			Name:           "glove80",
+			Type:           "zmk",
+			Description:    "MoErgo Glove80",
+			LocalPath:      "configs/zmk_glove80/glove80.keymap",
+			RawURL:         "https://raw.githubusercontent.com/masters3d/keyboard_layout_config_mapper/v7_target/configs/zmk_glove80/glove80.keymap",
+			Owner:          "masters3d",
+			Repo:           "glove80-zmk-config",
+			BaseBranch:     "cheyo",
+			UpstreamPath:   "config/glove80.keymap",
+			PhysicalLayout: "glove80",
+		},
+		{
+			Name:           "adv_mod",
+			Type:           "zmk",
+			Description:    "Kinesis Advantage with Pillz Mod",
+			LocalPath:      "configs/zmk_adv_mod/pillzmod_pro.keymap",
+			RawURL:         "https://raw.githubusercontent.com/masters3d/zmk-config-pillzmod-nicenano/cheyo/config/pillzmod_pro.keymap",
+			Owner:          "masters3d",
+			Repo:           "zmk-config-pillzmod-nicenano",
+			BaseBranch:     "cheyo",
+			UpstreamPath:   "config/pillzmod_pro.keymap",
+			PhysicalLayout: "adv_mod",
+		},
+	}
+}
+
+// Load builds the registry from the built-in keyboards and the "keyboards"
+// section of the config file. Entries for built-in keyboards override only
+// the fields they set; other entries add new keyboards, in name order. A
+// keyboard with a repository but no raw_url is fetched from its forge's raw
+// file URL (raw.githubusercontent.com for GitHub).
+//
+//	keyboards:
+//	  glove80:
+//	    raw_url: https://raw.githubusercontent.com/me/glove80-zmk-config/main/config/glove80.keymap
+//	  corne:
+//	    type: zmk
+//	    local_path: configs/zmk_corne/corne.keymap
+//	  lily58:
+//	    local_path: configs/zmk_lily58/lily58.keymap
+//	    forge: gitlab                       # or gitea; default github
+//	    forge_url: https://git.example.com  # default https://gitlab.com for gitlab
+//	    owner: team/keyboards
+//	    repo: zmk-config
+func Load(v *viper.Viper) (*Registry, error) {
+	keyboards := Defaults()
+	index := make(map[string]int)
+	for i, kb := range keyboards {
+		index[kb.Name] = i
+	}
+
+	var names []string
+	for name := range v.GetStringMap("keyboards") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		kb := Keyboard{Type: "zmk"}
+		i, builtin := index[name]
+		if builtin {
+			kb = keyboards[i]
+		}
+		if err := v.UnmarshalKey("keyboards."+name, &kb); err != nil {
+			return nil, fmt.Errorf("invalid keyboards.%s: %w", name, err)
+		}
+		kb.Name = name
+		if kb.PhysicalLayout == "" {
+			kb.PhysicalLayout = name
+		}
+		if kb.UpstreamPath == "" && kb.LocalPath != "" {
+			// ZMK user config repositories keep keymaps under config/
+			kb.UpstreamPath = "config/" + kb.Filename()
+		}
+		if kb.BaseBranch == "" {
+			kb.BaseBranch = "main"
+		}
+		if kb.RawURL == "" && kb.RepoURL() != "" {
+			kb.RawURL = kb.rawFileURL()
+		}
+		if err := kb.Validate(); err != nil {
+			return nil, err
+		}
+
+		if builtin {
+			keyboards[i] = kb
+		} else {
+			index[name] = len(keyboards)
+			keyboards = append(keyboards, kb)
+		}
+	}
+
+	return New(keyboards...), nil
+}
+
+// All returns every keyboard in registry order
+func (r *Registry) All() []Keyboard {
+	return append([]Keyboard(nil), r.keyboards...)
+}
+
+// Names returns every keyboard name in registry order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.keyboards))
+	for _, kb := range r.keyboards {
+		names = append(names, kb.Name)
+	}
+	return names
+}
+
+// Get returns the keyboard with the given name
+func (r *Registry) Get(name string) (Keyboard, bool) {
+	for _, kb := range r.keyboards {
+		if kb.Name == name {
+			return kb, true
+		}
+	}
+	return Keyboard{}, false
+}
+
+// Lookup is Get with an error naming the known keyboards
+func (r *Registry) Lookup(name string) (Keyboard, error) {
+	if kb, ok := r.Get(name); ok {
+		return kb, nil
+	}
+	return Keyboard{}, fmt.Errorf("unknown keyboard: %s (known: %s)", name, strings.Join(r.Names(), ", "))
+}
+
+// Select returns the named keyboards, or every keyboard if names is empty
+func (r *Registry) Select(names []string) ([]Keyboard, error) {
+	if len(names) == 0 {
+		return r.All(), nil
+	}
+	var selected []Keyboard
+	for _, name := range names {
+		kb, err := r.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, kb)
+	}
+	return selected, nil
+}
+
+// ByPath returns the keyboard whose keymap is at path, matching by file name
+// if no keyboard has exactly that path
+func (r *Registry) ByPath(path string) (Keyboard, bool) {
+	clean := filepath.Clean(path)
+	for _, kb := range r.keyboards {
+		if filepath.Clean(kb.LocalPath) == clean {
+			return kb, true
+		}
+	}
+	for _, kb := range r.keyboards {
+		if kb.Filename() == filepath.Base(path) {
+			return kb, true
+		}
+	}
+	return Keyboard{}, false
+}
+
+var current = New(Defaults()...)
+
+// Current returns the registry loaded at startup, or the built-in keyboards
+// before the config file has been read
+func Current() *Registry {
+	return current
+}
+
+// SetCurrent replaces the registry returned by Current
+func SetCurrent(r *Registry) {
+	current = r
+}
@@ -0,0 +1,208 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Auth supplies the GitHub token sent with requests to GitHub hosts, so keymaps
+// in private repositories can be fetched. The token is taken from, in order:
+// the GITHUB_TOKEN or GH_TOKEN environment variable, the configured token, or
+// `git credential fill` for github.com. It is resolved once, on first use,
+// and registered for redaction.
+type Auth struct {
+	ConfigToken      string // github.token from the config file
+	CredentialHelper bool   // ask git's credential helper if nothing else is set
+
+	once   sync.Once
+	token  string
+	origin string
+}
+
+// Token returns the token and where it came from, or "" if there is none
+func (a *Auth) Token() (token, origin string) {
+	if a == nil {
+		return "", ""
+	}
+	a.once.Do(func() {
+		a.token, a.origin = a.resolve()
+		AddSecret(a.token)
+	})
+	return a.token, a.origin
+}
+
+func (a *Auth) resolve() (string, string) {
+	for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if token := strings.TrimSpace(os.Getenv(name)); token != "" {
+			return token, name
+		}
+	}
+	if token := strings.TrimSpace(a.ConfigToken); token != "" {
+		return token, "github.token in the config file"
+	}
+	if a.CredentialHelper {
+		if token := credentialHelperToken("github.com"); token != "" {
+			return token, "git credential helper"
+		}
+	}
+	return "", ""
+}
+
+// credentialHelperToken asks git's configured credential helpers for the
+// password stored for host, without ever prompting
+func credentialHelperToken(host string) string {
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=https\nhost=%s\n\n", host))
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if password, ok := strings.CutPrefix(line, "password="); ok {
+			return strings.TrimSpace(password)
+		}
+	}
+	return ""
+}
+
+// apply adds the token to a request for a GitHub host and reports whether it
+// did. Other hosts never see the token.
+func (a *Auth) apply(req *http.Request) bool {
+	if !IsGitHubHost(req.URL.Hostname()) {
+		return false
+	}
+	token, _ := a.Token()
+	if token == "" {
+		return false
+	}
+	req.Header.Set("Authorization", "token "+token)
+	return true
+}
+
+// IsGitHubHost reports whether host serves GitHub content or its API,
+// including the GITHUB_API_URL host
+func IsGitHubHost(host string) bool {
+	host = strings.ToLower(host)
+	switch host {
+	case "github.com", "api.github.com", "raw.githubusercontent.com", "codeload.github.com":
+		return true
+	}
+	if api, err := url.Parse(GitHubAPIURL()); err == nil && strings.EqualFold(api.Hostname(), host) {
+		return true
+	}
+	return false
+}
+
+// AuthError is returned when a host rejects a request for lack of (valid)
+// credentials
+type AuthError struct {
+	URL           string
+	Status        string
+	Authenticated bool   // a token was sent
+	Origin        string // where the token came from
+}
+
+func (e *AuthError) Error() string {
+	if e.Authenticated {
+		return fmt.Sprintf("authentication failed (%s): the token from %s was rejected or lacks access to this repository", e.Status, e.Origin)
+	}
+	return fmt.Sprintf("authentication required (%s): set GITHUB_TOKEN, github.token in .klcm.yaml, or a git credential helper for github.com", e.Status)
+}
+
+// checkAuth turns responses that mean "no access" into an AuthError. GitHub
+// answers 404 rather than 403 for private repositories the caller cannot see.
+func (a *Auth) checkAuth(req *http.Request, resp *http.Response, authenticated bool) error {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		if resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			return nil
+		}
+	case http.StatusNotFound:
+		if authenticated || !IsGitHubHost(req.URL.Hostname()) {
+			return nil
+		}
+	default:
+		return nil
+	}
+	_, origin := a.Token()
+	return &AuthError{URL: Redact(req.URL.String()), Status: resp.Status, Authenticated: authenticated, Origin: origin}
+}
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// AddSecret registers a value Redact must hide
+func AddSecret(secret string) {
+	if len(secret) < 4 {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, s := range secrets {
+		if s == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+}
+
+// Redact hides registered secrets and the passwords of URLs in s
+func Redact(s string) string {
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, "***")
+	}
+	secretsMu.RUnlock()
+	return redactURLPasswords(s)
+}
+
+// redactURLPasswords replaces the password in user:password@host URLs
+func redactURLPasswords(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+	fields := strings.Fields(s)
+	for _, field := range fields {
+		trimmed := strings.Trim(field, `"'()<>,;`)
+		u, err := url.Parse(trimmed)
+		if err != nil || u.User == nil {
+			continue
+		}
+		if _, ok := u.User.Password(); ok {
+			s = strings.ReplaceAll(s, trimmed, u.Redacted())
+		}
+	}
+	return s
+}
+
+// redactedError hides secrets in an error message while keeping the error
+// chain for errors.As
+type redactedError struct {
+	err error
+}
+
+// RedactError wraps err so its message goes through Redact
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{err: err}
+}
+
+func (e *redactedError) Error() string {
+	return Redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
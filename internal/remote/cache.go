@@ -0,0 +1,105 @@
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cache stores fetched remote content with the validators needed for
+// conditional requests
+type Cache struct {
+	Dir string
+}
+
+// CacheEntry is one cached remote file
+type CacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"` // last time the remote confirmed this content
+	Content      []byte    `json:"-"`
+}
+
+// DefaultCache returns the cache under $KLCM_CACHE_DIR, or klcm/remote in
+// the user cache directory
+func DefaultCache() (*Cache, error) {
+	if dir := os.Getenv("KLCM_CACHE_DIR"); dir != "" {
+		return &Cache{Dir: dir}, nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate user cache directory: %w", err)
+	}
+	return &Cache{Dir: filepath.Join(base, "klcm", "remote")}, nil
+}
+
+func (c *Cache) paths(url string) (meta, body string) {
+	sum := sha256.Sum256([]byte(url))
+	key := hex.EncodeToString(sum[:16])
+	return filepath.Join(c.Dir, key+".json"), filepath.Join(c.Dir, key+".body")
+}
+
+// Get returns the cached entry for a URL, or nil if there is none
+func (c *Cache) Get(url string) (*CacheEntry, error) {
+	metaPath, bodyPath := c.paths(url)
+	meta, err := os.ReadFile(metaPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry CacheEntry
+	if err := json.Unmarshal(meta, &entry); err != nil {
+		// A corrupt entry is as good as none
+		return nil, nil
+	}
+	entry.Content, err = os.ReadFile(bodyPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Put stores an entry, replacing any previous one
+func (c *Cache) Put(entry *CacheEntry) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	meta, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	metaPath, bodyPath := c.paths(entry.URL)
+	// Body first: a meta file always describes a complete body
+	if err := writeFileAtomic(bodyPath, entry.Content); err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, meta)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
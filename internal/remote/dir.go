@@ -0,0 +1,32 @@
+package remote
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DirSource reads a keymap from a plain directory, such as a mirror or a
+// checkout managed outside klcm
+type DirSource struct {
+	Dir  string
+	Path string // keymap path relative to Dir
+}
+
+// String returns the keymap's path
+func (s *DirSource) String() string {
+	return filepath.Join(s.Dir, filepath.FromSlash(s.Path))
+}
+
+// Fetch reads the keymap
+func (s *DirSource) Fetch(ctx context.Context) (*Content, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.String())
+	if err != nil {
+		return nil, err
+	}
+	return &Content{Data: data, AsOf: time.Now()}, nil
+}
@@ -0,0 +1,78 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GitSource reads a keymap from a local git repository at a ref, without
+// touching its work tree
+type GitSource struct {
+	Repo string // work tree or bare repository
+	Ref  string // branch, tag or commit
+	Path string // keymap path inside the repository
+}
+
+// String returns "repo@ref:path"
+func (s *GitSource) String() string {
+	return fmt.Sprintf("%s@%s:%s", s.Repo, s.Ref, s.Path)
+}
+
+// Fetch returns the keymap as of Ref, and the commit Ref points to
+func (s *GitSource) Fetch(ctx context.Context) (*Content, error) {
+	commit, err := s.Revision(ctx)
+	if err != nil {
+		return nil, err
+	}
+	output, err := s.git(ctx, "show", commit+":"+s.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &Content{Data: output, AsOf: time.Now(), Revision: commit}, nil
+}
+
+// RefName returns the branch, tag or commit the source reads
+func (s *GitSource) RefName() string {
+	return s.Ref
+}
+
+// Revision resolves Ref to a commit SHA
+func (s *GitSource) Revision(ctx context.Context) (string, error) {
+	output, err := s.git(ctx, "rev-parse", "--verify", "--end-of-options", s.Ref+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// At returns the source reading the same file at a commit
+func (s *GitSource) At(commit string) Source {
+	pinned := *s
+	pinned.Ref = commit
+	return &pinned
+}
+
+func (s *GitSource) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", s.Repo}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+	return output, nil
+}
+
+// RepositoryURL returns the absolute repository path, which git can clone
+// and push to
+func (s *GitSource) RepositoryURL() string {
+	if abs, err := filepath.Abs(s.Repo); err == nil {
+		return abs
+	}
+	return s.Repo
+}
@@ -0,0 +1,235 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+)
+
+// HTTPSource fetches a keymap from a URL, such as raw.githubusercontent.com.
+// With a cache it revalidates with If-None-Match/If-Modified-Since; offline it
+// serves the cached copy without touching the network. Requests to GitHub
+// hosts carry the Auth token, if any.
+type HTTPSource struct {
+	URL     string
+	Client  *http.Client
+	Cache   *Cache
+	Offline bool
+	Retry   RetryPolicy
+	Auth    *Auth
+
+	// GitHub repository and ref the URL reads from, when klcm can tell, and
+	// the URL with {commit} in place of the ref
+	Owner, Repo, Branch string
+	pinned              string
+}
+
+// DefaultClient is shared by HTTP sources so connections are reused. It
+// honors HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+var DefaultClient = newDefaultClient()
+
+func newDefaultClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}
+}
+
+// NewHTTPSource creates an HTTP source using DefaultClient
+func NewHTTPSource(url string, opts Options) *HTTPSource {
+	retry := opts.Retry
+	if retry.Attempts == 0 {
+		retry = DefaultRetryPolicy
+	}
+	return &HTTPSource{
+		URL:     url,
+		Client:  DefaultClient,
+		Cache:   opts.Cache,
+		Offline: opts.Offline,
+		Retry:   retry,
+		Auth:    opts.Auth,
+	}
+}
+
+// String returns the URL, without any password it contains
+func (s *HTTPSource) String() string {
+	return Redact(s.URL)
+}
+
+// get sends the (conditional) request, retrying transient failures
+func (s *HTTPSource) get(ctx context.Context, cached *CacheEntry) (*http.Response, error) {
+	attempts := s.Retry.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if cached != nil {
+			if cached.ETag != "" {
+				req.Header.Set("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.LastModified)
+			}
+		}
+		authenticated := s.Auth.apply(req)
+
+		resp, err := s.Client.Do(req)
+		if err == nil && !retryable(resp.StatusCode) {
+			if authErr := s.Auth.checkAuth(req, resp, authenticated); authErr != nil {
+				resp.Body.Close()
+				return nil, authErr
+			}
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			// An unknown host will not appear by retrying
+			return nil, err
+		}
+		if attempt >= attempts {
+			if err != nil {
+				return nil, fmt.Errorf("%w (after %d attempts)", err, attempt)
+			}
+			resp.Body.Close()
+			return nil, fmt.Errorf("HTTP %d: %s (after %d attempts)", resp.StatusCode, resp.Status, attempt)
+		}
+
+		delay := s.Retry.delay(attempt, resp)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Fetch downloads the keymap, or confirms the cached copy is still current.
+// Errors never contain the token.
+func (s *HTTPSource) Fetch(ctx context.Context) (*Content, error) {
+	content, err := s.fetch(ctx)
+	return content, RedactError(err)
+}
+
+func (s *HTTPSource) fetch(ctx context.Context) (*Content, error) {
+	var cached *CacheEntry
+	if s.Cache != nil {
+		var err error
+		if cached, err = s.Cache.Get(s.URL); err != nil {
+			return nil, fmt.Errorf("failed to read cache: %w", err)
+		}
+	}
+
+	if s.Offline {
+		if cached == nil {
+			return nil, fmt.Errorf("not in the offline cache; fetch it once without --offline")
+		}
+		return &Content{Data: cached.Content, AsOf: cached.FetchedAt, Cached: true}, nil
+	}
+
+	resp, err := s.get(ctx, cached)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	now := time.Now()
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		cached.FetchedAt = now
+		if err := s.Cache.Put(cached); err != nil {
+			return nil, fmt.Errorf("failed to update cache: %w", err)
+		}
+		return &Content{Data: cached.Content, AsOf: now, Cached: true}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if s.Cache != nil {
+		entry := &CacheEntry{
+			URL:          s.URL,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			FetchedAt:    now,
+			Content:      data,
+		}
+		if err := s.Cache.Put(entry); err != nil {
+			return nil, fmt.Errorf("failed to update cache: %w", err)
+		}
+	}
+	return &Content{Data: data, AsOf: now}, nil
+}
+
+// setGitHubRevision works out which GitHub repository and ref a URL reads
+// from: raw.githubusercontent.com/owner/repo/ref/path URLs, and GitHub
+// templates using {owner}, {repo} and {branch}
+func (s *HTTPSource) setGitHubRevision(spec string, kb registry.Keyboard) {
+	u, err := url.Parse(s.URL)
+	if err != nil || !IsGitHubHost(u.Hostname()) {
+		return
+	}
+	if strings.Contains(spec, "{owner}") && strings.Contains(spec, "{repo}") && strings.Contains(spec, "{branch}") {
+		s.Owner, s.Repo, s.Branch = kb.Owner, kb.Repo, kb.BaseBranch
+		kb.BaseBranch = "{commit}"
+		s.pinned = expandTemplate(spec, kb)
+		return
+	}
+	if u.Hostname() != "raw.githubusercontent.com" {
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 4)
+	if len(parts) < 4 || parts[2] == "refs" {
+		return
+	}
+	s.Owner, s.Repo, s.Branch = parts[0], parts[1], parts[2]
+	pinned := *u
+	pinned.Path = "/" + strings.Join([]string{parts[0], parts[1], "{commit}", parts[3]}, "/")
+	s.pinned = strings.Replace(pinned.String(), "%7Bcommit%7D", "{commit}", 1)
+}
+
+// RefName returns the branch or tag the URL reads from
+func (s *HTTPSource) RefName() string {
+	return s.Branch
+}
+
+// Revision asks the GitHub API which commit the ref points to
+func (s *HTTPSource) Revision(ctx context.Context) (string, error) {
+	if s.pinned == "" {
+		return "", fmt.Errorf("%s is not a GitHub URL klcm can pin to a commit", s)
+	}
+	if s.Offline {
+		return "", fmt.Errorf("cannot resolve %s of %s/%s offline", s.Branch, s.Owner, s.Repo)
+	}
+	return githubCommit(ctx, s.Client, s.Auth, s.Owner, s.Repo, s.Branch)
+}
+
+// At returns the source reading the same file at a commit
+func (s *HTTPSource) At(commit string) Source {
+	if s.pinned == "" {
+		return s
+	}
+	pinned := *s
+	pinned.URL = strings.Replace(s.pinned, "{commit}", commit, 1)
+	pinned.Branch = commit
+	pinned.pinned = ""
+	return &pinned
+}
@@ -0,0 +1,172 @@
+package remote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetry retries quickly enough for tests
+var fastRetry = RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+func TestHTTPSourceRevalidatesCache(t *testing.T) {
+	var requests, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("keymap v1\n"))
+	}))
+	defer server.Close()
+
+	cache := &Cache{Dir: t.TempDir()}
+	source := NewHTTPSource(server.URL+"/glove80.keymap", Options{Cache: cache, Retry: fastRetry})
+
+	first, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first.Data) != "keymap v1\n" || first.Cached {
+		t.Errorf("first fetch = %q (cached %v), want fresh content", first.Data, first.Cached)
+	}
+	entry, err := cache.Get(source.URL)
+	if err != nil || entry == nil || entry.ETag != `"v1"` {
+		t.Fatalf("cache entry = %+v, %v; want ETag \"v1\"", entry, err)
+	}
+
+	second, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(second.Data) != "keymap v1\n" || !second.Cached {
+		t.Errorf("second fetch = %q (cached %v), want the cached content", second.Data, second.Cached)
+	}
+	if requests != 2 || notModified != 1 {
+		t.Errorf("server saw %d requests, %d answered 304; want 2 and 1", requests, notModified)
+	}
+}
+
+func TestHTTPSourceOffline(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte("keymap\n"))
+	}))
+	defer server.Close()
+
+	cache := &Cache{Dir: t.TempDir()}
+	url := server.URL + "/glove80.keymap"
+
+	offline := NewHTTPSource(url, Options{Cache: cache, Offline: true})
+	if _, err := offline.Fetch(context.Background()); err == nil || !strings.Contains(err.Error(), "offline cache") {
+		t.Errorf("offline fetch of an uncached URL error = %v, want not in the offline cache", err)
+	}
+
+	if _, err := NewHTTPSource(url, Options{Cache: cache}).Fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	content, err := offline.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content.Data) != "keymap\n" || !content.Cached {
+		t.Errorf("offline fetch = %q (cached %v), want the cached content", content.Data, content.Cached)
+	}
+	if requests != 1 {
+		t.Errorf("server saw %d requests, want 1", requests)
+	}
+}
+
+func TestHTTPSourceRetries(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte("keymap\n"))
+		}
+	}))
+	defer server.Close()
+
+	content, err := NewHTTPSource(server.URL, Options{Retry: fastRetry}).Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content.Data) != "keymap\n" || requests != 3 {
+		t.Errorf("fetch = %q after %d requests, want the content after 3", content.Data, requests)
+	}
+}
+
+func TestHTTPSourceGivesUp(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := NewHTTPSource(server.URL, Options{Retry: fastRetry}).Fetch(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("error = %v, want HTTP 502 after 3 attempts", err)
+	}
+	if requests != 3 {
+		t.Errorf("server saw %d requests, want 3", requests)
+	}
+
+	requests = 0
+	notFound := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer notFound.Close()
+	if _, err := NewHTTPSource(notFound.URL, Options{Retry: fastRetry}).Fetch(context.Background()); err == nil {
+		t.Error("fetch of a missing file succeeded")
+	}
+	if requests != 1 {
+		t.Errorf("a 404 was requested %d times, want once", requests)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{Attempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	retryAfter := func(value string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{value}}}
+	}
+
+	tests := []struct {
+		name    string
+		attempt int
+		resp    *http.Response
+		want    time.Duration
+	}{
+		{name: "first retry", attempt: 1, want: 100 * time.Millisecond},
+		{name: "doubles", attempt: 3, want: 400 * time.Millisecond},
+		{name: "capped", attempt: 6, want: time.Second},
+		{name: "retry-after seconds", attempt: 1, resp: retryAfter("0"), want: 0},
+		{name: "retry-after capped", attempt: 1, resp: retryAfter("120"), want: time.Second},
+	}
+	for _, tt := range tests {
+		// Jitter adds up to 20%
+		for i := 0; i < 20; i++ {
+			got := policy.delay(tt.attempt, tt.resp)
+			if got < tt.want || got > tt.want+tt.want/5 {
+				t.Errorf("%s: delay = %s, want %s plus at most 20%%", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package remote
+
+import (
+	"context"
+	"sync"
+)
+
+// Result is the outcome of fetching one source
+type Result struct {
+	Content *Content
+	Err     error
+}
+
+// FetchAll fetches sources with at most workers fetches in flight. Results
+// are in source order; a failing source does not stop the others, but
+// cancelling ctx does.
+func FetchAll(ctx context.Context, sources []Source, workers int) []Result {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]Result, len(sources))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(sources); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+				results[i].Content, results[i].Err = sources[i].Fetch(ctx)
+			}
+		}()
+	}
+	for i := range sources {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,91 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubSource returns its name after a delay, or its error
+type stubSource struct {
+	name     string
+	delay    time.Duration
+	err      error
+	inFlight *int32
+	peak     *int32
+}
+
+func (s *stubSource) String() string { return s.name }
+
+func (s *stubSource) Fetch(ctx context.Context) (*Content, error) {
+	n := atomic.AddInt32(s.inFlight, 1)
+	defer atomic.AddInt32(s.inFlight, -1)
+	for {
+		peak := atomic.LoadInt32(s.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(s.peak, peak, n) {
+			break
+		}
+	}
+
+	time.Sleep(s.delay)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &Content{Data: []byte(s.name)}, nil
+}
+
+func TestFetchAllKeepsSourceOrder(t *testing.T) {
+	var inFlight, peak int32
+	failure := errors.New("unreachable")
+
+	var sources []Source
+	for i := 0; i < 8; i++ {
+		source := &stubSource{
+			name:     fmt.Sprintf("source-%d", i),
+			delay:    time.Duration(8-i) * 5 * time.Millisecond, // later sources finish first
+			inFlight: &inFlight,
+			peak:     &peak,
+		}
+		if i == 3 {
+			source.err = failure
+		}
+		sources = append(sources, source)
+	}
+
+	results := FetchAll(context.Background(), sources, 3)
+	if len(results) != len(sources) {
+		t.Fatalf("got %d results, want %d", len(results), len(sources))
+	}
+	for i, result := range results {
+		if i == 3 {
+			if result.Err != failure {
+				t.Errorf("result 3 error = %v, want %v", result.Err, failure)
+			}
+			continue
+		}
+		if result.Err != nil || string(result.Content.Data) != sources[i].String() {
+			t.Errorf("result %d = %+v, want %s", i, result, sources[i])
+		}
+	}
+	if peak > 3 {
+		t.Errorf("%d fetches ran at once, want at most 3", peak)
+	}
+}
+
+func TestFetchAllStopsWhenCancelled(t *testing.T) {
+	var inFlight, peak int32
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	sources := []Source{&stubSource{name: "a", inFlight: &inFlight, peak: &peak}}
+	results := FetchAll(ctx, sources, 2)
+	if !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", results[0].Err)
+	}
+	if peak != 0 {
+		t.Error("a source was fetched after the context was cancelled")
+	}
+}
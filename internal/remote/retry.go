@@ -0,0 +1,61 @@
+package remote
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy retries transient HTTP failures (network errors, 5xx and 429)
+// with exponential backoff
+type RetryPolicy struct {
+	Attempts  int           // total attempts, including the first
+	BaseDelay time.Duration // delay before the first retry, doubled after each
+	MaxDelay  time.Duration // upper bound for a single delay, including Retry-After
+}
+
+// DefaultRetryPolicy makes up to four attempts over roughly four seconds
+var DefaultRetryPolicy = RetryPolicy{Attempts: 4, BaseDelay: 500 * time.Millisecond, MaxDelay: 10 * time.Second}
+
+// retryable reports whether a response status is worth retrying
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// delay returns how long to wait before retry number attempt (1-based),
+// preferring the server's Retry-After when it gives one
+func (p RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			d = time.Duration(seconds) * time.Second
+		} else if at, err := http.ParseTime(resp.Header.Get("Retry-After")); err == nil {
+			d = time.Until(at)
+		}
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d < 0 {
+		d = 0
+	}
+	// Up to 20% jitter so concurrent fetches do not retry in lockstep
+	if d > 0 {
+		d += time.Duration(rand.Int63n(int64(d)/5 + 1))
+	}
+	return d
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
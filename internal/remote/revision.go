@@ -0,0 +1,130 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Revisioned is implemented by sources that read from a branch or tag of a
+// repository, so the commit behind a fetch can be recorded and fetched again
+type Revisioned interface {
+	Source
+	// RefName is the branch, tag or commit the source follows
+	RefName() string
+	// Revision resolves RefName to a commit SHA
+	Revision(ctx context.Context) (string, error)
+	// At returns the same source reading from a fixed commit
+	At(commit string) Source
+}
+
+// Pin returns a source that reads at commit, or at the commit RefName currently
+// points to when commit is "", and reports it in Content.Revision. Sources
+// without revisions are returned as they are.
+func Pin(source Source, commit string) Source {
+	revisioned, ok := source.(Revisioned)
+	if !ok {
+		return source
+	}
+	return &pinnedSource{source: revisioned, commit: commit}
+}
+
+type pinnedSource struct {
+	source Revisioned
+	commit string
+}
+
+func (p *pinnedSource) String() string {
+	if p.commit == "" {
+		return p.source.String()
+	}
+	return p.source.At(p.commit).String()
+}
+
+// Fetch resolves the commit first, so the content and the revision recorded
+// for it cannot disagree if the branch moves in between. If the commit
+// cannot be resolved, the branch is read as usual and Content.Unpinned says
+// why Revision is empty.
+func (p *pinnedSource) Fetch(ctx context.Context) (*Content, error) {
+	commit := p.commit
+	if commit == "" {
+		resolved, err := p.source.Revision(ctx)
+		if err != nil {
+			content, fetchErr := p.source.Fetch(ctx)
+			if fetchErr != nil {
+				return nil, fetchErr
+			}
+			content.Unpinned = err
+			return content, nil
+		}
+		commit = resolved
+	}
+	content, err := p.source.At(commit).Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content.Revision = commit
+	return content, nil
+}
+
+// RefName returns the ref of the underlying source
+func (p *pinnedSource) RefName() string {
+	return p.source.RefName()
+}
+
+// GitHubAPIURL is the GitHub REST API base URL; GITHUB_API_URL overrides it
+// for GitHub Enterprise
+func GitHubAPIURL() string {
+	if base := strings.TrimSuffix(os.Getenv("GITHUB_API_URL"), "/"); base != "" {
+		return base
+	}
+	return "https://api.github.com"
+}
+
+// githubCommit resolves a ref of a GitHub repository to a commit SHA
+func githubCommit(ctx context.Context, client *http.Client, auth *Auth, owner, repo, ref string) (string, error) {
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/commits/%s", GitHubAPIURL(), url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(ref))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.sha")
+	authenticated := auth.apply(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", RedactError(err)
+	}
+	defer resp.Body.Close()
+	if authErr := auth.checkAuth(req, resp, authenticated); authErr != nil {
+		return "", authErr
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolving %s of %s/%s: HTTP %s", ref, owner, repo, resp.Status)
+	}
+	commit := strings.TrimSpace(string(body))
+	if !isCommitSHA(commit) {
+		return "", fmt.Errorf("resolving %s of %s/%s: unexpected response %q", ref, owner, repo, commit)
+	}
+	return commit, nil
+}
+
+func isCommitSHA(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// unresolvable follows a branch whose commit cannot be looked up, like a
+// GitHub raw URL when the API is rate limited
+type unresolvable struct{ DirSource }
+
+func (s *unresolvable) RefName() string { return "main" }
+
+func (s *unresolvable) Revision(ctx context.Context) (string, error) {
+	return "", errors.New("rate limited")
+}
+
+func (s *unresolvable) At(commit string) Source { return s }
+
+func TestPin(t *testing.T) {
+	repo := newGitRepo(t)
+	ctx := context.Background()
+	source := &GitSource{Repo: repo, Ref: "main", Path: testKeyboard.UpstreamPath}
+	head, err := source.Revision(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1, err := (&GitSource{Repo: repo, Ref: "v1"}).Revision(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		commit   string
+		want     string
+		revision string
+	}{
+		{name: "branch head", want: "v2\n", revision: head},
+		{name: "locked commit", commit: v1, want: "v1\n", revision: v1},
+	}
+	for _, tt := range tests {
+		pinned := Pin(source, tt.commit)
+		content, err := pinned.Fetch(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if string(content.Data) != tt.want || content.Revision != tt.revision {
+			t.Errorf("%s: Fetch = %q at %s, want %q at %s", tt.name, content.Data, content.Revision, tt.want, tt.revision)
+		}
+		if ref := pinned.(interface{ RefName() string }).RefName(); ref != "main" {
+			t.Errorf("%s: RefName = %q, want the branch followed", tt.name, ref)
+		}
+	}
+	if got := Pin(source, v1).String(); !strings.Contains(got, "@"+v1+":") {
+		t.Errorf("String of a pinned source = %q, want it at %s", got, v1)
+	}
+	if got := Pin(source, "").String(); got != source.String() {
+		t.Errorf("String of an unpinned source = %q, want %q", got, source.String())
+	}
+}
+
+func TestPinWithoutRevisions(t *testing.T) {
+	dir := &DirSource{Dir: t.TempDir(), Path: "glove80.keymap"}
+	if got := Pin(dir, ""); got != Source(dir) {
+		t.Errorf("Pin(directory) = %v, want the directory source itself", got)
+	}
+}
+
+func TestPinUnresolvedRevision(t *testing.T) {
+	repo := newGitRepo(t)
+	source := &unresolvable{DirSource{Dir: repo, Path: testKeyboard.UpstreamPath}}
+
+	content, err := Pin(source, "").Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content.Data) != "v2\n" || content.Revision != "" {
+		t.Errorf("Fetch = %q at %q, want the branch's content without a revision", content.Data, content.Revision)
+	}
+	if content.Unpinned == nil || !strings.Contains(content.Unpinned.Error(), "rate limited") {
+		t.Errorf("Unpinned = %v, want why the commit is unknown", content.Unpinned)
+	}
+}
@@ -0,0 +1,134 @@
+// Package remote fetches keymaps from where they are maintained upstream:
+// raw HTTP URLs, local git repositories or plain directories.
+package remote
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+)
+
+// Source is where a keyboard's upstream keymap comes from
+type Source interface {
+	// String describes the source in messages, e.g. its URL
+	String() string
+	// Fetch returns the upstream keymap content
+	Fetch(ctx context.Context) (*Content, error)
+}
+
+// Content is a fetched keymap
+type Content struct {
+	Data     []byte
+	AsOf     time.Time // when the remote last confirmed this content
+	Cached   bool      // served from the local cache
+	Revision string    // commit the content was read at, if known
+	Unpinned error     // why Revision is empty, if resolving it failed
+}
+
+// Options configure the sources ForKeyboard and Parse create
+type Options struct {
+	Cache   *Cache      // cache for HTTP sources; nil disables caching
+	Offline bool        // serve HTTP sources from the cache only
+	Retry   RetryPolicy // zero value means DefaultRetryPolicy
+	Auth    *Auth       // token for GitHub hosts; nil sends no credentials
+}
+
+// Repository is implemented by sources backed by a git repository that pull
+// request branches can be pushed to
+type Repository interface {
+	RepositoryURL() string
+}
+
+// ForKeyboard returns the source of a keyboard: its "source" spec if set,
+// otherwise its raw URL
+func ForKeyboard(kb registry.Keyboard, opts Options) (Source, error) {
+	spec := kb.Source
+	if spec == "" {
+		spec = kb.RawURL
+	}
+	if spec == "" {
+		return nil, fmt.Errorf("no source or raw_url configured for %s", kb.Name)
+	}
+	return Parse(spec, kb, opts)
+}
+
+// Parse resolves a source spec for a keyboard:
+//
+//	https://host/{owner}/{repo}/{branch}/{path}   HTTP URL template
+//	file:///srv/zmk-config#v2, ../zmk-config      git repository, at a ref
+//	/srv/keymaps                                  plain directory
+//
+// URL templates may use {owner}, {repo}, {branch}, {path} and {keyboard}.
+// Git sources read the keyboard's upstream_path at the ref after '#', or at
+// its base branch; directories hold files at their upstream_path. Git and
+// directory sources are local, so they work offline without a cache.
+func Parse(spec string, kb registry.Keyboard, opts Options) (Source, error) {
+	if strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") {
+		source := NewHTTPSource(expandTemplate(spec, kb), opts)
+		source.setGitHubRevision(spec, kb)
+		return source, nil
+	}
+
+	location, ref := spec, ""
+	if i := strings.LastIndex(spec, "#"); i >= 0 {
+		location, ref = spec[:i], spec[i+1:]
+	}
+	location = strings.TrimPrefix(location, "file://")
+	if location == "" {
+		return nil, fmt.Errorf("invalid source %q for %s", spec, kb.Name)
+	}
+
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, fmt.Errorf("source for %s: %w", kb.Name, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("source for %s: %s is not a directory", kb.Name, location)
+	}
+	if kb.UpstreamPath == "" {
+		return nil, fmt.Errorf("source for %s: upstream_path is required", kb.Name)
+	}
+
+	if isGitRepository(location) {
+		if ref == "" {
+			ref = kb.BaseBranch
+		}
+		if ref == "" {
+			ref = "HEAD"
+		}
+		return &GitSource{Repo: location, Ref: ref, Path: kb.UpstreamPath}, nil
+	}
+	if ref != "" {
+		return nil, fmt.Errorf("source for %s: %s is not a git repository, so it cannot be read at %q", kb.Name, location, ref)
+	}
+	return &DirSource{Dir: location, Path: kb.UpstreamPath}, nil
+}
+
+func expandTemplate(template string, kb registry.Keyboard) string {
+	return strings.NewReplacer(
+		"{owner}", kb.Owner,
+		"{repo}", kb.Repo,
+		"{branch}", kb.BaseBranch,
+		"{path}", kb.UpstreamPath,
+		"{keyboard}", kb.Name,
+	).Replace(template)
+}
+
+// isGitRepository reports whether dir is the top of a work tree or a bare
+// repository; a plain directory inside a work tree does not count
+func isGitRepository(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return true
+	}
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,194 @@
+package remote
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"masters3d.com/keyboard_layout_config_mapper/internal/registry"
+)
+
+var testKeyboard = registry.Keyboard{
+	Name:         "glove80",
+	Owner:        "moergo-sc",
+	Repo:         "glove80-zmk-config",
+	BaseBranch:   "main",
+	UpstreamPath: "config/glove80.keymap",
+}
+
+func TestParseExpandsURLTemplates(t *testing.T) {
+	tests := []struct {
+		spec   string
+		url    string
+		pinned string
+	}{
+		{
+			spec:   "https://raw.githubusercontent.com/{owner}/{repo}/{branch}/{path}",
+			url:    "https://raw.githubusercontent.com/moergo-sc/glove80-zmk-config/main/config/glove80.keymap",
+			pinned: "https://raw.githubusercontent.com/moergo-sc/glove80-zmk-config/abc123/config/glove80.keymap",
+		},
+		{
+			spec:   "https://raw.githubusercontent.com/moergo-sc/glove80-zmk-config/v2/config/glove80.keymap",
+			url:    "https://raw.githubusercontent.com/moergo-sc/glove80-zmk-config/v2/config/glove80.keymap",
+			pinned: "https://raw.githubusercontent.com/moergo-sc/glove80-zmk-config/abc123/config/glove80.keymap",
+		},
+		{
+			spec:   "https://mirror.example.com/keymaps/{keyboard}/{branch}.keymap",
+			url:    "https://mirror.example.com/keymaps/glove80/main.keymap",
+			pinned: "https://mirror.example.com/keymaps/glove80/main.keymap",
+		},
+	}
+
+	for _, tt := range tests {
+		source, err := Parse(tt.spec, testKeyboard, Options{})
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.spec, err)
+		}
+		httpSource, ok := source.(*HTTPSource)
+		if !ok {
+			t.Fatalf("Parse(%q) = %T, want *HTTPSource", tt.spec, source)
+		}
+		if httpSource.URL != tt.url {
+			t.Errorf("Parse(%q).URL = %q, want %q", tt.spec, httpSource.URL, tt.url)
+		}
+		if pinned := httpSource.At("abc123").(*HTTPSource).URL; pinned != tt.pinned {
+			t.Errorf("Parse(%q).At(abc123).URL = %q, want %q", tt.spec, pinned, tt.pinned)
+		}
+	}
+}
+
+// newGitRepo creates a repository whose main branch has two commits of the
+// keymap, the first tagged v1
+func newGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		path := filepath.Join(dir, "config", "glove80.keymap")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q", "-b", "main")
+	write("v1\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1")
+	write("v2\n")
+	git("commit", "-q", "-am", "v2")
+	return dir
+}
+
+func TestParseGitRefs(t *testing.T) {
+	repo := newGitRepo(t)
+
+	tests := []struct {
+		spec string
+		ref  string
+		want string
+	}{
+		{spec: repo, ref: "main", want: "v2\n"},
+		{spec: "file://" + repo, ref: "main", want: "v2\n"},
+		{spec: repo + "#v1", ref: "v1", want: "v1\n"},
+		{spec: "file://" + repo + "#main~1", ref: "main~1", want: "v1\n"},
+	}
+
+	for _, tt := range tests {
+		source, err := Parse(tt.spec, testKeyboard, Options{})
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.spec, err)
+		}
+		gitSource, ok := source.(*GitSource)
+		if !ok {
+			t.Fatalf("Parse(%q) = %T, want *GitSource", tt.spec, source)
+		}
+		if gitSource.Ref != tt.ref || gitSource.Path != testKeyboard.UpstreamPath {
+			t.Errorf("Parse(%q) = %s, want ref %q", tt.spec, gitSource, tt.ref)
+		}
+
+		content, err := source.Fetch(context.Background())
+		if err != nil {
+			t.Fatalf("Fetch(%q): %v", tt.spec, err)
+		}
+		if string(content.Data) != tt.want {
+			t.Errorf("Fetch(%q) = %q, want %q", tt.spec, content.Data, tt.want)
+		}
+		if !isCommitSHA(content.Revision) {
+			t.Errorf("Fetch(%q).Revision = %q, want a commit SHA", tt.spec, content.Revision)
+		}
+	}
+
+	kb := testKeyboard
+	kb.BaseBranch = ""
+	source, err := Parse(repo, kb, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref := source.(*GitSource).Ref; ref != "HEAD" {
+		t.Errorf("ref without a base branch = %q, want HEAD", ref)
+	}
+
+	if _, err := Parse(repo+"#no-such-ref", testKeyboard, Options{}); err != nil {
+		t.Fatalf("Parse with an unknown ref: %v", err)
+	}
+	missing, _ := Parse(repo+"#no-such-ref", testKeyboard, Options{})
+	if _, err := missing.Fetch(context.Background()); err == nil {
+		t.Error("Fetch at an unknown ref succeeded")
+	}
+}
+
+func TestParseDirectories(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config", "glove80.keymap")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("keymap\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := Parse(dir, testKeyboard, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := source.(*DirSource); !ok {
+		t.Fatalf("Parse(dir) = %T, want *DirSource", source)
+	}
+	content, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content.Data) != "keymap\n" {
+		t.Errorf("Fetch() = %q, want %q", content.Data, "keymap\n")
+	}
+
+	if _, err := Parse(dir+"#v1", testKeyboard, Options{}); err == nil || !strings.Contains(err.Error(), "not a git repository") {
+		t.Errorf("Parse(dir#v1) error = %v, want not a git repository", err)
+	}
+	if _, err := Parse(filepath.Join(dir, "missing"), testKeyboard, Options{}); err == nil {
+		t.Error("Parse of a missing directory succeeded")
+	}
+	kb := testKeyboard
+	kb.UpstreamPath = ""
+	if _, err := Parse(dir, kb, Options{}); err == nil || !strings.Contains(err.Error(), "upstream_path") {
+		t.Errorf("Parse without upstream_path error = %v, want upstream_path is required", err)
+	}
+}